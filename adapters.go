@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// answerAdapter coerces an answer field that a model returned in a known
+// non-schema format into a grid; ok is false when raw is not in its format.
+type answerAdapter func(raw json.RawMessage) (grid [][]int, ok bool)
+
+// answerAdapters are the known answer formats by the name ai.answer_adapters
+// uses for them.
+var answerAdapters = map[string]answerAdapter{
+	// [["0","1"],["2","3"]]
+	"string_cells": adaptStringCells,
+	// ["0 1", "2 3"], ["0,1", "2,3"] or ["01", "23"]
+	"string_rows": adaptStringRows,
+	// "0 1\n2 3" or "[[0,1],[2,3]]"
+	"string_grid": adaptStringGrid,
+}
+
+// defaultAnswerAdapters are tried, in order, for models without an entry in
+// ai.answer_adapters.
+var defaultAnswerAdapters = []string{"string_cells", "string_rows", "string_grid"}
+
+// answerAdapterKey is the ai.answer_adapters entry for models not listed.
+const answerAdapterKey = "*"
+
+// checkAnswerAdapters rejects unknown adapter names in ai.answer_adapters.
+func checkAnswerAdapters(byModel map[string][]string) error {
+	for model, names := range byModel {
+		for _, name := range names {
+			if _, ok := answerAdapters[name]; !ok {
+				known := make([]string, 0, len(answerAdapters))
+				for k := range answerAdapters {
+					known = append(known, k)
+				}
+				sort.Strings(known)
+				return fmt.Errorf("ai.answer_adapters[%q]: unknown adapter %q (want one of %s)", model, name, strings.Join(known, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// adaptersFor returns the adapters to try on model's answers: its own
+// ai.answer_adapters entry, else the "*" entry, else all known formats. An
+// empty list turns adapting off.
+func (s *Solver) adaptersFor(model string) []answerAdapter {
+	names, ok := s.cfg.AnswerAdapters[model]
+	if !ok {
+		names, ok = s.cfg.AnswerAdapters[answerAdapterKey]
+	}
+	if !ok {
+		names = defaultAnswerAdapters
+	}
+	out := make([]answerAdapter, 0, len(names))
+	for _, name := range names {
+		out = append(out, answerAdapters[name])
+	}
+	return out
+}
+
+// adaptAnswer decodes content whose answer field is not an integer grid,
+// coercing the field with adapters. The other fields are kept.
+func adaptAnswer(content string, adapters []answerAdapter) (Answer, error) {
+	var loose struct {
+		Reasoning  string          `json:"reasoning"`
+		Answer     json.RawMessage `json:"answer"`
+		Confidence json.Number     `json:"confidence"`
+	}
+	if json.Unmarshal([]byte(content), &loose) != nil && json.Unmarshal([]byte(repairJSON(content)), &loose) != nil {
+		return Answer{}, errors.New("not a JSON object")
+	}
+	if len(loose.Answer) == 0 {
+		return Answer{}, errors.New("no answer field")
+	}
+	for _, adapt := range adapters {
+		grid, ok := adapt(loose.Answer)
+		if !ok {
+			continue
+		}
+		grid, err := normalizeGrid(grid)
+		if err != nil {
+			return Answer{}, err
+		}
+		conf, _ := loose.Confidence.Float64()
+		return Answer{Reasoning: loose.Reasoning, Answer: grid, Confidence: int(conf)}, nil
+	}
+	return Answer{}, errors.New("answer in an unknown format")
+}
+
+func adaptStringCells(raw json.RawMessage) ([][]int, bool) {
+	var rows [][]string
+	if json.Unmarshal(raw, &rows) != nil || len(rows) == 0 {
+		return nil, false
+	}
+	grid := make([][]int, len(rows))
+	for i, row := range rows {
+		grid[i] = make([]int, len(row))
+		for j, cell := range row {
+			v, ok := parseCell(cell)
+			if !ok {
+				return nil, false
+			}
+			grid[i][j] = v
+		}
+	}
+	return grid, true
+}
+
+func adaptStringRows(raw json.RawMessage) ([][]int, bool) {
+	var rows []string
+	if json.Unmarshal(raw, &rows) != nil || len(rows) == 0 {
+		return nil, false
+	}
+	return parseRows(rows)
+}
+
+func adaptStringGrid(raw json.RawMessage) ([][]int, bool) {
+	var text string
+	if json.Unmarshal(raw, &text) != nil {
+		return nil, false
+	}
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "[") {
+		// A JSON grid encoded as a string, possibly of one of the formats
+		// above.
+		var grid [][]int
+		if json.Unmarshal([]byte(text), &grid) == nil && len(grid) > 0 {
+			return grid, true
+		}
+		if grid, ok := adaptStringCells(json.RawMessage(text)); ok {
+			return grid, true
+		}
+		return adaptStringRows(json.RawMessage(text))
+	}
+	var rows []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			rows = append(rows, line)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, false
+	}
+	return parseRows(rows)
+}
+
+// parseRows parses rows of cells separated by spaces, commas or semicolons,
+// or of single-digit cells written without separators.
+func parseRows(rows []string) ([][]int, bool) {
+	grid := make([][]int, len(rows))
+	for i, row := range rows {
+		row = strings.Trim(strings.TrimSpace(row), "[]")
+		cells := strings.FieldsFunc(row, func(r rune) bool {
+			return unicode.IsSpace(r) || r == ',' || r == ';'
+		})
+		if len(cells) == 1 && len(cells[0]) > 1 {
+			cells = strings.Split(cells[0], "")
+		}
+		grid[i] = make([]int, len(cells))
+		for j, cell := range cells {
+			v, ok := parseCell(cell)
+			if !ok {
+				return nil, false
+			}
+			grid[i][j] = v
+		}
+	}
+	return grid, true
+}
+
+// parseCell parses one cell, a color from 0 to 9.
+func parseCell(s string) (int, bool) {
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || v < 0 || v > 9 {
+		return 0, false
+	}
+	return v, true
+}
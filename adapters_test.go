@@ -0,0 +1,174 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAdaptAnswer(t *testing.T) {
+	all := []answerAdapter{adaptStringCells, adaptStringRows, adaptStringGrid}
+	tests := []struct {
+		name     string
+		content  string
+		adapters []answerAdapter
+		want     [][]int
+		wantErr  bool
+	}{
+		{
+			name:     "string_cells",
+			content:  `{"reasoning": "r", "answer": [["0","1"],["2","3"]], "confidence": 80}`,
+			adapters: []answerAdapter{adaptStringCells},
+			want:     [][]int{{0, 1}, {2, 3}},
+		},
+		{
+			name:     "string_rows with spaces",
+			content:  `{"answer": ["0 1 2", "3 4 5"]}`,
+			adapters: []answerAdapter{adaptStringRows},
+			want:     [][]int{{0, 1, 2}, {3, 4, 5}},
+		},
+		{
+			name:     "string_rows with commas",
+			content:  `{"answer": ["0,1,2", "3, 4, 5"]}`,
+			adapters: []answerAdapter{adaptStringRows},
+			want:     [][]int{{0, 1, 2}, {3, 4, 5}},
+		},
+		{
+			name:     "string_rows without separators",
+			content:  `{"answer": ["012", "345"]}`,
+			adapters: []answerAdapter{adaptStringRows},
+			want:     [][]int{{0, 1, 2}, {3, 4, 5}},
+		},
+		{
+			name:     "string_grid of lines",
+			content:  `{"answer": "0 1\n2 3\n"}`,
+			adapters: []answerAdapter{adaptStringGrid},
+			want:     [][]int{{0, 1}, {2, 3}},
+		},
+		{
+			name:     "string_grid of a JSON grid",
+			content:  `{"answer": "[[0,1],[2,3]]"}`,
+			adapters: []answerAdapter{adaptStringGrid},
+			want:     [][]int{{0, 1}, {2, 3}},
+		},
+		{
+			name:     "string_grid of string rows",
+			content:  `{"answer": "[\"01\", \"23\"]"}`,
+			adapters: []answerAdapter{adaptStringGrid},
+			want:     [][]int{{0, 1}, {2, 3}},
+		},
+		{
+			name:     "adapters tried in order",
+			content:  `{"answer": "0 1\n2 3"}`,
+			adapters: all,
+			want:     [][]int{{0, 1}, {2, 3}},
+		},
+		{
+			name:     "repaired JSON",
+			content:  "```json\n{\"answer\": [\"0 1\", \"2 3\",]}\n```",
+			adapters: all,
+			want:     [][]int{{0, 1}, {2, 3}},
+		},
+		{
+			name:     "string_cells cell out of range",
+			content:  `{"answer": [["0","10"]]}`,
+			adapters: []answerAdapter{adaptStringCells},
+			wantErr:  true,
+		},
+		{
+			name:     "string_rows cell out of range",
+			content:  `{"answer": ["0 12"]}`,
+			adapters: []answerAdapter{adaptStringRows},
+			wantErr:  true,
+		},
+		{
+			name:     "string_grid negative cell",
+			content:  `{"answer": "0 -1\n2 3"}`,
+			adapters: []answerAdapter{adaptStringGrid},
+			wantErr:  true,
+		},
+		{
+			name:     "format of another adapter",
+			content:  `{"answer": "0 1\n2 3"}`,
+			adapters: []answerAdapter{adaptStringCells, adaptStringRows},
+			wantErr:  true,
+		},
+		{
+			name:     "no adapters",
+			content:  `{"answer": ["0 1", "2 3"]}`,
+			adapters: nil,
+			wantErr:  true,
+		},
+		{
+			name:     "no answer field",
+			content:  `{"reasoning": "r"}`,
+			adapters: all,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := adaptAnswer(tt.content, tt.adapters)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("adaptAnswer = %v, want an error", got.Answer)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("adaptAnswer: %v", err)
+			}
+			if !reflect.DeepEqual(got.Answer, tt.want) {
+				t.Errorf("adaptAnswer = %v, want %v", got.Answer, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptAnswerKeepsFields(t *testing.T) {
+	got, err := adaptAnswer(`{"reasoning": "swap", "answer": ["01"], "confidence": 72.6}`, []answerAdapter{adaptStringRows})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Reasoning != "swap" || got.Confidence != 72 {
+		t.Errorf("adaptAnswer = %+v, want reasoning %q and confidence 72", got, "swap")
+	}
+}
+
+func TestAdaptersFor(t *testing.T) {
+	content := `{"answer": ["0 1", "2 3"]}`
+	tests := []struct {
+		name    string
+		byModel map[string][]string
+		model   string
+		wantN   int
+		wantErr bool
+	}{
+		{"defaults", nil, "m", len(defaultAnswerAdapters), false},
+		{"own entry", map[string][]string{"m": {"string_rows"}}, "m", 1, false},
+		{"catch-all entry", map[string][]string{"*": {"string_rows"}}, "m", 1, false},
+		{"own entry wins", map[string][]string{"m": {"string_cells"}, "*": {"string_rows"}}, "m", 1, true},
+		{"empty list turns adapting off", map[string][]string{"m": {}}, "m", 0, true},
+		{"empty catch-all turns adapting off", map[string][]string{"*": {}}, "m", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Solver{cfg: aiConfig{AnswerAdapters: tt.byModel}}
+			adapters := s.adaptersFor(tt.model)
+			if len(adapters) != tt.wantN {
+				t.Fatalf("adaptersFor = %d adapters, want %d", len(adapters), tt.wantN)
+			}
+			if _, err := adaptAnswer(content, adapters); (err != nil) != tt.wantErr {
+				t.Errorf("adaptAnswer error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckAnswerAdapters(t *testing.T) {
+	if err := checkAnswerAdapters(map[string][]string{"*": {"string_rows", "string_grid"}}); err != nil {
+		t.Errorf("known adapters rejected: %v", err)
+	}
+	if err := checkAnswerAdapters(map[string][]string{"m": {"string_rows", "csv"}}); err == nil {
+		t.Error("unknown adapter accepted")
+	}
+}
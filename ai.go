@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/openai/openai-go/v3"
@@ -15,6 +18,75 @@ import (
 	"github.com/openai/openai-go/v3/shared"
 )
 
+// ai.prompt_cache modes.
+const (
+	promptCacheOpenAI    = "openai"
+	promptCacheAnthropic = "anthropic"
+)
+
+// Structured-output pathways of the OpenAI-compatible client
+// (ai.structured_output), from the strictest down.
+const (
+	// structuredSchema sends the answer schema as a json_schema response format.
+	structuredSchema = "schema"
+	// structuredTool forces a function call whose parameters are the answer
+	// schema and reads the call's arguments.
+	structuredTool = "tool"
+	// structuredJSON asks for a plain JSON object, repaired and salvaged when
+	// parsed.
+	structuredJSON = "json"
+	// structuredAuto starts with structuredSchema and probes down.
+	structuredAuto = "auto"
+)
+
+var structuredFormats = []string{structuredSchema, structuredTool, structuredJSON}
+
+// presolverModel is the model recorded for answers of the deterministic
+// pre-solver.
+const presolverModel = "presolver"
+
+// ai.provider values.
+const (
+	providerOpenAI    = "openai"
+	providerAnthropic = "anthropic"
+	providerGemini    = "gemini"
+	providerOllama    = "ollama"
+)
+
+// defaultOllamaBaseURL is the OpenAI-compatible endpoint of a local Ollama
+// server.
+const defaultOllamaBaseURL = "http://localhost:11434/v1"
+
+// aiBackend is a provider with its own API. It sends one structured-output
+// request and returns the JSON content and token usage; the OpenAI-compatible
+// path is built into Solver.complete.
+type aiBackend interface {
+	complete(ctx context.Context, model string, prompt chatPrompt, schemaName, schemaDesc string, schema map[string]any) (string, tokenUsage, error)
+}
+
+// defaultModel is the model used when ai.model is unset. Local models vary
+// too much for a default, so ollama has none.
+func defaultModel(provider string) string {
+	switch provider {
+	case providerGemini:
+		return defaultGeminiModel
+	case providerOllama:
+		return ""
+	}
+	return defaultAIModel
+}
+
+// apiKeyEnv is the environment variable read when ai.api_key is unset.
+func apiKeyEnv(provider string) string {
+	switch provider {
+	case providerAnthropic:
+		return "ANTHROPIC_API_KEY"
+	case providerGemini:
+		return "GEMINI_API_KEY"
+	}
+	return "OPENAI_API_KEY"
+}
+
 // ErrAIUnavailable indicates the AI service is not reachable or returned an error.
 var ErrAIUnavailable = errors.New("AI service unavailable")
 
@@ -42,8 +114,10 @@ type spinner struct {
 
 func newSpinner() *spinner {
 	isTTY := false
-	if fi, err := os.Stdout.Stat(); err == nil {
-		isTTY = (fi.Mode() & os.ModeCharDevice) != 0
+	if f, ok := uiOut.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil {
+			isTTY = (fi.Mode() & os.ModeCharDevice) != 0
+		}
 	}
 	return &spinner{
 		frames: []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
@@ -63,7 +137,7 @@ func (s *spinner) Start(msg string) {
 	s.mu.Unlock()
 
 	if !s.isTTY {
-		fmt.Printf("%s %s\n", colorCyan+"⋯"+colorReset, msg)
+		fmt.Fprintf(uiOut, "%s %s\n", colorCyan+"⋯"+colorReset, msg)
 		return
 	}
 
@@ -82,7 +156,7 @@ func (s *spinner) Start(msg string) {
 			case <-ticker.C:
 				s.mu.Lock()
 				elapsed := time.Since(s.start).Round(100 * time.Millisecond)
-				fmt.Printf("\r%s%s %s%s %s[%s]%s  ", colorCyan, s.frames[i%len(s.frames)], s.message, colorReset, colorDim, elapsed, colorReset)
+				fmt.Fprintf(uiOut, "\r%s%s %s%s %s[%s]%s  ", colorCyan, s.frames[i%len(s.frames)], s.message, colorReset, colorDim, elapsed, colorReset)
 				s.mu.Unlock()
 				i++
 			}
@@ -104,16 +178,46 @@ func (s *spinner) Stop() {
 	if s.isTTY && stopCh != nil {
 		close(stopCh)
 		<-doneCh
-		fmt.Print("\r\033[K")
+		fmt.Fprint(uiOut, "\r\033[K")
 	}
 }
 
-// Solver uses an OpenAI-compatible API to solve ARC puzzles.
+// Solver uses an OpenAI-compatible API, or a native provider API, to solve
+// ARC puzzles.
 type Solver struct {
 	client openai.Client
-	model  string
-	cfg    aiConfig
-	log    *logger
+	// backend, when ai.provider names a native API, replaces client.
+	backend aiBackend
+	model   string
+	cfg     aiConfig
+	log     *logger
+
+	// slots bounds concurrent AI requests (nil means unlimited).
+	slots chan struct{}
+	// usage, if set, receives the token usage of every request.
+	usage usageReporter
+	// latency, if set, records the duration of every request per model.
+	latency *latencyTracker
+	// breaker stops requests after repeated consecutive failures.
+	breaker *circuitBreaker
+	// format indexes structuredFormats: the structured-output pathway in use
+	// on the OpenAI-compatible path. Probing moves it down the list when a
+	// server rejects one.
+	format atomic.Int32
+	// probe is false when ai.structured_output fixes the pathway.
+	probe bool
+	// economy is set by the run while its AI budget runs low; Solve then
+	// cuts back as ai.economy says.
+	economy atomic.Bool
+	// history, if set, supplies per-model accuracy for weighted ensemble
+	// votes.
+	history *history
+	// repairs counts the answer grids fixed up locally: ragged rows padded,
+	// repair steps applied, sizes cropped or padded by one.
+	repairs atomic.Int64
+	// streamed, if set, receives the model's output as it arrives; a native
+	// backend, which does not stream, hands over its whole reply at once.
+	streamed func(string)
 }
 
 // Answer represents the structured response from the AI solver.
@@ -123,13 +227,27 @@ type Answer struct {
 	Confidence int     `json:"confidence"`
 }
 
+// solveResult is the outcome of a solve, kept for reporting and history.
+type solveResult struct {
+	Answer     [][]int
+	Model      string
+	Confidence int
+	Reasoning  string
+	// Verified is nil when verification did not run (as for pre-solver
+	// answers) or errored.
+	Verified *bool
+	// Members holds the answer of each ensemble member by model, so the
+	// members can be scored once the answer is judged.
+	Members map[string][][]int
+}
+
 // VerifyResult represents the AI verification response.
 type VerifyResult struct {
 	Valid     bool   `json:"valid"`
 	Reasoning string `json:"reasoning"`
 }
 
-// JSON Schema for AI answer output.
+// JSON Schema for AI answer output. answerSchema narrows it per puzzle.
 var arcAnswerSchema = map[string]any{
 	"type": "object",
 	"properties": map[string]any{
@@ -156,6 +274,42 @@ var arcAnswerSchema = map[string]any{
 	"additionalProperties": false,
 }
 
+// answerSchema returns arcAnswerSchema with the answer grid pinned to the
+// hinted dimensions and cells limited to colors 0-9, so strict-schema
+// providers enforce the size while decoding. Without a size hint the generic
+// schema is returned.
+func answerSchema(p puzzle) map[string]any {
+	h, w := p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width
+	if h <= 0 || w <= 0 {
+		return arcAnswerSchema
+	}
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"reasoning": arcAnswerSchema["properties"].(map[string]any)["reasoning"],
+			"answer": map[string]any{
+				"type":        "array",
+				"description": fmt.Sprintf("Output grid: exactly %d rows of exactly %d integers", h, w),
+				"minItems":    h,
+				"maxItems":    h,
+				"items": map[string]any{
+					"type":     "array",
+					"minItems": w,
+					"maxItems": w,
+					"items": map[string]any{
+						"type":    "integer",
+						"minimum": 0,
+						"maximum": 9,
+					},
+				},
+			},
+			"confidence": arcAnswerSchema["properties"].(map[string]any)["confidence"],
+		},
+		"required":             arcAnswerSchema["required"],
+		"additionalProperties": false,
+	}
+}
+
 // JSON Schema for verification response.
 var verifySchema = map[string]any{
 	"type": "object",
@@ -178,17 +332,30 @@ func newAISolver(ctx context.Context, cfg appConfig, log *logger) (*Solver, erro
 		return nil, nil
 	}
 
+	switch cfg.AI.Provider {
+	case "", providerOpenAI, providerAnthropic, providerGemini, providerOllama:
+	default:
+		return nil, fmt.Errorf("unknown ai.provider %q (want %q, %q, %q or %q)", cfg.AI.Provider, providerOpenAI, providerAnthropic, providerGemini, providerOllama)
+	}
+	keyEnv := apiKeyEnv(cfg.AI.Provider)
 	apiKey := strings.TrimSpace(cfg.AI.APIKey)
 	if apiKey == "" {
-		apiKey = strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
+		apiKey = strings.TrimSpace(os.Getenv(keyEnv))
+	}
+	if apiKey == "" && cfg.AI.Provider == providerOllama {
+		// Ollama ignores the key, but the client requires one.
+		apiKey = providerOllama
 	}
 	if apiKey == "" {
-		return nil, errors.New("missing API key (set ai.api_key in config or OPENAI_API_KEY env)")
+		return nil, fmt.Errorf("missing API key (set ai.api_key in config or %s env)", keyEnv)
 	}
 
 	modelName := strings.TrimSpace(cfg.AI.Model)
 	if modelName == "" {
-		modelName = defaultAIModel
+		modelName = defaultModel(cfg.AI.Provider)
+	}
+	if modelName == "" {
+		return nil, fmt.Errorf("ai.model is required for ai.provider %q", cfg.AI.Provider)
 	}
 
 	opts := []option.RequestOption{
@@ -199,10 +366,113 @@ func newAISolver(ctx context.Context, cfg appConfig, log *logger) (*Solver, erro
 	if baseURL := strings.TrimSpace(cfg.AI.BaseURL); baseURL != "" {
 		opts = append(opts, option.WithBaseURL(baseURL))
 		log.infof("AI using custom endpoint: %s", baseURL)
+	} else if cfg.AI.Provider == providerOllama {
+		opts = append(opts, option.WithBaseURL(defaultOllamaBaseURL))
 	}
 
-	client := openai.NewClient(opts...)
-	return &Solver{client: client, model: modelName, cfg: cfg.AI, log: log}, nil
+	proxy := cfg.Proxy
+	if p := strings.TrimSpace(cfg.AI.Proxy); p != "" {
+		proxy = p
+	}
+	tr, err := proxiedTransport(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("ai: %w", err)
+	}
+	opts = append(opts, option.WithHTTPClient(&http.Client{Transport: tr}))
+	if p := strings.TrimSpace(proxy); p != "" && p != proxyDirect {
+		log.infof("AI using proxy: %s", redactProxy(p))
+	}
+
+	switch cfg.AI.Mode {
+	case "", aiModeText, aiModeVision:
+	default:
+		return nil, fmt.Errorf("unknown ai.mode %q (want %q or %q)", cfg.AI.Mode, aiModeText, aiModeVision)
+	}
+
+	if err := checkAnswerAdapters(cfg.AI.AnswerAdapters); err != nil {
+		return nil, err
+	}
+
+	switch q, n := cfg.AI.VerifyQuorum, len(cfg.AI.Verifiers); {
+	case q != 0 && n == 0:
+		return nil, errors.New("ai.verify_quorum requires ai.verifiers")
+	case q < 0 || q > n:
+		return nil, fmt.Errorf("ai.verify_quorum %d out of range (want 1 to %d, the number of ai.verifiers)", q, n)
+	}
+
+	if mc := cfg.AI.MinConfidence; mc < 0 || mc > 100 {
+		return nil, fmt.Errorf("ai.min_confidence %d out of range (want 0 to 100)", mc)
+	}
+
+	if b := cfg.AI.Economy.Below; b < 0 || b >= 1 {
+		return nil, fmt.Errorf("ai.economy.below %v out of range (want 0 to 1, a share of the budget)", b)
+	}
+
+	if cfg.AI.RepairDimensions && cfg.AI.StrictDimensions != nil && !*cfg.AI.StrictDimensions {
+		log.warn("ai.repair_dimensions has no effect with ai.strict_dimensions off")
+	}
+
+	switch cfg.AI.GridEncoding {
+	case "", gridEncodingJSON, gridEncodingDense, gridEncodingRLE, gridEncodingChars:
+	default:
+		return nil, fmt.Errorf("unknown ai.grid_encoding %q (want %q, %q, %q or %q)", cfg.AI.GridEncoding, gridEncodingJSON, gridEncodingDense, gridEncodingRLE, gridEncodingChars)
+	}
+
+	switch cfg.AI.RaggedRows {
+	case "", raggedPad, raggedReject:
+	default:
+		return nil, fmt.Errorf("unknown ai.ragged_rows %q (want %q or %q)", cfg.AI.RaggedRows, raggedPad, raggedReject)
+	}
+
+	switch cfg.AI.Solver {
+	case "", solverGrid, solverProgram:
+	default:
+		return nil, fmt.Errorf("unknown ai.solver %q (want %q or %q)", cfg.AI.Solver, solverGrid, solverProgram)
+	}
+
+	switch cfg.AI.PromptCache {
+	case "", promptCacheOpenAI, promptCacheAnthropic:
+	default:
+		return nil, fmt.Errorf("unknown ai.prompt_cache %q (want %q or %q)", cfg.AI.PromptCache, promptCacheOpenAI, promptCacheAnthropic)
+	}
+
+	s := &Solver{model: modelName, cfg: cfg.AI, log: log, breaker: newCircuitBreaker(cfg.AI.CircuitBreaker)}
+	switch so := cfg.AI.StructuredOutput; so {
+	case "", structuredAuto:
+		s.probe = true
+	case structuredSchema, structuredTool, structuredJSON:
+		s.format.Store(int32(slices.Index(structuredFormats, so)))
+		if cfg.AI.Provider == providerAnthropic || cfg.AI.Provider == providerGemini {
+			log.warnf("ai.structured_output has no effect with ai.provider %q", cfg.AI.Provider)
+		}
+	default:
+		return nil, fmt.Errorf("unknown ai.structured_output %q (want %q, %q, %q or %q)", so, structuredAuto, structuredSchema, structuredTool, structuredJSON)
+	}
+	baseURL := strings.TrimSpace(cfg.AI.BaseURL)
+	switch cfg.AI.Provider {
+	case providerAnthropic:
+		if baseURL == "" {
+			baseURL = defaultAnthropicBaseURL
+		}
+		s.backend = &anthropicClient{baseURL: baseURL, apiKey: apiKey, http: &http.Client{Transport: tr}, cache: cfg.AI.PromptCache == promptCacheAnthropic}
+		if cfg.AI.PromptCache == promptCacheOpenAI {
+			log.warn("ai.prompt_cache \"openai\" has no effect with ai.provider \"anthropic\"")
+		}
+	case providerGemini:
+		if baseURL == "" {
+			baseURL = defaultGeminiBaseURL
+		}
+		s.backend = &geminiClient{baseURL: baseURL, apiKey: apiKey, http: &http.Client{Transport: tr}}
+		if cfg.AI.PromptCache != "" {
+			log.warnf("ai.prompt_cache %q has no effect with ai.provider \"gemini\" (Gemini caches prompt prefixes implicitly)", cfg.AI.PromptCache)
+		}
+	default:
+		s.client = openai.NewClient(opts...)
+	}
+	if n := cfg.AI.MaxConcurrentRequests; n > 0 {
+		s.slots = make(chan struct{}, n)
+	}
+	return s, nil
 }
 
 const systemPrompt = `You are an expert ARC (Abstraction and Reasoning Corpus) puzzle solver.
@@ -236,178 +506,715 @@ const systemPrompt = `You are an expert ARC (Abstraction and Reasoning Corpus) p
 - Count your rows and columns before outputting to verify dimensions
 - confidence: 0-100, only >= 90 if you're certain about the pattern`
 
-// Solve attempts to solve the given puzzle using AI.
-func (s *Solver) Solve(ctx context.Context, p puzzle) ([][]int, error) {
-	puzzleJSON, err := json.MarshalIndent(p, "", "  ")
+// Solve attempts to solve the given puzzle using AI. With more than one
+// model configured in ai.models the models are queried concurrently and the
+// answers combined by vote (see solveEnsemble).
+func (s *Solver) Solve(ctx context.Context, p puzzle) (solveResult, error) {
+	log := s.log.forContext(ctx)
+	models := s.models()
+
+	if s.cfg.Presolve == nil || *s.cfg.Presolve {
+		if grid, rule, ok := presolve(p); ok {
+			log.infof("pre-solver: %s explains every training pair, skipping the AI", rule)
+			fmt.Fprintf(uiOut, "%s🧩 Solved locally: %s%s\n", colorGreen, rule, colorReset)
+			return solveResult{Answer: grid, Model: presolverModel, Confidence: 100, Reasoning: "Deterministic rule: " + rule}, nil
+		}
+	}
+	if s.cfg.Solver == solverProgram {
+		return s.solveProgram(ctx, p)
+	}
+
+	samples, verify, selfTest, consistency := s.cfg.Samples, true, s.cfg.SelfTest, s.cfg.ConsistencyCheck
+	if eco := s.cfg.Economy; s.economy.Load() {
+		if eco.SingleModel {
+			models = []string{s.model}
+		}
+		if eco.Samples > 0 {
+			samples = min(samples, eco.Samples)
+		}
+		if eco.SkipVerify {
+			verify, selfTest, consistency = false, false, false
+		}
+	}
+
+	fmt.Fprintln(uiOut)
+	fmt.Fprintf(uiOut, "%s┌─────────────────────────────────────────┐%s\n", colorCyan, colorReset)
+	fmt.Fprintf(uiOut, "%s│      🤖 AI Agent Starting                │%s\n", colorCyan, colorReset)
+	if len(models) > 1 {
+		fmt.Fprintf(uiOut, "%s│      📦 Ensemble: %-2d models             │%s\n", colorCyan, len(models), colorReset)
+	} else if samples > 1 {
+		fmt.Fprintf(uiOut, "%s│      📦 Best of %-2d: %-20s│%s\n", colorCyan, samples, s.model, colorReset)
+	} else {
+		fmt.Fprintf(uiOut, "%s│      📦 Model: %-24s│%s\n", colorCyan, s.model, colorReset)
+	}
+	fmt.Fprintf(uiOut, "%s└─────────────────────────────────────────┘%s\n", colorCyan, colorReset)
+	fmt.Fprintln(uiOut)
+
+	var (
+		answer   Answer
+		verifier = s.model
+		model    string
+		members  map[string][][]int
+		err      error
+	)
+	if len(models) == 1 && samples > 1 {
+		res, err := s.solveSampled(ctx, p, samples)
+		if err == nil && selfTest {
+			err = s.selfTest(ctx, p, s.model, res.Reasoning)
+		}
+		if err == nil && consistency {
+			err = s.consistencyCheck(ctx, p, s.model, res.Answer)
+		}
+		return res, err
+	}
+	if len(models) > 1 {
+		answer, members, err = s.solveEnsemble(ctx, p, models)
+		if err != nil {
+			return solveResult{}, err
+		}
+		model = "ensemble(" + strings.Join(models, ",") + ")"
+	} else {
+		answer, verifier, err = s.solveWithFallback(ctx, p)
+		if err != nil {
+			return solveResult{}, err
+		}
+		model = verifier
+		printAnswerDetails(answer)
+	}
+	answer, err = s.enforceSize(ctx, p, verifier, answer)
+	res := solveResult{Answer: answer.Answer, Model: model, Confidence: answer.Confidence, Reasoning: answer.Reasoning, Members: members}
 	if err != nil {
-		return nil, fmt.Errorf("marshal puzzle: %w", err)
+		return res, err
 	}
 
-	userQuery := fmt.Sprintf(`Solve this ARC puzzle:
+	if issues := crossCheck(p, answer.Answer); len(issues) > 0 {
+		answer = s.refine(ctx, p, verifier, answer, issues)
+		res.Answer, res.Confidence, res.Reasoning = answer.Answer, answer.Confidence, answer.Reasoning
+	}
 
-%s
+	if !verify {
+		fmt.Fprintf(uiOut, "%s⏭️  Self-verification skipped (economy mode)%s\n", colorDim, colorReset)
+		fmt.Fprintf(uiOut, "%s✨ Answer generated!%s\n", colorGreen, colorReset)
+		return res, nil
+	}
 
-IMPORTANT: Expected answer dimensions are EXACTLY %d rows × %d columns.
-Your answer array MUST have exactly %d rows, and EACH row MUST have exactly %d elements.
-Double-check your dimensions before responding!`, string(puzzleJSON), p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width, p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width)
+	spin2 := newSpinner()
+	spin2.Start("🔄 AI self-verifying...")
 
-	fmt.Println()
-	fmt.Printf("%s┌─────────────────────────────────────────┐%s\n", colorCyan, colorReset)
-	fmt.Printf("%s│      🤖 AI Agent Starting                │%s\n", colorCyan, colorReset)
-	fmt.Printf("%s│      📦 Model: %-24s│%s\n", colorCyan, s.model, colorReset)
-	fmt.Printf("%s└─────────────────────────────────────────┘%s\n", colorCyan, colorReset)
-	fmt.Println()
+	vr, verifyErr := s.verifyAnswer(ctx, p, answer.Answer, verifier)
+	spin2.Stop()
+	if verifyErr == nil && !vr.Valid {
+		answer, vr, verifyErr = s.correct(ctx, p, verifier, answer, vr)
+		res.Answer, res.Confidence, res.Reasoning = answer.Answer, answer.Confidence, answer.Reasoning
+	}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
 
-	messages := []openai.ChatCompletionMessageParamUnion{
-		openai.SystemMessage(systemPrompt),
-		openai.UserMessage(userQuery),
+	if verifyErr != nil {
+		log.warnf("verification error: %v", verifyErr)
+	} else {
+		res.Verified = &vr.Valid
+		if !vr.Valid {
+			return res, errors.New("AI self-verification failed: answer does not match pattern")
+		}
 	}
 
-	spin := newSpinner()
-	spin.Start("🔍 Analyzing puzzle...")
-
-	stream := s.client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
-		Model:    openai.ChatModel(s.model),
-		Messages: messages,
-		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
-			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
-				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
-					Name:        "arc_answer",
-					Description: openai.String("ARC puzzle answer with reasoning"),
-					Strict:      openai.Bool(true),
-					Schema:      arcAnswerSchema,
-				},
-			},
-		},
-	})
+	fmt.Fprintf(uiOut, "%s✅ AI self-verification passed!%s\n", colorGreen, colorReset)
+	if selfTest {
+		if err := s.selfTest(ctx, p, verifier, answer.Reasoning); err != nil {
+			return res, err
+		}
+	}
+	if consistency {
+		if err := s.consistencyCheck(ctx, p, verifier, res.Answer); err != nil {
+			return res, err
+		}
+	}
+	fmt.Fprintf(uiOut, "%s✨ Answer generated!%s\n", colorGreen, colorReset)
 
-	var contentBuilder strings.Builder
-	for stream.Next() {
-		chunk := stream.Current()
-		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-			contentBuilder.WriteString(chunk.Choices[0].Delta.Content)
+	return res, nil
+}
+
+// correct feeds the verifier's objection back to model and verifies the
+// corrected answer again, up to ai.max_refinements times or until one
+// passes. It returns the last answer with its verdict; corrections that fail
+// or contradict the size hint are skipped. An error means the last
+// verification itself failed.
+func (s *Solver) correct(ctx context.Context, p puzzle, model string, answer Answer, vr VerifyResult) (Answer, VerifyResult, error) {
+	log := s.log.forContext(ctx)
+	limit := s.cfg.MaxRefinements
+	for i := 1; i <= limit && !vr.Valid; i++ {
+		log.warnf("self-verification rejected the answer, refining (%d/%d)", i, limit)
+		spin := newSpinner()
+		spin.Start("🔁 Correcting answer...")
+		next, err := s.correctOnce(ctx, p, model, answer, vr.Reasoning)
+		spin.Stop()
+		if err == nil {
+			err = validateAnswerSize(p, next.Answer)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return answer, vr, nil
+			}
+			log.warnf("refinement %d discarded: %v", i, err)
+			continue
 		}
+		printAnswerDetails(next)
+
+		spin = newSpinner()
+		spin.Start("🔄 AI self-verifying...")
+		nvr, err := s.verifyAnswer(ctx, p, next.Answer, model)
+		spin.Stop()
+		if err != nil {
+			return next, VerifyResult{}, err
+		}
+		answer, vr = next, nvr
+	}
+	return answer, vr, nil
+}
+
+// correctOnce shows model its previous answer with the verifier's objection
+// and asks for a corrected one.
+func (s *Solver) correctOnce(ctx context.Context, p puzzle, model string, prev Answer, objection string) (Answer, error) {
+	prevJSON, err := json.Marshal(prev.Answer)
+	if err != nil {
+		return Answer{}, fmt.Errorf("marshal answer: %w", err)
 	}
+	if strings.TrimSpace(objection) == "" {
+		objection = "(no reason given)"
+	}
+
+	query := fmt.Sprintf(`A previous attempt at the ARC puzzle above answered:
+%s
+
+A reviewer checking it against the training pairs rejected it:
+%s
 
+Re-derive the transformation rule from the training pairs, address the reviewer's objection and answer again.
+The answer MUST be exactly %d rows × %d columns.`, string(prevJSON), objection, p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width)
+
+	return s.askAnswer(ctx, p, model, query)
+}
+
+// refine asks model once to reconsider an answer the cross-check flagged.
+// The refined answer is kept only if it fits the hinted size and draws fewer
+// flags; otherwise the original goes on to verification unchanged.
+func (s *Solver) refine(ctx context.Context, p puzzle, model string, answer Answer, issues []string) Answer {
+	log := s.log.forContext(ctx)
+	log.warnf("cross-check flagged the answer: %s", strings.Join(issues, "; "))
+
+	spin := newSpinner()
+	spin.Start("🔁 Refining flagged answer...")
+	refined, err := s.refineOnce(ctx, p, model, answer, issues)
 	spin.Stop()
+	if err != nil {
+		log.warnf("refinement failed, keeping the original answer: %v", err)
+		return answer
+	}
+	if err := validateAnswerSize(p, refined.Answer); err != nil {
+		log.warnf("refined answer rejected: %v", err)
+		return answer
+	}
+	left := crossCheck(p, refined.Answer)
+	if len(left) >= len(issues) {
+		log.warnf("refined answer still flagged (%d issues), keeping the original", len(left))
+		return answer
+	}
+	log.infof("refined answer accepted (%d of %d flags left)", len(left), len(issues))
+	printAnswerDetails(refined)
+	return refined
+}
 
-	if err := stream.Err(); err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrAIUnavailable, err)
+// models returns the models to query for a solve: ai.models when set,
+// otherwise just the primary model.
+func (s *Solver) models() []string {
+	var out []string
+	for _, m := range s.cfg.Models {
+		if m = strings.TrimSpace(m); m != "" {
+			out = append(out, m)
+		}
 	}
+	if len(out) == 0 {
+		return []string{s.model}
+	}
+	return out
+}
 
-	content := contentBuilder.String()
-	if content == "" {
-		return nil, errors.New("no content in response")
+// solveWithFallback tries the primary model and then each of
+// ai.fallback_models in order. A model is skipped when it is unavailable,
+// returns unparseable output, or returns a grid whose size contradicts the
+// hints. The last model's size-mismatched answer is still returned for the
+// caller's enforceSize, matching the behavior without fallbacks.
+func (s *Solver) solveWithFallback(ctx context.Context, p puzzle) (Answer, string, error) {
+	log := s.log.forContext(ctx)
+	chain := []string{s.model}
+	for _, m := range s.cfg.FallbackModels {
+		if m = strings.TrimSpace(m); m != "" && m != s.model {
+			chain = append(chain, m)
+		}
 	}
 
-	var answer Answer
-	if err := json.Unmarshal([]byte(content), &answer); err != nil {
-		grid, parseErr := parseAnswerGrid(content)
-		if parseErr != nil {
-			return nil, parseErr
+	var lastErr error
+	for i, model := range chain {
+		last := i == len(chain)-1
+		if i > 0 {
+			fmt.Fprintf(uiOut, "%s↪ Falling back to model: %s%s\n", colorYellow, model, colorReset)
+		}
+		spin := newSpinner()
+		spin.Start("🔍 Analyzing puzzle...")
+		answer, err := s.solveOnce(ctx, p, model)
+		spin.Stop()
+		if err != nil {
+			if !last {
+				log.warnf("model %s failed: %v", model, err)
+			}
+			lastErr = err
+			continue
+		}
+		if err := validateAnswerSize(p, answer.Answer); err != nil && !last {
+			log.warnf("model %s answer size mismatch: %v", model, err)
+			lastErr = err
+			continue
 		}
-		return grid, nil
+		return answer, model, nil
 	}
+	return Answer{}, "", lastErr
+}
 
+// printAnswerDetails prints the model's reasoning and confidence.
+func printAnswerDetails(answer Answer) {
 	if answer.Reasoning != "" {
-		fmt.Printf("%s💭 AI Reasoning:%s\n", colorYellow, colorReset)
-		fmt.Println(strings.Repeat("─", 50))
-		fmt.Printf("%s%s%s\n", colorBlue, answer.Reasoning, colorReset)
-		fmt.Println(strings.Repeat("─", 50))
+		fmt.Fprintf(uiOut, "%s💭 AI Reasoning:%s\n", colorYellow, colorReset)
+		fmt.Fprintln(uiOut, strings.Repeat("─", 50))
+		fmt.Fprintf(uiOut, "%s%s%s\n", colorBlue, answer.Reasoning, colorReset)
+		fmt.Fprintln(uiOut, strings.Repeat("─", 50))
 	}
+	fmt.Fprintf(uiOut, "%s📊 Confidence: %d%%%s\n", colorGreen, answer.Confidence, colorReset)
+}
 
-	fmt.Printf("%s📊 Confidence: %d%%%s\n", colorGreen, answer.Confidence, colorReset)
+// solveOnce asks a single model for an answer. Unavailability of the
+// endpoint is reported as ErrAIUnavailable.
+func (s *Solver) solveOnce(ctx context.Context, p puzzle, model string) (Answer, error) {
+	return s.askAnswer(ctx, p, model, solveQuery(p))
+}
 
-	if len(answer.Answer) == 0 {
-		return nil, errors.New("empty answer grid")
-	}
+// solveQuery is the request for a fresh answer to p.
+func solveQuery(p puzzle) string {
+	return fmt.Sprintf(`Solve the ARC puzzle above.
+
+IMPORTANT: Expected answer dimensions are EXACTLY %d rows × %d columns.
+Your answer array MUST have exactly %d rows, and EACH row MUST have exactly %d elements.
+Double-check your dimensions before responding!`, p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width, p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width)
+}
 
-	if err := validateAnswerSize(p, answer.Answer); err != nil {
-		s.log.warnf("answer size mismatch: %v", err)
+// refineOnce shows model its previous answer with the cross-check flags and
+// asks for a corrected one.
+func (s *Solver) refineOnce(ctx context.Context, p puzzle, model string, prev Answer, issues []string) (Answer, error) {
+	prevJSON, err := json.Marshal(prev.Answer)
+	if err != nil {
+		return Answer{}, fmt.Errorf("marshal answer: %w", err)
 	}
 
-	spin2 := newSpinner()
-	spin2.Start("🔄 AI self-verifying...")
+	query := fmt.Sprintf(`A previous attempt at the ARC puzzle above answered:
+%s
 
-	verified, verifyErr := s.verifyAnswer(ctx, p, answer.Answer)
-	spin2.Stop()
+Automatic checks against the training pairs flagged it:
+- %s
 
-	if verifyErr != nil {
-		s.log.warnf("verification error: %v", verifyErr)
-	} else if !verified {
-		return nil, errors.New("AI self-verification failed: answer does not match pattern")
-	}
+Re-derive the transformation rule from the training pairs and answer again. Keep the previous answer only if the rule really explains the flagged differences.
+The answer MUST be exactly %d rows × %d columns.`, string(prevJSON), strings.Join(issues, "\n- "), p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width)
 
-	fmt.Printf("%s✅ AI self-verification passed!%s\n", colorGreen, colorReset)
-	fmt.Printf("%s✨ Answer generated!%s\n", colorGreen, colorReset)
+	return s.askAnswer(ctx, p, model, query)
+}
 
-	return answer.Answer, nil
+// askAnswer sends query about p with the system prompt and parses the
+// answer.
+func (s *Solver) askAnswer(ctx context.Context, p puzzle, model, query string) (Answer, error) {
+	return s.askAnswerAt(ctx, p, model, query, 0)
 }
 
-func parseAnswerGrid(text string) ([][]int, error) {
-	var grid [][]int
-	if err := json.Unmarshal([]byte(text), &grid); err == nil {
-		return normalizeGrid(grid)
+// askAnswerAt is askAnswer sampling at temperature (0 keeps the provider's
+// default).
+func (s *Solver) askAnswerAt(ctx context.Context, p puzzle, model, query string, temperature float64) (Answer, error) {
+	prompt, err := s.puzzlePrompt(systemPrompt, p, query)
+	if err != nil {
+		return Answer{}, err
+	}
+	prompt.temperature = temperature
+	for retried := false; ; retried = true {
+		content, err := s.complete(ctx, model, prompt, "arc_answer", "ARC puzzle answer with reasoning", answerSchema(p))
+		if err != nil {
+			return Answer{}, fmt.Errorf("%w: %w", ErrAIUnavailable, err)
+		}
+		answer, err := s.answerFromReply(ctx, model, prompt, "ARC puzzle answer with reasoning", answerSchema(p), content)
+		if err != nil {
+			return Answer{}, err
+		}
+		if ragged(answer.Answer) {
+			// Ragged rows are padded with the background, or with
+			// ai.ragged_rows "reject" sent back once and then an error.
+			if s.cfg.RaggedRows != raggedReject {
+				g, n := padRagged(answer.Answer, p.Hints.BackgroundColor)
+				s.log.forContext(ctx).warnf("answer repair: padded %d short row(s) with background color %d", n, p.Hints.BackgroundColor)
+				s.repairs.Add(1)
+				answer.Answer = g
+			} else if retried {
+				return Answer{}, fmt.Errorf("ragged answer grid (ai.ragged_rows): row lengths %v", rowLengths(answer.Answer))
+			} else {
+				s.log.forContext(ctx).warnf("ragged answer grid from %s, asking again", model)
+				prompt.query = query + "\n\n" + raggedObjection(p, answer.Answer)
+				continue
+			}
+		}
+		answer.Answer = s.repairAnswer(ctx, p, answer.Answer)
+		return answer, nil
 	}
+}
 
-	start := strings.Index(text, "[[")
-	if start == -1 {
-		start = strings.Index(text, "[")
+// decodeAnswer decodes content as an answer object, repairing JSON defects
+// and adapting the answer formats model is configured for. Content that is
+// no such object fails with errNotAnswerObject.
+func (s *Solver) decodeAnswer(content, model string) (Answer, error) {
+	if content == "" {
+		return Answer{}, errors.New("no content in response")
 	}
-	if start == -1 {
-		return nil, errors.New("not valid json array")
+
+	var answer Answer
+	if err := json.Unmarshal([]byte(content), &answer); err != nil && json.Unmarshal([]byte(repairJSON(content)), &answer) != nil {
+		if adapted, err := adaptAnswer(content, s.adaptersFor(model)); err == nil {
+			return adapted, nil
+		}
+		return Answer{}, errNotAnswerObject
 	}
+	if len(answer.Answer) == 0 {
+		return Answer{}, errors.New("empty answer grid")
+	}
+	return answer, nil
+}
 
-	end := findMatchingBracket(text, start)
-	if end == -1 || end <= start {
-		return nil, errors.New("not valid json array")
+// acquire waits for a request slot when ai.max_concurrent_requests is set.
+func (s *Solver) acquire(ctx context.Context) (func(), error) {
+	if s.slots == nil {
+		return func() {}, nil
 	}
-	candidate := text[start : end+1]
-	if err := json.Unmarshal([]byte(candidate), &grid); err != nil {
-		return nil, fmt.Errorf("parse json array: %w", err)
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	return normalizeGrid(grid)
 }
 
-func findMatchingBracket(text string, start int) int {
-	depth := 0
-	for i := start; i < len(text); i++ {
-		switch text[i] {
-		case '[':
-			depth++
-		case ']':
-			depth--
-			if depth == 0 {
-				return i
+// chatPrompt is the input of one request. system, puzzle and images are the
+// static prefix repeated across requests; query is request specific and sent
+// last so the prefix can be served from the provider's prompt cache.
+type chatPrompt struct {
+	system   string
+	puzzle   string
+	images   []promptImage
+	query    string
+	cacheKey string
+	// temperature overrides the provider's default sampling temperature
+	// when non-zero.
+	temperature float64
+}
+
+// puzzlePrompt builds the prompt asking query about p under system, adding
+// the summaries ai.preprocess selects and rendered grid images in vision
+// mode, compacted to fit ai.max_context_tokens.
+func (s *Solver) puzzlePrompt(system string, p puzzle, query string) (chatPrompt, error) {
+	build := func(p puzzle, encoding string) (chatPrompt, error) {
+		block, err := puzzleBlock(p, encoding)
+		if err != nil {
+			return chatPrompt{}, err
+		}
+		if s.cfg.Preprocess.Objects {
+			block += "\n\n" + objectSummary(p)
+		}
+		if s.cfg.Preprocess.Symmetry {
+			block += "\n\n" + symmetrySummary(p)
+		}
+		if s.cfg.Preprocess.Diff {
+			block += "\n\n" + diffSummary(p)
+		}
+		prompt := chatPrompt{system: system, puzzle: block, query: query, cacheKey: p.ID}
+		if s.cfg.Mode == aiModeVision {
+			if prompt.images, err = puzzleImages(p); err != nil {
+				return chatPrompt{}, err
 			}
 		}
+		return prompt, nil
 	}
-	return -1
+	prompt, err := build(p, s.cfg.GridEncoding)
+	if err != nil {
+		return chatPrompt{}, err
+	}
+	return s.fitContext(p, prompt, build)
 }
 
-func normalizeGrid(grid [][]int) ([][]int, error) {
-	if len(grid) == 0 {
-		return nil, errors.New("empty grid")
+// messages lays out prompt for the configured ai.prompt_cache mode. With
+// "anthropic" the system prompt and the end of the puzzle prefix carry
+// cache_control breakpoints; otherwise plain messages rely on automatic
+// prefix caching. Images follow the puzzle text, each after its caption.
+func (s *Solver) messages(prompt chatPrompt) []openai.ChatCompletionMessageParamUnion {
+	cache := s.cfg.PromptCache == promptCacheAnthropic
+	if !cache && len(prompt.images) == 0 {
+		return []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(prompt.system),
+			openai.UserMessage(prompt.puzzle + "\n\n" + prompt.query),
+		}
 	}
+	ephemeral := map[string]any{"cache_control": map[string]string{"type": "ephemeral"}}
 
-	maxWidth := 0
-	for _, row := range grid {
-		if len(row) > maxWidth {
-			maxWidth = len(row)
+	parts := []openai.ChatCompletionContentPartUnionParam{openai.TextContentPart(prompt.puzzle)}
+	for _, img := range prompt.images {
+		parts = append(parts,
+			openai.TextContentPart(img.caption),
+			openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: img.dataURL}))
+	}
+	system := openai.SystemMessage(prompt.system)
+	if cache {
+		if last := parts[len(parts)-1]; last.OfImageURL != nil {
+			last.OfImageURL.SetExtraFields(ephemeral)
+		} else {
+			last.OfText.SetExtraFields(ephemeral)
 		}
+		sys := openai.ChatCompletionContentPartTextParam{Text: prompt.system}
+		sys.SetExtraFields(ephemeral)
+		system = openai.SystemMessage([]openai.ChatCompletionContentPartTextParam{sys})
 	}
-	if maxWidth == 0 {
-		return nil, errors.New("empty rows")
+	parts = append(parts, openai.TextContentPart(prompt.query))
+	return []openai.ChatCompletionMessageParamUnion{system, openai.UserMessage(parts)}
+}
+
+// complete streams a chat completion whose output is constrained to the given
+// JSON schema and returns the concatenated content. Native provider backends
+// enforce the schema their own way (a forced tool for Anthropic, a response
+// schema for Gemini) and return the same JSON content. Requests are refused
+// with errAICircuitOpen while the circuit breaker is open.
+func (s *Solver) complete(ctx context.Context, model string, prompt chatPrompt, schemaName, schemaDesc string, schema map[string]any) (content string, err error) {
+	if err := s.breaker.allow(); err != nil {
+		return "", err
+	}
+	release, err := s.acquire(ctx)
+	if err != nil {
+		return "", err
 	}
+	defer release()
+	defer s.latency.since("ai "+model, time.Now())
+	defer func() {
+		// A refused structured-output format says nothing about whether
+		// the endpoint is up.
+		if s.probe && s.rejectsFormat(err) {
+			return
+		}
+		if s.breaker.record(ctx, err) {
+			s.log.forContext(ctx).warnf("AI circuit open after %d consecutive failures; pausing AI requests until %s", s.breaker.consecutiveFailures(), s.breaker.openUntil().Format(time.TimeOnly))
+		}
+	}()
 
-	for i := range grid {
-		if len(grid[i]) < maxWidth {
-			padded := make([]int, maxWidth)
-			copy(padded, grid[i])
-			grid[i] = padded
+	used := tokenUsage{Requests: 1}
+	defer func() {
+		if s.usage != nil {
+			s.usage.reportUsage(ctx, model, used)
 		}
+	}()
+
+	if s.backend != nil {
+		content, used, err = s.backend.complete(ctx, model, prompt, schemaName, schemaDesc, schema)
+		if err == nil && s.streamed != nil {
+			s.streamed(content + "\n")
+		}
+		return content, err
+	}
+
+	strict := openai.ChatCompletionNewParamsResponseFormatUnion{
+		OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+			JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name:        schemaName,
+				Description: openai.String(schemaDesc),
+				Strict:      openai.Bool(true),
+				Schema:      schema,
+			},
+		},
+	}
+	plain := openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONObject: &shared.ResponseFormatJSONObjectParam{}}
+	tool := openai.ChatCompletionFunctionTool(shared.FunctionDefinitionParam{
+		Name:        schemaName,
+		Description: openai.String(schemaDesc),
+		Parameters:  schema,
+	})
+	params := openai.ChatCompletionNewParams{
+		Model:         openai.ChatModel(model),
+		Messages:      s.messages(prompt),
+		StreamOptions: openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)},
+	}
+	if prompt.temperature > 0 {
+		params.Temperature = openai.Float(prompt.temperature)
 	}
+	if s.cfg.PromptCache == promptCacheOpenAI && prompt.cacheKey != "" {
+		params.PromptCacheKey = openai.String("ergo-" + prompt.cacheKey)
+	}
+	for try := 0; ; try++ {
+		f := s.format.Load()
+		params.ResponseFormat, params.Tools, params.ToolChoice = openai.ChatCompletionNewParamsResponseFormatUnion{}, nil, openai.ChatCompletionToolChoiceOptionUnionParam{}
+		switch structuredFormats[f] {
+		case structuredSchema:
+			params.ResponseFormat = strict
+		case structuredTool:
+			params.Tools = []openai.ChatCompletionToolUnionParam{tool}
+			params.ToolChoice = openai.ToolChoiceOptionFunctionToolChoice(openai.ChatCompletionNamedToolChoiceFunctionParam{Name: schemaName})
+		default:
+			params.ResponseFormat = plain
+		}
+		var u tokenUsage
+		content, u, err = s.stream(ctx, params)
+		if try == 0 {
+			used = u
+		} else {
+			used.add(u)
+		}
+		if err == nil || !s.probe || int(f) == len(structuredFormats)-1 || !s.rejectsFormat(err) {
+			return content, err
+		}
+		// Try the next pathway; the first request to see the rejection
+		// switches every later one over.
+		if s.format.CompareAndSwap(f, f+1) {
+			s.log.forContext(ctx).warnf("%s rejected %s structured output (%v); using %s output from now on", model, structuredFormats[f], err, structuredFormats[f+1])
+		}
+	}
+}
 
+// rejectsFormat reports whether err is the server refusing the requested
+// structured-output pathway. Local servers often fail such requests with a
+// bare status; hosted APIs are only trusted when the error names the format,
+// so a malformed request is not taken for a missing capability.
+func (s *Solver) rejectsFormat(err error) bool {
+	if !isSchemaRejection(err) {
+		return false
+	}
+	if s.cfg.Provider == providerOllama {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, hint := range []string{"response_format", "json_schema", "schema", "tool", "function"} {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// stream runs one streaming chat completion and returns its content.
+func (s *Solver) stream(ctx context.Context, params openai.ChatCompletionNewParams) (string, tokenUsage, error) {
+	stream := s.client.Chat.Completions.NewStreaming(ctx, params)
+	defer func() { _ = stream.Close() }()
+
+	used := tokenUsage{Requests: 1}
+	var contentBuilder, args strings.Builder
+	for stream.Next() {
+		chunk := stream.Current()
+		if len(chunk.Choices) > 0 {
+			delta := chunk.Choices[0].Delta
+			contentBuilder.WriteString(delta.Content)
+			// Only the first call matters: the answer tool is forced.
+			for _, call := range delta.ToolCalls {
+				if call.Index == 0 {
+					args.WriteString(call.Function.Arguments)
+					if s.streamed != nil {
+						s.streamed(call.Function.Arguments)
+					}
+				}
+			}
+			if s.streamed != nil && delta.Content != "" {
+				s.streamed(delta.Content)
+			}
+		}
+		if chunk.JSON.Usage.Valid() {
+			used = openaiUsage(chunk.Usage)
+		}
+	}
+	if s.streamed != nil {
+		s.streamed("\n")
+	}
+	if err := stream.Err(); err != nil {
+		return "", used, err
+	}
+	if args.Len() > 0 {
+		return args.String(), used, nil
+	}
+	return contentBuilder.String(), used, nil
+}
+
+// isSchemaRejection reports whether err is the server refusing the request
+// itself (as opposed to auth, rate limiting or a missing model), which for a
+// local server usually means the response schema is not supported.
+func isSchemaRejection(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusInternalServerError:
+		return true
+	}
+	return false
+}
+
+// backendStatusError is a native provider backend's non-2xx response.
+type backendStatusError struct {
+	StatusCode int
+	msg        string
+}
+
+func (e *backendStatusError) Error() string { return e.msg }
+
+// openaiUsage converts the usage chunk of an OpenAI-compatible stream.
+func openaiUsage(u openai.CompletionUsage) tokenUsage {
+	return tokenUsage{
+		Requests:         1,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		ReasoningTokens:  u.CompletionTokensDetails.ReasoningTokens,
+		CachedTokens:     u.PromptTokensDetails.CachedTokens,
+	}
+}
+
+// parseAnswerGrid salvages the answer grid from output that did not decode
+// as an Answer: an object with an answer field, a bare grid, or the first
+// grid anywhere in the text, each after repairJSONValue.
+func parseAnswerGrid(text string) ([][]int, error) {
+	var obj struct {
+		Answer [][]int `json:"answer"`
+	}
+	if err := json.Unmarshal([]byte(repairJSON(text)), &obj); err == nil && len(obj.Answer) > 0 {
+		return normalizeGrid(obj.Answer)
+	}
+	var grid [][]int
+	if err := json.Unmarshal([]byte(repairJSONValue(text, '[')), &grid); err == nil && len(grid) > 0 {
+		return normalizeGrid(grid)
+	}
+
+	start := strings.Index(text, "[[")
+	if start == -1 {
+		return nil, errors.New("not valid json array")
+	}
+	if err := json.Unmarshal([]byte(repairFrom(text, start)), &grid); err != nil {
+		return nil, fmt.Errorf("parse json array: %w", err)
+	}
+	return normalizeGrid(grid)
+}
+
+// normalizeGrid checks that a salvaged grid has cells. Ragged rows are
+// left for askAnswerAt, which knows the puzzle's background.
+func normalizeGrid(grid [][]int) ([][]int, error) {
+	if len(grid) == 0 {
+		return nil, errors.New("empty grid")
+	}
+	if !slices.ContainsFunc(grid, func(row []int) bool { return len(row) > 0 }) {
+		return nil, errors.New("empty rows")
+	}
 	return grid, nil
 }
 
@@ -443,78 +1250,56 @@ const verifyPrompt = `You are an ARC puzzle validator. Your task is to verify if
 
 IMPORTANT: Return valid=true ONLY if the answer correctly follows the pattern. When in doubt, return false.`
 
-func (s *Solver) verifyAnswer(ctx context.Context, p puzzle, answer [][]int) (bool, error) {
-	puzzleJSON, err := json.MarshalIndent(p, "", "  ")
-	if err != nil {
-		return false, fmt.Errorf("marshal puzzle: %w", err)
+// verifyAnswer asks model whether answer follows the puzzle's pattern and
+// returns its verdict with the reasoning. With ai.verifiers set the
+// verifier quorum decides instead of model (see verifyQuorum).
+func (s *Solver) verifyAnswer(ctx context.Context, p puzzle, answer [][]int, model string) (VerifyResult, error) {
+	if len(s.cfg.Verifiers) > 0 {
+		return s.verifyQuorum(ctx, p, answer)
 	}
+	vr, err := s.verifyOnce(ctx, p, answer, model)
+	if err == nil && vr.Reasoning != "" {
+		fmt.Fprintf(uiOut, "%s🔍 Verification: %s%s\n", colorYellow, vr.Reasoning, colorReset)
+	}
+	return vr, err
+}
 
+// verifyOnce asks model for its verdict on answer.
+func (s *Solver) verifyOnce(ctx context.Context, p puzzle, answer [][]int, model string) (VerifyResult, error) {
 	answerJSON, err := json.Marshal(answer)
 	if err != nil {
-		return false, fmt.Errorf("marshal answer: %w", err)
+		return VerifyResult{}, fmt.Errorf("marshal answer: %w", err)
 	}
 
-	userQuery := fmt.Sprintf(`Verify this ARC puzzle answer:
-
-## Puzzle (training examples + test input):
-%s
+	query := fmt.Sprintf(`Verify this answer to the ARC puzzle above:
 
 ## Proposed Answer:
 %s
 
-Does this answer correctly follow the transformation pattern from the training examples?`, string(puzzleJSON), string(answerJSON))
+Does this answer correctly follow the transformation pattern from the training examples?`, string(answerJSON))
 
-	stream := s.client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
-		Model: openai.ChatModel(s.model),
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(verifyPrompt),
-			openai.UserMessage(userQuery),
-		},
-		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
-			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
-				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
-					Name:        "verify_response",
-					Description: openai.String("Verification result"),
-					Strict:      openai.Bool(true),
-					Schema:      verifySchema,
-				},
-			},
-		},
-	})
-
-	var contentBuilder strings.Builder
-	for stream.Next() {
-		chunk := stream.Current()
-		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-			contentBuilder.WriteString(chunk.Choices[0].Delta.Content)
-		}
+	prompt, err := s.puzzlePrompt(verifyPrompt, p, query)
+	if err != nil {
+		return VerifyResult{}, err
 	}
-
-	if err := stream.Err(); err != nil {
-		return false, fmt.Errorf("verify chat completion error: %w", err)
+	content, err := s.complete(ctx, model, prompt, "verify_response", "Verification result", verifySchema)
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("verify chat completion error: %w", err)
 	}
 
-	content := contentBuilder.String()
 	if content == "" {
-		return false, errors.New("no content in verify response")
+		return VerifyResult{}, errors.New("no content in verify response")
 	}
 
 	var verifyResult VerifyResult
 	if err := json.Unmarshal([]byte(content), &verifyResult); err != nil {
-		start := strings.Index(content, "{")
-		end := strings.LastIndex(content, "}")
-		if start != -1 && end > start {
-			if err := json.Unmarshal([]byte(content[start:end+1]), &verifyResult); err != nil {
-				return false, fmt.Errorf("parse verify response: %w", err)
-			}
-		} else {
-			return false, fmt.Errorf("invalid verify response format")
+		if !strings.Contains(content, "{") {
+			return VerifyResult{}, fmt.Errorf("invalid verify response format")
+		}
+		if err := json.Unmarshal([]byte(repairJSON(content)), &verifyResult); err != nil {
+			return VerifyResult{}, fmt.Errorf("parse verify response: %w", err)
 		}
 	}
 
-	if verifyResult.Reasoning != "" {
-		fmt.Printf("%s🔍 Verification: %s%s\n", colorYellow, verifyResult.Reasoning, colorReset)
-	}
-
-	return verifyResult.Valid, nil
+	return verifyResult, nil
 }
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultAnthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion        = "2023-06-01"
+	// anthropicMaxTokens caps a response; the Messages API requires a limit
+	// and answers with reasoning fit well within it.
+	anthropicMaxTokens = 16384
+)
+
+// anthropicClient talks to the Anthropic Messages API. Structured output is
+// obtained by forcing a single tool whose input schema is the answer schema.
+type anthropicClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+	// cache marks the system prompt and puzzle prefix with cache_control.
+	cache bool
+}
+
+type anthropicBlock struct {
+	Type         string             `json:"type"`
+	Text         string             `json:"text,omitempty"`
+	Source       *anthropicImage    `json:"source,omitempty"`
+	CacheControl *anthropicCacheCtl `json:"cache_control,omitempty"`
+	Name         string             `json:"name,omitempty"`
+	Input        json.RawMessage    `json:"input,omitempty"`
+}
+
+type anthropicImage struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicCacheCtl struct {
+	Type string `json:"type"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model     string `json:"model"`
+	MaxTokens int    `json:"max_tokens"`
+	// Temperature is left to the API default when nil.
+	Temperature *float64           `json:"temperature,omitempty"`
+	System      []anthropicBlock   `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Tools       []anthropicTool    `json:"tools"`
+	ToolChoice  map[string]string  `json:"tool_choice"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      struct {
+		InputTokens              int64 `json:"input_tokens"`
+		OutputTokens             int64 `json:"output_tokens"`
+		CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+}
+
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// request builds the Messages API request for prompt, mirroring the layout
+// of Solver.messages: puzzle text, captioned images, then the query.
+func (c *anthropicClient) request(model string, prompt chatPrompt, toolName, toolDesc string, schema map[string]any) (anthropicRequest, error) {
+	blocks := []anthropicBlock{{Type: "text", Text: prompt.puzzle}}
+	for _, img := range prompt.images {
+		mediaType, data, ok := parseDataURL(img.dataURL)
+		if !ok {
+			return anthropicRequest{}, fmt.Errorf("image %q is not a base64 data URL", img.caption)
+		}
+		blocks = append(blocks,
+			anthropicBlock{Type: "text", Text: img.caption},
+			anthropicBlock{Type: "image", Source: &anthropicImage{Type: "base64", MediaType: mediaType, Data: data}})
+	}
+	system := []anthropicBlock{{Type: "text", Text: prompt.system}}
+	if c.cache {
+		ephemeral := &anthropicCacheCtl{Type: "ephemeral"}
+		system[0].CacheControl = ephemeral
+		blocks[len(blocks)-1].CacheControl = ephemeral
+	}
+	blocks = append(blocks, anthropicBlock{Type: "text", Text: prompt.query})
+	var temperature *float64
+	if prompt.temperature > 0 {
+		// The Messages API accepts 0-1.
+		t := min(prompt.temperature, 1)
+		temperature = &t
+	}
+	return anthropicRequest{
+		Model:       model,
+		MaxTokens:   anthropicMaxTokens,
+		Temperature: temperature,
+		System:      system,
+		Messages:    []anthropicMessage{{Role: "user", Content: blocks}},
+		Tools:       []anthropicTool{{Name: toolName, Description: toolDesc, InputSchema: schema}},
+		ToolChoice:  map[string]string{"type": "tool", "name": toolName},
+	}, nil
+}
+
+// complete sends one request and returns the forced tool call's input as
+// JSON, the same content an OpenAI structured-output response carries.
+func (c *anthropicClient) complete(ctx context.Context, model string, prompt chatPrompt, toolName, toolDesc string, schema map[string]any) (string, tokenUsage, error) {
+	used := tokenUsage{Requests: 1}
+	body, err := c.request(model, prompt, toolName, toolDesc, schema)
+	if err != nil {
+		return "", used, err
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", used, fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.baseURL, "/")+"/v1/messages", bytes.NewReader(b))
+	if err != nil {
+		return "", used, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", used, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", used, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		var e anthropicError
+		msg := "anthropic: " + resp.Status
+		if json.Unmarshal(raw, &e) == nil && e.Error.Message != "" {
+			msg += ": " + e.Error.Type + ": " + e.Error.Message
+		}
+		return "", used, &backendStatusError{StatusCode: resp.StatusCode, msg: msg}
+	}
+
+	var out anthropicResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return "", used, fmt.Errorf("parse response: %w", err)
+	}
+	u := out.Usage
+	used = tokenUsage{
+		Requests:         1,
+		PromptTokens:     u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens,
+		CompletionTokens: u.OutputTokens,
+		CachedTokens:     u.CacheReadInputTokens,
+	}
+	if out.StopReason == "max_tokens" {
+		return "", used, fmt.Errorf("response truncated at %d tokens", anthropicMaxTokens)
+	}
+	for _, blk := range out.Content {
+		if blk.Type == "tool_use" && blk.Name == toolName {
+			return string(blk.Input), used, nil
+		}
+	}
+	return "", used, errors.New("no tool call in response")
+}
+
+// parseDataURL splits a base64 data URL into its media type and payload.
+func parseDataURL(u string) (mediaType, data string, ok bool) {
+	rest, found := strings.CutPrefix(u, "data:")
+	if !found {
+		return "", "", false
+	}
+	meta, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mediaType, found = strings.CutSuffix(meta, ";base64")
+	return mediaType, data, found
+}
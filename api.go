@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,7 +11,9 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,6 +25,50 @@ type apiClient struct {
 	userAgent     string
 	jar           http.CookieJar
 	http          *http.Client
+
+	// archiveDir, when set, receives a copy of every failed response.
+	archiveDir string
+	// challenge configures the external solver for anti-bot interstitials.
+	challenge challengeConfig
+	// pow configures the Proof-of-Work nonce search.
+	pow powConfig
+	// log, when set, reports challenge handling, retries and cookie health.
+	log *logger
+	// retry decides which failed calls doJSON repeats.
+	retry retryPolicy
+	// latency, when set, records the duration of every request.
+	latency *latencyTracker
+	// answerFormat is answer_format, the submitted answer's shape.
+	answerFormat string
+
+	sessionCookie  string
+	sessionMissing bool
+	dropCookies    []string
+
+	// spacing is the minimum gap between requests; lastRequest is guarded
+	// by spacingMu.
+	spacing     time.Duration
+	spacingMu   sync.Mutex
+	lastRequest time.Time
+
+	// home locates the certificate pin store; pinned is the fingerprint
+	// recorded for this host, peerFP the one seen on the last handshake.
+	home    string
+	pinMode string
+	pinned  string
+	pinMu   sync.Mutex
+	peerFP  string
+
+	// cached keeps the validators and body of the last daily quota
+	// response, so repeated polls can be answered with 304 Not Modified.
+	cachedMu sync.Mutex
+	cached   map[string]cachedGET
+}
+
+// cachedGET is a GET response body with the validators it was served with.
+type cachedGET struct {
+	etag, lastModified string
+	body               []byte
 }
 
 // newAPIClient creates a new API client with the given configuration.
@@ -40,20 +87,54 @@ func newAPIClient(cfg appConfig) (*apiClient, error) {
 		jar.SetCookies(u, parseCookieHeader(cfg.Cookie))
 	}
 
+	switch cfg.AnswerFormat {
+	case "", answerNested, answerFlat, answerRows:
+	default:
+		return nil, fmt.Errorf("unknown answer_format %q (want %q, %q or %q)", cfg.AnswerFormat, answerNested, answerFlat, answerRows)
+	}
+
+	tr, err := proxiedTransport(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
 	c := &apiClient{
 		baseURL:       u.String(),
 		baseURLParsed: u,
 		cookie:        strings.TrimSpace(cfg.Cookie),
 		userAgent:     cfg.UserAgent,
 		jar:           jar,
+		challenge:     cfg.Challenge,
+		pow:           cfg.Pow,
+		sessionCookie: strings.TrimSpace(cfg.SessionCookie),
+		dropCookies:   cfg.DropCookies,
+		spacing:       time.Duration(cfg.RequestSpacingMS) * time.Millisecond,
+		home:          cfg.home,
+		pinMode:       pinMode(cfg.TLSPin),
+		retry:         newRetryPolicy(cfg.Retry),
+		answerFormat:  cfg.AnswerFormat,
 		http: &http.Client{
-			Timeout: 30 * time.Second,
-			Jar:     jar,
+			Timeout:   30 * time.Second,
+			Jar:       jar,
+			Transport: tr,
 		},
 	}
+	if c.pinMode != pinOff && c.home != "" {
+		if store, err := loadPinStore(c.home); err == nil {
+			c.pinned = store.Hosts[u.Host].SHA256
+		}
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		}
+		tr.TLSClientConfig.VerifyConnection = c.verifyPinned
+	}
 	if c.userAgent == "" {
 		c.userAgent = defaultUA
 	}
+	if c.dropCookies == nil {
+		c.dropCookies = defaultDropCookies
+	}
+	c.pruneCookies()
+	c.cookie = strings.TrimSpace(c.exportCookieHeader())
 	return c, nil
 }
 
@@ -62,17 +143,60 @@ type apiError struct {
 	StatusCode int
 	Message    string
 	Body       []byte
+	RequestID  string
+	// RetryAfter is the server's Retry-After delay, if it sent one.
+	RetryAfter time.Duration
+
+	// ArchivePath is where the full response was saved, if archiving is on.
+	ArchivePath string
 }
 
 func (e *apiError) Error() string {
+	msg := fmt.Sprintf("api %d", e.StatusCode)
 	if e.Message != "" {
-		return fmt.Sprintf("api %d: %s", e.StatusCode, e.Message)
+		msg += ": " + e.Message
 	}
-	return fmt.Sprintf("api %d", e.StatusCode)
+	if e.RequestID != "" {
+		msg += " (request_id=" + e.RequestID + ")"
+	}
+	if e.ArchivePath != "" {
+		msg += " [full response: " + e.ArchivePath + "]"
+	}
+	return msg
 }
 
-// doJSON performs an HTTP request with JSON body and response.
+// doJSON performs an HTTP request with JSON body and response, repeating it
+// on transient failures according to the retry policy.
 func (c *apiClient) doJSON(ctx context.Context, method, path string, body any, out any) error {
+	return c.retry.do(ctx, c.log, method, path, func() error {
+		return c.doJSONChallenge(ctx, method, path, body, out)
+	})
+}
+
+// doJSONChallenge performs one request. When it is intercepted by an
+// anti-bot challenge page and a challenge command is configured, the command
+// is run once and the request retried.
+func (c *apiClient) doJSONChallenge(ctx context.Context, method, path string, body any, out any) error {
+	err := c.doJSONOnce(ctx, method, path, body, out)
+	var he *htmlPageError
+	if !errors.As(err, &he) || he.Kind != htmlChallenge || len(c.challenge.Command) == 0 {
+		return err
+	}
+
+	if c.log != nil {
+		c.log.forContext(ctx).warn("anti-bot challenge detected, running challenge command...")
+	}
+	if cerr := solveFrontChallenge(ctx, c); cerr != nil {
+		return fmt.Errorf("%w (challenge hook: %v)", err, cerr)
+	}
+	if c.log != nil {
+		c.log.forContext(ctx).ok("challenge cookies obtained, retrying request")
+	}
+	return c.doJSONOnce(ctx, method, path, body, out)
+}
+
+// doJSONOnce performs a single HTTP request with JSON body and response.
+func (c *apiClient) doJSONOnce(ctx context.Context, method, path string, body any, out any) error {
 	if !strings.HasPrefix(path, "/") {
 		path = "/" + path
 	}
@@ -91,7 +215,12 @@ func (c *apiClient) doJSON(ctx context.Context, method, path string, body any, o
 	if err != nil {
 		return fmt.Errorf("build request: %w", err)
 	}
+	if err := c.waitSpacing(ctx); err != nil {
+		return err
+	}
 
+	reqID := newRequestID(ctx)
+	req.Header.Set("X-Request-ID", reqID)
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", c.userAgent)
 	req.Header.Set("Referer", c.baseURL+"/")
@@ -101,10 +230,20 @@ func (c *apiClient) doJSON(ctx context.Context, method, path string, body any, o
 	if c.jar == nil && c.cookie != "" {
 		req.Header.Set("Cookie", c.cookie)
 	}
+	cached, haveCached := c.cachedGET(method, path)
+	if haveCached {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
 
+	start := time.Now()
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return fmt.Errorf("request failed (request_id=%s): %w", reqID, err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
@@ -113,10 +252,20 @@ func (c *apiClient) doJSON(ctx context.Context, method, path string, body any, o
 	if err != nil {
 		return fmt.Errorf("read response: %w", err)
 	}
+	c.latency.since(method+" "+req.URL.Path, start)
 
+	c.pruneCookies()
+	c.checkSessionCookie(ctx)
 	c.cookie = strings.TrimSpace(c.exportCookieHeader())
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+	if kind, title, ok := classifyHTMLPage(resp, b); ok {
+		return &htmlPageError{Kind: kind, Title: title, api: c.newAPIError(req, resp, b, reqID, title)}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && haveCached:
+		b = cached.body
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
 		msg := ""
 		var m map[string]any
 		if json.Unmarshal(b, &m) == nil {
@@ -126,7 +275,9 @@ func (c *apiClient) doJSON(ctx context.Context, method, path string, body any, o
 				msg = s
 			}
 		}
-		return &apiError{StatusCode: resp.StatusCode, Message: msg, Body: b}
+		return c.newAPIError(req, resp, b, reqID, msg)
+	default:
+		c.storeGET(method, path, resp.Header, b)
 	}
 
 	if out == nil {
@@ -136,11 +287,86 @@ func (c *apiClient) doJSON(ctx context.Context, method, path string, body any, o
 		return errors.New("empty response body")
 	}
 	if err := json.Unmarshal(b, out); err != nil {
-		return fmt.Errorf("parse response: %w", err)
+		return fmt.Errorf("parse response (request_id=%s): %w", reqID, err)
+	}
+	return nil
+}
+
+// dailyRemainingPath is the daily quota endpoint, the only one requested
+// conditionally.
+const dailyRemainingPath = "/api/daily/remaining"
+
+// conditionalGET reports whether a request may be sent as a conditional GET.
+// Only the daily quota is polled often enough to be worth it; other
+// endpoints are always fetched in full.
+func conditionalGET(method, path string) bool {
+	return method == http.MethodGet && path == dailyRemainingPath
+}
+
+// cachedGET returns the cached response to a conditional GET of path, if
+// any.
+func (c *apiClient) cachedGET(method, path string) (cachedGET, bool) {
+	if !conditionalGET(method, path) {
+		return cachedGET{}, false
+	}
+	c.cachedMu.Lock()
+	defer c.cachedMu.Unlock()
+	e, ok := c.cached[path]
+	return e, ok
+}
+
+// storeGET caches a successful conditional GET response that carries an
+// ETag or Last-Modified validator.
+func (c *apiClient) storeGET(method, path string, h http.Header, body []byte) {
+	if !conditionalGET(method, path) {
+		return
+	}
+	e := cachedGET{etag: h.Get("ETag"), lastModified: h.Get("Last-Modified"), body: body}
+	c.cachedMu.Lock()
+	defer c.cachedMu.Unlock()
+	if e.etag == "" && e.lastModified == "" {
+		delete(c.cached, path)
+		return
+	}
+	if c.cached == nil {
+		c.cached = map[string]cachedGET{}
+	}
+	c.cached[path] = e
+}
+
+// waitSpacing delays until at least c.spacing has passed since the previous
+// request was sent.
+func (c *apiClient) waitSpacing(ctx context.Context) error {
+	if c.spacing <= 0 {
+		return nil
+	}
+	c.spacingMu.Lock()
+	defer c.spacingMu.Unlock()
+	if wait := c.spacing - time.Since(c.lastRequest); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
 	}
+	c.lastRequest = time.Now()
 	return nil
 }
 
+// newAPIError builds an apiError for resp, archiving the response first when
+// an archive directory is configured.
+func (c *apiClient) newAPIError(req *http.Request, resp *http.Response, body []byte, reqID, msg string) *apiError {
+	ae := &apiError{StatusCode: resp.StatusCode, Message: msg, Body: body, RequestID: reqID, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	if c.archiveDir != "" {
+		if path, err := archiveAPIError(c.archiveDir, req, resp, body, reqID); err == nil {
+			ae.ArchivePath = path
+		}
+	}
+	return ae
+}
+
 // exportCookieHeader returns the current cookies as a header string.
 func (c *apiClient) exportCookieHeader() string {
 	if c.jar == nil || c.baseURLParsed == nil {
@@ -214,7 +440,7 @@ type dailyRemainingResponse struct {
 // dailyRemaining fetches the remaining daily puzzle attempts.
 func (c *apiClient) dailyRemaining(ctx context.Context) (*dailyRemainingResponse, error) {
 	var out dailyRemainingResponse
-	if err := c.doJSON(ctx, http.MethodGet, "/api/daily/remaining", nil, &out); err != nil {
+	if err := c.doJSON(ctx, http.MethodGet, dailyRemainingPath, nil, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
@@ -306,8 +532,45 @@ func (c *apiClient) puzzleNew(ctx context.Context) (*puzzleNewResponse, error) {
 
 // puzzleSubmitRequest represents the answer submission request.
 type puzzleSubmitRequest struct {
-	PuzzleID string  `json:"puzzleId"`
-	Answer   [][]int `json:"answer"`
+	PuzzleID string `json:"puzzleId"`
+	// Answer is the grid as answer_format writes it: [][]int, a flat []int
+	// with Height and Width, or []string rows.
+	Answer any `json:"answer"`
+	Height int `json:"height,omitempty"`
+	Width  int `json:"width,omitempty"`
+}
+
+// answer_format values: how puzzleSubmit serializes the answer grid.
+const (
+	answerNested = "nested"
+	answerFlat   = "flat"
+	answerRows   = "rows"
+)
+
+// newSubmitRequest serializes answer for submission in format: nested as
+// [][]int (the default), flat as the cells row by row with the grid's
+// height and width, rows as one string of digits per row.
+func newSubmitRequest(puzzleID string, answer [][]int, format string) puzzleSubmitRequest {
+	req := puzzleSubmitRequest{PuzzleID: puzzleID, Answer: answer}
+	switch format {
+	case answerFlat:
+		cells := []int{}
+		for _, row := range answer {
+			cells = append(cells, row...)
+		}
+		req.Answer, req.Height, req.Width = cells, len(answer), gridWidth(answer)
+	case answerRows:
+		rows := make([]string, len(answer))
+		for i, row := range answer {
+			var b strings.Builder
+			for _, v := range row {
+				b.WriteString(strconv.Itoa(v))
+			}
+			rows[i] = b.String()
+		}
+		req.Answer = rows
+	}
+	return req
 }
 
 // puzzleSubmitResponse represents the answer submission result.
@@ -325,7 +588,33 @@ type puzzleSubmitResponse struct {
 // puzzleSubmit submits an answer for the given puzzle.
 func (c *apiClient) puzzleSubmit(ctx context.Context, puzzleID string, answer [][]int) (*puzzleSubmitResponse, error) {
 	var out puzzleSubmitResponse
-	if err := c.doJSON(ctx, http.MethodPost, "/api/puzzle/submit", puzzleSubmitRequest{PuzzleID: puzzleID, Answer: answer}, &out); err != nil {
+	if err := c.doJSON(ctx, http.MethodPost, "/api/puzzle/submit", newSubmitRequest(puzzleID, answer, c.answerFormat), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// serverSubmission is one answer in the account's server-side history,
+// whether submitted by this tool or in the browser.
+type serverSubmission struct {
+	PuzzleID      string  `json:"puzzleId"`
+	Correct       bool    `json:"correct"`
+	PointsAwarded int     `json:"pointsAwarded"`
+	SubmittedAt   int64   `json:"submittedAt"`
+	Answer        [][]int `json:"answer,omitempty"`
+}
+
+// submissionHistoryResponse is one page of the submission history.
+type submissionHistoryResponse struct {
+	Submissions []serverSubmission `json:"submissions"`
+	HasMore     bool               `json:"hasMore"`
+}
+
+// submissionHistory fetches page (from 1) of the account's submissions.
+// Not every server exposes this endpoint; those answer 404.
+func (c *apiClient) submissionHistory(ctx context.Context, page int) (*submissionHistoryResponse, error) {
+	var out submissionHistoryResponse
+	if err := c.doJSON(ctx, http.MethodGet, fmt.Sprintf("/api/puzzle/history?page=%d", page), nil, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxArchivedBody bounds how much of an error response body is archived.
+const maxArchivedBody = 1 << 20
+
+// runDirFor returns the per-run artifact directory under home. It is created
+// lazily by whoever writes into it first.
+func runDirFor(home string, started time.Time) string {
+	return filepath.Join(home, "runs", started.Format("20060102-150405"))
+}
+
+// archiveAPIError writes the request line, response headers and (bounded)
+// body of a failed API call to dir and returns the file path.
+func archiveAPIError(dir string, req *http.Request, resp *http.Response, body []byte, reqID string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("mkdir run dir: %w", err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s\n", req.Method, req.URL.String())
+	fmt.Fprintf(&buf, "X-Request-ID: %s\n", reqID)
+	fmt.Fprintf(&buf, "Time: %s\n\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&buf, "%s %s\n", resp.Proto, resp.Status)
+
+	keys := make([]string, 0, len(resp.Header))
+	for k := range resp.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "Set-Cookie" {
+			fmt.Fprintf(&buf, "%s: [%d redacted]\n", k, len(resp.Header[k]))
+			continue
+		}
+		for _, v := range resp.Header[k] {
+			fmt.Fprintf(&buf, "%s: %s\n", k, v)
+		}
+	}
+	buf.WriteByte('\n')
+	if len(body) > maxArchivedBody {
+		buf.Write(body[:maxArchivedBody])
+		fmt.Fprintf(&buf, "\n[truncated: %d of %d bytes]\n", maxArchivedBody, len(body))
+	} else {
+		buf.Write(body)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("api-error-%d-%s.txt", resp.StatusCode, reqID))
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return "", fmt.Errorf("write archive: %w", err)
+	}
+	return path, nil
+}
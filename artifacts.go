@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Puzzle image layout, in pixels.
+const (
+	artifactCell = 12
+	artifactGap  = 16
+)
+
+// Puzzle image colors: the background and the cell borders.
+var (
+	artifactBackground = color.RGBA{0x30, 0x30, 0x30, 0xFF}
+	artifactBorder     = color.RGBA{0x55, 0x55, 0x55, 0xFF}
+)
+
+// puzzleArtifacts locates the files written for one solved puzzle. Each
+// field is a path, or a URL when notifications.artifact_base_url is set.
+type puzzleArtifacts struct {
+	Image     string `json:"image,omitempty"`
+	Answer    string `json:"answer,omitempty"`
+	Reasoning string `json:"reasoning,omitempty"`
+}
+
+// writePuzzleArtifacts renders the puzzle with the answer, and saves the
+// answer grid and reasoning, under dir/puzzles/<id>.
+func writePuzzleArtifacts(dir string, p puzzle, res solveResult) (puzzleArtifacts, error) {
+	id := strings.NewReplacer("/", "_", "\\", "_").Replace(p.ID)
+	dir = filepath.Join(dir, puzzlesDirName, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return puzzleArtifacts{}, fmt.Errorf("mkdir artifacts: %w", err)
+	}
+
+	var out puzzleArtifacts
+	out.Image = filepath.Join(dir, "puzzle.png")
+	f, err := os.Create(out.Image)
+	if err != nil {
+		return puzzleArtifacts{}, fmt.Errorf("create image: %w", err)
+	}
+	err = png.Encode(f, renderPuzzleImage(p, res.Answer))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return puzzleArtifacts{}, fmt.Errorf("write image: %w", err)
+	}
+
+	if res.Answer != nil {
+		b, _ := json.Marshal(map[string]any{"id": p.ID, "answer": res.Answer})
+		out.Answer = filepath.Join(dir, "answer.json")
+		if err := os.WriteFile(out.Answer, append(b, '\n'), 0o644); err != nil {
+			return puzzleArtifacts{}, fmt.Errorf("write answer: %w", err)
+		}
+	}
+	if res.Reasoning != "" {
+		out.Reasoning = filepath.Join(dir, "reasoning.txt")
+		text := fmt.Sprintf("model: %s\nconfidence: %d%%\n\n%s\n", res.Model, res.Confidence, res.Reasoning)
+		if err := os.WriteFile(out.Reasoning, []byte(text), 0o644); err != nil {
+			return puzzleArtifacts{}, fmt.Errorf("write reasoning: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// placedGrid is a grid and the pixel position of its top-left corner.
+type placedGrid struct {
+	x, y int
+	g    [][]int
+}
+
+// layoutPuzzle places one row per training pair (input, output) and a final
+// row with the test input and answer, if any, and returns the grids with
+// the size of the whole picture.
+func layoutPuzzle(p puzzle, answer [][]int) (grids []placedGrid, width, height int) {
+	rows := make([][2][][]int, 0, len(p.Train)+1)
+	for _, ex := range p.Train {
+		rows = append(rows, [2][][]int{ex.Input, ex.Output})
+	}
+	rows = append(rows, [2][][]int{p.TestInput, answer})
+
+	var leftW int
+	for _, r := range rows {
+		leftW = max(leftW, gridPixels(r[0], false))
+	}
+	y := artifactGap
+	for _, r := range rows {
+		width = max(width, leftW+artifactGap+gridPixels(r[1], false))
+		grids = append(grids, placedGrid{artifactGap, y, r[0]}, placedGrid{artifactGap + leftW + artifactGap, y, r[1]})
+		y += max(gridPixels(r[0], true), gridPixels(r[1], true)) + artifactGap
+	}
+	return grids, width + 2*artifactGap, y
+}
+
+// renderPuzzleImage draws the puzzle as layoutPuzzle places it.
+func renderPuzzleImage(p puzzle, answer [][]int) image.Image {
+	grids, width, height := layoutPuzzle(p, answer)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(artifactBackground), image.Point{}, draw.Src)
+	for _, pg := range grids {
+		drawGrid(img, pg.x, pg.y, pg.g)
+	}
+	return img
+}
+
+// gridPixels returns the rendered width (or height) of g.
+func gridPixels(g [][]int, height bool) int {
+	n := len(g)
+	if !height {
+		n = 0
+		for _, row := range g {
+			n = max(n, len(row))
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return n*artifactCell + 1
+}
+
+// drawGrid paints g at (x0, y0) with one-pixel grey cell borders.
+func drawGrid(img *image.RGBA, x0, y0 int, g [][]int) {
+	if len(g) == 0 {
+		return
+	}
+	border := image.Rect(x0, y0, x0+gridPixels(g, false), y0+gridPixels(g, true))
+	draw.Draw(img, border, image.NewUniform(artifactBorder), image.Point{}, draw.Src)
+	for r, row := range g {
+		for c, v := range row {
+			if v < 0 || v >= len(arcPalette) {
+				continue
+			}
+			pc := arcPalette[v]
+			x, y := x0+c*artifactCell, y0+r*artifactCell
+			cell := image.Rect(x+1, y+1, x+artifactCell, y+artifactCell)
+			draw.Draw(img, cell, image.NewUniform(color.RGBA{pc[0], pc[1], pc[2], 0xFF}), image.Point{}, draw.Src)
+		}
+	}
+}
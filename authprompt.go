@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// authPromptAttempts is how many pastes a login prompt accepts before
+// giving up on cookies the site rejects.
+const authPromptAttempts = 2
+
+// stdin buffers os.Stdin for every prompt, so lines piped in for later
+// prompts are not lost in the buffer of an earlier one.
+var stdin = bufio.NewReader(os.Stdin)
+
+// promptAuthMaterial asks for a cookie, `Cookie:` header or curl command on
+// stdin. On a terminal the paste is hidden, the parsed result is shown and
+// can be corrected before it is used; otherwise the input is read as is. A
+// live dashboard gives way to the prompt until it is answered.
+func promptAuthMaterial() (authMaterial, error) {
+	defer liveDashboard.suspend()()
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return readAuthMaterial(stdin, os.Stdout)
+	}
+	return promptAuthTerminal(fd, os.Stdout)
+}
+
+// readAuthMaterial reads auth material up to an empty line without echo
+// control, for piped input.
+func readAuthMaterial(r *bufio.Reader, w io.Writer) (authMaterial, error) {
+	_, _ = fmt.Fprintln(w, "Enter token/cookie (paste cookie / `Cookie: ...` / curl command, end with empty line):")
+	_, _ = fmt.Fprint(w, "> ")
+
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if strings.TrimSpace(line) == "" {
+			if err != nil && !errors.Is(err, io.EOF) {
+				return authMaterial{}, err
+			}
+			break
+		}
+		lines = append(lines, strings.TrimRight(line, "\r\n"))
+		if err != nil {
+			break
+		}
+	}
+	return parsePastedAuth(strings.Join(lines, "\n"))
+}
+
+// parsePastedAuth parses pasted text and requires a cookie in it.
+func parsePastedAuth(text string) (authMaterial, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return authMaterial{}, errors.New("empty input")
+	}
+	out := parseAuthMaterial(text)
+	if out.Cookie == "" {
+		return authMaterial{}, errors.New("cookie not found: paste `-b '...'` content or `Cookie: ...`")
+	}
+	return out, nil
+}
+
+// promptAuthTerminal reads a hidden paste from the terminal fd, shows what
+// was parsed from it and lets the user re-paste or override the user agent
+// and base URL before confirming.
+func promptAuthTerminal(fd int, w io.Writer) (authMaterial, error) {
+	in, err := pasteAuthHidden(fd, w)
+	if err != nil {
+		return authMaterial{}, err
+	}
+	for {
+		printAuthSummary(w, in)
+		_, _ = fmt.Fprint(w, "[Enter] use this, (p) paste again, (u) set user agent, (b) set base URL, (q) abort: ")
+		choice, err := readTerminalLine(fd, false)
+		if err != nil {
+			return authMaterial{}, err
+		}
+		switch strings.ToLower(strings.TrimSpace(choice)) {
+		case "":
+			return in, nil
+		case "p":
+			pasted, err := pasteAuthHidden(fd, w)
+			if err != nil {
+				_, _ = fmt.Fprintf(w, "  %v\n", err)
+				continue
+			}
+			in = pasted
+		case "u":
+			_, _ = fmt.Fprint(w, "User agent: ")
+			ua, err := readTerminalLine(fd, false)
+			if err != nil {
+				return authMaterial{}, err
+			}
+			in.UserAgent = strings.TrimSpace(ua)
+		case "b":
+			_, _ = fmt.Fprint(w, "Base URL: ")
+			raw, err := readTerminalLine(fd, false)
+			if err != nil {
+				return authMaterial{}, err
+			}
+			base, err := urlToBase(strings.TrimSpace(raw))
+			if err != nil {
+				_, _ = fmt.Fprintf(w, "  invalid base URL: %v\n", err)
+				continue
+			}
+			in.BaseURL = base
+		case "q":
+			return authMaterial{}, errors.New("login aborted")
+		default:
+			_, _ = fmt.Fprintln(w, "  unknown choice")
+		}
+	}
+}
+
+// pasteAuthHidden reads lines without echo until an empty line, reporting
+// the length of each so the user can tell the paste arrived.
+func pasteAuthHidden(fd int, w io.Writer) (authMaterial, error) {
+	_, _ = fmt.Fprintln(w, "Paste the cookie, `Cookie: ...` header or curl command (input is hidden), then press Enter on an empty line:")
+	var lines []string
+	for {
+		line, err := readTerminalLine(fd, true)
+		if err != nil {
+			return authMaterial{}, err
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		lines = append(lines, line)
+		_, _ = fmt.Fprintf(w, "  line %d: %d characters received\n", len(lines), len(line))
+	}
+	return parsePastedAuth(strings.Join(lines, "\n"))
+}
+
+// readTerminalLine reads one line from the terminal fd, without echo when
+// hidden is set.
+func readTerminalLine(fd int, hidden bool) (string, error) {
+	if hidden {
+		b, err := term.ReadPassword(fd)
+		return string(b), err
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// previewAuth applies in to cfg and probes the site with authMe, then shows
+// the captured cookie names, user agent and base URL together with the
+// probe's outcome. Nothing is persisted; the caller saves the returned
+// config only when err is nil.
+func previewAuth(ctx context.Context, cfg appConfig, in authMaterial, w io.Writer) (appConfig, *apiClient, *authMeResponse, error) {
+	cfg.Cookie = in.Cookie
+	if in.UserAgent != "" {
+		cfg.UserAgent = in.UserAgent
+	}
+	if in.BaseURL != "" && cfg.BaseURL == "" {
+		cfg.BaseURL = in.BaseURL
+	}
+	_, _ = fmt.Fprintln(w, "Captured:")
+	printAuthSummary(w, authMaterial{Cookie: cfg.Cookie, UserAgent: cfg.UserAgent, BaseURL: cfg.BaseURL})
+
+	if cfg.BaseURL == "" {
+		return appConfig{}, nil, nil, errors.New("no base URL: set base_url in the config or paste a curl command of the site")
+	}
+	client, err := newAPIClient(cfg)
+	if err != nil {
+		return appConfig{}, nil, nil, err
+	}
+	me, err := client.authMe(ctx)
+	switch {
+	case err == nil:
+		_, _ = fmt.Fprintf(w, "  login:      ok, %s(%s)\n", me.User.Username, me.User.ID)
+	case isAuthError(err):
+		_, _ = fmt.Fprintln(w, "  login:      rejected by the site; nothing was saved")
+	default:
+		_, _ = fmt.Fprintf(w, "  login:      check failed: %v\n", err)
+	}
+	if err != nil {
+		return appConfig{}, nil, nil, err
+	}
+	return cfg, client, me, nil
+}
+
+// printAuthSummary shows what was parsed without revealing cookie values.
+func printAuthSummary(w io.Writer, in authMaterial) {
+	names := cookieNames(in.Cookie)
+	_, _ = fmt.Fprintf(w, "  cookies:    %s (%d, %d characters)\n", strings.Join(names, ", "), len(names), len(in.Cookie))
+	_, _ = fmt.Fprintf(w, "  user agent: %s\n", orDash(in.UserAgent))
+	_, _ = fmt.Fprintf(w, "  base URL:   %s\n", orDash(in.BaseURL))
+}
+
+// cookieNames lists the cookie names of a Cookie header value.
+func cookieNames(cookie string) []string {
+	var names []string
+	for _, part := range strings.Split(cookie, ";") {
+		name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errCanaryUnverified fails a canary answer that self-verification did not
+// approve, including one it could not check.
+var errCanaryUnverified = errors.New("canary: the answer was not confirmed by self-verification")
+
+// canaryAI tightens the AI settings for a canary run: the pre-solver is
+// skipped so the model is always exercised, the rule self-test follows
+// self-verification, and an answer whose size still contradicts the hint
+// fails instead of being submitted with a warning.
+func canaryAI(ai aiConfig) aiConfig {
+	off, strict := false, true
+	ai.Presolve = &off
+	ai.SelfTest = true
+	ai.StrictDimensions = &strict
+	return ai
+}
+
+// checkCanary requires what an ordinary run only prefers: an answer that
+// self-verification ran on and approved.
+func checkCanary(res solveResult) error {
+	if res.Verified == nil || !*res.Verified {
+		return errCanaryUnverified
+	}
+	return nil
+}
+
+// canaryRecord is the attempt summary a canary run writes beside its
+// puzzle artifacts.
+type canaryRecord struct {
+	PuzzleID   string             `json:"puzzleId"`
+	StartedAt  time.Time          `json:"startedAt"`
+	Model      string             `json:"model"`
+	Confidence int                `json:"confidence"`
+	Verified   *bool              `json:"verified"`
+	Members    map[string][][]int `json:"members,omitempty"`
+	ElapsedMS  int64              `json:"elapsedMs"`
+	DryRun     bool               `json:"dryRun"`
+	Submitted  bool               `json:"submitted"`
+	Correct    *bool              `json:"correct"`
+	Points     int                `json:"points"`
+	Usage      tokenUsage         `json:"usage"`
+	CostUSD    float64            `json:"costUsd"`
+	Repairs    int                `json:"repairs"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// writeCanaryArtifacts saves everything about a canary attempt under
+// dir/puzzles/<id>: the notification artifacts (image, answer, reasoning),
+// the puzzle itself, and the attempt summary. It returns the directory.
+func writeCanaryArtifacts(dir string, p puzzle, a attempt) (string, error) {
+	art, err := writePuzzleArtifacts(dir, p, a.Result)
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Dir(art.Image)
+	rec := canaryRecord{
+		PuzzleID:   a.PuzzleID,
+		StartedAt:  a.StartedAt,
+		Model:      a.Result.Model,
+		Confidence: a.Result.Confidence,
+		Verified:   a.Result.Verified,
+		Members:    a.Result.Members,
+		ElapsedMS:  a.SolveTime.Milliseconds(),
+		DryRun:     a.DryRun,
+		Submitted:  a.Submitted,
+		Correct:    a.Correct,
+		Points:     a.Points,
+		Usage:      a.Spend.Usage,
+		CostUSD:    a.Spend.Cost,
+		Repairs:    a.Repairs,
+	}
+	if a.Err != nil {
+		rec.Error = a.Err.Error()
+	}
+	for name, v := range map[string]any{"puzzle.json": p, "attempt.json": rec} {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("marshal %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), append(b, '\n'), 0o644); err != nil {
+			return "", fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return dir, nil
+}
+
+// reportCanary writes the canary's artifacts and logs its verdict.
+func reportCanary(log *logger, runDir string, p puzzle, a attempt) {
+	if dir, err := writeCanaryArtifacts(runDir, p, a); err != nil {
+		log.warnf("write canary artifacts: %v", err)
+	} else {
+		log.infof("canary artifacts: %s", dir)
+	}
+	if a.Err != nil {
+		log.err(fmt.Sprintf("canary failed: %v", a.Err))
+		return
+	}
+	graded := "not submitted"
+	switch {
+	case a.Correct != nil && *a.Correct:
+		graded = "graded correct"
+	case a.Correct != nil:
+		graded = "graded incorrect"
+	}
+	log.okf("canary passed (%s, %s); --auto may start", a.Result.Model, graded)
+}
+
+// requireCanary refuses --auto unless canary.max_age_hours is unset or a
+// canary succeeded within it.
+func requireCanary(ctx context.Context, cfg appConfig) error {
+	hours := cfg.Canary.MaxAgeHours
+	if hours <= 0 {
+		return nil
+	}
+	hist, err := openHistory(cfg)
+	if err != nil {
+		return fmt.Errorf("canary check: %w", err)
+	}
+	defer hist.close()
+	last, err := hist.lastCanary(ctx)
+	if err != nil {
+		return fmt.Errorf("canary check: %w", err)
+	}
+	if last.IsZero() {
+		return fmt.Errorf("no successful canary run recorded; run solve --canary before --auto (canary.max_age_hours=%d)", hours)
+	}
+	if age := time.Since(last); age > time.Duration(hours)*time.Hour {
+		return fmt.Errorf("the last successful canary ran %s ago, more than canary.max_age_hours=%d; run solve --canary before --auto",
+			age.Round(time.Minute), hours)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultChallengeTimeout bounds the external challenge command.
+const defaultChallengeTimeout = 120 * time.Second
+
+// solveFrontChallenge runs the configured external command to obtain a
+// clearance cookie for an anti-bot interstitial and merges its output into
+// the client's cookie jar.
+//
+// The command receives ERGO_BASE_URL, ERGO_USER_AGENT and ERGO_COOKIE in its
+// environment and must print the cookies to add, either as a raw
+// `name=value; ...` string or as `Cookie: ...` / `User-Agent: ...` header
+// lines. A printed User-Agent replaces the client's, since clearance cookies
+// are usually bound to it.
+func solveFrontChallenge(ctx context.Context, c *apiClient) error {
+	cfg := c.challenge
+	if len(cfg.Command) == 0 {
+		return errors.New("no challenge command configured")
+	}
+	timeout := defaultChallengeTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cctx, cfg.Command[0], cfg.Command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"ERGO_BASE_URL="+c.baseURL,
+		"ERGO_USER_AGENT="+c.userAgent,
+		"ERGO_COOKIE="+c.exportCookieHeader(),
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 200 {
+			msg = msg[:200] + "..."
+		}
+		return fmt.Errorf("challenge command failed: %w: %s", err, msg)
+	}
+
+	out := strings.TrimSpace(stdout.String())
+	if out == "" {
+		return errors.New("challenge command printed no cookies")
+	}
+	in := parseAuthMaterial(out)
+	cookies := parseCookieHeader(in.Cookie)
+	if len(cookies) == 0 {
+		return errors.New("challenge command output contained no cookies")
+	}
+	if c.jar != nil {
+		c.jar.SetCookies(c.baseURLParsed, cookies)
+	}
+	c.cookie = strings.TrimSpace(c.exportCookieHeader())
+	if in.UserAgent != "" {
+		c.userAgent = in.UserAgent
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpointFileName is where an interrupted solve loop records how far it
+// got, inside the runtime home.
+const checkpointFileName = "checkpoint.json"
+
+// checkpoint is the state of a solve loop stopped by SIGINT/SIGTERM. Auto
+// mode and scheduled passes also write one after every puzzle, so a run
+// killed outright (OOM, reboot) can be resumed from its last puzzle.
+type checkpoint struct {
+	SavedAt time.Time `json:"savedAt"`
+	// Phase is the loop phase at the interruption; "solving" or
+	// "submitting" with a PuzzleID means that puzzle was left unanswered
+	// or its submission outcome is unknown.
+	Phase          string `json:"phase"`
+	PuzzleID       string `json:"puzzleId,omitempty"`
+	Solved         int    `json:"solved"`
+	Target         int    `json:"target"`
+	DailyRemaining int    `json:"dailyRemaining"`
+	DailyLimit     int    `json:"dailyLimit"`
+	DryRun         bool   `json:"dryRun"`
+	Auto           bool   `json:"auto"`
+	// Puzzle is the puzzle that was open (fetched but not yet answered) at
+	// the interruption, kept so --resume can answer it without fetching a
+	// new one.
+	Puzzle            *puzzle `json:"puzzle,omitempty"`
+	RemainingAttempts int     `json:"remainingAttempts,omitempty"`
+	// NextAt is when a paced run was due to fetch its next puzzle, so a
+	// resumed run keeps the spacing between puzzles.
+	NextAt *time.Time `json:"nextAt,omitempty"`
+	// ScheduledAt is the firing of the scheduled pass in progress; a
+	// resumed run finishes that pass (Target-Solved puzzles) before waiting
+	// for the next one.
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+}
+
+func checkpointPath(home string) string {
+	return filepath.Join(home, checkpointFileName)
+}
+
+// newCheckpoint captures the live status of a run, the puzzle it had open,
+// if any, and when it was due to fetch the next one.
+func newCheckpoint(snap statusSnapshot, o onlineRun, open *puzzleNewResponse, nextAt time.Time) checkpoint {
+	cp := checkpoint{
+		SavedAt:        time.Now(),
+		Phase:          snap.Phase,
+		PuzzleID:       snap.PuzzleID,
+		Solved:         snap.Solved,
+		Target:         snap.Target,
+		DailyRemaining: snap.DailyRemaining,
+		DailyLimit:     snap.DailyLimit,
+		DryRun:         o.dryRun,
+		Auto:           o.autoLoop,
+	}
+	if !nextAt.IsZero() {
+		cp.NextAt = &nextAt
+	}
+	if !o.scheduledAt.IsZero() {
+		cp.ScheduledAt = &o.scheduledAt
+	}
+	if open != nil {
+		p := open.Puzzle
+		cp.Puzzle = &p
+		cp.RemainingAttempts = open.RemainingAttempts
+	}
+	return cp
+}
+
+// loadCheckpoint reads the checkpoint at path.
+func loadCheckpoint(path string) (checkpoint, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint{}, fmt.Errorf("no checkpoint to resume (%s not found)", path)
+	}
+	if err != nil {
+		return checkpoint{}, fmt.Errorf("read checkpoint: %w", err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return checkpoint{}, fmt.Errorf("parse checkpoint %s: %w", path, err)
+	}
+	return cp, nil
+}
+
+// resumeRun applies cp to o: the interrupted run's mode, its place in the
+// pacing and the schedule, and the puzzles it still had to solve, starting
+// with its open puzzle if attempts remain.
+func resumeRun(o onlineRun, cp checkpoint, log *logger) onlineRun {
+	o.dryRun = cp.DryRun
+	// Scheduled passes run under --auto.
+	o.autoLoop = cp.Auto || cp.ScheduledAt != nil
+	o.count = max(cp.Target-cp.Solved, 1)
+	if cp.NextAt != nil {
+		o.notBefore = *cp.NextAt
+	}
+	if cp.ScheduledAt != nil {
+		o.scheduledAt = *cp.ScheduledAt
+	}
+	if cp.Puzzle != nil && cp.RemainingAttempts > 0 {
+		o.resume = &puzzleNewResponse{
+			Puzzle:            *cp.Puzzle,
+			RemainingAttempts: cp.RemainingAttempts,
+			DailyRemaining:    cp.DailyRemaining,
+			DailyLimit:        cp.DailyLimit,
+		}
+	} else if cp.Puzzle != nil {
+		log.warnf("resume: puzzle %s has no attempts left, fetching a new one", cp.Puzzle.ID)
+	}
+	log.infof("resuming run interrupted at %s: solved %d/%d, dryRun=%v auto=%v", cp.SavedAt.Format(time.DateTime), cp.Solved, cp.Target, o.dryRun, o.autoLoop)
+	return o
+}
+
+// saveCheckpoint writes cp to path, replacing any previous checkpoint.
+func saveCheckpoint(path string, cp checkpoint) error {
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal checkpoint: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("mkdir checkpoint dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write temp checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace checkpoint: %w", err)
+	}
+	return nil
+}
+
+// clearCheckpoint removes a checkpoint left by an earlier interrupted run.
+func clearCheckpoint(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove checkpoint: %w", err)
+	}
+	return nil
+}
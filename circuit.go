@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+)
+
+// Circuit breaker defaults, used for unset breakerConfig fields.
+const (
+	defaultBreakerThreshold = 3
+	defaultBreakerCooldown  = 5 * time.Minute
+)
+
+// errAICircuitOpen is returned instead of sending a request while the
+// breaker is open.
+var errAICircuitOpen = errors.New("AI circuit open")
+
+// circuitBreaker stops AI requests after threshold consecutive failures
+// until cooldown has passed. The first request after the cooldown is a
+// probe: success closes the circuit, failure opens it again. A nil
+// *circuitBreaker never trips.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(cfg breakerConfig) *circuitBreaker {
+	b := &circuitBreaker{threshold: defaultBreakerThreshold, cooldown: defaultBreakerCooldown}
+	if cfg.Threshold > 0 {
+		b.threshold = cfg.Threshold
+	}
+	if cfg.CooldownSeconds > 0 {
+		b.cooldown = time.Duration(cfg.CooldownSeconds) * time.Second
+	}
+	return b
+}
+
+// allow returns errAICircuitOpen while the circuit is open.
+func (b *circuitBreaker) allow() error {
+	if until := b.openUntil(); !until.IsZero() && time.Now().Before(until) {
+		return fmt.Errorf("%w until %s", errAICircuitOpen, until.Format(time.TimeOnly))
+	}
+	return nil
+}
+
+// record counts the outcome of one request made under ctx and reports
+// whether it opened the circuit. Only an endpoint failing counts against
+// it (see endpointFailure); other errors, and any once the caller cancelled
+// ctx or its deadline passed, are ignored.
+func (b *circuitBreaker) record(ctx context.Context, err error) bool {
+	if b == nil || ctx.Err() != nil || (err != nil && !endpointFailure(err)) {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return false
+	}
+	b.failures++
+	if b.failures < b.threshold {
+		return false
+	}
+	b.openedAt = time.Now()
+	return true
+}
+
+// endpointFailure reports whether err means the AI endpoint is failing: a
+// server error, rate limiting, or no complete response at all (a transport
+// error or request timeout). A 4xx refusal of the request is not.
+func endpointFailure(err error) bool {
+	var apiErr *openai.Error
+	var beErr *backendStatusError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
+	case errors.As(err, &beErr):
+		return beErr.StatusCode >= 500 || beErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// openUntil returns when an open circuit lets the next probe through, or
+// the zero time if it is closed.
+func (b *circuitBreaker) openUntil() time.Time {
+	if b == nil {
+		return time.Time{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return time.Time{}
+	}
+	return b.openedAt.Add(b.cooldown)
+}
+
+// consecutiveFailures returns the current failure streak.
+func (b *circuitBreaker) consecutiveFailures() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errLowConfidence marks an answer held back because its confidence is
+// below --min-confidence (ai.min_confidence).
+var errLowConfidence = errors.New("answer confidence below the minimum")
+
+// confidenceGate wraps solve so that answers reporting less than min
+// percent confidence are refused with errLowConfidence. With again set, as
+// in auto mode, a low-confidence answer is solved once more first, since a
+// second sample often differs, and refused only if that one is low too.
+// The refused result is still returned so its answer is recorded.
+func confidenceGate(solve func(context.Context, puzzle) (solveResult, error), min int, again bool) func(context.Context, puzzle) (solveResult, error) {
+	return func(ctx context.Context, p puzzle) (solveResult, error) {
+		res, err := solve(ctx, p)
+		if err != nil || res.Confidence >= min {
+			return res, err
+		}
+		if again {
+			fmt.Fprintf(uiOut, "%s📉 Confidence %d%% is below the minimum %d%%, solving again%s\n", colorYellow, res.Confidence, min, colorReset)
+			res, err = solve(ctx, p)
+		}
+		return checkConfidence(res, err, min)
+	}
+}
+
+// checkConfidence passes a solve result through unless it succeeded below
+// min percent confidence, which becomes errLowConfidence.
+func checkConfidence(res solveResult, err error, min int) (solveResult, error) {
+	if err != nil || res.Confidence >= min {
+		return res, err
+	}
+	return res, fmt.Errorf("%w: %d%% < %d%%", errLowConfidence, res.Confidence, min)
+}
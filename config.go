@@ -1,12 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	koanfjson "github.com/knadh/koanf/parsers/json"
 	"github.com/knadh/koanf/providers/file"
@@ -17,6 +19,9 @@ import (
 const (
 	defaultUA      = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
 	defaultAIModel = "claude-sonnet-4-5-20250929"
+	// defaultGeminiModel replaces defaultAIModel for ai.provider "gemini"
+	// (see defaultModel).
+	defaultGeminiModel = "gemini-2.5-pro"
 )
 
 // aiConfig holds AI solver configuration.
@@ -25,14 +30,443 @@ type aiConfig struct {
 	Model   string `json:"model,omitempty"`
 	BaseURL string `json:"base_url,omitempty"`
 	APIKey  string `json:"api_key,omitempty"`
+
+	// Provider is "openai" (default) for OpenAI-compatible chat completions,
+	// "anthropic" for Anthropic's Messages API, where the answer schema is
+	// enforced through tool use, or "gemini" for the Generative Language
+	// API's response schema. The API key falls back to OPENAI_API_KEY,
+	// ANTHROPIC_API_KEY or GEMINI_API_KEY accordingly.
+	Provider string `json:"provider,omitempty"`
+
+	// Models, when it lists more than one model, enables ensemble solving:
+	// all are queried concurrently and the answers combined by Vote
+	// ("exact" majority of whole grids, "cell" per-cell majority, or
+	// "weighted" whole-grid votes weighed by historical accuracy).
+	// Model remains the verifier.
+	Models []string `json:"models,omitempty"`
+	Vote   string   `json:"vote,omitempty"`
+
+	// Samples, when above 1, draws that many answers from Model at
+	// SampleTemperature (default 1.0), verifies each and keeps the one with
+	// the most verifier approvals, then the highest confidence.
+	Samples           int     `json:"samples,omitempty"`
+	SampleTemperature float64 `json:"sample_temperature,omitempty"`
+
+	// MaxRefinements is how many times an answer self-verification rejects
+	// is sent back to the model with the verifier's reasoning for a
+	// corrected one; 0 fails the attempt at the first rejection.
+	MaxRefinements int `json:"max_refinements,omitempty"`
+
+	// Presolve tries deterministic rules (flips, rotations, transposes,
+	// recolors, scaling, tiling) before the AI and answers locally when one
+	// explains every training pair (default true).
+	Presolve *bool `json:"presolve,omitempty"`
+
+	// Solver selects what the model writes: "grid" (default) answers with
+	// the output grid, "program" with a program in a small grid DSL that
+	// is checked against the training pairs and then run on the test input.
+	Solver string `json:"solver,omitempty"`
+
+	// SelfTest, after self-verification, gives the model only the answer's
+	// stated rule and each training input, and rejects the answer unless
+	// the rule reproduces every training output. It costs one request per
+	// training pair.
+	SelfTest bool `json:"self_test,omitempty"`
+
+	// ConsistencyCheck solves the puzzle a second time with the training
+	// pairs reordered and rejects the answer unless both agree.
+	ConsistencyCheck bool `json:"consistency_check,omitempty"`
+
+	// MaxResubmits is how many more times a puzzle the site graded
+	// incorrect is solved again, by the next model or at a higher
+	// temperature, and resubmitted while it has attempts left; 0 moves on
+	// after the first incorrect answer.
+	MaxResubmits int `json:"max_resubmits,omitempty"`
+
+	// DegradedMode keeps --auto and scheduled runs going while the AI is
+	// unavailable: puzzles the pre-solver's deterministic rules explain are
+	// still answered, and the rest are queued until the AI is back.
+	DegradedMode bool `json:"degraded_mode,omitempty"`
+
+	// Verifiers, when set, replace the single self-verification by Model
+	// with a vote: every listed model verifies the answer and it passes
+	// when at least VerifyQuorum of them accept it (default: a majority).
+	Verifiers    []string `json:"verifiers,omitempty"`
+	VerifyQuorum int      `json:"verify_quorum,omitempty"`
+
+	// MinConfidence is the least confidence (0-100) an answer must report
+	// to be submitted; 0 submits every answer. --min-confidence overrides
+	// it.
+	MinConfidence int `json:"min_confidence,omitempty"`
+
+	// StrictDimensions (default true) fails an answer whose size still
+	// contradicts the hint after one re-solve, instead of submitting it
+	// with a warning. RepairDimensions first crops or pads answers that
+	// are off by one row or column.
+	StrictDimensions *bool `json:"strict_dimensions,omitempty"`
+	RepairDimensions bool  `json:"repair_dimensions,omitempty"`
+
+	// RaggedRows handles answer grids whose rows differ in length: "pad"
+	// (default) pads short rows with the hinted background color, "reject"
+	// asks the model once more and then fails the answer.
+	RaggedRows string `json:"ragged_rows,omitempty"`
+
+	// Repair post-processes every answer grid before it is checked and
+	// submitted; each step is off by default.
+	Repair repairConfig `json:"repair,omitempty"`
+
+	// FallbackModels are tried in order when Model is unavailable, returns
+	// unparseable output, or answers with the wrong grid size.
+	FallbackModels []string `json:"fallback_models,omitempty"`
+
+	// MaxConcurrentRequests caps in-flight AI requests (solve and verify
+	// alike) to stay within the provider's concurrency tier; 0 = no cap.
+	MaxConcurrentRequests int `json:"max_concurrent_requests,omitempty"`
+
+	// Mode is "text" (default), sending grids as JSON, or "vision", which
+	// also sends each training pair and the test input as a rendered image
+	// for multimodal models.
+	Mode string `json:"mode,omitempty"`
+
+	// PromptCache selects how the shared prompt prefix is marked for the
+	// provider's cache: "openai" sends a per-puzzle prompt_cache_key,
+	// "anthropic" adds cache_control breakpoints; empty relies on
+	// automatic prefix caching alone.
+	PromptCache string `json:"prompt_cache,omitempty"`
+
+	// AnswerAdapters maps model names ("*" for the rest) to the adapters
+	// that coerce answers returned in known non-schema formats, such as
+	// rows as strings, before the generic grid salvage; unset tries them
+	// all, an empty list none.
+	AnswerAdapters map[string][]string `json:"answer_adapters,omitempty"`
+
+	// StructuredOutput selects how OpenAI-compatible servers are held to the
+	// answer schema: "schema" (response_format json_schema), "tool" (a
+	// forced function call), "json" (plain JSON, repaired when parsed), or
+	// "auto" (default), which starts with "schema" and moves down the list
+	// when a server rejects one.
+	StructuredOutput string `json:"structured_output,omitempty"`
+
+	// Proxy overrides the top-level proxy for AI requests only.
+	Proxy string `json:"proxy,omitempty"`
+
+	// Prices maps model names to their per-token prices, used to estimate
+	// the cost of a run. Models without an entry are counted but not priced.
+	Prices map[string]modelPrice `json:"prices,omitempty"`
+	// MaxCost (USD, estimated from Prices) and MaxTokens (prompt plus
+	// completion) end a run once its AI spend reaches them; 0 = no limit.
+	// The --max-cost and --max-tokens flags override them.
+	MaxCost   float64 `json:"max_cost,omitempty"`
+	MaxTokens int64   `json:"max_tokens,omitempty"`
+
+	// Economy cuts back ensembles, sampling and verification while the
+	// budget runs low.
+	Economy economyConfig `json:"economy,omitempty"`
+
+	// GridEncoding writes the puzzle's grids into the prompt as "json"
+	// (default, indented JSON), "dense" digit rows, "rle" run-length
+	// encoded rows or "chars" rows of one letter per color.
+	GridEncoding string `json:"grid_encoding,omitempty"`
+
+	// MaxContextTokens is the model's context window. A prompt estimated
+	// not to fit with room for the reply is compacted (dense grids, fewer
+	// training pairs) or fails before it is sent; 0 disables the guard.
+	MaxContextTokens int `json:"max_context_tokens,omitempty"`
+
+	// Preprocess adds locally computed features of the grids to the
+	// prompt.
+	Preprocess preprocessConfig `json:"preprocess,omitempty"`
+
+	// CircuitBreaker stops AI requests for a cool-down after repeated
+	// consecutive failures.
+	CircuitBreaker breakerConfig `json:"circuit_breaker,omitempty"`
+}
+
+// modelPrice is what a model costs in USD per million tokens.
+type modelPrice struct {
+	Input float64 `json:"input"`
+	// CachedInput prices prompt tokens served from the provider's cache;
+	// unset bills them at Input.
+	CachedInput *float64 `json:"cached_input,omitempty"`
+	Output      float64  `json:"output"`
+}
+
+// breakerConfig tunes the AI circuit breaker. Unset fields keep their
+// defaults.
+type breakerConfig struct {
+	// Threshold is the number of consecutive failed AI requests that opens
+	// the circuit (default 3).
+	Threshold int `json:"threshold,omitempty"`
+	// CooldownSeconds is how long the circuit stays open before a probe
+	// request is let through (default 300).
+	CooldownSeconds int `json:"cooldown_seconds,omitempty"`
+}
+
+// economyConfig is the budget-aware policy of a run with a budget
+// (max_cost, max_tokens or the matching flags). Economy mode starts once
+// at most Below of the budget is left, or when the spend per puzzle so far
+// would use the rest up before --count puzzles are solved.
+type economyConfig struct {
+	// Below is the share of the budget (0-1) left that starts economy
+	// mode; 0 disables it.
+	Below float64 `json:"below,omitempty"`
+	// Samples caps best-of-N sampling in economy mode.
+	Samples int `json:"samples,omitempty"`
+	// SingleModel answers with ai.model alone instead of the ensemble.
+	SingleModel bool `json:"single_model,omitempty"`
+	// SkipVerify skips self-verification, the training self-test and the
+	// consistency check.
+	SkipVerify bool `json:"skip_verify,omitempty"`
+}
+
+// repairConfig toggles the answer repair steps, which run in field order.
+type repairConfig struct {
+	// TrimPadding drops uniform outer rows and columns from an answer
+	// larger than the hinted size.
+	TrimPadding bool `json:"trim_padding,omitempty"`
+	// Background recolors an answer's most common color to the hinted
+	// background when the answer lacks the background color but every
+	// training output is mostly background.
+	Background bool `json:"background,omitempty"`
+	// FitSize truncates or pads, with background, an answer that still
+	// does not have the hinted size.
+	FitSize bool `json:"fit_size,omitempty"`
+}
+
+// preprocessConfig selects the grid features computed locally and added to
+// the puzzle prompt.
+type preprocessConfig struct {
+	// Objects lists each grid's connected single-color objects with their
+	// colors, sizes and bounding boxes.
+	Objects bool `json:"objects,omitempty"`
+	// Symmetry states each grid's mirror and rotational symmetries, exact
+	// or broken only by one color, and its row and column periods.
+	Symmetry bool `json:"symmetry,omitempty"`
+	// Diff maps the cells each same-size training pair changes.
+	Diff bool `json:"diff,omitempty"`
+}
+
+// challengeConfig configures the external anti-bot challenge solver.
+type challengeConfig struct {
+	Command        []string `json:"command,omitempty"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+}
+
+// powConfig holds Proof-of-Work solver configuration.
+type powConfig struct {
+	// Workers is the number of goroutines searching for a nonce;
+	// 0 uses GOMAXPROCS.
+	Workers int `json:"workers,omitempty"`
+	// WarnSeconds is the expected PoW time, estimated from a short hash
+	// rate benchmark, above which a warning is logged (default 60).
+	WarnSeconds int `json:"warn_seconds,omitempty"`
+}
+
+// retryConfig tunes how failed API calls are retried. Unset fields keep
+// their defaults.
+type retryConfig struct {
+	// MaxAttempts counts the first try (default 6).
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// BaseDelayMS is the first backoff, doubled per retry up to MaxDelayMS
+	// (defaults 2000 and 30000).
+	BaseDelayMS int `json:"base_delay_ms,omitempty"`
+	MaxDelayMS  int `json:"max_delay_ms,omitempty"`
+	// Jitter spreads each delay by up to ±this fraction (default 0.2).
+	Jitter *float64 `json:"jitter,omitempty"`
+	// StatusCodes are the retryable HTTP statuses (default 429, 500, 502,
+	// 503).
+	StatusCodes []int `json:"status_codes,omitempty"`
+	// NetworkErrors retries connection failures and timeouts (default
+	// true).
+	NetworkErrors *bool `json:"network_errors,omitempty"`
+	// Post also retries POST requests on 5xx and network errors, at the
+	// risk of repeating a submission the server did process.
+	Post bool `json:"post,omitempty"`
+}
+
+// thinkConfig bounds the randomized minimum time between fetching a puzzle
+// and submitting its answer.
+type thinkConfig struct {
+	// MinSeconds enables the floor; each puzzle draws its thinking time
+	// uniformly from [MinSeconds, MaxSeconds). MaxSeconds defaults to twice
+	// MinSeconds.
+	MinSeconds int `json:"min_seconds,omitempty"`
+	MaxSeconds int `json:"max_seconds,omitempty"`
+}
+
+// canaryConfig gates --auto on a recent successful solve --canary.
+type canaryConfig struct {
+	// MaxAgeHours, when set, makes --auto refuse to start unless a canary
+	// run succeeded within that many hours.
+	MaxAgeHours int `json:"max_age_hours,omitempty"`
+}
+
+// historyConfig selects how the run history is kept.
+type historyConfig struct {
+	// Store is "sqlite" (default) for a history.db database, "jsonl" for
+	// history.jsonl, an append-only file of one JSON record per line that
+	// needs no database tools to read, sync or merge, or "postgres" for a
+	// Postgres database shared by several machines.
+	Store string `json:"store,omitempty"`
+	// DSN is the postgres:// connection URL of the "postgres" store.
+	DSN string `json:"dsn,omitempty"`
+}
+
+// daemonConfig schedules the daemon command.
+type daemonConfig struct {
+	// ActiveHours lists local "HH:MM-HH:MM" windows in which puzzles are
+	// solved; a window whose end is before its start runs past midnight.
+	// Empty means any time of day.
+	ActiveHours []string `json:"active_hours,omitempty"`
+	// QuotaReset is the local "HH:MM" at which the daily quota resets
+	// (default "00:00").
+	QuotaReset string `json:"quota_reset,omitempty"`
+	// RetryMinutes is the first backoff after a failed run; it doubles up
+	// to an hour while failures continue (default 5).
+	RetryMinutes int `json:"retry_minutes,omitempty"`
+	// PollMinMinutes and PollMaxMinutes bound the interval at which
+	// /api/daily/remaining is polled while the quota is used up; it is
+	// longest far from quota_reset (defaults 1 and 60).
+	PollMinMinutes int `json:"poll_min_minutes,omitempty"`
+	PollMaxMinutes int `json:"poll_max_minutes,omitempty"`
+}
+
+// scheduleEntry solves Count puzzles whenever the five-field cron
+// expression Cron fires (local time).
+type scheduleEntry struct {
+	Cron  string `json:"cron"`
+	Count int    `json:"count"`
+}
+
+// notifyConfig configures run notifications.
+type notifyConfig struct {
+	// WebhookURL receives a POST for every puzzle outcome.
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// Format shapes the webhook body: "json" (default) posts the event
+	// itself, "discord" posts a Discord-compatible {"content": ...} message.
+	Format string `json:"format,omitempty"`
+	// Artifacts writes a rendered puzzle image, the answer grid and the
+	// reasoning transcript per puzzle and references them in the event.
+	Artifacts bool `json:"artifacts,omitempty"`
+	// ArtifactBaseURL, when the runtime home is served over HTTP, turns
+	// artifact paths (relative to the home) into links.
+	ArtifactBaseURL string `json:"artifact_base_url,omitempty"`
+	// Throttle rate-limits and batches the webhook's puzzle events;
+	// auth_required is always sent at once.
+	Throttle throttleConfig `json:"throttle,omitempty"`
+	// Telegram sends login problems and run summaries to a Telegram chat.
+	Telegram telegramConfig `json:"telegram,omitempty"`
+}
+
+// telegramConfig names the bot and chat Telegram notifications go to.
+type telegramConfig struct {
+	// Token is the bot token from @BotFather.
+	Token string `json:"token,omitempty"`
+	// ChatID is the chat, group or @channel the bot writes to.
+	ChatID string `json:"chat_id,omitempty"`
+	// Throttle rate-limits and batches the chat's messages like the
+	// webhook's.
+	Throttle throttleConfig `json:"throttle,omitempty"`
+}
+
+func (t telegramConfig) configured() bool {
+	return strings.TrimSpace(t.Token) != "" && strings.TrimSpace(t.ChatID) != ""
+}
+
+// throttleConfig limits how often a notification channel is messaged.
+type throttleConfig struct {
+	// DigestMinutes collects puzzle events and sends them as one digest
+	// this often and when the run ends; 0 sends each as it happens.
+	DigestMinutes int `json:"digest_minutes,omitempty"`
+	// MaxPerHour caps the messages sent in any hour; events over the cap
+	// wait for the next digest. 0 means no cap.
+	MaxPerHour int `json:"max_per_hour,omitempty"`
+}
+
+// reviewConfig holds answers for human approval before they are submitted.
+type reviewConfig struct {
+	// WebhookURL receives a POST with each answer; a JSON response of
+	// {"decision": "approve"|"reject"} decides at once.
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// Listen serves approve/reject callback links (host:port).
+	Listen string `json:"listen,omitempty"`
+	// CallbackBaseURL is how the reviewer reaches Listen, e.g. through a
+	// reverse proxy; defaults to http://<listen>.
+	CallbackBaseURL string `json:"callback_base_url,omitempty"`
+	// PollURL is polled for the decision instead of or beside callbacks;
+	// "{id}" is replaced with the review ID.
+	PollURL             string `json:"poll_url,omitempty"`
+	PollIntervalSeconds int    `json:"poll_interval_seconds,omitempty"`
+	// TimeoutSeconds bounds the wait (default 600), after which OnTimeout
+	// ("reject", the default, or "approve") applies.
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+	OnTimeout      string `json:"on_timeout,omitempty"`
 }
 
 // appConfig holds the application configuration.
 type appConfig struct {
-	BaseURL   string   `json:"base_url"`
-	Cookie    string   `json:"cookie"`
-	UserAgent string   `json:"user_agent"`
-	AI        aiConfig `json:"ai,omitempty"`
+	BaseURL   string          `json:"base_url"`
+	Cookie    string          `json:"cookie"`
+	UserAgent string          `json:"user_agent"`
+	AI        aiConfig        `json:"ai,omitempty"`
+	Challenge challengeConfig `json:"challenge,omitempty"`
+	Pow       powConfig       `json:"pow,omitempty"`
+
+	// RequestSpacingMS is the minimum delay between consecutive API calls,
+	// keeping bursts (notably at startup) under the server's rate limiter.
+	RequestSpacingMS int `json:"request_spacing_ms,omitempty"`
+
+	// ThinkTime holds back answers that are ready too soon after the puzzle
+	// was fetched, so submission times look like a person's.
+	ThinkTime thinkConfig `json:"think_time,omitempty"`
+
+	// SkipQuotaCheck disables /api/daily/remaining for servers where it is
+	// unreliable or missing; the quota is then read from puzzle responses.
+	SkipQuotaCheck bool `json:"skip_quota_check,omitempty"`
+
+	// SessionCookie names the cookie that carries the login session; a
+	// warning is logged when a response clears it.
+	SessionCookie string `json:"session_cookie,omitempty"`
+	// DropCookies lists cookie name patterns (path.Match syntax) that are
+	// pruned from the jar and never persisted. Defaults to common analytics
+	// cookies when unset.
+	DropCookies []string `json:"drop_cookies,omitempty"`
+
+	// TLSPin controls trust-on-first-use certificate pinning: "warn"
+	// (default) logs loudly when the site's certificate changes, "refuse"
+	// aborts the TLS handshake instead, "off" disables pinning.
+	TLSPin string `json:"tls_pin,omitempty"`
+
+	// Proxy routes puzzle API, AI and webhook traffic through an http(s) or
+	// socks5(h) proxy URL. Empty uses HTTPS_PROXY/HTTP_PROXY/NO_PROXY;
+	// "direct" ignores them.
+	Proxy string `json:"proxy,omitempty"`
+
+	// Retry configures retries of failed API calls.
+	Retry retryConfig `json:"retry,omitempty"`
+
+	// AnswerFormat is how answers are submitted, for forks of the puzzle
+	// API: "nested" (default) sends the [][]int grid, "flat" the cells row
+	// by row with "height" and "width", "rows" one digit string per row.
+	AnswerFormat string `json:"answer_format,omitempty"`
+
+	Notifications notifyConfig  `json:"notifications,omitempty"`
+	Review        reviewConfig  `json:"review,omitempty"`
+	Daemon        daemonConfig  `json:"daemon,omitempty"`
+	History       historyConfig `json:"history,omitempty"`
+	Canary        canaryConfig  `json:"canary,omitempty"`
+
+	// Schedule, when set, paces the daemon and --auto: instead of solving
+	// continuously, each entry's count of puzzles is solved when its cron
+	// expression fires, spreading the quota over the day.
+	Schedule []scheduleEntry `json:"schedule,omitempty"`
+
+	// home is the runtime directory derived from the config path; it is not
+	// part of the file.
+	home string
+	// seedCookie is the cookie as written in the file, before the saved
+	// login state replaced it.
+	seedCookie string
 }
 
 func defaultConfig() appConfig {
@@ -45,9 +479,33 @@ func defaultConfig() appConfig {
 	}
 }
 
-// loadConfig loads configuration from the specified path.
+// loadConfig loads configuration from the specified path together with the
+// saved login state, for the commands talking to the puzzle site. A missing
+// file yields the defaults; the login prompt then takes base_url from the
+// pasted curl command.
 func loadConfig(path string) (appConfig, error) {
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		return appConfig{}, err
+	}
+	cfg.seedCookie = cfg.Cookie
+	st, err := loadLoginState(statePath(cfg.home))
+	if err != nil {
+		return appConfig{}, err
+	}
+	applyLoginState(&cfg, st)
+	return cfg, nil
+}
+
+// loadConfigFile loads configuration from path without requiring the site
+// settings, for commands that only use the AI solver. A missing file yields
+// the defaults.
+func loadConfigFile(path string) (appConfig, error) {
 	cfg := defaultConfig()
+	cfg.home = homeDir(path)
+	if path == "" {
+		return cfg, nil
+	}
 
 	if _, err := os.Stat(path); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -63,43 +521,112 @@ func loadConfig(path string) (appConfig, error) {
 	if err := k.UnmarshalWithConf("", &cfg, koanf.UnmarshalConf{Tag: "json"}); err != nil {
 		return appConfig{}, fmt.Errorf("unmarshal config: %w", err)
 	}
+	cfg.home = homeDir(path)
 
 	cfg.Cookie = strings.TrimSpace(cfg.Cookie)
 	cfg.BaseURL = strings.TrimSpace(cfg.BaseURL)
-	if cfg.BaseURL == "" {
-		return appConfig{}, errors.New("base_url is required in config")
-	}
 	if cfg.UserAgent == "" {
 		cfg.UserAgent = defaultUA
 	}
-	if strings.TrimSpace(cfg.AI.Model) == "" {
-		cfg.AI.Model = defaultAIModel
+	if strings.TrimSpace(cfg.AI.Model) == "" || !k.Exists("ai.model") {
+		cfg.AI.Model = defaultModel(cfg.AI.Provider)
 	}
 	return cfg, nil
 }
 
-// saveConfig writes configuration to the specified path.
-func saveConfig(path string, cfg appConfig) error {
-	if cfg.UserAgent == "" {
-		cfg.UserAgent = defaultUA
+// homeDir returns the state directory holding everything the solver writes
+// (login state, history, pins, runs, the pause sentinel): ERGO_PROXY_HOME
+// when set, otherwise the config file's directory.
+func homeDir(configPath string) string {
+	if h := strings.TrimSpace(os.Getenv("ERGO_PROXY_HOME")); h != "" {
+		return h
 	}
+	return filepath.Dir(configPath)
+}
 
-	b, err := json.MarshalIndent(cfg, "", "  ")
+// updateConfigFile sets the given keys (dotted paths such as "ai.base_url")
+// in the config file at path, keeping every other setting. The previous
+// file is first saved by backupFile, whose path is returned. Keys are
+// written back in sorted order.
+func updateConfigFile(path string, values map[string]any) (backup string, err error) {
+	b, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("marshal config: %w", err)
+		return "", fmt.Errorf("read config: %w", err)
 	}
-	b = append(b, '\n')
-
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return fmt.Errorf("mkdir config dir: %w", err)
+	doc := map[string]any{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return "", fmt.Errorf("parse config: %w", err)
+	}
+	for key, v := range values {
+		m := doc
+		parts := strings.Split(key, ".")
+		for _, p := range parts[:len(parts)-1] {
+			child, ok := m[p].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				m[p] = child
+			}
+			m = child
+		}
+		m[parts[len(parts)-1]] = v
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal config: %w", err)
 	}
 
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("stat config: %w", err)
+	}
+	if backup, err = backupFile(path); err != nil {
+		return "", err
+	}
 	tmp := path + ".tmp"
-	if err := os.WriteFile(tmp, b, 0o600); err != nil {
-		return fmt.Errorf("write temp config: %w", err)
+	if err := os.WriteFile(tmp, append(out, '\n'), fi.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("write temp config: %w", err)
 	}
 	if err := os.Rename(tmp, path); err != nil {
-		return fmt.Errorf("replace config: %w", err)
+		return "", fmt.Errorf("replace config: %w", err)
+	}
+	return backup, nil
+}
+
+// backupFile copies the file at path next to it as PATH.<timestamp>.bak with
+// the same permissions and returns the copy's path. An existing backup is
+// never overwritten; a second one within the same second gets a numbered
+// name.
+func backupFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("back up %s: %w", path, err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("back up %s: %w", path, err)
+	}
+	stem := path + "." + time.Now().Format("20060102-150405")
+	for n := 0; ; n++ {
+		backup := stem + ".bak"
+		if n > 0 {
+			backup = fmt.Sprintf("%s-%d.bak", stem, n)
+		}
+		f, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("back up %s: %w", path, err)
+		}
+		_, err = f.Write(b)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return "", fmt.Errorf("back up %s: %w", path, err)
+		}
+		return backup, nil
 	}
-	return nil
 }
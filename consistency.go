@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// consistencyCheck solves p again with its training pairs in reverse order
+// and returns an error unless model gives the same answer. The rule does not
+// depend on the order of the pairs, so a different answer means the first
+// one leaned on how the puzzle was presented. Puzzles with fewer than two
+// pairs are not checked; a failed request is logged and the answer kept.
+func (s *Solver) consistencyCheck(ctx context.Context, p puzzle, model string, answer [][]int) error {
+	log := s.log.forContext(ctx)
+	if len(p.Train) < 2 {
+		return nil
+	}
+	q := p
+	q.Train = slices.Clone(p.Train)
+	slices.Reverse(q.Train)
+
+	spin := newSpinner()
+	spin.Start("🔀 Re-solving with the training pairs reordered...")
+	got, err := s.askAnswer(ctx, q, model, solveQuery(q))
+	spin.Stop()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err != nil {
+		log.warnf("consistency check error: %v", err)
+		return nil
+	}
+	if !gridsEqual(got.Answer, answer) {
+		return fmt.Errorf("consistency check failed: reordering the training pairs changed the answer (%s)", gridDiff(got.Answer, answer))
+	}
+	fmt.Fprintf(uiOut, "%s✅ Consistency check passed: same answer with the pairs reordered%s\n", colorGreen, colorReset)
+	return nil
+}
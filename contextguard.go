@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Prompt size estimates for ai.max_context_tokens. Tokens are estimated as
+// one per three bytes, which errs on the large side for English and JSON
+// grids alike; each image counts as imageTokens.
+const (
+	bytesPerToken = 3
+	imageTokens   = 1000
+	// replyReserve is the room kept for the reply besides its answer grid,
+	// which is reserved at three tokens per cell.
+	replyReserve = 2048
+)
+
+// promptTokens estimates the input tokens of prompt.
+func promptTokens(prompt chatPrompt) int {
+	n := len(prompt.system) + len(prompt.puzzle) + len(prompt.query)
+	for _, img := range prompt.images {
+		n += len(img.caption)
+	}
+	return (n+bytesPerToken-1)/bytesPerToken + imageTokens*len(prompt.images)
+}
+
+// fitContext makes prompt, built by build from p, fit ai.max_context_tokens
+// with room for the reply: it switches to the dense grid encoding, then
+// drops the least informative training pairs down to one, and fails if the
+// prompt is still too large. Only the prompt is compacted; local checks
+// still see every pair.
+func (s *Solver) fitContext(p puzzle, prompt chatPrompt, build func(puzzle, string) (chatPrompt, error)) (chatPrompt, error) {
+	limit := s.cfg.MaxContextTokens
+	if limit <= 0 {
+		return prompt, nil
+	}
+	limit -= replyReserve + 3*p.Hints.AnswerSize.Height*p.Hints.AnswerSize.Width
+	est := promptTokens(prompt)
+	if est <= limit {
+		return prompt, nil
+	}
+
+	encoding := s.cfg.GridEncoding
+	if encoding == "" || encoding == gridEncodingJSON {
+		encoding = gridEncodingDense
+		next, err := build(p, encoding)
+		if err != nil {
+			return chatPrompt{}, err
+		}
+		s.log.warnf("context guard: prompt of ~%d tokens exceeds ai.max_context_tokens, switching to the dense grid encoding (~%d tokens)", est, promptTokens(next))
+		prompt, est = next, promptTokens(next)
+	}
+	q := p
+	q.Train = slices.Clone(p.Train)
+	for est > limit && len(q.Train) > 1 {
+		i := leastInformativePair(q.Train)
+		q.Train = slices.Delete(q.Train, i, i+1)
+		next, err := build(q, encoding)
+		if err != nil {
+			return chatPrompt{}, err
+		}
+		s.log.warnf("context guard: prompt of ~%d tokens still too large, leaving out a training pair (%d left, ~%d tokens)", est, len(q.Train), promptTokens(next))
+		prompt, est = next, promptTokens(next)
+	}
+	if est > limit {
+		return chatPrompt{}, fmt.Errorf("prompt needs ~%d tokens even compacted, more than ai.max_context_tokens %d leaves after reserving room for the reply", est, s.cfg.MaxContextTokens)
+	}
+	return prompt, nil
+}
+
+// leastInformativePair returns the index of the training pair whose loss
+// teaches the model least: the one showing the fewest colors and size
+// changes that no other pair shows, and among those the largest, which
+// saves the most tokens.
+func leastInformativePair(train []puzzleExample) int {
+	sizeChange := func(ex puzzleExample) [2]string { return [2]string{gridDims(ex.Input), gridDims(ex.Output)} }
+	best, bestScore, bestCells := 0, -1, 0
+	for i, ex := range train {
+		others := map[int]bool{}
+		var otherSizes [][2]string
+		for j, o := range train {
+			if j == i {
+				continue
+			}
+			for c := range colorSet(o.Input) {
+				others[c] = true
+			}
+			for c := range colorSet(o.Output) {
+				others[c] = true
+			}
+			otherSizes = append(otherSizes, sizeChange(o))
+		}
+		score := 0
+		seen := colorSet(ex.Input)
+		for c := range colorSet(ex.Output) {
+			seen[c] = true
+		}
+		for c := range seen {
+			if !others[c] {
+				score++
+			}
+		}
+		if !slices.Contains(otherSizes, sizeChange(ex)) {
+			score++
+		}
+		cells := len(ex.Input)*gridWidth(ex.Input) + len(ex.Output)*gridWidth(ex.Output)
+		if bestScore < 0 || score < bestScore || score == bestScore && cells > bestCells {
+			best, bestScore, bestCells = i, score, cells
+		}
+	}
+	return best
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// defaultDropCookies lists analytics/tracking cookie patterns that are never
+// needed by the API and only bloat the persisted cookie string.
+var defaultDropCookies = []string{
+	"_ga", "_ga_*", "_gid", "_gat*", "_gcl_*", "__utm*", "_fbp", "_clck", "_clsk",
+	"Hm_lvt_*", "Hm_lpvt_*", "_hj*", "mp_*", "ajs_*",
+}
+
+// cookieDropped reports whether name matches one of the drop patterns.
+func cookieDropped(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneCookies expires jar entries matching the client's drop patterns. The
+// standard jar already forgets cookies past their expiry, so this only has
+// to handle irrelevant ones.
+func (c *apiClient) pruneCookies() {
+	if c.jar == nil || c.baseURLParsed == nil {
+		return
+	}
+	var drop []*http.Cookie
+	for _, ck := range c.jar.Cookies(c.baseURLParsed) {
+		if cookieDropped(ck.Name, c.dropCookies) {
+			drop = append(drop, &http.Cookie{Name: ck.Name, Path: "/", MaxAge: -1})
+		}
+	}
+	if len(drop) > 0 {
+		c.jar.SetCookies(c.baseURLParsed, drop)
+	}
+}
+
+// checkSessionCookie warns once each time the configured session cookie
+// disappears from the jar, typically because the server cleared it.
+func (c *apiClient) checkSessionCookie(ctx context.Context) {
+	if c.sessionCookie == "" || c.jar == nil || c.baseURLParsed == nil {
+		return
+	}
+	present := false
+	for _, ck := range c.jar.Cookies(c.baseURLParsed) {
+		if ck.Name == c.sessionCookie && strings.TrimSpace(ck.Value) != "" {
+			present = true
+			break
+		}
+	}
+	if !present && !c.sessionMissing && c.log != nil {
+		c.log.forContext(ctx).warnf("session cookie %q missing after response; the session may have been revoked", c.sessionCookie)
+	}
+	c.sessionMissing = !present
+}
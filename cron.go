@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSpec is a parsed five-field cron expression (minute hour
+// day-of-month month day-of-week), evaluated in local time. Each field is a
+// bit set of the values it matches.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record unrestricted day fields: as in cron, when
+	// both day fields are restricted a day matching either one fires.
+	domStar, dowStar bool
+}
+
+// parseCron parses expressions such as "0 9 * * *", "30 8-18/2 * * 1-5" or
+// "0 9,20 * * *". Day-of-week 7 is accepted as Sunday.
+func parseCron(expr string) (cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSpec{}, fmt.Errorf("cron %q: want 5 fields (minute hour day month weekday), got %d", expr, len(fields))
+	}
+	var (
+		c   cronSpec
+		err error
+	)
+	bounds := []struct {
+		dst    *uint64
+		lo, hi int
+		name   string
+	}{
+		{&c.minute, 0, 59, "minute"},
+		{&c.hour, 0, 23, "hour"},
+		{&c.dom, 1, 31, "day of month"},
+		{&c.month, 1, 12, "month"},
+		{&c.dow, 0, 7, "day of week"},
+	}
+	for i, b := range bounds {
+		if *b.dst, err = parseCronField(fields[i], b.lo, b.hi); err != nil {
+			return cronSpec{}, fmt.Errorf("cron %q: %s: %w", expr, b.name, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*"
+	c.dowStar = fields[4] == "*"
+	return c, nil
+}
+
+// parseCronField parses a comma-separated list of "*", "N", "N-M", each
+// optionally followed by "/step".
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			n, err := strconv.Atoi(a)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", a)
+			}
+			from, to = n, n
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid value %q", b)
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (c cronSpec) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first matching minute strictly after t, or the zero time
+// if none occurs within five years (e.g. "0 0 31 2 *"). Times are matched
+// on the wall clock of t's location: a time skipped when the clocks go
+// forward fires as they jump, and one repeated when they go back fires only
+// the first time.
+func (c cronSpec) next(t time.Time) time.Time {
+	start := t.Truncate(time.Minute).Add(time.Minute)
+	y, m, d := start.Date()
+	// Days are stepped on a UTC calendar, free of DST, and each matching
+	// minute is placed on t's clock.
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	for limit := day.AddDate(5, 0, 0); day.Before(limit); day = day.AddDate(0, 0, 1) {
+		if c.month&(1<<int(day.Month())) == 0 || !c.dayMatches(day) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if c.hour&(1<<h) == 0 {
+				continue
+			}
+			for mi := 0; mi < 60; mi++ {
+				if c.minute&(1<<mi) == 0 {
+					continue
+				}
+				if at := wallTime(day, h, mi, t.Location()); !at.Before(start) {
+					return at
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// wallTime returns the instant loc's clock shows h:mi on day's date. When
+// the clocks skip that time it returns the instant they jump.
+func wallTime(day time.Time, h, mi int, loc *time.Location) time.Time {
+	at := time.Date(day.Year(), day.Month(), day.Day(), h, mi, 0, 0, loc)
+	if at.Hour() == h && at.Minute() == mi {
+		return at
+	}
+	// time.Date read the missing time on one side of the change; the
+	// change itself is the bound of that side's zone.
+	start, end := at.ZoneBounds()
+	shown := time.Date(at.Year(), at.Month(), at.Day(), at.Hour(), at.Minute(), 0, 0, time.UTC)
+	if shown.Before(time.Date(day.Year(), day.Month(), day.Day(), h, mi, 0, 0, time.UTC)) {
+		return end
+	}
+	return start
+}
+
+// scheduledRun is a parsed config schedule entry.
+type scheduledRun struct {
+	spec  cronSpec
+	count int
+}
+
+func parseSchedule(entries []scheduleEntry) ([]scheduledRun, error) {
+	out := make([]scheduledRun, 0, len(entries))
+	for _, e := range entries {
+		spec, err := parseCron(e.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule: %w", err)
+		}
+		if e.Count <= 0 {
+			return nil, fmt.Errorf("schedule %q: count must be > 0", e.Cron)
+		}
+		out = append(out, scheduledRun{spec: spec, count: e.Count})
+	}
+	return out, nil
+}
+
+// nextScheduled returns the earliest entry firing after t and when it fires.
+// Entries firing at the same minute are merged into one run.
+func nextScheduled(runs []scheduledRun, t time.Time) (at time.Time, count int) {
+	for _, r := range runs {
+		n := r.spec.next(t)
+		switch {
+		case n.IsZero():
+		case at.IsZero() || n.Before(at):
+			at, count = n, r.count
+		case n.Equal(at):
+			count += r.count
+		}
+	}
+	return at, count
+}
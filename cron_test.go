@@ -0,0 +1,143 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name, expr, from, want string
+	}{
+		{"every minute", "* * * * *", "2026-10-16 10:07", "2026-10-16 10:08"},
+		{"strictly after", "0 9 * * *", "2026-10-16 09:00", "2026-10-17 09:00"},
+		{"minute step", "*/15 * * * *", "2026-10-16 10:07", "2026-10-16 10:15"},
+		{"minute step wraps the hour", "*/15 * * * *", "2026-10-16 10:45", "2026-10-16 11:00"},
+		{"step from a value", "5/20 * * * *", "2026-10-16 10:26", "2026-10-16 10:45"},
+		{"hour range step", "30 8-18/2 * * *", "2026-10-16 11:00", "2026-10-16 12:30"},
+		{"hour range step ends the day", "30 8-18/2 * * *", "2026-10-16 18:31", "2026-10-17 08:30"},
+		{"list", "0 9,20 * * *", "2026-10-16 09:30", "2026-10-16 20:00"},
+		{"weekdays skip the weekend", "0 9 * * 1-5", "2026-10-16 10:00", "2026-10-19 09:00"},
+		{"7 is Sunday", "0 0 * * 7", "2026-10-16 10:00", "2026-10-18 00:00"},
+		{"0 is Sunday", "0 0 * * 0", "2026-10-16 10:00", "2026-10-18 00:00"},
+		// Both day fields restricted: the 1st or a Monday. 2026-11-01 is a
+		// Sunday, 2026-11-02 a Monday.
+		{"day of month or weekday: the date", "0 9 1 * 1", "2026-10-27 00:00", "2026-11-01 09:00"},
+		{"day of month or weekday: the weekday", "0 9 1 * 1", "2026-11-01 10:00", "2026-11-02 09:00"},
+		{"day of month alone", "0 9 1 * *", "2026-11-01 10:00", "2026-12-01 09:00"},
+		{"weekday alone", "0 9 * * 1", "2026-10-27 00:00", "2026-11-02 09:00"},
+		{"month", "0 0 1 3 *", "2026-10-16 00:00", "2027-03-01 00:00"},
+		{"leap day", "0 0 29 2 *", "2026-10-16 00:00", "2028-02-29 00:00"},
+		{"never", "0 0 31 2 *", "2026-10-16 00:00", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("parseCron(%q): %v", tt.expr, err)
+			}
+			got := c.next(at(tt.from))
+			var want time.Time
+			if tt.want != "" {
+				want = at(tt.want)
+			}
+			if !got.Equal(want) {
+				t.Errorf("next(%s) of %q = %v, want %v", tt.from, tt.expr, got, want)
+			}
+		})
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04 -0700", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	// The clocks go forward from 02:00 EST to 03:00 EDT on 2026-03-08 and
+	// back from 02:00 EDT to 01:00 EST on 2026-11-01.
+	tests := []struct {
+		name, expr, from string
+		want             []string
+	}{
+		{"skipped time fires at the jump", "30 2 * * *", "2026-03-07 12:00 -0500",
+			[]string{"2026-03-08 03:00 -0400", "2026-03-09 02:30 -0400"}},
+		{"skipped minutes fire once", "*/20 * * * *", "2026-03-08 01:30 -0500",
+			[]string{"2026-03-08 01:40 -0500", "2026-03-08 03:00 -0400", "2026-03-08 03:20 -0400"}},
+		{"time before the jump", "30 1 * * *", "2026-03-07 12:00 -0500",
+			[]string{"2026-03-08 01:30 -0500", "2026-03-09 01:30 -0400"}},
+		{"repeated time fires once", "30 1 * * *", "2026-10-31 12:00 -0400",
+			[]string{"2026-11-01 01:30 -0400", "2026-11-02 01:30 -0500"}},
+		{"from inside the repeated hour", "50 1 * * *", "2026-11-01 01:40 -0500",
+			[]string{"2026-11-02 01:50 -0500"}},
+		{"time after the change", "0 3 * * *", "2026-10-31 12:00 -0400",
+			[]string{"2026-11-01 03:00 -0500", "2026-11-02 03:00 -0500"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("parseCron(%q): %v", tt.expr, err)
+			}
+			from := at(tt.from).In(ny)
+			for _, w := range tt.want {
+				got := c.next(from)
+				if want := at(w); !got.Equal(want) {
+					t.Fatalf("next(%v) of %q = %v, want %v", from, tt.expr, got, want.In(ny))
+				}
+				from = got
+			}
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestNextScheduledMergesSameMinute(t *testing.T) {
+	runs, err := parseSchedule([]scheduleEntry{
+		{Cron: "0 9 * * *", Count: 2},
+		{Cron: "0 9 * * 1-5", Count: 3},
+		{Cron: "0 20 * * *", Count: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	at, count := nextScheduled(runs, from)
+	if want := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC); !at.Equal(want) || count != 5 {
+		t.Errorf("nextScheduled = %v, %d; want %v, 5", at, count, want)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// crossCheck compares cheap statistics of answer against what the training
+// pairs predict for the test input and returns a description of every
+// mismatch. Each rule applies only when all training pairs agree on it, so
+// a clean result proves nothing but a flag is a strong hint the answer is
+// wrong.
+func crossCheck(p puzzle, answer [][]int) []string {
+	if len(p.Train) == 0 || len(answer) == 0 {
+		return nil
+	}
+	bg := p.Hints.BackgroundColor
+	var issues []string
+
+	// Colors: outputs use colors from their input plus colors that some
+	// training output introduces.
+	introduced := colorSet(nil)
+	for _, ex := range p.Train {
+		in := colorSet(ex.Input)
+		for c := range colorSet(ex.Output) {
+			if !in[c] {
+				introduced[c] = true
+			}
+		}
+	}
+	allowed := colorSet(p.TestInput)
+	for c := range introduced {
+		allowed[c] = true
+	}
+	var stray []int
+	for c := range colorSet(answer) {
+		if !allowed[c] {
+			stray = append(stray, c)
+		}
+	}
+	if len(stray) > 0 {
+		sort.Ints(stray)
+		issues = append(issues, fmt.Sprintf("uses colors %v that appear in neither the test input nor any training output", stray))
+	}
+
+	// Color histogram: every training pair keeps the per-color cell counts.
+	// This implies the foreground count below, so only one is reported.
+	keepsHistogram := allPairs(p, func(in, out [][]int) bool { return colorCounts(in) == colorCounts(out) })
+	if keepsHistogram && colorCounts(p.TestInput) != colorCounts(answer) {
+		issues = append(issues, fmt.Sprintf("color counts %s differ from the test input's %s, training pairs keep them", colorCounts(answer), colorCounts(p.TestInput)))
+	} else if d, ok := constantDelta(p, func(g [][]int) int { return foregroundCells(g, bg) }); ok {
+		// Foreground cell count: a constant difference between input and
+		// output.
+		want, got := foregroundCells(p.TestInput, bg)+d, foregroundCells(answer, bg)
+		if got != want {
+			issues = append(issues, fmt.Sprintf("has %d non-background cells, training pairs predict %d", got, want))
+		}
+	}
+
+	// Objects: a constant difference in connected-component counts.
+	if d, ok := constantDelta(p, func(g [][]int) int { return countObjects(g, bg) }); ok {
+		want, got := countObjects(p.TestInput, bg)+d, countObjects(answer, bg)
+		if got != want {
+			issues = append(issues, fmt.Sprintf("has %d objects, training pairs predict %d", got, want))
+		}
+	}
+
+	// Distinct colors: a constant difference in how many colors are used.
+	if d, ok := constantDelta(p, func(g [][]int) int { return len(colorSet(g)) }); ok {
+		want, got := len(colorSet(p.TestInput))+d, len(colorSet(answer))
+		if got != want {
+			issues = append(issues, fmt.Sprintf("uses %d distinct colors, training pairs predict %d", got, want))
+		}
+	}
+	return issues
+}
+
+// allPairs reports whether pred holds for every training pair.
+func allPairs(p puzzle, pred func(in, out [][]int) bool) bool {
+	for _, ex := range p.Train {
+		if !pred(ex.Input, ex.Output) {
+			return false
+		}
+	}
+	return true
+}
+
+// constantDelta returns f(output)-f(input) if it is the same for every
+// training pair.
+func constantDelta(p puzzle, f func([][]int) int) (int, bool) {
+	d := f(p.Train[0].Output) - f(p.Train[0].Input)
+	ok := allPairs(p, func(in, out [][]int) bool { return f(out)-f(in) == d })
+	return d, ok
+}
+
+func colorSet(g [][]int) map[int]bool {
+	set := map[int]bool{}
+	for _, row := range g {
+		for _, v := range row {
+			set[v] = true
+		}
+	}
+	return set
+}
+
+func foregroundCells(g [][]int, bg int) int {
+	n := 0
+	for _, row := range g {
+		for _, v := range row {
+			if v != bg {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// countObjects counts 4-connected single-color regions of non-background
+// cells.
+func countObjects(g [][]int, bg int) int {
+	seen := make([][]bool, len(g))
+	for r := range g {
+		seen[r] = make([]bool, len(g[r]))
+	}
+	var stack [][2]int
+	n := 0
+	for r := range g {
+		for c, v := range g[r] {
+			if v == bg || seen[r][c] {
+				continue
+			}
+			n++
+			seen[r][c] = true
+			stack = append(stack[:0], [2]int{r, c})
+			for len(stack) > 0 {
+				cur := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					nr, nc := cur[0]+d[0], cur[1]+d[1]
+					if nr < 0 || nr >= len(g) || nc < 0 || nc >= len(g[nr]) || seen[nr][nc] || g[nr][nc] != v {
+						continue
+					}
+					seen[nr][nc] = true
+					stack = append(stack, [2]int{nr, nc})
+				}
+			}
+		}
+	}
+	return n
+}
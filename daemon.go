@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Daemon retry backoff bounds.
+const (
+	defaultDaemonRetry = 5 * time.Minute
+	maxDaemonRetry     = time.Hour
+)
+
+// clockWindow is a daily local time range [start, end), in minutes after
+// midnight. A window with end <= start wraps past midnight.
+type clockWindow struct {
+	start, end int
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(strings.TrimSpace(s), ":")
+	h, herr := strconv.Atoi(hh)
+	m, merr := strconv.Atoi(mm)
+	if !ok || herr != nil || merr != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return h*60 + m, nil
+}
+
+func parseWindows(specs []string) ([]clockWindow, error) {
+	out := make([]clockWindow, 0, len(specs))
+	for _, spec := range specs {
+		from, to, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid active_hours window %q (want HH:MM-HH:MM)", spec)
+		}
+		start, err := parseClock(from)
+		if err != nil {
+			return nil, fmt.Errorf("active_hours %q: %w", spec, err)
+		}
+		end, err := parseClock(to)
+		if err != nil {
+			return nil, fmt.Errorf("active_hours %q: %w", spec, err)
+		}
+		out = append(out, clockWindow{start: start, end: end})
+	}
+	return out, nil
+}
+
+func (w clockWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.end > w.start {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// inWindows reports whether t falls in any window; no windows means always.
+func inWindows(ws []clockWindow, t time.Time) bool {
+	if len(ws) == 0 {
+		return true
+	}
+	for _, w := range ws {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextClock returns the first time after t whose local clock reads m
+// minutes after midnight.
+func nextClock(t time.Time, m int) time.Time {
+	y, mo, d := t.Date()
+	next := time.Date(y, mo, d, m/60, m%60, 0, 0, t.Location())
+	if !next.After(t) {
+		next = time.Date(y, mo, d+1, m/60, m%60, 0, 0, t.Location())
+	}
+	return next
+}
+
+// nextWindowStart returns t if a window is open at t, otherwise the earliest
+// window opening after t.
+func nextWindowStart(ws []clockWindow, t time.Time) time.Time {
+	if inWindows(ws, t) {
+		return t
+	}
+	var best time.Time
+	for _, w := range ws {
+		if n := nextClock(t, w.start); best.IsZero() || n.Before(best) {
+			best = n
+		}
+	}
+	return best
+}
+
+// sleepCtx sleeps for d or until ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// daemonRetry returns the first backoff after a failed run.
+func daemonRetry(cfg appConfig) time.Duration {
+	if cfg.Daemon.RetryMinutes > 0 {
+		return time.Duration(cfg.Daemon.RetryMinutes) * time.Minute
+	}
+	return defaultDaemonRetry
+}
+
+// runScheduledPass waits for the next schedule firing and solves its count
+// of puzzles with o. Firings outside the active hours are skipped. A failed
+// run is retried with backoff while the following firing is still ahead;
+// otherwise the rest of the slot is dropped.
+func runScheduledPass(ctx context.Context, log *logger, o onlineRun, runs []scheduledRun, windows []clockWindow, retry time.Duration) (onlineOutcome, error) {
+	at, n := nextScheduled(runs, time.Now())
+	if !o.scheduledAt.IsZero() {
+		// A resumed pass finishes its remaining count right away.
+		at, n = o.scheduledAt, o.count
+		log.infof("schedule: resuming the run of %s with %d puzzle(s) left", at.Format(time.DateTime), n)
+	} else {
+		if at.IsZero() {
+			return onlineOutcome{}, errors.New("schedule: no entry fires within five years")
+		}
+		log.infof("schedule: next run of %d puzzle(s) at %s", n, at.Format(time.DateTime))
+		o.dash.setNext(at, fmt.Sprintf("scheduled run of %d", n))
+		if err := sleepCtx(ctx, time.Until(at)); err != nil {
+			return onlineOutcome{}, err
+		}
+		if !inWindows(windows, time.Now()) {
+			log.info("schedule: outside active hours, skipping this run")
+			return onlineOutcome{}, nil
+		}
+	}
+	following, _ := nextScheduled(runs, time.Now())
+
+	var total onlineOutcome
+	backoff := retry
+	o.scheduledAt = at
+	for {
+		o.count = n - total.solved
+		o.paced = true
+		out, err := runOnline(ctx, log, o)
+		o.resume, o.notBefore = nil, time.Time{}
+		total.solved += out.solved
+		total.exhausted = out.exhausted
+		total.overBudget = out.overBudget
+		if err == nil {
+			return total, nil
+		}
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		if !following.IsZero() && time.Now().Add(backoff).After(following) {
+			log.warnf("schedule: run failed: %v; dropping %d puzzle(s) until the next run", err, n-total.solved)
+			return total, nil
+		}
+		log.warnf("schedule: run failed: %v; retrying in %s", err, backoff)
+		o.dash.setNext(time.Now().Add(backoff), "retry")
+		if err := sleepCtx(ctx, backoff); err != nil {
+			return total, err
+		}
+		backoff = min(backoff*2, maxDaemonRetry)
+	}
+}
+
+// runDaemon solves puzzles indefinitely: an auto-mode run whenever an active
+// window is open and quota remains, polling of the quota until it resets
+// once it is used up, and backoff retries after failures. Each run reloads the
+// config and logs in afresh, so a cookie updated on disk is picked up
+// without a restart. With a schedule configured, runs follow it instead.
+func runDaemon(ctx context.Context, log *logger, args []string) error {
+	fs := flag.NewFlagSet(cmdDaemon, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath string
+		logFile    string
+		readOnly   bool
+		dashOn     bool
+	)
+	fs.StringVar(&configPath, "config", "", "config path (required)")
+	fs.StringVar(&logFile, "log-file", "", "append logs to this file (reopened on SIGHUP)")
+	fs.BoolVar(&readOnly, "read-only-config", false, "fail early unless the daemon can run without writing the config file")
+	fs.BoolVar(&dashOn, "dashboard", false, "show the daemon on a live full-screen dashboard instead of the scrolling log")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		return errors.New("--config is required")
+	}
+	if readOnly {
+		if err := checkReadOnlyConfig(configPath, logFile); err != nil {
+			return err
+		}
+	}
+	if logFile != "" {
+		closeLog, err := log.logToFile(logFile)
+		if err != nil {
+			return err
+		}
+		defer closeLog()
+	}
+	var dash *dashboard
+	if dashOn {
+		d, err := startDashboard(log, "daemon")
+		if err != nil {
+			return err
+		}
+		defer d.close()
+		dash = d
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	pause := newPauseController(homeDir(configPath))
+	defer pause.stop()
+	// The lock and the notification settings are taken once: the lock is
+	// held between passes and the throttle must span them.
+	startCfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	lock, err := lockInstance(startCfg)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	notify := newNotifier(startCfg, log)
+	defer notify.close(ctx)
+	status, closeStatus := startStatus(homeDir(configPath), log)
+	defer closeStatus()
+	status.setPhase(phaseWaiting)
+
+	var (
+		backoff    time.Duration
+		afterReset bool
+		// daily is the AI budget shared by the passes of one quota day.
+		daily budget
+	)
+	for {
+		cfg, err := loadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		daily.maxCost, daily.maxTokens = cfg.AI.MaxCost, cfg.AI.MaxTokens
+		windows, err := parseWindows(cfg.Daemon.ActiveHours)
+		if err != nil {
+			return err
+		}
+		reset := 0
+		if cfg.Daemon.QuotaReset != "" {
+			if reset, err = parseClock(cfg.Daemon.QuotaReset); err != nil {
+				return fmt.Errorf("daemon.quota_reset: %w", err)
+			}
+		}
+		retry := daemonRetry(cfg)
+		runs, err := parseSchedule(cfg.Schedule)
+		if err != nil {
+			return err
+		}
+		if len(runs) > 0 {
+			out, err := runScheduledPass(ctx, log, onlineRun{configPath: configPath, budget: &daily, dash: dash, pause: pause, lock: lock, notify: notify, status: status}, runs, windows, retry)
+			if ctx.Err() != nil {
+				log.info("daemon: stopped")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if out.overBudget {
+				next := nextClock(time.Now(), reset)
+				log.infof("daemon: AI budget reached, sleeping until reset at %s", next.Format(time.DateTime))
+				dash.setNext(next, "budget reset")
+				if err := sleepCtx(ctx, time.Until(next)); err != nil {
+					log.info("daemon: stopped")
+					return nil
+				}
+				daily.spent = spend{}
+			}
+			continue
+		}
+
+		var wait time.Duration
+		now := time.Now()
+		if open := nextWindowStart(windows, now); open.After(now) {
+			log.infof("daemon: outside active hours, sleeping until %s", open.Format(time.DateTime))
+			wait = open.Sub(now)
+		} else {
+			out, err := runOnline(ctx, log, onlineRun{
+				configPath: configPath,
+				count:      1,
+				autoLoop:   true,
+				stop:       func() bool { return !inWindows(windows, time.Now()) },
+				budget:     &daily,
+				dash:       dash,
+				pause:      pause,
+				lock:       lock,
+				notify:     notify,
+				status:     status,
+			})
+			if ctx.Err() != nil {
+				log.info("daemon: stopped")
+				return nil
+			}
+			switch {
+			case err != nil:
+				backoff = min(max(backoff*2, retry), maxDaemonRetry)
+				log.warnf("daemon: run failed: %v; retrying in %s", err, backoff)
+				wait = backoff
+			case out.exhausted && !cfg.SkipQuotaCheck:
+				backoff = 0
+				log.infof("daemon: daily quota used up (solved %d), polling until the reset expected at %s", out.solved, nextClock(time.Now(), reset).Format(time.DateTime))
+				dash.setNext(nextClock(time.Now(), reset), "quota reset")
+				if err := waitForQuota(ctx, log, configPath, reset); err != nil {
+					if ctx.Err() != nil {
+						log.info("daemon: stopped")
+						return nil
+					}
+					backoff = retry
+					log.warnf("daemon: quota polling failed: %v; retrying in %s", err, backoff)
+					wait = backoff
+					break
+				}
+				daily.spent = spend{}
+			case out.exhausted && out.solved == 0 && afterReset:
+				// Still exhausted after the configured reset time: the
+				// server resets later, so poll instead of waiting a day.
+				backoff = 0
+				log.infof("daemon: quota not reset yet, checking again in %s", retry)
+				wait = retry
+			case out.exhausted:
+				backoff = 0
+				afterReset = true
+				next := nextClock(time.Now(), reset)
+				log.infof("daemon: daily quota used up (solved %d), sleeping until reset at %s", out.solved, next.Format(time.DateTime))
+				wait = time.Until(next)
+				daily.spent = spend{}
+			case out.overBudget:
+				backoff = 0
+				next := nextClock(time.Now(), reset)
+				log.infof("daemon: AI budget reached (solved %d), sleeping until reset at %s", out.solved, next.Format(time.DateTime))
+				wait = time.Until(next)
+				daily.spent = spend{}
+			default:
+				backoff = 0
+				afterReset = false
+			}
+		}
+		if wait > 0 {
+			dash.setNext(time.Now().Add(wait), "next run")
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			log.info("daemon: stopped")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// dashboardRefresh is how often the dashboard is redrawn.
+	dashboardRefresh = 500 * time.Millisecond
+	// dashboardLogLines is how many recent log lines it keeps.
+	dashboardLogLines = 200
+	// dashboardStreamBytes is how much of the model's latest output it keeps.
+	dashboardStreamBytes = 8 << 10
+)
+
+// dashboard is the full-screen view of solve --auto --dashboard and daemon
+// --dashboard: the state of the current run, the model's output as it
+// streams in, and the latest log lines, redrawn in place of the scrolling
+// log and spinners. A nil *dashboard ignores every call, so a run without
+// one needs no checks.
+type dashboard struct {
+	out   *os.File
+	title string
+	start time.Time
+
+	mu sync.Mutex
+	// status is the run in progress; between the runs of a daemon, last is
+	// what it reported.
+	status *runStatus
+	last   statusSnapshot
+	logs   []string
+	// partial is a log line not yet ended by a newline.
+	partial string
+	stream  string
+	balance int
+	// today counts the submissions of the current local day.
+	today    dayStats
+	next     time.Time
+	nextWhat string
+	// hidden stops drawing while the plain terminal is lent to a prompt.
+	hidden bool
+
+	restoreLog func()
+	prevUI     io.Writer
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// liveDashboard is the dashboard on screen, if any, for prompts that need
+// the plain terminal.
+var liveDashboard *dashboard
+
+// startDashboard switches the terminal on stdout to the dashboard, taking
+// over the log output of log and silencing the interactive output. The
+// caller must close it to restore the terminal.
+func startDashboard(log *logger, title string) (*dashboard, error) {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil, errors.New("--dashboard needs a terminal on stdout")
+	}
+	d := &dashboard{
+		out:     os.Stdout,
+		title:   title,
+		start:   time.Now(),
+		last:    statusSnapshot{Phase: phaseStarting, DailyRemaining: -1, DailyLimit: -1},
+		balance: -1,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	d.restoreLog = log.logTo(d)
+	d.prevUI, uiOut = uiOut, io.Discard
+	_, _ = fmt.Fprint(d.out, "\033[?1049h\033[?25l")
+	liveDashboard = d
+	go d.loop()
+	return d, nil
+}
+
+// close stops redrawing, restores the terminal and the log output, and
+// repeats the last few log lines on stderr so the end of the run stays in
+// view.
+func (d *dashboard) close() {
+	if d == nil {
+		return
+	}
+	close(d.stop)
+	<-d.done
+	liveDashboard = nil
+	_, _ = fmt.Fprint(d.out, "\033[?25h\033[?1049l")
+	d.restoreLog()
+	uiOut = d.prevUI
+	d.mu.Lock()
+	tail := d.logs[max(len(d.logs)-10, 0):]
+	d.mu.Unlock()
+	for _, line := range tail {
+		_, _ = fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+// suspend hands the plain terminal back, as for a login prompt, until the
+// returned func is called.
+func (d *dashboard) suspend() func() {
+	if d == nil {
+		return func() {}
+	}
+	d.mu.Lock()
+	d.hidden = true
+	_, _ = fmt.Fprint(d.out, "\033[?25h\033[?1049l")
+	uiOut = d.prevUI
+	d.mu.Unlock()
+	return func() {
+		d.mu.Lock()
+		d.hidden = false
+		_, _ = fmt.Fprint(d.out, "\033[?1049h\033[?25l")
+		uiOut = io.Discard
+		d.mu.Unlock()
+	}
+}
+
+func (d *dashboard) loop() {
+	defer close(d.done)
+	t := time.NewTicker(dashboardRefresh)
+	defer t.Stop()
+	for {
+		d.draw()
+		select {
+		case <-d.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Write takes log output, one or more lines at a time.
+func (d *dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := strings.Split(d.partial+string(p), "\n")
+	d.partial = lines[len(lines)-1]
+	d.logs = append(d.logs, lines[:len(lines)-1]...)
+	if n := len(d.logs) - dashboardLogLines; n > 0 {
+		d.logs = append(d.logs[:0], d.logs[n:]...)
+	}
+	return len(p), nil
+}
+
+// attach shows st, the status of the run starting; detach keeps its last
+// state on screen once the run ends.
+func (d *dashboard) attach(st *runStatus) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.status = st
+	d.stream = ""
+	d.mu.Unlock()
+}
+
+func (d *dashboard) detach() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	if d.status != nil {
+		d.last = d.status.snapshot()
+		d.last.Phase, d.last.PuzzleID = phaseWaiting, ""
+		d.status = nil
+	}
+	d.mu.Unlock()
+}
+
+// newPuzzle clears the model output of the previous puzzle.
+func (d *dashboard) newPuzzle() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.stream = ""
+	d.mu.Unlock()
+}
+
+// streamed appends model output, keeping the latest dashboardStreamBytes.
+func (d *dashboard) streamed(s string) {
+	d.mu.Lock()
+	d.stream += s
+	if n := len(d.stream) - dashboardStreamBytes; n > 0 {
+		d.stream = d.stream[n:]
+	}
+	d.mu.Unlock()
+}
+
+func (d *dashboard) setBalance(points int) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.balance = points
+	d.mu.Unlock()
+}
+
+// setNext shows when the next solve is due and what it is.
+func (d *dashboard) setNext(at time.Time, what string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.next, d.nextWhat = at, what
+	d.mu.Unlock()
+}
+
+// loadToday seeds today's accuracy from the run history.
+func (d *dashboard) loadToday(ctx context.Context, hist *history) {
+	if d == nil || hist == nil {
+		return
+	}
+	y, m, day := time.Now().Date()
+	days, err := hist.dailyStats(ctx, time.Date(y, m, day, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		return
+	}
+	today := dayStats{Day: time.Now().Format(time.DateOnly)}
+	for _, s := range days {
+		if s.Day == today.Day {
+			today = s
+		}
+	}
+	d.mu.Lock()
+	d.today = today
+	d.mu.Unlock()
+}
+
+// graded counts a submitted attempt toward today's accuracy.
+func (d *dashboard) graded(a attempt) {
+	if d == nil || !a.Submitted || a.Correct == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if day := time.Now().Format(time.DateOnly); d.today.Day != day {
+		d.today = dayStats{Day: day}
+	}
+	d.today.Submitted++
+	if *a.Correct {
+		d.today.Correct++
+	}
+	d.today.Points += a.Points
+}
+
+func (d *dashboard) draw() {
+	w, h, err := term.GetSize(int(d.out.Fd()))
+	if err != nil || w < 20 || h < 12 {
+		w, h = 80, 24
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.hidden {
+		return
+	}
+	lines := d.view(w, h, time.Now())
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(truncateRunes(line, w))
+		b.WriteString("\033[K")
+	}
+	b.WriteString("\033[J")
+	_, _ = io.WriteString(d.out, b.String())
+}
+
+// view lays out the screen, w columns by h rows: the run's state, then the
+// model output and the log sharing the rest.
+func (d *dashboard) view(w, h int, now time.Time) []string {
+	snap := d.last
+	if d.status != nil {
+		snap = d.status.snapshot()
+	}
+	puzzle := "—"
+	if snap.PuzzleID != "" {
+		puzzle = snap.PuzzleID
+	}
+	if snap.Target > 0 {
+		puzzle += fmt.Sprintf(" (%d/%d solved this run)", snap.Solved, snap.Target)
+	}
+	quota := "—"
+	if snap.DailyLimit >= 0 {
+		quota = fmt.Sprintf("%d/%d left", snap.DailyRemaining, snap.DailyLimit)
+	}
+	points := "—"
+	if d.balance >= 0 {
+		points = fmt.Sprint(d.balance)
+	}
+	today := "—"
+	if d.today.Submitted > 0 && d.today.Day == now.Format(time.DateOnly) {
+		today = fmt.Sprintf("%d/%d correct (%.0f%%), %+d points", d.today.Correct, d.today.Submitted,
+			100*float64(d.today.Correct)/float64(d.today.Submitted), d.today.Points)
+	}
+	next := "—"
+	if d.next.After(now) {
+		next = fmt.Sprintf("%s at %s (in %s)", d.nextWhat, d.next.Format(time.TimeOnly), d.next.Sub(now).Round(time.Second))
+	}
+
+	lines := []string{
+		fmt.Sprintf("ergo-solver %s · up %s · Ctrl-C to stop", d.title, now.Sub(d.start).Round(time.Second)),
+		"",
+		"  Phase    " + snap.Phase,
+		"  Puzzle   " + puzzle,
+		"  Quota    " + quota,
+		"  Points   " + points,
+		"  Today    " + today,
+		"  Next     " + next,
+	}
+	rest := h - len(lines) - 2
+	streamRows := min(rest/2, 10)
+	lines = append(lines, rule("Model output", w))
+	out := wrapTail(strings.ReplaceAll(d.stream, `\n`, "\n"), w, streamRows)
+	lines = append(lines, out...)
+	for range streamRows - len(out) {
+		lines = append(lines, "")
+	}
+	lines = append(lines, rule("Log", w))
+	logRows := rest - streamRows
+	lines = append(lines, d.logs[max(len(d.logs)-logRows, 0):]...)
+	return lines
+}
+
+// rule is a horizontal line w wide headed by title.
+func rule(title string, w int) string {
+	head := "── " + title + " "
+	return head + strings.Repeat("─", max(w-len([]rune(head)), 0))
+}
+
+// wrapTail wraps text to w columns and returns its last n lines.
+func wrapTail(text string, w, n int) []string {
+	var out []string
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		r := []rune(strings.TrimRight(line, "\r"))
+		for len(r) > w {
+			out = append(out, string(r[:w]))
+			r = r[w:]
+		}
+		out = append(out, string(r))
+	}
+	if text == "" || n <= 0 {
+		return nil
+	}
+	return out[max(len(out)-n, 0):]
+}
+
+// truncateRunes cuts s to at most w runes.
+func truncateRunes(s string, w int) string {
+	if r := []rune(s); len(r) > w {
+		return string(r[:w])
+	}
+	return s
+}
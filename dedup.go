@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// errKnownWrong reports an answer identical to one the site has already
+// graded incorrect for the same puzzle.
+var errKnownWrong = errors.New("answer repeats one already graded incorrect for this puzzle")
+
+func containsGrid(list [][][]int, g [][]int) bool {
+	for _, w := range list {
+		if gridsEqual(w, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkKnownWrong compares answer with the answers to the same puzzle graded
+// incorrect before: it prints which cells changed since the most recent one
+// and returns errKnownWrong when answer equals any of them.
+func checkKnownWrong(w io.Writer, answer [][]int, wrong [][][]int) error {
+	if len(wrong) == 0 {
+		return nil
+	}
+	if containsGrid(wrong, answer) {
+		_, _ = fmt.Fprintf(w, "%s♻ Answer is identical to one graded incorrect before (%d known wrong)%s\n", colorYellow, len(wrong), colorReset)
+		return errKnownWrong
+	}
+	last := wrong[len(wrong)-1]
+	_, _ = fmt.Fprintf(w, "%s♻ Puzzle answered incorrectly before (%d known wrong); against the last one: %s%s\n", colorCyan, len(wrong), answerDiff(last, answer), colorReset)
+	if rectangular(last) && gridDims(last) == gridDims(answer) {
+		for _, line := range answerDiffMap(last, answer) {
+			_, _ = fmt.Fprintf(w, "   %s\n", line)
+		}
+	}
+	return nil
+}
+
+// answerDiff summarizes how answer differs from old.
+func answerDiff(old, answer [][]int) string {
+	if !rectangular(old) || gridDims(old) != gridDims(answer) {
+		return fmt.Sprintf("size changed from %s to %s", gridDims(old), gridDims(answer))
+	}
+	return gridDiff(answer, old)
+}
+
+// answerDiffMap maps the cells of answer against the same-size grid old:
+// "." for an unchanged cell and the new color for a changed one.
+func answerDiffMap(old, answer [][]int) []string {
+	lines := make([]string, 0, len(answer))
+	for r, row := range answer {
+		var b strings.Builder
+		for c, v := range row {
+			if v == old[r][c] {
+				b.WriteByte('.')
+				continue
+			}
+			fmt.Fprint(&b, v)
+		}
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+// avoidKnownWrong is the pre-submission duplicate check against the run
+// history. An answer equal to one graded incorrect in an earlier run is not
+// submitted again: refine, when set, solves the puzzle once more with every
+// known wrong answer shown to the model, and its answer must pass the same
+// checks. Without refine (manual mode) the attempt fails.
+func avoidKnownWrong(ctx context.Context, log *logger, hist *history, p puzzle, id string, res solveResult, refine func(context.Context, puzzle, [][][]int) (solveResult, error)) (solveResult, error) {
+	wrong, err := hist.wrongAnswers(ctx, id)
+	if err != nil {
+		log.warnf("read earlier answers: %v", err)
+		return res, nil
+	}
+	if err := checkKnownWrong(uiOut, res.Answer, wrong); err == nil || refine == nil {
+		return res, err
+	}
+	log.warn("known-wrong answer: forcing a refinement round instead of resubmitting it")
+	refined, err := refine(ctx, p, wrong)
+	if err != nil {
+		return refined, fmt.Errorf("refine known-wrong answer: %w", err)
+	}
+	if err := sanityCheck(p, refined.Answer); err != nil {
+		return refined, err
+	}
+	return refined, checkKnownWrong(uiOut, refined.Answer, wrong)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxDeferred caps the puzzles degraded mode queues; once it is full the
+// run waits for the AI circuit again instead of fetching more.
+const maxDeferred = 5
+
+// errDeferred reports a puzzle degraded mode queued for when the AI is
+// back.
+var errDeferred = errors.New("deferred until the AI is back")
+
+// degradedSolve answers p without the AI after the solve failed with
+// cause, an ErrAIUnavailable: with the pre-solver's rule if one explains
+// every training pair, which is tried here even when ai.presolve is off,
+// or else it reports errDeferred.
+func degradedSolve(log *logger, p puzzle, cause error) (solveResult, error) {
+	grid, rule, ok := presolve(p)
+	if !ok {
+		return solveResult{}, fmt.Errorf("%w (%w)", errDeferred, cause)
+	}
+	log.warnf("degraded mode: AI unavailable, answering with the pre-solver rule %s", rule)
+	return solveResult{Answer: grid, Model: presolverModel, Confidence: 100, Reasoning: "Deterministic rule: " + rule}, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffSummary shows, for each same-size training pair of p, which cells the
+// output changes: a map with "." for an unchanged cell and the output color
+// for a changed one. Pairs whose grids differ in size are named as such.
+func diffSummary(p puzzle) string {
+	var b strings.Builder
+	b.WriteString("## Changed cells (input → output; \".\" = unchanged, a digit = the new color):")
+	for i, ex := range p.Train {
+		fmt.Fprintf(&b, "\nTraining pair %d: ", i+1)
+		if !rectangular(ex.Input) || !rectangular(ex.Output) || gridDims(ex.Input) != gridDims(ex.Output) {
+			fmt.Fprintf(&b, "size changes from %s to %s, no cell map", gridDims(ex.Input), gridDims(ex.Output))
+			continue
+		}
+		n := 0
+		var rows []string
+		for r, row := range ex.Output {
+			var line strings.Builder
+			for c, v := range row {
+				if v == ex.Input[r][c] {
+					line.WriteByte('.')
+					continue
+				}
+				n++
+				fmt.Fprint(&line, v)
+			}
+			rows = append(rows, line.String())
+		}
+		if n == 0 {
+			b.WriteString("no cells change")
+			continue
+		}
+		fmt.Fprintf(&b, "%d cell(s) change\n%s", n, strings.Join(rows, "\n"))
+	}
+	return b.String()
+}
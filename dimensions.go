@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// enforceSize deals with an answer whose size contradicts the hint. With
+// ai.strict_dimensions off it only warns. Otherwise an answer off by one
+// row or column is cropped or padded when ai.repair_dimensions allows,
+// and any other is sent back to model once with the required size; an
+// answer that still does not fit is an error, so it is never submitted.
+func (s *Solver) enforceSize(ctx context.Context, p puzzle, model string, answer Answer) (Answer, error) {
+	log := s.log.forContext(ctx)
+	sizeErr := validateAnswerSize(p, answer.Answer)
+	if sizeErr == nil {
+		return answer, nil
+	}
+	if s.cfg.StrictDimensions != nil && !*s.cfg.StrictDimensions {
+		log.warnf("answer size mismatch: %v", sizeErr)
+		return answer, nil
+	}
+	h, w := p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width
+	if s.cfg.RepairDimensions {
+		if fixed, ok := fitOffByOne(answer.Answer, h, w, p.Hints.BackgroundColor); ok {
+			log.warnf("answer size repaired: %s cropped or padded to %s", gridDims(answer.Answer), gridDims(fixed))
+			s.repairs.Add(1)
+			answer.Answer = fixed
+			return answer, nil
+		}
+	}
+
+	log.warnf("answer size mismatch: %v, asking %s again", sizeErr, model)
+	spin := newSpinner()
+	spin.Start("📐 Re-solving for the hinted size...")
+	next, err := s.correctOnce(ctx, p, model, answer, fmt.Sprintf("The answer grid is %s, but the output must be exactly %d rows × %d columns.", gridDims(answer.Answer), h, w))
+	spin.Stop()
+	if err == nil {
+		err = validateAnswerSize(p, next.Answer)
+	}
+	if err != nil {
+		return answer, fmt.Errorf("answer size mismatch (ai.strict_dimensions): %w", err)
+	}
+	printAnswerDetails(next)
+	return next, nil
+}
+
+// fitOffByOne crops or pads a rectangular grid that is at most one row and
+// one column off h×w. An extra row or column is dropped from the end,
+// or from the start when only that side is all background; a missing one is
+// added as background at the end.
+func fitOffByOne(g [][]int, h, w, bg int) ([][]int, bool) {
+	if !rectangular(g) || max(len(g)-h, h-len(g)) > 1 || max(len(g[0])-w, w-len(g[0])) > 1 {
+		return nil, false
+	}
+	out := make([][]int, 0, h)
+	for _, row := range g {
+		out = append(out, slices.Clone(row))
+	}
+	blank := func(cells []int) bool {
+		return !slices.ContainsFunc(cells, func(v int) bool { return v != bg })
+	}
+	switch {
+	case len(out) == h+1 && blank(out[0]) && !blank(out[h]):
+		out = out[1:]
+	case len(out) == h+1:
+		out = out[:h]
+	case len(out) == h-1:
+		out = append(out, slices.Repeat([]int{bg}, len(out[0])))
+	}
+	column := func(c int) []int {
+		col := make([]int, len(out))
+		for r, row := range out {
+			col[r] = row[c]
+		}
+		return col
+	}
+	width := len(out[0])
+	dropFirst := width == w+1 && blank(column(0)) && !blank(column(w))
+	for r, row := range out {
+		switch {
+		case dropFirst:
+			out[r] = row[1:]
+		case width == w+1:
+			out[r] = row[:w]
+		case width == w-1:
+			out[r] = append(row, bg)
+		}
+	}
+	return out, true
+}
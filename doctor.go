@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// doctorFinding is one problem the doctor command found. fix, when set,
+// describes the correction and apply makes it.
+type doctorFinding struct {
+	problem string
+	fix     string
+	apply   func() error
+}
+
+// runDoctor checks the config and the saved login for common problems: a
+// missing user agent, a trailing slash in base_url, a cookie the site no
+// longer accepts, secrets other users can read, and the insecure settings of
+// the config warnings. With --fix it offers to correct each fixable one,
+// asking first; config edits keep a backup of the previous file. It fails
+// while problems remain.
+func runDoctor(ctx context.Context, log *logger, args []string) error {
+	fs := flag.NewFlagSet(cmdDoctor, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath string
+		fix        bool
+		offline    bool
+	)
+	fs.StringVar(&configPath, "config", "", "config path (required)")
+	fs.BoolVar(&fix, "fix", false, "offer to fix the problems found")
+	fs.BoolVar(&offline, "offline", false, "skip the login check against the site")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		return errors.New("--config is required")
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return fmt.Errorf("stat config: %w", err)
+	}
+
+	cfg, err := loadConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	cfg.seedCookie = cfg.Cookie
+	st, err := loadLoginState(statePath(cfg.home))
+	if err != nil {
+		return err
+	}
+	applyLoginState(&cfg, st)
+
+	findings := doctorChecks(ctx, configPath, cfg, offline)
+	if len(findings) == 0 {
+		log.ok("doctor: no problems found")
+		return nil
+	}
+	remaining := 0
+	for _, f := range findings {
+		fmt.Fprintf(uiOut, "%s⚠ %s%s\n", colorYellow, f.problem, colorReset)
+		if f.apply == nil {
+			remaining++
+			continue
+		}
+		if !fix {
+			fmt.Fprintf(uiOut, "  %sfixable with --fix: %s%s\n", colorDim, f.fix, colorReset)
+			remaining++
+			continue
+		}
+		fmt.Fprintf(uiOut, "  Fix: %s? [y/N] ", f.fix)
+		answer, err := readTerminalLine(int(os.Stdin.Fd()), false)
+		if err != nil {
+			return err
+		}
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			remaining++
+			continue
+		}
+		if err := f.apply(); err != nil {
+			log.warnf("fix failed: %v", err)
+			remaining++
+		}
+	}
+	if remaining > 0 {
+		return fmt.Errorf("doctor: %d of %d problem(s) remain", remaining, len(findings))
+	}
+	log.okf("doctor: fixed %d problem(s)", len(findings))
+	return nil
+}
+
+// doctorChecks runs the doctor's checks on cfg, the config at path with the
+// saved login applied. Unless offline, the cookie is tried against the site.
+func doctorChecks(ctx context.Context, path string, cfg appConfig, offline bool) []doctorFinding {
+	var out []doctorFinding
+	done := func(format string, args ...any) {
+		fmt.Fprintf(uiOut, "  %s✅ %s%s\n", colorGreen, fmt.Sprintf(format, args...), colorReset)
+	}
+
+	if perm, readable := secretsReadable(path, cfg); readable {
+		out = append(out, doctorFinding{
+			problem: fmt.Sprintf("%s holds %s but is readable by other users (mode %#o)", path, strings.Join(configSecrets(cfg), ", "), perm),
+			fix:     "restrict it to mode 0600",
+			apply: func() error {
+				if err := os.Chmod(path, 0o600); err != nil {
+					return err
+				}
+				done("%s is now mode 0600", path)
+				return nil
+			},
+		})
+	}
+	if sp := statePath(cfg.home); runtime.GOOS != "windows" {
+		if fi, err := os.Stat(sp); err == nil && fi.Mode().Perm()&0o044 != 0 {
+			out = append(out, doctorFinding{
+				problem: fmt.Sprintf("%s holds the login cookie but is readable by other users (mode %#o)", sp, fi.Mode().Perm()),
+				fix:     "restrict it to mode 0600",
+				apply: func() error {
+					if err := os.Chmod(sp, 0o600); err != nil {
+						return err
+					}
+					done("%s is now mode 0600", sp)
+					return nil
+				},
+			})
+		}
+	}
+
+	switch {
+	case cfg.BaseURL == "":
+		out = append(out, doctorFinding{problem: "base_url is not set"})
+	case strings.HasSuffix(cfg.BaseURL, "/"):
+		base := strings.TrimRight(cfg.BaseURL, "/")
+		out = append(out, doctorFinding{
+			problem: fmt.Sprintf("base_url %s ends with a slash", cfg.BaseURL),
+			fix:     fmt.Sprintf("set base_url to %s", base),
+			apply:   setConfigValue(path, "base_url", base, done),
+		})
+	}
+
+	if configString(path, "user_agent") == "" && cfg.UserAgent == defaultUA {
+		out = append(out, doctorFinding{
+			problem: "user_agent is not set, so the built-in default is sent; a cf_clearance cookie only works with the user agent of the browser it came from",
+			fix:     "set user_agent to your browser's",
+			apply: func() error {
+				fmt.Fprint(uiOut, "  User agent (Enter for the built-in default): ")
+				ua, err := readTerminalLine(int(os.Stdin.Fd()), false)
+				if err != nil {
+					return err
+				}
+				if ua = strings.TrimSpace(ua); ua == "" {
+					ua = defaultUA
+				}
+				return setConfigValue(path, "user_agent", ua, done)()
+			},
+		})
+	}
+
+	if !offline && cfg.BaseURL != "" {
+		if f, ok := checkCookie(ctx, cfg, done); ok {
+			out = append(out, f)
+		}
+	}
+
+	for _, w := range lintSettings(path, cfg) {
+		out = append(out, doctorFinding{problem: w})
+	}
+	return out
+}
+
+// checkCookie tries the login against the site and reports a missing or
+// rejected cookie, fixed by logging in again as the login command does.
+func checkCookie(ctx context.Context, cfg appConfig, done func(string, ...any)) (doctorFinding, bool) {
+	relogin := func() error {
+		in, err := promptAuthMaterial()
+		if err != nil {
+			return err
+		}
+		next, _, me, err := previewAuth(ctx, cfg, in, uiOut)
+		if err != nil {
+			return err
+		}
+		sp := statePath(cfg.home)
+		if _, err := os.Stat(sp); err == nil {
+			backup, err := backupFile(sp)
+			if err != nil {
+				return err
+			}
+			done("backed up %s to %s", sp, backup)
+		}
+		if err := saveLoginState(next); err != nil {
+			return err
+		}
+		done("logged in as %s; login state saved", me.User.Username)
+		return nil
+	}
+	if cfg.Cookie == "" {
+		return doctorFinding{problem: "no cookie is set", fix: "log in with a fresh cookie", apply: relogin}, true
+	}
+	client, err := newAPIClient(cfg)
+	if err != nil {
+		return doctorFinding{problem: err.Error()}, true
+	}
+	_, err = client.authMe(ctx)
+	switch {
+	case err == nil:
+		return doctorFinding{}, false
+	case isAuthError(err):
+		return doctorFinding{problem: "the cookie is stale: the site rejects the login", fix: "log in with a fresh cookie", apply: relogin}, true
+	default:
+		return doctorFinding{problem: fmt.Sprintf("login check failed: %v", err)}, true
+	}
+}
+
+// setConfigValue returns a fix that writes key to the config file at path,
+// keeping a backup of the previous file.
+func setConfigValue(path, key, value string, done func(string, ...any)) func() error {
+	return func() error {
+		backup, err := updateConfigFile(path, map[string]any{key: value})
+		if err != nil {
+			return err
+		}
+		done("set %s (previous config saved to %s)", key, backup)
+		return nil
+	}
+}
+
+// configString returns the top-level string key as written in the config
+// file at path, or "" when it is absent or the file cannot be read.
+func configString(path, key string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var doc map[string]any
+	if json.Unmarshal(b, &doc) != nil {
+		return ""
+	}
+	s, _ := doc[key].(string)
+	return strings.TrimSpace(s)
+}
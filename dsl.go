@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// dslProgram is a grid program in the solver's DSL: steps applied in order,
+// starting from the input grid.
+type dslProgram struct {
+	Steps []dslStep `json:"steps"`
+}
+
+// dslStep is one operation. Only the arguments its op documents in
+// dslReference are read; the rest are null.
+type dslStep struct {
+	Op         string  `json:"op"`
+	Times      *int    `json:"times"`
+	Axis       *string `json:"axis"`
+	Top        *int    `json:"top"`
+	Left       *int    `json:"left"`
+	Height     *int    `json:"height"`
+	Width      *int    `json:"width"`
+	Rows       *int    `json:"rows"`
+	Cols       *int    `json:"cols"`
+	Row        *int    `json:"row"`
+	Col        *int    `json:"col"`
+	From       *int    `json:"from"`
+	To         *int    `json:"to"`
+	Color      *int    `json:"color"`
+	Background *int    `json:"background"`
+	Select     *string `json:"select"`
+}
+
+// dslMaxSteps and dslMaxCells bound a program so a bad one cannot run away.
+const (
+	dslMaxSteps = 32
+	dslMaxCells = 30 * 30 * 16
+)
+
+// dslReference documents the DSL for the model.
+const dslReference = `Operations (each step is an object with "op" and its arguments; set every other argument to null):
+- rotate(times): rotate 90° clockwise, times = 1, 2 or 3
+- flip(axis): mirror left-right (axis "h") or top-bottom (axis "v")
+- transpose(): swap rows and columns
+- crop(top, left, height, width): keep that rectangle (0-based)
+- crop_content(background): keep the bounding box of all cells not of the background color
+- extract_object(select, background): keep the bounding box of the "largest" or "smallest" object (4-connected cells of one non-background color); other cells in the box become background
+- recolor(from, to): change every cell of color from to color to
+- tile(rows, cols): repeat the grid rows times down and cols times across
+- scale(rows, cols): turn every cell into a rows × cols block
+- flood_fill(row, col, color): fill the 4-connected region of same-colored cells containing (row, col) with color
+- overlay(color): draw the ORIGINAL input's cells that are not of color on top of the current grid (same size required)`
+
+var dslOps = []string{"rotate", "flip", "transpose", "crop", "crop_content", "extract_object", "recolor", "tile", "scale", "flood_fill", "overlay"}
+
+// run applies the program to input.
+func (prog dslProgram) run(input [][]int) ([][]int, error) {
+	if len(prog.Steps) == 0 {
+		return nil, errors.New("empty program")
+	}
+	if len(prog.Steps) > dslMaxSteps {
+		return nil, fmt.Errorf("program has %d steps, at most %d allowed", len(prog.Steps), dslMaxSteps)
+	}
+	if !rectangular(input) {
+		return nil, errors.New("input grid is not rectangular")
+	}
+	g := input
+	for i, st := range prog.Steps {
+		next, err := st.apply(g, input)
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i+1, st.Op, err)
+		}
+		if !rectangular(next) {
+			return nil, fmt.Errorf("step %d (%s): result is empty", i+1, st.Op)
+		}
+		if len(next)*len(next[0]) > dslMaxCells {
+			return nil, fmt.Errorf("step %d (%s): result exceeds %d cells", i+1, st.Op, dslMaxCells)
+		}
+		g = next
+	}
+	return g, nil
+}
+
+func (st dslStep) apply(g, input [][]int) ([][]int, error) {
+	h, w := len(g), len(g[0])
+	switch st.Op {
+	case "rotate":
+		times, err := arg(st.Times, "times")
+		if err != nil {
+			return nil, err
+		}
+		if times < 1 || times > 3 {
+			return nil, fmt.Errorf("times %d out of range 1-3", times)
+		}
+		for range times {
+			g = rotateCW(g)
+		}
+		return g, nil
+	case "flip":
+		if st.Axis == nil {
+			return nil, errors.New("missing axis")
+		}
+		switch *st.Axis {
+		case "h":
+			return flipH(g), nil
+		case "v":
+			return flipV(g), nil
+		}
+		return nil, fmt.Errorf("axis %q is not \"h\" or \"v\"", *st.Axis)
+	case "transpose":
+		return transpose(g), nil
+	case "crop":
+		top, err1 := arg(st.Top, "top")
+		left, err2 := arg(st.Left, "left")
+		height, err3 := arg(st.Height, "height")
+		width, err4 := arg(st.Width, "width")
+		if err := errors.Join(err1, err2, err3, err4); err != nil {
+			return nil, err
+		}
+		if top < 0 || left < 0 || height < 1 || width < 1 || top+height > h || left+width > w {
+			return nil, fmt.Errorf("rectangle %d,%d %d×%d outside the %d×%d grid", top, left, height, width, h, w)
+		}
+		return cropGrid(g, top, left, height, width), nil
+	case "crop_content":
+		bg, err := arg(st.Background, "background")
+		if err != nil {
+			return nil, err
+		}
+		top, left, bottom, right := h, w, -1, -1
+		for r, row := range g {
+			for c, v := range row {
+				if v != bg {
+					top, left, bottom, right = min(top, r), min(left, c), max(bottom, r), max(right, c)
+				}
+			}
+		}
+		if bottom < 0 {
+			return nil, errors.New("grid has only background cells")
+		}
+		return cropGrid(g, top, left, bottom-top+1, right-left+1), nil
+	case "extract_object":
+		bg, err := arg(st.Background, "background")
+		if err != nil {
+			return nil, err
+		}
+		if st.Select == nil || (*st.Select != "largest" && *st.Select != "smallest") {
+			return nil, errors.New(`select must be "largest" or "smallest"`)
+		}
+		return extractObject(g, bg, *st.Select == "largest")
+	case "recolor":
+		from, err1 := arg(st.From, "from")
+		to, err2 := arg(st.To, "to")
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		out := cropGrid(g, 0, 0, h, w)
+		for _, row := range out {
+			for c, v := range row {
+				if v == from {
+					row[c] = to
+				}
+			}
+		}
+		return out, nil
+	case "tile", "scale":
+		rows, err1 := arg(st.Rows, "rows")
+		cols, err2 := arg(st.Cols, "cols")
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		if rows < 1 || cols < 1 {
+			return nil, fmt.Errorf("factors %d×%d must be at least 1", rows, cols)
+		}
+		if h*rows*w*cols > dslMaxCells {
+			return nil, fmt.Errorf("result exceeds %d cells", dslMaxCells)
+		}
+		if st.Op == "tile" {
+			return tile(g, rows, cols), nil
+		}
+		return scale(g, rows, cols), nil
+	case "flood_fill":
+		row, err1 := arg(st.Row, "row")
+		col, err2 := arg(st.Col, "col")
+		color, err3 := arg(st.Color, "color")
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return nil, err
+		}
+		if row < 0 || row >= h || col < 0 || col >= w {
+			return nil, fmt.Errorf("cell %d,%d outside the %d×%d grid", row, col, h, w)
+		}
+		out := cropGrid(g, 0, 0, h, w)
+		for _, cell := range component(out, row, col) {
+			out[cell[0]][cell[1]] = color
+		}
+		return out, nil
+	case "overlay":
+		color, err := arg(st.Color, "color")
+		if err != nil {
+			return nil, err
+		}
+		if len(input) != h || len(input[0]) != w {
+			return nil, fmt.Errorf("input is %s, current grid %s", gridDims(input), gridDims(g))
+		}
+		out := cropGrid(g, 0, 0, h, w)
+		for r, row := range input {
+			for c, v := range row {
+				if v != color {
+					out[r][c] = v
+				}
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown op (want one of %s)", strings.Join(dslOps, ", "))
+}
+
+// arg returns a required integer argument.
+func arg(v *int, name string) (int, error) {
+	if v == nil {
+		return 0, fmt.Errorf("missing %s", name)
+	}
+	return *v, nil
+}
+
+// cropGrid copies the height×width rectangle of g at top, left.
+func cropGrid(g [][]int, top, left, height, width int) [][]int {
+	out := make([][]int, height)
+	for r := range out {
+		out[r] = append([]int(nil), g[top+r][left:left+width]...)
+	}
+	return out
+}
+
+// component returns the 4-connected cells of g with the color of (r, c)
+// that include it.
+func component(g [][]int, r, c int) [][2]int {
+	color := g[r][c]
+	seen := map[[2]int]bool{{r, c}: true}
+	cells := [][2]int{{r, c}}
+	for i := 0; i < len(cells); i++ {
+		cur := cells[i]
+		for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			n := [2]int{cur[0] + d[0], cur[1] + d[1]}
+			if n[0] < 0 || n[0] >= len(g) || n[1] < 0 || n[1] >= len(g[n[0]]) || seen[n] || g[n[0]][n[1]] != color {
+				continue
+			}
+			seen[n] = true
+			cells = append(cells, n)
+		}
+	}
+	return cells
+}
+
+// extractObject crops g to the bounding box of its largest (or smallest)
+// object, the first in reading order on ties; cells of other objects in the
+// box become bg.
+func extractObject(g [][]int, bg int, largest bool) ([][]int, error) {
+	seen := map[[2]int]bool{}
+	var best [][2]int
+	for r, row := range g {
+		for c, v := range row {
+			if v == bg || seen[[2]int{r, c}] {
+				continue
+			}
+			obj := component(g, r, c)
+			for _, cell := range obj {
+				seen[cell] = true
+			}
+			if best == nil || (largest && len(obj) > len(best)) || (!largest && len(obj) < len(best)) {
+				best = obj
+			}
+		}
+	}
+	if best == nil {
+		return nil, errors.New("grid has no objects")
+	}
+	top, left, bottom, right := len(g), len(g[0]), -1, -1
+	for _, cell := range best {
+		top, left, bottom, right = min(top, cell[0]), min(left, cell[1]), max(bottom, cell[0]), max(right, cell[1])
+	}
+	out := make([][]int, bottom-top+1)
+	for r := range out {
+		out[r] = make([]int, right-left+1)
+		for c := range out[r] {
+			out[r][c] = bg
+		}
+	}
+	color := g[best[0][0]][best[0][1]]
+	for _, cell := range best {
+		out[cell[0]-top][cell[1]-left] = color
+	}
+	return out, nil
+}
+
+// String renders the program one step per line, with the arguments set.
+func (prog dslProgram) String() string {
+	var b strings.Builder
+	for i, st := range prog.Steps {
+		var args []string
+		add := func(name string, v *int) {
+			if v != nil {
+				args = append(args, fmt.Sprintf("%s=%d", name, *v))
+			}
+		}
+		if st.Axis != nil {
+			args = append(args, "axis="+*st.Axis)
+		}
+		if st.Select != nil {
+			args = append(args, "select="+*st.Select)
+		}
+		add("times", st.Times)
+		add("top", st.Top)
+		add("left", st.Left)
+		add("height", st.Height)
+		add("width", st.Width)
+		add("rows", st.Rows)
+		add("cols", st.Cols)
+		add("row", st.Row)
+		add("col", st.Col)
+		add("from", st.From)
+		add("to", st.To)
+		add("color", st.Color)
+		add("background", st.Background)
+		fmt.Fprintf(&b, "%d. %s(%s)\n", i+1, st.Op, strings.Join(args, ", "))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// programSchema is the structured-output schema of a program answer. Every
+// argument is required but nullable, as strict schemas demand.
+func programSchema() map[string]any {
+	nullableInt := map[string]any{"type": []string{"integer", "null"}}
+	step := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"op":         map[string]any{"type": "string", "enum": dslOps},
+			"times":      nullableInt,
+			"axis":       map[string]any{"type": []string{"string", "null"}, "enum": []any{"h", "v", nil}},
+			"top":        nullableInt,
+			"left":       nullableInt,
+			"height":     nullableInt,
+			"width":      nullableInt,
+			"rows":       nullableInt,
+			"cols":       nullableInt,
+			"row":        nullableInt,
+			"col":        nullableInt,
+			"from":       nullableInt,
+			"to":         nullableInt,
+			"color":      nullableInt,
+			"background": nullableInt,
+			"select":     map[string]any{"type": []string{"string", "null"}, "enum": []any{"largest", "smallest", nil}},
+		},
+		"additionalProperties": false,
+	}
+	step["required"] = []string{"op", "times", "axis", "top", "left", "height", "width", "rows", "cols", "row", "col", "from", "to", "color", "background", "select"}
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"reasoning": map[string]any{"type": "string", "description": "The transformation rule and how the program implements it"},
+			"program": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"steps": map[string]any{"type": "array", "items": step, "minItems": 1, "maxItems": dslMaxSteps},
+				},
+				"required":             []string{"steps"},
+				"additionalProperties": false,
+			},
+			"confidence": map[string]any{"type": "integer", "description": "Confidence level 0-100"},
+		},
+		"required":             []string{"reasoning", "program", "confidence"},
+		"additionalProperties": false,
+	}
+}
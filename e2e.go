@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// selftestCookie is the session the mock site accepts.
+const selftestCookie = "session=selftest"
+
+// selftestPuzzle is the puzzle the mock site serves, with selftestAnswer as
+// its answer: every cell of color 1 turns 2.
+var (
+	selftestPuzzle = puzzle{
+		ID: "selftest",
+		Train: []puzzleExample{
+			{Input: [][]int{{1, 0, 0}, {0, 0, 1}}, Output: [][]int{{2, 0, 0}, {0, 0, 2}}},
+			{Input: [][]int{{0, 0, 0}, {0, 1, 0}}, Output: [][]int{{0, 0, 0}, {0, 2, 0}}},
+		},
+		TestInput: [][]int{{0, 1, 0}, {1, 0, 0}},
+		Hints:     puzzleHints{BackgroundColor: 0},
+	}
+	selftestAnswer = [][]int{{0, 2, 0}, {2, 0, 0}}
+)
+
+// runSelftest checks that this build works on this machine: the state
+// directory is writable, DNS resolves, and one puzzle goes through the full
+// solve pipeline (login, PoW, fetch, AI, verification, submit, history)
+// against an in-process mock site and OpenAI-compatible AI endpoint served
+// over TLS. Nothing is sent to the real site or AI provider.
+func runSelftest(ctx context.Context, log *logger, args []string) error {
+	fs := flag.NewFlagSet(cmdSelftest, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var configPath string
+	fs.StringVar(&configPath, "config", "", "config whose state directory and hosts are checked too")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var failed []string
+	check := func(name string, err error) {
+		if err != nil {
+			fmt.Fprintf(uiOut, "%s❌ %s: %v%s\n", colorYellow, name, err, colorReset)
+			failed = append(failed, name)
+			return
+		}
+		fmt.Fprintf(uiOut, "%s✅ %s%s\n", colorGreen, name, colorReset)
+	}
+
+	hosts := []string{"localhost"}
+	if configPath != "" {
+		check("state directory writable", checkWritable(homeDir(configPath)))
+		if cfg, err := loadConfigFile(configPath); err != nil {
+			check("config", err)
+		} else {
+			hosts = configHosts(cfg)
+		}
+	}
+	for _, h := range hosts {
+		check("DNS "+h, checkDNS(ctx, h))
+	}
+	check("solve pipeline against the mock server", selftestPipeline(ctx, log))
+
+	if len(failed) > 0 {
+		return fmt.Errorf("selftest failed: %s", strings.Join(failed, ", "))
+	}
+	fmt.Fprintf(uiOut, "%s✅ selftest passed%s\n", colorGreen, colorReset)
+	return nil
+}
+
+// checkWritable creates and removes a file in dir.
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".selftest-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_, err = f.WriteString("ok")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if rerr := os.Remove(name); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// configHosts lists the hosts of the site and AI endpoint in cfg.
+func configHosts(cfg appConfig) []string {
+	var hosts []string
+	for _, raw := range []string{cfg.BaseURL, cfg.AI.BaseURL} {
+		if u, err := url.Parse(strings.TrimSpace(raw)); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = []string{"localhost"}
+	}
+	return hosts
+}
+
+func checkDNS(ctx context.Context, host string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = errors.New("no addresses")
+	}
+	return err
+}
+
+// selftestPipeline solves selftestPuzzle end to end in a throwaway state
+// directory.
+func selftestPipeline(ctx context.Context, log *logger) error {
+	site := &mockSite{}
+	srv := httptest.NewUnstartedServer(site.handler())
+	srv.StartTLS()
+	defer srv.Close()
+	rootCAs = x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+	defer func() { rootCAs = nil }()
+
+	home, err := os.MkdirTemp("", "ergo-selftest-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(home) }()
+	// The state of the run must not land in the real runtime home.
+	prevHome, hadHome := os.LookupEnv("ERGO_PROXY_HOME")
+	if err := os.Setenv("ERGO_PROXY_HOME", home); err != nil {
+		return err
+	}
+	defer func() {
+		if hadHome {
+			_ = os.Setenv("ERGO_PROXY_HOME", prevHome)
+		} else {
+			_ = os.Unsetenv("ERGO_PROXY_HOME")
+		}
+	}()
+
+	presolve := false
+	cfg := appConfig{
+		BaseURL: srv.URL,
+		Cookie:  selftestCookie,
+		Proxy:   proxyDirect,
+		AI: aiConfig{
+			Enabled:  true,
+			Model:    "selftest",
+			BaseURL:  srv.URL + "/v1",
+			APIKey:   "selftest",
+			Presolve: &presolve,
+		},
+	}
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	configPath := filepath.Join(home, "config.json")
+	if err := os.WriteFile(configPath, b, 0o600); err != nil {
+		return err
+	}
+
+	out, err := runOnline(ctx, log, onlineRun{configPath: configPath, count: 1})
+	if err != nil {
+		return err
+	}
+	if out.solved != 1 {
+		return fmt.Errorf("solved %d puzzles, want 1", out.solved)
+	}
+	return site.verify()
+}
+
+// mockSite imitates the puzzle site's API and an OpenAI-compatible chat
+// completions endpoint for the selftest.
+type mockSite struct {
+	mu        sync.Mutex
+	pow       bool
+	challenge string
+	submitted bool
+	correct   bool
+}
+
+// verify reports whether the run did everything the selftest expects.
+func (m *mockSite) verify() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case !m.pow:
+		return errors.New("no proof-of-work was verified")
+	case !m.submitted:
+		return errors.New("no answer was submitted")
+	case !m.correct:
+		return errors.New("the submitted answer was wrong")
+	}
+	return nil
+}
+
+func (m *mockSite) handler() http.Handler {
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	authed := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if ck, err := r.Cookie("session"); err != nil || "session="+ck.Value != selftestCookie {
+				w.WriteHeader(http.StatusUnauthorized)
+				reply(w, map[string]string{"message": "not logged in"})
+				return
+			}
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			h(w, r)
+		}
+	}
+
+	mux.HandleFunc("GET /api/auth/me", authed(func(w http.ResponseWriter, r *http.Request) {
+		var me authMeResponse
+		me.User.ID, me.User.Username = "selftest", "selftest"
+		reply(w, me)
+	}))
+	mux.HandleFunc("GET /api/daily/remaining", authed(func(w http.ResponseWriter, r *http.Request) {
+		reply(w, dailyRemainingResponse{Remaining: 1, Limit: 1})
+	}))
+	mux.HandleFunc("GET /api/pow/status", authed(func(w http.ResponseWriter, r *http.Request) {
+		st := powStatusResponse{HasValidPow: m.pow}
+		if m.pow {
+			st.PowExpiresAt = time.Now().Add(time.Hour).UnixMilli()
+		}
+		reply(w, st)
+	}))
+	mux.HandleFunc("POST /api/pow/challenge", authed(func(w http.ResponseWriter, r *http.Request) {
+		m.challenge = newTraceID()
+		reply(w, powChallengeResponse{Challenge: m.challenge, Difficulty: 2, ExpiresAt: time.Now().Add(5 * time.Minute).UnixMilli()})
+	}))
+	mux.HandleFunc("POST /api/pow/verify", authed(func(w http.ResponseWriter, r *http.Request) {
+		var req powVerifyRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Challenge != m.challenge || !hasLeadingZeroNibbles(sha256.Sum256([]byte(req.Challenge+req.Nonce)), 1, false) {
+			w.WriteHeader(http.StatusBadRequest)
+			reply(w, map[string]string{"message": "invalid proof-of-work"})
+			return
+		}
+		m.pow = true
+		reply(w, map[string]bool{"success": true})
+	}))
+	mux.HandleFunc("GET /api/puzzle/new", authed(func(w http.ResponseWriter, r *http.Request) {
+		p := selftestPuzzle
+		p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width = len(selftestAnswer), len(selftestAnswer[0])
+		reply(w, puzzleNewResponse{Puzzle: p, RemainingAttempts: 1, DailyRemaining: 1, DailyLimit: 1})
+	}))
+	mux.HandleFunc("POST /api/puzzle/submit", authed(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			PuzzleID string  `json:"puzzleId"`
+			Answer   [][]int `json:"answer"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		m.submitted, m.correct = true, req.PuzzleID == selftestPuzzle.ID && gridsEqual(req.Answer, selftestAnswer)
+		reply(w, puzzleSubmitResponse{Success: true, Correct: m.correct, Message: "selftest", PointsAwarded: 1, PointsBalance: 1})
+	}))
+
+	// The stub AI answers every solve request with selftestAnswer and
+	// accepts every answer it is asked to verify.
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		content, _ := json.Marshal(Answer{Reasoning: "Every 1 turns 2.", Answer: selftestAnswer, Confidence: 99})
+		if strings.Contains(string(body), "verify_response") {
+			content, _ = json.Marshal(VerifyResult{Valid: true, Reasoning: "Matches the training pairs."})
+		}
+		chunk, _ := json.Marshal(map[string]any{
+			"id": "selftest", "object": "chat.completion.chunk", "created": time.Now().Unix(), "model": "selftest",
+			"choices": []map[string]any{{"index": 0, "delta": map[string]string{"role": "assistant", "content": string(content)}, "finish_reason": "stop"}},
+		})
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", chunk)
+	})
+	return mux
+}
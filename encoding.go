@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ai.grid_encoding values.
+const (
+	gridEncodingJSON  = "json"
+	gridEncodingDense = "dense"
+	gridEncodingRLE   = "rle"
+	gridEncodingChars = "chars"
+)
+
+// colorChars are the characters of the chars encoding for colors 0-9, after
+// the ARC color names: black, blue, red, green, yellow, grey, magenta,
+// orange, azure, maroon.
+const colorChars = ".brgyxmoaw"
+
+// puzzleBlock renders p as the leading user content of every request about
+// it, so solve, refine and verify requests for one puzzle share a
+// byte-identical prefix after their system prompt. encoding picks how grids
+// are written; every encoding but json writes one grid row per line, which
+// is far shorter than indented JSON for large grids.
+func puzzleBlock(p puzzle, encoding string) (string, error) {
+	var rowFn func([]int) string
+	var legend string
+	switch encoding {
+	case "", gridEncodingJSON:
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("marshal puzzle: %w", err)
+		}
+		return "## Puzzle (training examples + test input):\n" + string(b), nil
+	case gridEncodingDense:
+		rowFn, legend = denseRow, "one row per line, one digit (color 0-9) per cell"
+	case gridEncodingRLE:
+		rowFn, legend = rleRow, `one row per line, cells as space-separated colors 0-9, where "c*n" is n cells of color c in a row`
+	case gridEncodingChars:
+		rowFn, legend = charsRow, "one row per line, one character per cell ("+charsLegend()+"); answer with color numbers"
+	default:
+		return "", fmt.Errorf("unknown ai.grid_encoding %q", encoding)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Puzzle %s (training examples + test input; grids are written %s):\n", p.ID, legend)
+	fmt.Fprintf(&b, "Background color: %d\n", p.Hints.BackgroundColor)
+	fmt.Fprintf(&b, "Answer size: %d rows × %d columns\n", p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width)
+	writeGrid := func(label string, g [][]int) {
+		fmt.Fprintf(&b, "\n### %s (%s)\n", label, gridDims(g))
+		for _, row := range g {
+			b.WriteString(rowFn(row))
+			b.WriteByte('\n')
+		}
+	}
+	for i, ex := range p.Train {
+		writeGrid(fmt.Sprintf("Training pair %d input", i+1), ex.Input)
+		writeGrid(fmt.Sprintf("Training pair %d output", i+1), ex.Output)
+	}
+	writeGrid("Test input", p.TestInput)
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// cellString writes a cell of the text encodings; values outside 0-9 keep
+// their number in brackets so nothing is lost.
+func cellString(v int, char bool) string {
+	switch {
+	case v < 0 || v > 9:
+		return "[" + strconv.Itoa(v) + "]"
+	case char:
+		return string(colorChars[v])
+	default:
+		return strconv.Itoa(v)
+	}
+}
+
+func denseRow(row []int) string {
+	var b strings.Builder
+	for _, v := range row {
+		b.WriteString(cellString(v, false))
+	}
+	return b.String()
+}
+
+func charsRow(row []int) string {
+	var b strings.Builder
+	for _, v := range row {
+		b.WriteString(cellString(v, true))
+	}
+	return b.String()
+}
+
+func rleRow(row []int) string {
+	var runs []string
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		run := cellString(row[i], false)
+		if j-i > 1 {
+			run += "*" + strconv.Itoa(j-i)
+		}
+		runs = append(runs, run)
+		i = j
+	}
+	return strings.Join(runs, " ")
+}
+
+func charsLegend() string {
+	pairs := make([]string, len(colorChars))
+	for i := range colorChars {
+		pairs[i] = fmt.Sprintf("%c=%d", colorChars[i], i)
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Ensemble vote strategies (ai.vote).
+const (
+	voteExact = "exact"
+	voteCell  = "cell"
+)
+
+// modelAnswer is one ensemble member's result.
+type modelAnswer struct {
+	Model  string
+	Answer Answer
+	Err    error
+}
+
+// solveEnsemble queries every model concurrently and combines the answers
+// with the configured vote strategy. Members that fail are ignored as long
+// as at least one answers; if all fail the first error is returned. The
+// answers of the members are returned by model too.
+func (s *Solver) solveEnsemble(ctx context.Context, p puzzle, models []string) (Answer, map[string][][]int, error) {
+	log := s.log.forContext(ctx)
+
+	spin := newSpinner()
+	spin.Start(fmt.Sprintf("🔍 Querying %d models...", len(models)))
+	results := make([]modelAnswer, len(models))
+	var wg sync.WaitGroup
+	for i, m := range models {
+		wg.Add(1)
+		go func(i int, m string) {
+			defer wg.Done()
+			a, err := s.solveOnce(ctx, p, m)
+			results[i] = modelAnswer{Model: m, Answer: a, Err: err}
+		}(i, m)
+	}
+	wg.Wait()
+	spin.Stop()
+
+	var ok []modelAnswer
+	var firstErr error
+	for _, r := range results {
+		if r.Err != nil {
+			log.warnf("ensemble member %s failed: %v", r.Model, r.Err)
+			if firstErr == nil {
+				firstErr = r.Err
+			}
+			continue
+		}
+		fmt.Fprintf(uiOut, "%s📦 %s: %s, confidence %d%%%s\n", colorDim, r.Model, gridDims(r.Answer.Answer), r.Answer.Confidence, colorReset)
+		ok = append(ok, r)
+	}
+	if len(ok) == 0 {
+		return Answer{}, nil, firstErr
+	}
+	members := map[string][][]int{}
+	for _, r := range ok {
+		members[r.Model] = r.Answer.Answer
+	}
+
+	var (
+		winner Answer
+		votes  int
+	)
+	switch strings.ToLower(strings.TrimSpace(s.cfg.Vote)) {
+	case voteCell:
+		winner, votes = voteByCell(ok)
+	case voteWeighted:
+		winner, votes = voteWeightedMatch(ok, s.voteWeights(ctx, p, models))
+	case "", voteExact:
+		winner, votes = voteExactMatch(ok)
+	default:
+		return Answer{}, nil, fmt.Errorf("unknown ai.vote %q (want %q, %q or %q)", s.cfg.Vote, voteExact, voteCell, voteWeighted)
+	}
+	if len(winner.Answer) == 0 {
+		return Answer{}, nil, errors.New("ensemble produced no answer")
+	}
+
+	fmt.Fprintf(uiOut, "%s🗳  Ensemble vote: %d/%d agree%s\n", colorGreen, votes, len(ok), colorReset)
+	printAnswerDetails(winner)
+	return winner, members, nil
+}
+
+// gridKey returns a canonical string for exact grid comparison.
+func gridKey(g [][]int) string {
+	b, _ := json.Marshal(g)
+	return string(b)
+}
+
+// voteExactMatch picks the grid returned by the most models. Ties are broken
+// by summed confidence, then by model order. The winning answer carries the
+// reasoning of its most confident supporter.
+func voteExactMatch(answers []modelAnswer) (Answer, int) {
+	type bucket struct {
+		first int
+		count int
+		conf  int
+		best  Answer
+	}
+	buckets := map[string]*bucket{}
+	for i, a := range answers {
+		k := gridKey(a.Answer.Answer)
+		b, ok := buckets[k]
+		if !ok {
+			b = &bucket{first: i, best: a.Answer}
+			buckets[k] = b
+		}
+		b.count++
+		b.conf += a.Answer.Confidence
+		if a.Answer.Confidence > b.best.Confidence {
+			b.best = a.Answer
+		}
+	}
+	list := make([]*bucket, 0, len(buckets))
+	for _, b := range buckets {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].count != list[j].count {
+			return list[i].count > list[j].count
+		}
+		if list[i].conf != list[j].conf {
+			return list[i].conf > list[j].conf
+		}
+		return list[i].first < list[j].first
+	})
+	return list[0].best, list[0].count
+}
+
+// voteByCell builds the answer cell by cell from the answers sharing the
+// most common dimensions. Each cell takes its most frequent value (ties go
+// to the earliest model). The reported vote count is the number of models
+// whose full grid equals the result.
+func voteByCell(answers []modelAnswer) (Answer, int) {
+	dimCount := map[string]int{}
+	for _, a := range answers {
+		dimCount[gridDims(a.Answer.Answer)]++
+	}
+	bestDims, bestN := "", 0
+	for _, a := range answers {
+		d := gridDims(a.Answer.Answer)
+		if dimCount[d] > bestN {
+			bestDims, bestN = d, dimCount[d]
+		}
+	}
+
+	var same []Answer
+	for _, a := range answers {
+		if gridDims(a.Answer.Answer) == bestDims {
+			same = append(same, a.Answer)
+		}
+	}
+	h, w := len(same[0].Answer), gridWidth(same[0].Answer)
+	out := make([][]int, h)
+	conf := 0
+	for _, a := range same {
+		conf += a.Confidence
+	}
+	for i := 0; i < h; i++ {
+		out[i] = make([]int, w)
+		for j := 0; j < w; j++ {
+			var counts [10]int
+			best := -1
+			for _, a := range same {
+				if j >= len(a.Answer[i]) {
+					continue
+				}
+				v := a.Answer[i][j]
+				if v < 0 || v > 9 {
+					continue
+				}
+				counts[v]++
+				if best == -1 || counts[v] > counts[best] {
+					best = v
+				}
+			}
+			if best < 0 {
+				best = 0
+			}
+			out[i][j] = best
+		}
+	}
+
+	agree := 0
+	reasoning := ""
+	key := gridKey(out)
+	for _, a := range same {
+		if gridKey(a.Answer) == key {
+			agree++
+			if reasoning == "" {
+				reasoning = a.Reasoning
+			}
+		}
+	}
+	if reasoning == "" {
+		reasoning = same[0].Reasoning
+	}
+	return Answer{Reasoning: reasoning, Answer: out, Confidence: conf / len(same)}, agree
+}
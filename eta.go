@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Auto mode waits between puzzles: longer after a correct answer to pace the
+// daily quota, shorter after a failure before moving on.
+const (
+	autoDelayMin      = 60 * time.Second
+	autoDelayMax      = 300 * time.Second
+	autoRetryDelayMin = 30 * time.Second
+	autoRetryDelayMax = 60 * time.Second
+)
+
+// etaSmoothing is the weight of the newest solve time in the moving average.
+const etaSmoothing = 0.3
+
+// thinkTime draws the thinking time for one puzzle, or 0 when the floor is
+// off.
+func thinkTime(cfg thinkConfig) time.Duration {
+	if cfg.MinSeconds <= 0 {
+		return 0
+	}
+	lo := time.Duration(cfg.MinSeconds) * time.Second
+	hi := time.Duration(cfg.MaxSeconds) * time.Second
+	if hi <= lo {
+		hi = 2 * lo
+	}
+	return randDelay(lo, hi)
+}
+
+// randDelay returns a uniformly random duration in [lo, hi).
+func randDelay(lo, hi time.Duration) time.Duration {
+	return lo + time.Duration(rand.Int63n(int64(hi-lo)))
+}
+
+// etaEstimator keeps an exponentially smoothed per-puzzle solve time so auto
+// mode can estimate when the remaining daily quota will be used up.
+type etaEstimator struct {
+	avg     time.Duration
+	samples int
+}
+
+// observe folds one puzzle's fetch-to-submit time into the average.
+func (e *etaEstimator) observe(d time.Duration) {
+	if e.samples == 0 {
+		e.avg = d
+	} else {
+		e.avg = time.Duration(etaSmoothing*float64(d) + (1-etaSmoothing)*float64(e.avg))
+	}
+	e.samples++
+}
+
+// eta estimates the time to finish remaining puzzles, each preceded by an
+// average auto-mode delay. ok is false until a solve time has been observed.
+func (e *etaEstimator) eta(remaining int) (d time.Duration, ok bool) {
+	if e.samples == 0 || remaining <= 0 {
+		return 0, e.samples > 0
+	}
+	delay := (autoDelayMin + autoDelayMax) / 2
+	return time.Duration(remaining) * (e.avg + delay), true
+}
+
+// describe formats the estimate for log lines.
+func (e *etaEstimator) describe(remaining int) string {
+	d, ok := e.eta(remaining)
+	if !ok {
+		return "unknown"
+	}
+	return d.Round(time.Minute).String() + " (avg solve " + e.avg.Round(time.Second).String() + ")"
+}
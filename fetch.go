@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// savePuzzleResponse writes the full puzzleNew response (puzzle, hints and
+// counters) to dir as <timestamp>-<id>.json and returns the path.
+func savePuzzleResponse(dir string, resp *puzzleNewResponse, at time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("mkdir puzzles dir: %w", err)
+	}
+	b, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal puzzle: %w", err)
+	}
+	id := strings.NewReplacer("/", "_", "\\", "_").Replace(resp.Puzzle.ID)
+	path := filepath.Join(dir, at.Format("20060102-150405")+"-"+id+".json")
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return "", fmt.Errorf("write puzzle: %w", err)
+	}
+	return path, nil
+}
+
+func runFetch(ctx context.Context, log *logger, args []string) error {
+	fs := flag.NewFlagSet(cmdFetch, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath string
+		outDir     string
+	)
+	fs.StringVar(&configPath, "config", "", "config path (required)")
+	fs.StringVar(&outDir, "out", "", "directory to save puzzles in (default: $ERGO_PROXY_HOME/puzzles)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		return errors.New("--config is required")
+	}
+	if outDir == "" {
+		outDir = filepath.Join(homeDir(configPath), puzzlesDirName)
+	}
+
+	sess, err := openSession(ctx, configPath, log, nil)
+	if err != nil {
+		return err
+	}
+	if err := ensurePow(ctx, sess.client, log); err != nil {
+		return err
+	}
+	sess.persist(log)
+
+	pNew, err := sess.client.puzzleNew(ctx)
+	if err != nil {
+		if isDailyExhaustedError(err) {
+			log.warn("daily limit exhausted, nothing to fetch")
+			return nil
+		}
+		return err
+	}
+	sess.persist(log)
+
+	path, err := savePuzzleResponse(outDir, pNew, time.Now())
+	if err != nil {
+		return err
+	}
+	log.okf("puzzle saved: puzzleId=%s train=%d answer=%d×%d file=%s", pNew.Puzzle.ID, len(pNew.Puzzle.Train), pNew.Puzzle.Hints.AnswerSize.Height, pNew.Puzzle.Hints.AnswerSize.Width, path)
+	return nil
+}
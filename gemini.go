@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const defaultGeminiBaseURL = "https://generativelanguage.googleapis.com"
+
+// geminiClient talks to the Gemini Generative Language API. Structured
+// output uses the response JSON schema, so the reply text is the answer
+// JSON itself.
+type geminiClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+type geminiPart struct {
+	Text       string      `json:"text,omitempty"`
+	InlineData *geminiBlob `json:"inlineData,omitempty"`
+	// Thought marks a thinking summary part, which is not answer text.
+	Thought bool `json:"thought,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	ResponseMimeType   string         `json:"responseMimeType"`
+	ResponseJSONSchema map[string]any `json:"responseJsonSchema"`
+	// Temperature is left to the API default when nil.
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type geminiRequest struct {
+	SystemInstruction geminiContent          `json:"systemInstruction"`
+	Contents          []geminiContent        `json:"contents"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount        int64 `json:"promptTokenCount"`
+		CandidatesTokenCount    int64 `json:"candidatesTokenCount"`
+		CachedContentTokenCount int64 `json:"cachedContentTokenCount"`
+		ThoughtsTokenCount      int64 `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+type geminiError struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// request builds the generateContent request for prompt, mirroring the
+// layout of Solver.messages: puzzle text, captioned images, then the query.
+// Gemini caches repeated prefixes implicitly, so no markers are needed.
+func (c *geminiClient) request(prompt chatPrompt, schema map[string]any) (geminiRequest, error) {
+	parts := []geminiPart{{Text: prompt.puzzle}}
+	for _, img := range prompt.images {
+		mediaType, data, ok := parseDataURL(img.dataURL)
+		if !ok {
+			return geminiRequest{}, fmt.Errorf("image %q is not a base64 data URL", img.caption)
+		}
+		parts = append(parts, geminiPart{Text: img.caption}, geminiPart{InlineData: &geminiBlob{MimeType: mediaType, Data: data}})
+	}
+	parts = append(parts, geminiPart{Text: prompt.query})
+	gen := geminiGenerationConfig{ResponseMimeType: "application/json", ResponseJSONSchema: schema}
+	if prompt.temperature > 0 {
+		gen.Temperature = &prompt.temperature
+	}
+	return geminiRequest{
+		SystemInstruction: geminiContent{Parts: []geminiPart{{Text: prompt.system}}},
+		Contents:          []geminiContent{{Role: "user", Parts: parts}},
+		GenerationConfig:  gen,
+	}, nil
+}
+
+// complete sends one generateContent request and returns the reply text,
+// which the response schema constrains to the answer JSON.
+func (c *geminiClient) complete(ctx context.Context, model string, prompt chatPrompt, _, _ string, schema map[string]any) (string, tokenUsage, error) {
+	used := tokenUsage{Requests: 1}
+	body, err := c.request(prompt, schema)
+	if err != nil {
+		return "", used, err
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", used, fmt.Errorf("marshal request: %w", err)
+	}
+	endpoint := strings.TrimRight(c.baseURL, "/") + "/v1beta/models/" + url.PathEscape(strings.TrimPrefix(model, "models/")) + ":generateContent"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return "", used, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", c.apiKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", used, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", used, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		var e geminiError
+		msg := "gemini: " + resp.Status
+		if json.Unmarshal(raw, &e) == nil && e.Error.Message != "" {
+			msg += ": " + e.Error.Status + ": " + e.Error.Message
+		}
+		return "", used, &backendStatusError{StatusCode: resp.StatusCode, msg: msg}
+	}
+
+	var out geminiResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return "", used, fmt.Errorf("parse response: %w", err)
+	}
+	u := out.UsageMetadata
+	used = tokenUsage{
+		Requests:         1,
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+		ReasoningTokens:  u.ThoughtsTokenCount,
+		CachedTokens:     u.CachedContentTokenCount,
+	}
+	if r := out.PromptFeedback.BlockReason; r != "" {
+		return "", used, fmt.Errorf("prompt blocked: %s", r)
+	}
+	if len(out.Candidates) == 0 {
+		return "", used, errors.New("no candidates in response")
+	}
+	cand := out.Candidates[0]
+	if cand.FinishReason == "MAX_TOKENS" {
+		return "", used, errors.New("response truncated at the output token limit")
+	}
+	var text strings.Builder
+	for _, p := range cand.Content.Parts {
+		if !p.Thought {
+			text.WriteString(p.Text)
+		}
+	}
+	return text.String(), used, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// arcPalette holds the canonical ARC colors (RGB) for values 0-9.
+var arcPalette = [10][3]uint8{
+	{0x00, 0x00, 0x00}, // 0 black
+	{0x00, 0x74, 0xD9}, // 1 blue
+	{0xFF, 0x41, 0x36}, // 2 red
+	{0x2E, 0xCC, 0x40}, // 3 green
+	{0xFF, 0xDC, 0x00}, // 4 yellow
+	{0xAA, 0xAA, 0xAA}, // 5 grey
+	{0xF0, 0x12, 0xBE}, // 6 magenta
+	{0xFF, 0x85, 0x1B}, // 7 orange
+	{0x7F, 0xDB, 0xFF}, // 8 azure
+	{0x87, 0x0C, 0x25}, // 9 maroon
+}
+
+// colorEnabled reports whether ANSI colors should be written to f.
+func colorEnabled(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && (fi.Mode()&os.ModeCharDevice) != 0
+}
+
+// renderGridLines renders grid as one string per row, two columns per cell.
+// With color each cell is a colored block showing its digit; without color
+// only the digits are printed.
+func renderGridLines(grid [][]int, color bool) []string {
+	return renderGridCursor(grid, color, -1, -1)
+}
+
+// renderGridCursor is renderGridLines with the cell at row, col marked by a
+// "›" before its digit, for the grid editor.
+func renderGridCursor(grid [][]int, color bool, row, col int) []string {
+	lines := make([]string, 0, len(grid))
+	for r, cells := range grid {
+		var b strings.Builder
+		for c, v := range cells {
+			text := fmt.Sprintf("%2d", v)
+			if r == row && c == col {
+				text = fmt.Sprintf("›%d", v)
+			}
+			if !color || v < 0 || v > 9 {
+				b.WriteString(text)
+				continue
+			}
+			pc := arcPalette[v]
+			fg := "97"
+			if v == 4 || v == 5 || v == 8 {
+				fg = "30"
+			}
+			fmt.Fprintf(&b, "\033[48;2;%d;%d;%dm\033[%sm%s%s", pc[0], pc[1], pc[2], fg, text, colorReset)
+		}
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+// gridWidth returns the widest row length in grid.
+func gridWidth(grid [][]int) int {
+	w := 0
+	for _, row := range grid {
+		if len(row) > w {
+			w = len(row)
+		}
+	}
+	return w
+}
+
+// gridDims formats grid dimensions as rows×cols.
+func gridDims(grid [][]int) string {
+	return fmt.Sprintf("%d×%d", len(grid), gridWidth(grid))
+}
+
+// colorCounts formats how many cells of each value appear in grid.
+func colorCounts(grid [][]int) string {
+	counts := map[int]int{}
+	for _, row := range grid {
+		for _, v := range row {
+			counts[v]++
+		}
+	}
+	keys := make([]int, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%d:%d", k, counts[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// sideBySide joins two blocks of rendered lines horizontally. leftWidth is
+// the visible width of the left block (escape codes excluded).
+func sideBySide(left, right []string, leftWidth int, sep string) []string {
+	n := len(left)
+	if len(right) > n {
+		n = len(right)
+	}
+	blank := strings.Repeat(" ", leftWidth)
+	out := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		l, r := blank, ""
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		s := sep
+		if i > 0 {
+			s = strings.Repeat(" ", len([]rune(sep)))
+		}
+		out = append(out, l+s+r)
+	}
+	return out
+}
+
+// printAnswerPreview shows the test input beside the answer about to be
+// submitted, so a person watching the run can spot an obviously broken
+// answer. It prints only when uiOut is a terminal, in color unless NO_COLOR
+// is set.
+func printAnswerPreview(p puzzle, answer [][]int) {
+	f, ok := uiOut.(*os.File)
+	if !ok || !isTerminal(f) {
+		return
+	}
+	color := colorEnabled(f)
+	left := renderGridLines(p.TestInput, color)
+	right := renderGridLines(answer, color)
+	_, _ = fmt.Fprintf(f, "%s👀 Test input %s → answer %s%s\n", colorCyan, gridDims(p.TestInput), gridDims(answer), colorReset)
+	for _, line := range sideBySide(left, right, gridWidth(p.TestInput)*2, "  →  ") {
+		_, _ = fmt.Fprintln(f, line)
+	}
+}
@@ -0,0 +1,583 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Run history stores (history.store).
+const (
+	historySQLite   = "sqlite"
+	historyJSONL    = "jsonl"
+	historyPostgres = "postgres"
+)
+
+// historyFileName is the SQLite run history inside the runtime home;
+// historyJSONLFileName is the JSONL one.
+const (
+	historyFileName      = "history.db"
+	historyJSONLFileName = "history.jsonl"
+)
+
+// historyStore is a run history backend. All stores answer every query the
+// same way; they differ only in how the records are kept.
+type historyStore interface {
+	savePuzzle(ctx context.Context, p puzzle, at time.Time) error
+	record(ctx context.Context, a attempt) error
+	mergeServerSubmissions(ctx context.Context, subs []serverSubmission) (added int, err error)
+	modelStats(ctx context.Context, since, recent time.Time) ([]modelStats, error)
+	dailyStats(ctx context.Context, since time.Time) ([]dayStats, error)
+	streaks(ctx context.Context) (current, longest int, err error)
+	recent(ctx context.Context, limit int, failedOnly bool) ([]historyEntry, error)
+	modelAccuracy(ctx context.Context, class string) (inClass, overall map[string]accuracyRecord, err error)
+	wrongAnswers(ctx context.Context, id string) ([][][]int, error)
+	lastCanary(ctx context.Context) (time.Time, error)
+	close() error
+}
+
+// history records fetched puzzles and solve attempts in the configured
+// store. A nil *history is a valid no-op store so a broken database never
+// stops a run.
+type history struct {
+	store historyStore
+}
+
+// historyPath returns the file of the store cfg selects in its runtime
+// home, or the DSN of a Postgres store.
+func historyPath(cfg appConfig) (store, path string, err error) {
+	switch cfg.History.Store {
+	case "", historySQLite:
+		return historySQLite, filepath.Join(cfg.home, historyFileName), nil
+	case historyJSONL:
+		return historyJSONL, filepath.Join(cfg.home, historyJSONLFileName), nil
+	case historyPostgres:
+		dsn := cfg.History.DSN
+		if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+			return "", "", fmt.Errorf("history.store %q needs a postgres:// history.dsn", historyPostgres)
+		}
+		return historyPostgres, dsn, nil
+	default:
+		return "", "", fmt.Errorf("unknown history.store %q (want %q, %q or %q)", cfg.History.Store, historySQLite, historyJSONL, historyPostgres)
+	}
+}
+
+// openHistory opens, creating it if needed, the run history cfg selects.
+func openHistory(cfg appConfig) (*history, error) {
+	store, path, err := historyPath(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var s historyStore
+	if store == historyPostgres {
+		s, err = openPostgresHistory(path)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("mkdir history dir: %w", err)
+		}
+		if store == historyJSONL {
+			s, err = openJSONLHistory(path)
+		} else {
+			s, err = openSQLiteHistory(path)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &history{store: s}, nil
+}
+
+// openHistoryFile opens an existing run history for the read-only
+// commands, failing if no run has created one yet. A Postgres store is
+// opened as is.
+func openHistoryFile(configPath string) (*history, error) {
+	cfg, err := loadConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	store, path, err := historyPath(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if store == historyPostgres {
+		return openHistory(cfg)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no run history at %s: %w", path, err)
+	}
+	return openHistory(cfg)
+}
+
+func (h *history) close() {
+	if h != nil {
+		_ = h.store.close()
+	}
+}
+
+// savePuzzle stores the puzzle as first fetched; refetches keep the original.
+func (h *history) savePuzzle(ctx context.Context, p puzzle, at time.Time) error {
+	if h == nil {
+		return nil
+	}
+	return h.store.savePuzzle(ctx, p, at)
+}
+
+func (h *history) record(ctx context.Context, a attempt) error {
+	if h == nil {
+		return nil
+	}
+	return h.store.record(ctx, a)
+}
+
+// mergeServerSubmissions imports submissions the local history lacks. A
+// puzzle's local submitted attempts are matched to its server submissions
+// oldest first, so only the surplus (answers given in the browser or by
+// another machine) is added and repeated syncs add nothing new.
+func (h *history) mergeServerSubmissions(ctx context.Context, subs []serverSubmission) (added int, err error) {
+	if h == nil {
+		return 0, nil
+	}
+	return h.store.mergeServerSubmissions(ctx, subs)
+}
+
+func (h *history) modelStats(ctx context.Context, since, recent time.Time) ([]modelStats, error) {
+	return h.store.modelStats(ctx, since, recent)
+}
+
+// dailyStats groups submitted attempts by local day, oldest first.
+func (h *history) dailyStats(ctx context.Context, since time.Time) ([]dayStats, error) {
+	return h.store.dailyStats(ctx, since)
+}
+
+// streaks returns the current and longest runs of consecutive correct
+// submissions. Dry runs and unsubmitted failures do not break a streak.
+func (h *history) streaks(ctx context.Context) (current, longest int, err error) {
+	return h.store.streaks(ctx)
+}
+
+// recent returns up to limit attempts, newest first. failedOnly keeps
+// attempts that errored or were judged incorrect.
+func (h *history) recent(ctx context.Context, limit int, failedOnly bool) ([]historyEntry, error) {
+	return h.store.recent(ctx, limit, failedOnly)
+}
+
+// modelAccuracy returns, per model, its judged attempts on puzzles of class
+// and overall. Ensemble members count too: a member is right when its
+// answer was the one judged correct, and wrong when the judged answer was
+// incorrect and equal to its own, or correct and different. Imported
+// submissions, which have no model, and attempts whose puzzle was never
+// saved are left out.
+func (h *history) modelAccuracy(ctx context.Context, class string) (inClass, overall map[string]accuracyRecord, err error) {
+	if h == nil {
+		return map[string]accuracyRecord{}, map[string]accuracyRecord{}, nil
+	}
+	return h.store.modelAccuracy(ctx, class)
+}
+
+// wrongAnswers returns the distinct answers to puzzle id that the site
+// graded incorrect in earlier submissions, including imported ones, oldest
+// first.
+func (h *history) wrongAnswers(ctx context.Context, id string) ([][][]int, error) {
+	if h == nil {
+		return nil, nil
+	}
+	return h.store.wrongAnswers(ctx, id)
+}
+
+// lastCanary returns when the most recent canary that succeeded (no error)
+// started, or the zero time if none did.
+func (h *history) lastCanary(ctx context.Context) (time.Time, error) {
+	if h == nil {
+		return time.Time{}, nil
+	}
+	return h.store.lastCanary(ctx)
+}
+
+// attempt is one solve of one puzzle, whether or not it was submitted.
+type attempt struct {
+	PuzzleID  string
+	StartedAt time.Time
+	Result    solveResult
+	SolveTime time.Duration
+	DryRun    bool
+	Submitted bool
+	Correct   *bool
+	Points    int
+	Err       error
+	// Spend is the AI usage and estimated cost of the solve.
+	Spend spend
+	// Repairs counts the local fix-ups of answer grids during the solve.
+	Repairs int
+	// Canary marks the attempt of a solve --canary run.
+	Canary bool
+}
+
+// serverModel is the model recorded for submissions imported by stats sync,
+// which the server does not attribute.
+const serverModel = "(server)"
+
+// modelStats summarises attempts for one model. Counted and Repaired are
+// the attempts whose answer repairs were counted and those that needed at
+// least one; the Recent pair covers only attempts since the recent cutoff.
+type modelStats struct {
+	Model     string
+	Attempts  int
+	Submitted int
+	Correct   int
+	AvgSolve  time.Duration
+	Tokens    int64
+	Cost      float64
+
+	Counted, Repaired             int
+	RecentCounted, RecentRepaired int
+}
+
+// dayStats summarises submitted attempts for one local calendar day.
+type dayStats struct {
+	Day       string
+	Submitted int
+	Correct   int
+	Points    int
+}
+
+// dayTally groups submitted attempts by local calendar day.
+type dayTally map[string]*dayStats
+
+func (t dayTally) add(at time.Time, correct bool, points int) {
+	day := at.Format(time.DateOnly)
+	d := t[day]
+	if d == nil {
+		d = &dayStats{Day: day}
+		t[day] = d
+	}
+	d.Submitted++
+	if correct {
+		d.Correct++
+	}
+	d.Points += points
+}
+
+// list returns the days oldest first.
+func (t dayTally) list() []dayStats {
+	out := make([]dayStats, 0, len(t))
+	for _, d := range t {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Day < out[j].Day })
+	return out
+}
+
+// streak tracks the current and longest runs of correct submissions.
+type streak struct{ current, longest int }
+
+func (s *streak) add(correct bool) {
+	if correct {
+		s.current++
+		s.longest = max(s.longest, s.current)
+	} else {
+		s.current = 0
+	}
+}
+
+// groupSubmissions groups server submissions by puzzle, each oldest first,
+// with the puzzles in order of first appearance. Submissions without a
+// puzzle ID are dropped.
+func groupSubmissions(subs []serverSubmission) (order []string, byPuzzle map[string][]serverSubmission) {
+	byPuzzle = map[string][]serverSubmission{}
+	for _, s := range subs {
+		if s.PuzzleID == "" {
+			continue
+		}
+		if _, ok := byPuzzle[s.PuzzleID]; !ok {
+			order = append(order, s.PuzzleID)
+		}
+		byPuzzle[s.PuzzleID] = append(byPuzzle[s.PuzzleID], s)
+	}
+	for _, list := range byPuzzle {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].SubmittedAt < list[j].SubmittedAt })
+	}
+	return order, byPuzzle
+}
+
+// dayStreaks returns the current and longest runs of consecutive days with
+// at least one correct submission. The current run may end yesterday.
+func dayStreaks(days []dayStats, today time.Time) (current, longest int) {
+	var prev time.Time
+	run := 0
+	for _, d := range days {
+		if d.Correct == 0 {
+			continue
+		}
+		t, err := time.ParseInLocation(time.DateOnly, d.Day, time.Local)
+		if err != nil {
+			continue
+		}
+		if !prev.IsZero() && prev.AddDate(0, 0, 1).Equal(t) {
+			run++
+		} else {
+			run = 1
+		}
+		prev = t
+		longest = max(longest, run)
+	}
+	y, m, dd := today.Date()
+	midnight := time.Date(y, m, dd, 0, 0, 0, 0, time.Local)
+	if !prev.IsZero() && (prev.Equal(midnight) || prev.AddDate(0, 0, 1).Equal(midnight)) {
+		current = run
+	}
+	return current, longest
+}
+
+// historyEntry is one attempt as listed by the history command.
+type historyEntry struct {
+	PuzzleID   string    `json:"puzzleId"`
+	StartedAt  time.Time `json:"startedAt"`
+	Model      string    `json:"model"`
+	Confidence int       `json:"confidence"`
+	Verified   *bool     `json:"verified"`
+	DryRun     bool      `json:"dryRun"`
+	Submitted  bool      `json:"submitted"`
+	Correct    *bool     `json:"correct"`
+	Points     int       `json:"points"`
+	ElapsedMS  int64     `json:"elapsedMs"`
+	Tokens     int64     `json:"tokens"`
+	CostUSD    float64   `json:"costUsd"`
+	Error      string    `json:"error,omitempty"`
+	Canary     bool      `json:"canary,omitempty"`
+}
+
+func runHistory(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet(cmdHistory, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath string
+		limit      int
+		failedOnly bool
+		asJSON     bool
+	)
+	fs.StringVar(&configPath, "config", "", "config path (locates the runtime directory)")
+	fs.IntVar(&limit, "limit", 20, "how many attempts to list")
+	fs.BoolVar(&failedOnly, "failed-only", false, "list only failed or incorrect attempts")
+	fs.BoolVar(&asJSON, "json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if limit <= 0 {
+		return errors.New("--limit must be > 0")
+	}
+
+	h, err := openHistoryFile(configPath)
+	if err != nil {
+		return err
+	}
+	defer h.close()
+
+	entries, err := h.recent(ctx, limit, failedOnly)
+	if err != nil {
+		return fmt.Errorf("query history: %w", err)
+	}
+	if asJSON {
+		if entries == nil {
+			entries = []historyEntry{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	printHistory(os.Stdout, entries)
+	return nil
+}
+
+func printHistory(w io.Writer, entries []historyEntry) {
+	if len(entries) == 0 {
+		_, _ = fmt.Fprintln(w, "(no attempts recorded)")
+		return
+	}
+	for _, e := range entries {
+		result := "-"
+		switch {
+		case e.Error != "":
+			result = "error"
+		case e.Correct != nil && *e.Correct:
+			result = "correct"
+		case e.Correct != nil:
+			result = "incorrect"
+		case e.DryRun:
+			result = "dry-run"
+		}
+		if e.DryRun && e.Correct != nil {
+			result += " (holdout)"
+		}
+		if e.Canary {
+			result += " (canary)"
+		}
+		_, _ = fmt.Fprintf(w, "%s  %-32s  %-24s  %-18s  conf=%3d%%  %s\n",
+			e.StartedAt.Format("2006-01-02 15:04"), e.PuzzleID, e.Model, result, e.Confidence,
+			(time.Duration(e.ElapsedMS) * time.Millisecond).Round(100*time.Millisecond))
+		if e.Error != "" {
+			_, _ = fmt.Fprintf(w, "    %s\n", e.Error)
+		}
+	}
+}
+
+func runStats(ctx context.Context, log *logger, args []string) error {
+	if len(args) > 0 && args[0] == "sync" {
+		return runStatsSync(ctx, log, args[1:])
+	}
+	fs := flag.NewFlagSet(cmdStats, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath string
+		days       int
+	)
+	fs.StringVar(&configPath, "config", "", "config path (locates the runtime directory)")
+	fs.IntVar(&days, "days", 14, "how many days of per-day points and recent repair rates to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if days <= 0 {
+		return errors.New("--days must be > 0")
+	}
+
+	h, err := openHistoryFile(configPath)
+	if err != nil {
+		return err
+	}
+	defer h.close()
+
+	now := time.Now()
+	models, err := h.modelStats(ctx, time.Time{}, now.AddDate(0, 0, -days))
+	if err != nil {
+		return fmt.Errorf("query models: %w", err)
+	}
+	// Streaks look at the whole history; only the table is limited to --days.
+	allDays, err := h.dailyStats(ctx, time.Time{})
+	if err != nil {
+		return fmt.Errorf("query days: %w", err)
+	}
+	cur, longest, err := h.streaks(ctx)
+	if err != nil {
+		return fmt.Errorf("query streaks: %w", err)
+	}
+	dayCur, dayLongest := dayStreaks(allDays, now)
+
+	cutoff := now.AddDate(0, 0, -days+1).Format(time.DateOnly)
+	var recent []dayStats
+	for _, d := range allDays {
+		if d.Day >= cutoff {
+			recent = append(recent, d)
+		}
+	}
+	printStats(os.Stdout, models, days, recent, cur, longest, dayCur, dayLongest)
+	return nil
+}
+
+func percent(n, of int) string {
+	if of == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(of))
+}
+
+// repairTrend flags a model whose recent repair rate is at least double its
+// rate before the window, over at least a few repaired attempts: the
+// provider may have swapped the model behind the name.
+func repairTrend(m modelStats) string {
+	oldCounted, oldRepaired := m.Counted-m.RecentCounted, m.Repaired-m.RecentRepaired
+	if m.RecentRepaired < 3 || oldCounted == 0 {
+		return ""
+	}
+	if float64(m.RecentRepaired)/float64(m.RecentCounted) >= 2*float64(oldRepaired)/float64(oldCounted) {
+		return "  ⚠ repair rate rising"
+	}
+	return ""
+}
+
+// maxSyncPages bounds stats sync against a server that never stops
+// reporting more pages.
+const maxSyncPages = 1000
+
+// runStatsSync pulls the server-side submission history and merges it into
+// the local database, so stats also cover puzzles solved in the browser.
+func runStatsSync(ctx context.Context, log *logger, args []string) error {
+	fs := flag.NewFlagSet(cmdStats+" sync", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var configPath string
+	fs.StringVar(&configPath, "config", "", "config path (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		return errors.New("--config is required")
+	}
+
+	sess, err := openSession(ctx, configPath, log, nil)
+	if err != nil {
+		return err
+	}
+	var subs []serverSubmission
+	for page := 1; page <= maxSyncPages; page++ {
+		resp, err := sess.client.submissionHistory(ctx, page)
+		if err != nil {
+			var ae *apiError
+			if errors.As(err, &ae) && ae.StatusCode == 404 {
+				return fmt.Errorf("the server has no submission history endpoint: %w", err)
+			}
+			return fmt.Errorf("fetch submission history (page %d): %w", page, err)
+		}
+		subs = append(subs, resp.Submissions...)
+		if !resp.HasMore || len(resp.Submissions) == 0 {
+			break
+		}
+	}
+	sess.persist(log)
+	log.infof("server history: %d submissions", len(subs))
+
+	h, err := openHistory(sess.cfg)
+	if err != nil {
+		return err
+	}
+	defer h.close()
+	added, err := h.mergeServerSubmissions(ctx, subs)
+	if err != nil {
+		return fmt.Errorf("merge submission history: %w", err)
+	}
+	log.okf("stats sync: %d submissions added, %d already recorded", added, len(subs)-added)
+	return nil
+}
+
+func printStats(w io.Writer, models []modelStats, window int, days []dayStats, cur, longest, dayCur, dayLongest int) {
+	_, _ = fmt.Fprintln(w, "Per model:")
+	if len(models) == 0 {
+		_, _ = fmt.Fprintln(w, "  (no attempts recorded)")
+	}
+	for _, m := range models {
+		_, _ = fmt.Fprintf(w, "  %-32s attempts=%-4d correct=%d/%d (%s) avg solve=%s tokens=%d cost=$%.2f\n",
+			m.Model, m.Attempts, m.Correct, m.Submitted, percent(m.Correct, m.Submitted), m.AvgSolve.Round(100*time.Millisecond), m.Tokens, m.Cost)
+		if m.Counted > 0 {
+			_, _ = fmt.Fprintf(w, "  %-32s repaired=%d/%d (%s), last %dd %d/%d (%s)%s\n", "",
+				m.Repaired, m.Counted, percent(m.Repaired, m.Counted),
+				window, m.RecentRepaired, m.RecentCounted, percent(m.RecentRepaired, m.RecentCounted), repairTrend(m))
+		}
+	}
+
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Points per day:")
+	if len(days) == 0 {
+		_, _ = fmt.Fprintln(w, "  (no submissions in range)")
+	}
+	for _, d := range days {
+		_, _ = fmt.Fprintf(w, "  %s  correct=%d/%d  points=%d\n", d.Day, d.Correct, d.Submitted, d.Points)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintf(w, "Streaks: %d correct in a row (best %d), %d days in a row (best %d)\n", cur, longest, dayCur, dayLongest)
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// jsonlHistory keeps the run history in an append-only file of JSON lines,
+// each holding either a fetched puzzle or an attempt. Queries read the
+// whole file, so records appended by another process are seen too; a line
+// that does not decode, such as one cut short by a crash, is skipped.
+type jsonlHistory struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// jsonlRecord is one line of the file.
+type jsonlRecord struct {
+	Puzzle  *jsonlPuzzle  `json:"puzzle,omitempty"`
+	Attempt *jsonlAttempt `json:"attempt,omitempty"`
+}
+
+type jsonlPuzzle struct {
+	FetchedAt int64  `json:"fetchedAt"`
+	Puzzle    puzzle `json:"puzzle"`
+}
+
+// jsonlAttempt mirrors a row of the SQLite attempts table; times are Unix
+// milliseconds.
+type jsonlAttempt struct {
+	PuzzleID   string             `json:"puzzleId"`
+	StartedAt  int64              `json:"startedAt"`
+	Model      string             `json:"model"`
+	Answer     [][]int            `json:"answer,omitempty"`
+	Members    map[string][][]int `json:"members,omitempty"`
+	Confidence int                `json:"confidence,omitempty"`
+	Verified   *bool              `json:"verified,omitempty"`
+	SolveMS    int64              `json:"solveMs,omitempty"`
+	DryRun     bool               `json:"dryRun,omitempty"`
+	Submitted  bool               `json:"submitted,omitempty"`
+	Correct    *bool              `json:"correct,omitempty"`
+	Points     int                `json:"points,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Usage      tokenUsage         `json:"usage"`
+	CostUSD    float64            `json:"costUsd,omitempty"`
+	// Repairs is nil when repairs were not counted, as for imported
+	// submissions.
+	Repairs *int `json:"repairs,omitempty"`
+	Canary  bool `json:"canary,omitempty"`
+}
+
+func openJSONLHistory(path string) (*jsonlHistory, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+	return &jsonlHistory{path: path, f: f}, nil
+}
+
+func (h *jsonlHistory) close() error {
+	return h.f.Close()
+}
+
+// append writes recs, one line each, in a single write.
+func (h *jsonlHistory) append(recs ...jsonlRecord) error {
+	var b []byte
+	for _, r := range recs {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("marshal history record: %w", err)
+		}
+		b = append(append(b, line...), '\n')
+	}
+	_, err := h.f.Write(b)
+	return err
+}
+
+// load reads the file: the puzzles by ID, each as first saved, and the
+// attempts ordered by start time, in file order within the same
+// millisecond.
+func (h *jsonlHistory) load(ctx context.Context) (map[string]jsonlPuzzle, []jsonlAttempt, error) {
+	f, err := os.Open(h.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = f.Close() }()
+	puzzles := map[string]jsonlPuzzle{}
+	var attempts []jsonlAttempt
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64<<20)
+	for sc.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		var r jsonlRecord
+		if json.Unmarshal(sc.Bytes(), &r) != nil {
+			continue
+		}
+		switch {
+		case r.Puzzle != nil:
+			if _, ok := puzzles[r.Puzzle.Puzzle.ID]; !ok {
+				puzzles[r.Puzzle.Puzzle.ID] = *r.Puzzle
+			}
+		case r.Attempt != nil:
+			attempts = append(attempts, *r.Attempt)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read history: %w", err)
+	}
+	sort.SliceStable(attempts, func(i, j int) bool { return attempts[i].StartedAt < attempts[j].StartedAt })
+	return puzzles, attempts, nil
+}
+
+func (h *jsonlHistory) savePuzzle(ctx context.Context, p puzzle, at time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	puzzles, _, err := h.load(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := puzzles[p.ID]; ok {
+		return nil
+	}
+	return h.append(jsonlRecord{Puzzle: &jsonlPuzzle{FetchedAt: at.UnixMilli(), Puzzle: p}})
+}
+
+func (h *jsonlHistory) record(_ context.Context, a attempt) error {
+	errText := ""
+	if a.Err != nil {
+		errText = a.Err.Error()
+	}
+	repairs := a.Repairs
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.append(jsonlRecord{Attempt: &jsonlAttempt{
+		PuzzleID:   a.PuzzleID,
+		StartedAt:  a.StartedAt.UnixMilli(),
+		Model:      a.Result.Model,
+		Answer:     a.Result.Answer,
+		Members:    a.Result.Members,
+		Confidence: a.Result.Confidence,
+		Verified:   a.Result.Verified,
+		SolveMS:    a.SolveTime.Milliseconds(),
+		DryRun:     a.DryRun,
+		Submitted:  a.Submitted,
+		Correct:    a.Correct,
+		Points:     a.Points,
+		Error:      errText,
+		Usage:      a.Spend.Usage,
+		CostUSD:    a.Spend.Cost,
+		Repairs:    &repairs,
+		Canary:     a.Canary,
+	}})
+}
+
+func (h *jsonlHistory) mergeServerSubmissions(ctx context.Context, subs []serverSubmission) (added int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, attempts, err := h.load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	have := map[string]int{}
+	for _, a := range attempts {
+		if a.Submitted {
+			have[a.PuzzleID]++
+		}
+	}
+	order, byPuzzle := groupSubmissions(subs)
+	var recs []jsonlRecord
+	for _, id := range order {
+		list := byPuzzle[id]
+		for _, s := range list[min(have[id], len(list)):] {
+			correct := s.Correct
+			recs = append(recs, jsonlRecord{Attempt: &jsonlAttempt{
+				PuzzleID:  id,
+				StartedAt: s.SubmittedAt,
+				Model:     serverModel,
+				Answer:    s.Answer,
+				Submitted: true,
+				Correct:   &correct,
+				Points:    s.PointsAwarded,
+			}})
+		}
+	}
+	if len(recs) == 0 {
+		return 0, nil
+	}
+	if err := h.append(recs...); err != nil {
+		return 0, err
+	}
+	return len(recs), nil
+}
+
+// attempts loads the attempts alone.
+func (h *jsonlHistory) attempts(ctx context.Context) ([]jsonlAttempt, error) {
+	_, attempts, err := h.load(ctx)
+	return attempts, err
+}
+
+func (h *jsonlHistory) modelStats(ctx context.Context, since, recent time.Time) ([]modelStats, error) {
+	attempts, err := h.attempts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byModel := map[string]*modelStats{}
+	solved := map[string][2]int64{}
+	for _, a := range attempts {
+		if a.StartedAt < since.UnixMilli() {
+			continue
+		}
+		ms := byModel[a.Model]
+		if ms == nil {
+			ms = &modelStats{Model: a.Model}
+			byModel[a.Model] = ms
+		}
+		ms.Attempts++
+		if a.Submitted {
+			ms.Submitted++
+			if a.Correct != nil && *a.Correct {
+				ms.Correct++
+			}
+		}
+		if a.Error == "" {
+			s := solved[a.Model]
+			solved[a.Model] = [2]int64{s[0] + a.SolveMS, s[1] + 1}
+		}
+		ms.Tokens += a.Usage.PromptTokens + a.Usage.CompletionTokens
+		ms.Cost += a.CostUSD
+		if a.Repairs != nil {
+			isRecent := a.StartedAt >= recent.UnixMilli()
+			ms.Counted++
+			if isRecent {
+				ms.RecentCounted++
+			}
+			if *a.Repairs > 0 {
+				ms.Repaired++
+				if isRecent {
+					ms.RecentRepaired++
+				}
+			}
+		}
+	}
+	out := make([]modelStats, 0, len(byModel))
+	for model, ms := range byModel {
+		if s := solved[model]; s[1] > 0 {
+			ms.AvgSolve = time.Duration(float64(s[0]) / float64(s[1]) * float64(time.Millisecond))
+		}
+		out = append(out, *ms)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Model < out[j].Model })
+	return out, nil
+}
+
+func (h *jsonlHistory) dailyStats(ctx context.Context, since time.Time) ([]dayStats, error) {
+	attempts, err := h.attempts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	days := dayTally{}
+	for _, a := range attempts {
+		if a.Submitted && a.StartedAt >= since.UnixMilli() {
+			days.add(time.UnixMilli(a.StartedAt), a.Correct != nil && *a.Correct, a.Points)
+		}
+	}
+	return days.list(), nil
+}
+
+func (h *jsonlHistory) streaks(ctx context.Context) (current, longest int, err error) {
+	attempts, err := h.attempts(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	var st streak
+	for _, a := range attempts {
+		if a.Submitted {
+			st.add(a.Correct != nil && *a.Correct)
+		}
+	}
+	return st.current, st.longest, nil
+}
+
+func (h *jsonlHistory) recent(ctx context.Context, limit int, failedOnly bool) ([]historyEntry, error) {
+	attempts, err := h.attempts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []historyEntry
+	for i := len(attempts) - 1; i >= 0 && len(out) < limit; i-- {
+		a := attempts[i]
+		if failedOnly && a.Error == "" && (a.Correct == nil || *a.Correct) {
+			continue
+		}
+		out = append(out, historyEntry{
+			PuzzleID:   a.PuzzleID,
+			StartedAt:  time.UnixMilli(a.StartedAt),
+			Model:      a.Model,
+			Confidence: a.Confidence,
+			Verified:   a.Verified,
+			DryRun:     a.DryRun,
+			Submitted:  a.Submitted,
+			Correct:    a.Correct,
+			Points:     a.Points,
+			ElapsedMS:  a.SolveMS,
+			Tokens:     a.Usage.PromptTokens + a.Usage.CompletionTokens,
+			CostUSD:    a.CostUSD,
+			Error:      a.Error,
+			Canary:     a.Canary,
+		})
+	}
+	return out, nil
+}
+
+func (h *jsonlHistory) modelAccuracy(ctx context.Context, class string) (inClass, overall map[string]accuracyRecord, err error) {
+	puzzles, attempts, err := h.load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	acc := newAccuracyTally(class)
+	for _, a := range attempts {
+		p, ok := puzzles[a.PuzzleID]
+		if a.Correct == nil || !ok {
+			continue
+		}
+		pclass := puzzleClass(p.Puzzle)
+		if a.Model != serverModel {
+			acc.add(a.Model, *a.Correct, pclass)
+		}
+		for model, g := range a.Members {
+			same := a.Answer != nil && gridsEqual(g, a.Answer)
+			if *a.Correct || same {
+				acc.add(model, *a.Correct && same, pclass)
+			}
+		}
+	}
+	return acc.inClass, acc.overall, nil
+}
+
+func (h *jsonlHistory) wrongAnswers(ctx context.Context, id string) ([][][]int, error) {
+	attempts, err := h.attempts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out [][][]int
+	for _, a := range attempts {
+		if a.PuzzleID != id || !a.Submitted || a.Correct == nil || *a.Correct || a.Answer == nil {
+			continue
+		}
+		if !containsGrid(out, a.Answer) {
+			out = append(out, a.Answer)
+		}
+	}
+	return out, nil
+}
+
+func (h *jsonlHistory) lastCanary(ctx context.Context) (time.Time, error) {
+	attempts, err := h.attempts(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for i := len(attempts) - 1; i >= 0; i-- {
+		if a := attempts[i]; a.Canary && a.Error == "" {
+			return time.UnixMilli(a.StartedAt), nil
+		}
+	}
+	return time.Time{}, nil
+}
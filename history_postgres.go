@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresHistorySchema is historySchema in Postgres types: flags are
+// booleans rather than 0/1 integers, so the queries below test them directly.
+const postgresHistorySchema = `
+CREATE TABLE IF NOT EXISTS puzzles (
+	puzzle_id  TEXT PRIMARY KEY,
+	fetched_at BIGINT NOT NULL,
+	puzzle     TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS attempts (
+	id                BIGSERIAL PRIMARY KEY,
+	puzzle_id         TEXT NOT NULL,
+	started_at        BIGINT NOT NULL,
+	model             TEXT NOT NULL DEFAULT '',
+	answer            TEXT,
+	confidence        INTEGER NOT NULL DEFAULT 0,
+	verified          BOOLEAN,
+	solve_ms          BIGINT NOT NULL DEFAULT 0,
+	dry_run           BOOLEAN NOT NULL DEFAULT FALSE,
+	submitted         BOOLEAN NOT NULL DEFAULT FALSE,
+	correct           BOOLEAN,
+	points            INTEGER NOT NULL DEFAULT 0,
+	error             TEXT NOT NULL DEFAULT '',
+	requests          INTEGER NOT NULL DEFAULT 0,
+	prompt_tokens     BIGINT NOT NULL DEFAULT 0,
+	completion_tokens BIGINT NOT NULL DEFAULT 0,
+	cached_tokens     BIGINT NOT NULL DEFAULT 0,
+	reasoning_tokens  BIGINT NOT NULL DEFAULT 0,
+	cost_usd          DOUBLE PRECISION NOT NULL DEFAULT 0,
+	repairs           INTEGER,
+	canary            BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS attempts_started_at ON attempts(started_at);
+CREATE TABLE IF NOT EXISTS member_answers (
+	attempt_id BIGINT NOT NULL,
+	model      TEXT NOT NULL,
+	answer     TEXT NOT NULL
+);
+`
+
+// postgresConnectTimeout bounds opening the database, so an unreachable
+// server disables the history instead of stalling the run.
+const postgresConnectTimeout = 15 * time.Second
+
+// postgresHistory keeps the run history in a Postgres database, which
+// several machines can share.
+type postgresHistory struct {
+	db *sql.DB
+}
+
+func openPostgresHistory(dsn string) (*postgresHistory, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postgresConnectTimeout)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, postgresHistorySchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init history: %w", err)
+	}
+	return &postgresHistory{db: db}, nil
+}
+
+func (h *postgresHistory) close() error {
+	return h.db.Close()
+}
+
+func (h *postgresHistory) savePuzzle(ctx context.Context, p puzzle, at time.Time) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshal puzzle: %w", err)
+	}
+	_, err = h.db.ExecContext(ctx,
+		`INSERT INTO puzzles (puzzle_id, fetched_at, puzzle) VALUES ($1, $2, $3) ON CONFLICT (puzzle_id) DO NOTHING`,
+		p.ID, at.UnixMilli(), string(b))
+	return err
+}
+
+func (h *postgresHistory) record(ctx context.Context, a attempt) error {
+	var answer sql.NullString
+	if a.Result.Answer != nil {
+		b, err := json.Marshal(a.Result.Answer)
+		if err != nil {
+			return fmt.Errorf("marshal answer: %w", err)
+		}
+		answer = sql.NullString{String: string(b), Valid: true}
+	}
+	errText := ""
+	if a.Err != nil {
+		errText = a.Err.Error()
+	}
+	u := a.Spend.Usage
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO attempts (puzzle_id, started_at, model, answer, confidence, verified, solve_ms, dry_run, submitted, correct, points, error,
+		                       requests, prompt_tokens, completion_tokens, cached_tokens, reasoning_tokens, cost_usd, repairs, canary)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+		 RETURNING id`,
+		a.PuzzleID, a.StartedAt.UnixMilli(), a.Result.Model, answer, a.Result.Confidence,
+		nullBool(a.Result.Verified), a.SolveTime.Milliseconds(), a.DryRun, a.Submitted,
+		nullBool(a.Correct), a.Points, errText,
+		u.Requests, u.PromptTokens, u.CompletionTokens, u.CachedTokens, u.ReasoningTokens, a.Spend.Cost, a.Repairs, a.Canary).Scan(&id)
+	if err != nil {
+		return err
+	}
+	for model, grid := range a.Result.Members {
+		b, err := json.Marshal(grid)
+		if err != nil {
+			return fmt.Errorf("marshal answer: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO member_answers (attempt_id, model, answer) VALUES ($1, $2, $3)`, id, model, string(b)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (h *postgresHistory) mergeServerSubmissions(ctx context.Context, subs []serverSubmission) (added int, err error) {
+	order, byPuzzle := groupSubmissions(subs)
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, id := range order {
+		list := byPuzzle[id]
+		var have int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM attempts WHERE puzzle_id = $1 AND submitted`, id).Scan(&have); err != nil {
+			return 0, err
+		}
+		for _, s := range list[min(have, len(list)):] {
+			var answer sql.NullString
+			if s.Answer != nil {
+				b, err := json.Marshal(s.Answer)
+				if err != nil {
+					return 0, fmt.Errorf("marshal answer: %w", err)
+				}
+				answer = sql.NullString{String: string(b), Valid: true}
+			}
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO attempts (puzzle_id, started_at, model, answer, submitted, correct, points) VALUES ($1, $2, $3, $4, TRUE, $5, $6)`,
+				id, s.SubmittedAt, serverModel, answer, s.Correct, s.PointsAwarded); err != nil {
+				return 0, err
+			}
+			added++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// modelStats casts the sums and the average, which Postgres returns as
+// numeric, to the integer and float types they are scanned into.
+func (h *postgresHistory) modelStats(ctx context.Context, since, recent time.Time) ([]modelStats, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT model, COUNT(*),
+		       COUNT(*) FILTER (WHERE submitted),
+		       COUNT(*) FILTER (WHERE submitted AND correct),
+		       AVG(CASE WHEN error = '' THEN solve_ms END)::float8,
+		       SUM(prompt_tokens + completion_tokens)::bigint,
+		       SUM(cost_usd),
+		       COUNT(repairs),
+		       COUNT(*) FILTER (WHERE repairs > 0),
+		       COUNT(*) FILTER (WHERE repairs IS NOT NULL AND started_at >= $1),
+		       COUNT(*) FILTER (WHERE repairs > 0 AND started_at >= $1)
+		FROM attempts
+		WHERE started_at >= $2
+		GROUP BY model
+		ORDER BY model`, recent.UnixMilli(), since.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []modelStats
+	for rows.Next() {
+		var (
+			ms  modelStats
+			avg sql.NullFloat64
+		)
+		if err := rows.Scan(&ms.Model, &ms.Attempts, &ms.Submitted, &ms.Correct, &avg, &ms.Tokens, &ms.Cost,
+			&ms.Counted, &ms.Repaired, &ms.RecentCounted, &ms.RecentRepaired); err != nil {
+			return nil, err
+		}
+		ms.AvgSolve = time.Duration(avg.Float64 * float64(time.Millisecond))
+		out = append(out, ms)
+	}
+	return out, rows.Err()
+}
+
+// dailyStats groups in Go rather than in SQL so days follow the local time
+// zone.
+func (h *postgresHistory) dailyStats(ctx context.Context, since time.Time) ([]dayStats, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT started_at, COALESCE(correct, FALSE), points
+		FROM attempts
+		WHERE submitted AND started_at >= $1
+		ORDER BY started_at`, since.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	days := dayTally{}
+	for rows.Next() {
+		var (
+			ms      int64
+			correct bool
+			points  int
+		)
+		if err := rows.Scan(&ms, &correct, &points); err != nil {
+			return nil, err
+		}
+		days.add(time.UnixMilli(ms), correct, points)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return days.list(), nil
+}
+
+func (h *postgresHistory) streaks(ctx context.Context) (current, longest int, err error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT COALESCE(correct, FALSE) FROM attempts WHERE submitted ORDER BY started_at, id`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var st streak
+	for rows.Next() {
+		var correct bool
+		if err := rows.Scan(&correct); err != nil {
+			return 0, 0, err
+		}
+		st.add(correct)
+	}
+	return st.current, st.longest, rows.Err()
+}
+
+func (h *postgresHistory) recent(ctx context.Context, limit int, failedOnly bool) ([]historyEntry, error) {
+	q := `SELECT puzzle_id, started_at, model, confidence, verified, dry_run, submitted, correct, points, solve_ms,
+		       prompt_tokens + completion_tokens, cost_usd, error, canary
+		FROM attempts`
+	if failedOnly {
+		q += ` WHERE error != '' OR correct = FALSE`
+	}
+	q += ` ORDER BY started_at DESC, id DESC LIMIT $1`
+	rows, err := h.db.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []historyEntry
+	for rows.Next() {
+		var (
+			e                 historyEntry
+			ms                int64
+			verified, correct sql.NullBool
+		)
+		if err := rows.Scan(&e.PuzzleID, &ms, &e.Model, &e.Confidence, &verified, &e.DryRun,
+			&e.Submitted, &correct, &e.Points, &e.ElapsedMS, &e.Tokens, &e.CostUSD, &e.Error, &e.Canary); err != nil {
+			return nil, err
+		}
+		e.StartedAt = time.UnixMilli(ms)
+		if verified.Valid {
+			e.Verified = &verified.Bool
+		}
+		if correct.Valid {
+			e.Correct = &correct.Bool
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (h *postgresHistory) modelAccuracy(ctx context.Context, class string) (inClass, overall map[string]accuracyRecord, err error) {
+	acc := newAccuracyTally(class)
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT a.model, a.correct, p.puzzle
+		FROM attempts a JOIN puzzles p ON p.puzzle_id = a.puzzle_id
+		WHERE a.correct IS NOT NULL AND a.model != $1
+		UNION ALL
+		SELECT m.model, a.correct AND m.answer = a.answer, p.puzzle
+		FROM member_answers m
+		JOIN attempts a ON a.id = m.attempt_id
+		JOIN puzzles p ON p.puzzle_id = a.puzzle_id
+		WHERE a.correct IS NOT NULL AND (a.correct OR m.answer = a.answer)`, serverModel)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			model, raw string
+			correct    bool
+			p          puzzle
+		)
+		if err := rows.Scan(&model, &correct, &raw); err != nil {
+			return nil, nil, err
+		}
+		pclass := ""
+		if json.Unmarshal([]byte(raw), &p) == nil {
+			pclass = puzzleClass(p)
+		}
+		acc.add(model, correct, pclass)
+	}
+	return acc.inClass, acc.overall, rows.Err()
+}
+
+func (h *postgresHistory) wrongAnswers(ctx context.Context, id string) ([][][]int, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT answer FROM attempts
+		WHERE puzzle_id = $1 AND submitted AND correct = FALSE AND answer IS NOT NULL
+		ORDER BY started_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var out [][][]int
+	for rows.Next() {
+		var raw sql.NullString
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var g [][]int
+		if err := json.Unmarshal([]byte(raw.String), &g); err != nil {
+			continue
+		}
+		if !containsGrid(out, g) {
+			out = append(out, g)
+		}
+	}
+	return out, rows.Err()
+}
+
+func (h *postgresHistory) lastCanary(ctx context.Context) (time.Time, error) {
+	var ms sql.NullInt64
+	err := h.db.QueryRowContext(ctx, `SELECT MAX(started_at) FROM attempts WHERE canary AND error = ''`).Scan(&ms)
+	if err != nil || !ms.Valid {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms.Int64), nil
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS puzzles (
+	puzzle_id  TEXT PRIMARY KEY,
+	fetched_at INTEGER NOT NULL,
+	puzzle     TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS attempts (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	puzzle_id   TEXT NOT NULL,
+	started_at  INTEGER NOT NULL,
+	model       TEXT NOT NULL DEFAULT '',
+	answer      TEXT,
+	confidence  INTEGER NOT NULL DEFAULT 0,
+	verified    INTEGER,
+	solve_ms    INTEGER NOT NULL DEFAULT 0,
+	dry_run     INTEGER NOT NULL DEFAULT 0,
+	submitted   INTEGER NOT NULL DEFAULT 0,
+	correct     INTEGER,
+	points      INTEGER NOT NULL DEFAULT 0,
+	error       TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS attempts_started_at ON attempts(started_at);
+CREATE TABLE IF NOT EXISTS member_answers (
+	attempt_id INTEGER NOT NULL,
+	model      TEXT NOT NULL,
+	answer     TEXT NOT NULL
+);
+`
+
+// historyColumns are attempts columns added after the first schema, with
+// their definitions; openSQLiteHistory adds those an older database lacks.
+var historyColumns = []struct{ name, def string }{
+	{"requests", "INTEGER NOT NULL DEFAULT 0"},
+	{"prompt_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"completion_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"cached_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"reasoning_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"cost_usd", "REAL NOT NULL DEFAULT 0"},
+	// repairs is NULL for attempts recorded before it was counted and for
+	// imported submissions, which repair rates leave out.
+	{"repairs", "INTEGER"},
+	{"canary", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// sqliteHistory keeps the run history in a SQLite database.
+type sqliteHistory struct {
+	db *sql.DB
+}
+
+func openSQLiteHistory(path string) (*sqliteHistory, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init history: %w", err)
+	}
+	if err := migrateHistory(db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate history: %w", err)
+	}
+	return &sqliteHistory{db: db}, nil
+}
+
+// migrateHistory adds the historyColumns missing from attempts.
+func migrateHistory(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('attempts')`)
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		have[name] = true
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, c := range historyColumns {
+		if have[c.name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE attempts ADD COLUMN ` + c.name + ` ` + c.def); err != nil {
+			return fmt.Errorf("add column %s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+func (h *sqliteHistory) close() error {
+	return h.db.Close()
+}
+
+func (h *sqliteHistory) savePuzzle(ctx context.Context, p puzzle, at time.Time) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshal puzzle: %w", err)
+	}
+	_, err = h.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO puzzles (puzzle_id, fetched_at, puzzle) VALUES (?, ?, ?)`,
+		p.ID, at.UnixMilli(), string(b))
+	return err
+}
+
+func (h *sqliteHistory) record(ctx context.Context, a attempt) error {
+	var answer sql.NullString
+	if a.Result.Answer != nil {
+		b, err := json.Marshal(a.Result.Answer)
+		if err != nil {
+			return fmt.Errorf("marshal answer: %w", err)
+		}
+		answer = sql.NullString{String: string(b), Valid: true}
+	}
+	errText := ""
+	if a.Err != nil {
+		errText = a.Err.Error()
+	}
+	u := a.Spend.Usage
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO attempts (puzzle_id, started_at, model, answer, confidence, verified, solve_ms, dry_run, submitted, correct, points, error,
+		                       requests, prompt_tokens, completion_tokens, cached_tokens, reasoning_tokens, cost_usd, repairs, canary)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.PuzzleID, a.StartedAt.UnixMilli(), a.Result.Model, answer, a.Result.Confidence,
+		nullBool(a.Result.Verified), a.SolveTime.Milliseconds(), a.DryRun, a.Submitted,
+		nullBool(a.Correct), a.Points, errText,
+		u.Requests, u.PromptTokens, u.CompletionTokens, u.CachedTokens, u.ReasoningTokens, a.Spend.Cost, a.Repairs, a.Canary)
+	if err != nil {
+		return err
+	}
+	if len(a.Result.Members) > 0 {
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for model, grid := range a.Result.Members {
+			b, err := json.Marshal(grid)
+			if err != nil {
+				return fmt.Errorf("marshal answer: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO member_answers (attempt_id, model, answer) VALUES (?, ?, ?)`, id, model, string(b)); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (h *sqliteHistory) mergeServerSubmissions(ctx context.Context, subs []serverSubmission) (added int, err error) {
+	order, byPuzzle := groupSubmissions(subs)
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, id := range order {
+		list := byPuzzle[id]
+		var have int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM attempts WHERE puzzle_id = ? AND submitted = 1`, id).Scan(&have); err != nil {
+			return 0, err
+		}
+		for _, s := range list[min(have, len(list)):] {
+			var answer sql.NullString
+			if s.Answer != nil {
+				b, err := json.Marshal(s.Answer)
+				if err != nil {
+					return 0, fmt.Errorf("marshal answer: %w", err)
+				}
+				answer = sql.NullString{String: string(b), Valid: true}
+			}
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO attempts (puzzle_id, started_at, model, answer, submitted, correct, points) VALUES (?, ?, ?, ?, 1, ?, ?)`,
+				id, s.SubmittedAt, serverModel, answer, s.Correct, s.PointsAwarded); err != nil {
+				return 0, err
+			}
+			added++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+func nullBool(b *bool) sql.NullBool {
+	if b == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: *b, Valid: true}
+}
+
+func (h *sqliteHistory) modelStats(ctx context.Context, since, recent time.Time) ([]modelStats, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT model, COUNT(*),
+		       SUM(submitted),
+		       SUM(CASE WHEN submitted = 1 AND correct = 1 THEN 1 ELSE 0 END),
+		       AVG(CASE WHEN error = '' THEN solve_ms END),
+		       SUM(prompt_tokens + completion_tokens),
+		       SUM(cost_usd),
+		       COUNT(repairs),
+		       SUM(CASE WHEN repairs > 0 THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN repairs IS NOT NULL AND started_at >= ? THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN repairs > 0 AND started_at >= ? THEN 1 ELSE 0 END)
+		FROM attempts
+		WHERE started_at >= ?
+		GROUP BY model
+		ORDER BY model`, recent.UnixMilli(), recent.UnixMilli(), since.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []modelStats
+	for rows.Next() {
+		var (
+			ms  modelStats
+			avg sql.NullFloat64
+		)
+		if err := rows.Scan(&ms.Model, &ms.Attempts, &ms.Submitted, &ms.Correct, &avg, &ms.Tokens, &ms.Cost,
+			&ms.Counted, &ms.Repaired, &ms.RecentCounted, &ms.RecentRepaired); err != nil {
+			return nil, err
+		}
+		ms.AvgSolve = time.Duration(avg.Float64 * float64(time.Millisecond))
+		out = append(out, ms)
+	}
+	return out, rows.Err()
+}
+
+// dailyStats groups in Go rather than in SQL so days follow the local time
+// zone.
+func (h *sqliteHistory) dailyStats(ctx context.Context, since time.Time) ([]dayStats, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT started_at, COALESCE(correct, 0), points
+		FROM attempts
+		WHERE submitted = 1 AND started_at >= ?
+		ORDER BY started_at`, since.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	days := dayTally{}
+	for rows.Next() {
+		var (
+			ms      int64
+			correct bool
+			points  int
+		)
+		if err := rows.Scan(&ms, &correct, &points); err != nil {
+			return nil, err
+		}
+		days.add(time.UnixMilli(ms), correct, points)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return days.list(), nil
+}
+
+func (h *sqliteHistory) streaks(ctx context.Context) (current, longest int, err error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT COALESCE(correct, 0) FROM attempts WHERE submitted = 1 ORDER BY started_at, id`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var st streak
+	for rows.Next() {
+		var correct bool
+		if err := rows.Scan(&correct); err != nil {
+			return 0, 0, err
+		}
+		st.add(correct)
+	}
+	return st.current, st.longest, rows.Err()
+}
+
+func (h *sqliteHistory) recent(ctx context.Context, limit int, failedOnly bool) ([]historyEntry, error) {
+	q := `SELECT puzzle_id, started_at, model, confidence, verified, dry_run, submitted, correct, points, solve_ms,
+		       prompt_tokens + completion_tokens, cost_usd, error, canary
+		FROM attempts`
+	if failedOnly {
+		q += ` WHERE error != '' OR correct = 0`
+	}
+	q += ` ORDER BY started_at DESC, id DESC LIMIT ?`
+	rows, err := h.db.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []historyEntry
+	for rows.Next() {
+		var (
+			e                 historyEntry
+			ms                int64
+			verified, correct sql.NullBool
+		)
+		if err := rows.Scan(&e.PuzzleID, &ms, &e.Model, &e.Confidence, &verified, &e.DryRun,
+			&e.Submitted, &correct, &e.Points, &e.ElapsedMS, &e.Tokens, &e.CostUSD, &e.Error, &e.Canary); err != nil {
+			return nil, err
+		}
+		e.StartedAt = time.UnixMilli(ms)
+		if verified.Valid {
+			e.Verified = &verified.Bool
+		}
+		if correct.Valid {
+			e.Correct = &correct.Bool
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (h *sqliteHistory) modelAccuracy(ctx context.Context, class string) (inClass, overall map[string]accuracyRecord, err error) {
+	acc := newAccuracyTally(class)
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT a.model, a.correct, p.puzzle
+		FROM attempts a JOIN puzzles p ON p.puzzle_id = a.puzzle_id
+		WHERE a.correct IS NOT NULL AND a.model != ?
+		UNION ALL
+		SELECT m.model, a.correct = 1 AND m.answer = a.answer, p.puzzle
+		FROM member_answers m
+		JOIN attempts a ON a.id = m.attempt_id
+		JOIN puzzles p ON p.puzzle_id = a.puzzle_id
+		WHERE a.correct IS NOT NULL AND (a.correct = 1 OR m.answer = a.answer)`, serverModel)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			model, raw string
+			correct    bool
+			p          puzzle
+		)
+		if err := rows.Scan(&model, &correct, &raw); err != nil {
+			return nil, nil, err
+		}
+		pclass := ""
+		if json.Unmarshal([]byte(raw), &p) == nil {
+			pclass = puzzleClass(p)
+		}
+		acc.add(model, correct, pclass)
+	}
+	return acc.inClass, acc.overall, rows.Err()
+}
+
+func (h *sqliteHistory) wrongAnswers(ctx context.Context, id string) ([][][]int, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT answer FROM attempts
+		WHERE puzzle_id = ? AND submitted = 1 AND correct = 0 AND answer IS NOT NULL
+		ORDER BY started_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var out [][][]int
+	for rows.Next() {
+		var raw sql.NullString
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var g [][]int
+		if err := json.Unmarshal([]byte(raw.String), &g); err != nil {
+			continue
+		}
+		if !containsGrid(out, g) {
+			out = append(out, g)
+		}
+	}
+	return out, rows.Err()
+}
+
+func (h *sqliteHistory) lastCanary(ctx context.Context) (time.Time, error) {
+	var ms sql.NullInt64
+	err := h.db.QueryRowContext(ctx, `SELECT MAX(started_at) FROM attempts WHERE canary = 1 AND error = ''`).Scan(&ms)
+	if err != nil || !ms.Valid {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms.Int64), nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// htmlPageKind classifies an HTML page returned where JSON was expected.
+type htmlPageKind string
+
+// Known HTML page kinds.
+const (
+	htmlMaintenance htmlPageKind = "maintenance"
+	htmlChallenge   htmlPageKind = "challenge"
+	htmlLogin       htmlPageKind = "login"
+	htmlUnknown     htmlPageKind = "unknown"
+)
+
+// htmlPageError is returned when the server (or a front proxy) answers with
+// an HTML page. It unwraps to the underlying apiError so status-code based
+// checks keep working.
+type htmlPageError struct {
+	Kind  htmlPageKind
+	Title string
+	api   *apiError
+}
+
+func (e *htmlPageError) Error() string {
+	msg := fmt.Sprintf("server returned an HTML %s page instead of JSON (status %d", e.Kind, e.api.StatusCode)
+	if e.Title != "" {
+		msg += fmt.Sprintf(", title %q", e.Title)
+	}
+	msg += "): " + e.guidance()
+	if e.api.RequestID != "" {
+		msg += " (request_id=" + e.api.RequestID + ")"
+	}
+	if e.api.ArchivePath != "" {
+		msg += " [full response: " + e.api.ArchivePath + "]"
+	}
+	return msg
+}
+
+func (e *htmlPageError) Unwrap() error { return e.api }
+
+func (e *htmlPageError) guidance() string {
+	switch e.Kind {
+	case htmlMaintenance:
+		return "the site appears to be down for maintenance, try again later"
+	case htmlChallenge:
+		return "an anti-bot challenge intercepted the request; open the site in a browser and copy a fresh cookie (including cf_clearance) with the same User-Agent"
+	case htmlLogin:
+		return "the session is not logged in; refresh the cookie"
+	default:
+		return "check that base_url points at the puzzle site and not a proxy or landing page"
+	}
+}
+
+var reHTMLTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// reLoginForm matches the markup of a login form: a password input or a form
+// posting to a login route. Link text such as "Sign in" is not enough, since
+// nearly every page carries it in its navigation bar.
+var reLoginForm = regexp.MustCompile(`(?is)<input\b[^>]*\btype\s*=\s*["']?password\b|<form\b[^>]*\baction\s*=\s*["']?[^"'\s>]*(?:log-?in|sign-?in)`)
+
+// Body markers for each page kind, matched case-insensitively.
+var (
+	challengeMarkers = []string{
+		"cf-chl", "challenge-platform", "cf-browser-verification", "just a moment",
+		"attention required", "checking your browser", "ddos-guard", "captcha",
+	}
+	maintenanceMarkers = []string{
+		"maintenance", "temporarily unavailable", "维护", "be right back",
+	}
+)
+
+// classifyHTMLPage reports whether the response is an HTML page and, if so,
+// what kind of page it looks like along with its <title>.
+func classifyHTMLPage(resp *http.Response, body []byte) (htmlPageKind, string, bool) {
+	ct := strings.ToLower(resp.Header.Get("Content-Type"))
+	trimmed := bytes.TrimSpace(body)
+	if !strings.Contains(ct, "text/html") && !bytes.HasPrefix(trimmed, []byte("<")) {
+		return "", "", false
+	}
+
+	title := ""
+	if m := reHTMLTitle.FindSubmatch(body); len(m) == 2 {
+		title = strings.Join(strings.Fields(string(m[1])), " ")
+	}
+	lower := strings.ToLower(string(body))
+	containsAny := func(markers []string) bool {
+		for _, m := range markers {
+			if strings.Contains(lower, m) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case resp.Header.Get("Cf-Mitigated") == "challenge" || containsAny(challengeMarkers):
+		return htmlChallenge, title, true
+	case resp.StatusCode == http.StatusServiceUnavailable || containsAny(maintenanceMarkers):
+		return htmlMaintenance, title, true
+	case isLoginURL(resp) || reLoginForm.Match(body):
+		return htmlLogin, title, true
+	default:
+		return htmlUnknown, title, true
+	}
+}
+
+// isLoginURL reports whether the request was redirected to a login page.
+func isLoginURL(resp *http.Response) bool {
+	if resp.Request == nil || resp.Request.URL == nil {
+		return false
+	}
+	p := strings.ToLower(resp.Request.URL.Path)
+	return strings.Contains(p, "login") || strings.Contains(p, "signin")
+}
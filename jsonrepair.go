@@ -0,0 +1,177 @@
+package main
+
+import (
+	"strings"
+)
+
+// repairJSON recovers the first JSON object in model output that was not
+// held to a schema. See repairJSONValue for what it fixes.
+func repairJSON(text string) string {
+	return repairJSONValue(text, '{')
+}
+
+// repairJSONValue extracts the first JSON value opening with open ('{' or
+// '[') from text and fixes the usual defects on the way: a markdown code
+// fence or prose around it, // and /* */ comments, single-quoted strings,
+// raw newlines inside strings, trailing commas and mismatched closing
+// brackets. Output cut off mid-value is closed, dropping a key that lost
+// its value, so whatever arrived can still be decoded. Text without such a
+// value is returned trimmed.
+func repairJSONValue(text string, open byte) string {
+	text = strings.TrimSpace(text)
+	if fenced, ok := fencedBlock(text); ok && strings.IndexByte(fenced, open) != -1 {
+		text = fenced
+	}
+	start := strings.IndexByte(text, open)
+	if start == -1 {
+		return text
+	}
+	return repairFrom(text, start)
+}
+
+// fencedBlock returns the content of the first markdown code fence in text.
+func fencedBlock(text string) (string, bool) {
+	_, rest, ok := strings.Cut(text, "```")
+	if !ok {
+		return "", false
+	}
+	// Drop the info string ("json") on the opening line.
+	if nl := strings.IndexByte(rest, '\n'); nl != -1 {
+		rest = rest[nl+1:]
+	}
+	body, _, _ := strings.Cut(rest, "```")
+	return body, true
+}
+
+// repairFrom rewrites the value starting at text[start] as valid JSON as
+// far as the defects repairJSONValue lists go.
+func repairFrom(text string, start int) string {
+	var out strings.Builder
+	// closers holds the closing bracket expected for each open one.
+	var closers []byte
+	// prev is the last byte written other than whitespace; keyAt is where
+	// the last object key starts in out while its value has not begun.
+	var prev byte
+	keyAt := -1
+	for i := start; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '"' || c == '\'':
+			keyAt = -1
+			if len(closers) > 0 && closers[len(closers)-1] == '}' && (prev == '{' || prev == ',') {
+				keyAt = out.Len()
+			}
+			s, n := scanString(text[i:])
+			out.WriteString(s)
+			prev = '"'
+			i += n
+			continue
+		case c == '/' && strings.HasPrefix(text[i:], "//"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end == -1 {
+				end = len(text) - i
+			}
+			i += end
+			continue
+		case c == '/' && strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end == -1 {
+				i = len(text)
+			} else {
+				i += end + 4
+			}
+			continue
+		case c == '{':
+			closers = append(closers, '}')
+		case c == '[':
+			closers = append(closers, ']')
+		case c == '}' || c == ']':
+			trimTrailingComma(&out)
+			if len(closers) > 0 {
+				// A mismatched bracket closes what is actually open.
+				c = closers[len(closers)-1]
+				closers = closers[:len(closers)-1]
+			}
+			out.WriteByte(c)
+			if len(closers) == 0 {
+				return out.String()
+			}
+			prev, keyAt = c, -1
+			i++
+			continue
+		}
+		out.WriteByte(c)
+		switch c {
+		case ' ', '\t', '\r', '\n':
+		case ':':
+			prev = c
+		default:
+			prev, keyAt = c, -1
+		}
+		i++
+	}
+	// Truncated: drop a key whose value never arrived and close whatever
+	// is still open.
+	if keyAt >= 0 {
+		s := out.String()[:keyAt]
+		out.Reset()
+		out.WriteString(s)
+	}
+	trimTrailingComma(&out)
+	for j := len(closers) - 1; j >= 0; j-- {
+		out.WriteByte(closers[j])
+	}
+	return out.String()
+}
+
+// scanString reads the string literal at the start of s, quoted with " or
+// ', and returns it as a double-quoted JSON string with the number of bytes
+// consumed. An unterminated string is closed at the end of s, without a
+// trailing backslash that would escape the closing quote.
+func scanString(s string) (string, int) {
+	quote := s[0]
+	var b strings.Builder
+	b.WriteByte('"')
+	i := 1
+	for ; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			if s[i] == '\'' {
+				b.WriteByte('\'')
+			} else {
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		case c == '\\':
+			// Cut off before the character it escapes.
+		case c == quote:
+			b.WriteByte('"')
+			return b.String(), i + 1
+		case c == '"':
+			b.WriteString(`\"`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String(), i
+}
+
+// trimTrailingComma drops a comma, and the whitespace after it, from the end
+// of out.
+func trimTrailingComma(out *strings.Builder) {
+	s := strings.TrimRight(out.String(), " \t\r\n")
+	if !strings.HasSuffix(s, ",") {
+		return
+	}
+	out.Reset()
+	out.WriteString(strings.TrimSuffix(s, ","))
+}
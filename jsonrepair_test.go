@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"valid", `{"answer": [[1, 2]]}`, `{"answer": [[1, 2]]}`},
+		{"prose around", `Here you go: {"a": 1} Hope this helps.`, `{"a": 1}`},
+		{"code fence", "```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"brace in prose before the fence", "Use {curly} braces:\n```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"trailing commas", `{"a": [1, 2, ], "b": 3, }`, `{"a": [1, 2], "b": 3}`},
+
+		{"truncated array", `{"answer": [[1, 2], [3, 4`, `{"answer": [[1, 2], [3, 4]]}`},
+		{"truncated after a comma", `{"answer": [[1, 2], [3,`, `{"answer": [[1, 2], [3]]}`},
+		{"truncated string", `{"reasoning": "rotate the gr`, `{"reasoning": "rotate the gr"}`},
+		{"truncated escape", `{"reasoning": "a \`, `{"reasoning": "a "}`},
+		{"truncated after a key", `{"answer": [[1]], "confidence"`, `{"answer": [[1]]}`},
+		{"truncated after a colon", `{"answer": [[1]], "confidence": `, `{"answer": [[1]]}`},
+		{"truncated key", `{"answer": [[1]], "conf`, `{"answer": [[1]]}`},
+
+		{"bracket closing a brace", `{"a": [1, 2}`, `{"a": [1, 2]}`},
+		{"brace closing a bracket", `{"a": [1, 2}}`, `{"a": [1, 2]}`},
+		{"stray closer inside", `{"a": [[1], 2]]}`, `{"a": [[1], 2]}`},
+
+		{"single quotes", `{'a': 'b'}`, `{"a": "b"}`},
+		{"escaped single quote", `{'a': 'it\'s'}`, `{"a": "it's"}`},
+		{"double quote inside single", `{'a': 'say "hi"'}`, `{"a": "say \"hi\""}`},
+		{"escapes kept", `{"a": "x\"y\\z\n"}`, `{"a": "x\"y\\z\n"}`},
+		{"raw newline in string", "{\"a\": \"line1\nline2\"}", `{"a": "line1\nline2"}`},
+
+		{"line comment", "{\"a\": 1, // the answer\n\"b\": 2}", "{\"a\": 1, \n\"b\": 2}"},
+		{"block comment", `{"a": /* one */ 1}`, `{"a":  1}`},
+		{"comment markers in strings", `{"url": "http://x/*y*/"}`, `{"url": "http://x/*y*/"}`},
+		{"unterminated block comment", `{"a": 1 /* cut`, `{"a": 1 }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := repairJSON(tt.in)
+			if got != tt.want {
+				t.Errorf("repairJSON(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			var v any
+			if err := json.Unmarshal([]byte(got), &v); err != nil {
+				t.Errorf("repairJSON(%q) = %q, which does not decode: %v", tt.in, got, err)
+			}
+		})
+	}
+}
+
+func TestRepairJSONValueGrid(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"bare grid", `[[1, 2], [3, 4]]`, `[[1, 2], [3, 4]]`},
+		{"grid after prose", `The answer is [[0, 1], [1, 0]].`, `[[0, 1], [1, 0]]`},
+		{"truncated grid", `[[0, 1], [1,`, `[[0, 1], [1]]`},
+		{"no grid", `no answer`, `no answer`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := repairJSONValue(tt.in, '['); got != tt.want {
+				t.Errorf("repairJSONValue(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanString(t *testing.T) {
+	tests := []struct {
+		in, want string
+		n        int
+	}{
+		{`"abc" rest`, `"abc"`, 5},
+		{`'abc' rest`, `"abc"`, 5},
+		{`'a"b'`, `"a\"b"`, 5},
+		{`'it\'s'`, `"it's"`, 7},
+		{`"a\"b"`, `"a\"b"`, 6},
+		{"\"a\tb\"", `"a\tb"`, 5},
+		{`"unterminated`, `"unterminated"`, 13},
+		{`"ends in \`, `"ends in "`, 10},
+	}
+	for _, tt := range tests {
+		got, n := scanString(tt.in)
+		if got != tt.want || n != tt.n {
+			t.Errorf("scanString(%q) = %q, %d; want %q, %d", tt.in, got, n, tt.want, tt.n)
+		}
+	}
+}
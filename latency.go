@@ -0,0 +1,70 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyTracker collects request durations per endpoint over a run so the
+// summary shows where the time went: the puzzle API, the AI or the PoW. A
+// nil *latencyTracker records nothing.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{samples: map[string][]time.Duration{}}
+}
+
+// observe records one duration for endpoint.
+func (t *latencyTracker) observe(endpoint string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples[endpoint] = append(t.samples[endpoint], d)
+}
+
+// since records the time elapsed since start for endpoint; it suits defer.
+func (t *latencyTracker) since(endpoint string, start time.Time) {
+	t.observe(endpoint, time.Since(start))
+}
+
+// logSummary logs count, p50, p95 and max per endpoint, by name.
+func (t *latencyTracker) logSummary(log *logger) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	names := make([]string, 0, len(t.samples))
+	for name := range t.samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ds := append([]time.Duration(nil), t.samples[name]...)
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+		log.infof("latency: %s n=%d p50=%s p95=%s max=%s", name, len(ds),
+			roundLatency(percentile(ds, 50)), roundLatency(percentile(ds, 95)), roundLatency(ds[len(ds)-1]))
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted ds.
+func percentile(ds []time.Duration, p int) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	rank := (p*len(ds) + 99) / 100
+	return ds[max(rank, 1)-1]
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Millisecond)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// lintConfig returns warnings about insecure settings in the config at path:
+// secrets in a file other users can read, plain-http endpoints that would
+// carry the cookie or API key, disabled certificate checks, and an API key
+// kept in the file although a system keyring could hold it.
+func lintConfig(path string, cfg appConfig) []string {
+	var warns []string
+	if perm, readable := secretsReadable(path, cfg); readable {
+		warns = append(warns, fmt.Sprintf("%s holds %s but is readable by other users (mode %#o); run chmod 600 %s",
+			path, strings.Join(configSecrets(cfg), ", "), perm, path))
+	}
+	return append(warns, lintSettings(path, cfg)...)
+}
+
+// secretsReadable reports whether the config at path holds a secret but
+// other users can read it, and the file's permissions. Windows is not
+// checked.
+func secretsReadable(path string, cfg appConfig) (os.FileMode, bool) {
+	if path == "" || len(configSecrets(cfg)) == 0 || runtime.GOOS == "windows" {
+		return 0, false
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	return fi.Mode().Perm(), fi.Mode().Perm()&0o044 != 0
+}
+
+// lintSettings returns the warnings of lintConfig about the settings
+// themselves rather than the file's permissions.
+func lintSettings(path string, cfg appConfig) []string {
+	var warns []string
+	if plainHTTP(cfg.BaseURL) {
+		warns = append(warns, fmt.Sprintf("base_url %s uses http://; the login cookie is sent unencrypted", cfg.BaseURL))
+	}
+	if cfg.AI.Enabled && plainHTTP(cfg.AI.BaseURL) {
+		warns = append(warns, fmt.Sprintf("ai.base_url %s uses http://; the API key is sent unencrypted", cfg.AI.BaseURL))
+	}
+	if cfg.TLSPin == pinOff {
+		warns = append(warns, `tls_pin is "off"; a changed certificate for base_url would go unnoticed`)
+	}
+	for _, key := range insecureKeys(path) {
+		warns = append(warns, fmt.Sprintf("%s is set in the config; certificate verification is never skipped and the key is ignored, remove it", key))
+	}
+	if strings.TrimSpace(cfg.AI.APIKey) != "" {
+		if kr := keyringTool(); kr != "" {
+			warns = append(warns, fmt.Sprintf("ai.api_key is stored in plain text although a keyring is available (%s); keep the key there and pass it in %s instead",
+				kr, apiKeyEnv(cfg.AI.Provider)))
+		}
+	}
+	return warns
+}
+
+// configSecrets names the credentials present in cfg.
+func configSecrets(cfg appConfig) []string {
+	var out []string
+	if cfg.seedCookie != "" {
+		out = append(out, "cookie")
+	}
+	if cfg.AI.APIKey != "" {
+		out = append(out, "ai.api_key")
+	}
+	if cfg.Notifications.WebhookURL != "" {
+		out = append(out, "notifications.webhook_url")
+	}
+	if cfg.Notifications.Telegram.Token != "" {
+		out = append(out, "notifications.telegram.token")
+	}
+	if cfg.Review.WebhookURL != "" {
+		out = append(out, "review.webhook_url")
+	}
+	if u, err := url.Parse(cfg.History.DSN); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			out = append(out, "history.dsn")
+		}
+	}
+	return out
+}
+
+// plainHTTP reports whether raw is an http:// URL to a host other than the
+// loopback interface, where plain HTTP does not leave the machine.
+func plainHTTP(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// insecureKeys returns the paths of insecure_skip_verify keys set to true
+// anywhere in the config file. The option does not exist, but one copied
+// from another tool's config shows the user expects it to take effect.
+func insecureKeys(path string) []string {
+	if path == "" {
+		return nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var doc any
+	if json.Unmarshal(b, &doc) != nil {
+		return nil
+	}
+	var out []string
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return
+		}
+		for k, child := range m {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			if strings.EqualFold(k, "insecure_skip_verify") && child == true {
+				out = append(out, key)
+			}
+			walk(key, child)
+		}
+	}
+	walk("", doc)
+	sort.Strings(out)
+	return out
+}
+
+// keyringTool names the system keyring usable from a shell, or "" if none
+// is found: the Keychain on macOS, the Credential Manager on Windows, and
+// secret-tool with a session bus elsewhere.
+func keyringTool() string {
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("security"); err == nil {
+			return "macOS Keychain"
+		}
+	case "windows":
+		return "Windows Credential Manager"
+	default:
+		if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+			return ""
+		}
+		if _, err := exec.LookPath("secret-tool"); err == nil {
+			return "secret-tool"
+		}
+	}
+	return ""
+}
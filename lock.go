@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// instanceLock is a locked PID file guarding one runtime home and site against
+// concurrent runs.
+type instanceLock struct {
+	f *os.File
+}
+
+// lockPath returns the lock file for the site host in home. It is known
+// before logging in, so a second run fails before it prompts for a cookie.
+func lockPath(home, baseURL string) string {
+	host := baseURL
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(host)))
+	return filepath.Join(home, "ergo-solver-"+hex.EncodeToString(sum[:6])+".lock")
+}
+
+// lockInstance takes the lock of the site cfg points at in its home.
+func lockInstance(cfg appConfig) (*instanceLock, error) {
+	return acquireInstanceLock(lockPath(cfg.home, cfg.BaseURL))
+}
+
+// errLocked reports that another process holds the lock on a file.
+var errLocked = errors.New("file is locked")
+
+// acquireInstanceLock takes an advisory lock on the file at path and writes
+// the PID into it. The operating system releases the lock when the process
+// exits, so a lock left by a crashed run never blocks the next one.
+func acquireInstanceLock(path string) (*instanceLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir lock dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		if !errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		// The holder may not have written its PID yet.
+		b, _ := os.ReadFile(path)
+		if pid, perr := strconv.Atoi(strings.TrimSpace(string(b))); perr == nil && pid > 0 {
+			return nil, fmt.Errorf("another instance (pid %d) is already running for this site; lock file: %s", pid, path)
+		}
+		return nil, fmt.Errorf("another instance is already running for this site; lock file: %s", path)
+	}
+	if err := f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("write lock file: %w", err)
+	}
+	return &instanceLock{f: f}, nil
+}
+
+// release clears the PID and drops the lock. The file stays: removing it
+// could let a run that opened it just before lock a file no longer at path.
+func (l *instanceLock) release() {
+	if l == nil {
+		return
+	}
+	_ = l.f.Truncate(0)
+	_ = l.f.Close()
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -11,6 +16,9 @@ import (
 // logger wraps zerolog for structured logging.
 type logger struct {
 	z zerolog.Logger
+	// file is the writer of the log file set by logToFile, nil while
+	// logging to stderr.
+	file io.Writer
 }
 
 // newLogger creates a logger with console output.
@@ -29,6 +37,119 @@ func newLogger() *logger {
 	return &logger{z: zl}
 }
 
+// logToFile redirects output to an append-only file that is reopened on
+// SIGHUP, so external rotation (logrotate) can move it aside without a
+// restart. The returned func stops the signal handler and closes the file.
+func (l *logger) logToFile(path string) (func(), error) {
+	rf, err := openReopenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := zerolog.ConsoleWriter{
+		Out:        rf,
+		TimeFormat: time.RFC3339,
+		NoColor:    true,
+	}
+	l.z = zerolog.New(out).With().Timestamp().Logger()
+	l.file = out
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-hup:
+				if err := rf.reopen(); err != nil {
+					l.warnf("reopen log file: %v", err)
+					continue
+				}
+				l.info("log file reopened")
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hup)
+		close(done)
+		_ = rf.Close()
+	}, nil
+}
+
+// logTo sends every line, uncolored and with a short timestamp, to w in
+// place of stderr; a log file set by logToFile keeps receiving them too. The
+// returned func restores the previous output.
+func (l *logger) logTo(w io.Writer) func() {
+	prev := l.z
+	var out io.Writer = zerolog.ConsoleWriter{
+		Out:        w,
+		TimeFormat: time.TimeOnly,
+		NoColor:    true,
+	}
+	if l.file != nil {
+		out = zerolog.MultiLevelWriter(l.file, out)
+	}
+	l.z = zerolog.New(out).With().Timestamp().Logger()
+	return func() { l.z = prev }
+}
+
+// reopenFile is an append-only file writer whose underlying descriptor can be
+// swapped atomically with respect to writes.
+type reopenFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+func openReopenFile(path string) (*reopenFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open log file: %w", err)
+	}
+	return &reopenFile{path: path, f: f}, nil
+}
+
+func (r *reopenFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Write(p)
+}
+
+// reopen opens path afresh and closes the previous descriptor. Writes issued
+// concurrently land entirely in either the old or the new file.
+func (r *reopenFile) reopen() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	old := r.f
+	r.f = f
+	r.mu.Unlock()
+	return old.Close()
+}
+
+func (r *reopenFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// with returns a child logger that adds key=value to every line.
+func (l *logger) with(key, value string) *logger {
+	return &logger{z: l.z.With().Str(key, value).Logger(), file: l.file}
+}
+
+// forContext returns a child logger tagged with the trace ID in ctx, if any.
+func (l *logger) forContext(ctx context.Context) *logger {
+	if id := traceIDFrom(ctx); id != "" {
+		return l.with("trace", id)
+	}
+	return l
+}
+
 func (l *logger) info(msg string) { l.z.Info().Msg(msg) }
 func (l *logger) warn(msg string) { l.z.Warn().Msg(msg) }
 func (l *logger) ok(msg string)   { l.z.Info().Msg(msg) }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// runLogin replaces the saved login with new auth material, pasted at the
+// prompt or read from the clipboard, once the site accepts it.
+func runLogin(ctx context.Context, log *logger, args []string) error {
+	fs := flag.NewFlagSet(cmdLogin, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath    string
+		fromClipboard bool
+	)
+	fs.StringVar(&configPath, "config", "", "config path (required)")
+	fs.BoolVar(&fromClipboard, "from-clipboard", false, "read the cookie, Cookie header or curl command from the clipboard")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		return errors.New("--config is required")
+	}
+
+	cfg, err := loadConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	cfg.seedCookie = cfg.Cookie
+
+	var in authMaterial
+	if fromClipboard {
+		text, err := readClipboard(ctx)
+		if err != nil {
+			return err
+		}
+		if in, err = parsePastedAuth(text); err != nil {
+			return fmt.Errorf("clipboard: %w", err)
+		}
+		log.infof("clipboard: %d cookies (%s), %d characters", len(cookieNames(in.Cookie)), strings.Join(cookieNames(in.Cookie), ", "), len(in.Cookie))
+	} else if in, err = promptAuthMaterial(); err != nil {
+		return err
+	}
+
+	// Without base_url in the config, the pasted command names the site,
+	// and a bare cookie logs in again to the one saved before.
+	if cfg.BaseURL == "" && in.BaseURL == "" {
+		st, err := loadLoginState(statePath(cfg.home))
+		if err != nil {
+			return err
+		}
+		cfg.BaseURL = st.BaseURL
+	}
+	cfg, _, me, err := previewAuth(ctx, cfg, in, os.Stdout)
+	if err != nil {
+		if isAuthError(err) {
+			return errors.New("login rejected: please check cookie/token")
+		}
+		return err
+	}
+	if err := saveLoginState(cfg); err != nil {
+		return err
+	}
+	log.okf("logged in to %s: %s(%s); login state saved", cfg.BaseURL, me.User.Username, me.User.ID)
+	return nil
+}
+
+// readClipboard returns the system clipboard's text using the platform's
+// clipboard tool: pbpaste on macOS, PowerShell on Windows, and wl-paste,
+// xclip or xsel elsewhere.
+func readClipboard(ctx context.Context) (string, error) {
+	var cmds [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		cmds = [][]string{{"pbpaste"}}
+	case "windows":
+		cmds = [][]string{{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"}}
+	default:
+		cmds = [][]string{
+			{"wl-paste", "--no-newline"},
+			{"xclip", "-selection", "clipboard", "-o"},
+			{"xsel", "--clipboard", "--output"},
+		}
+	}
+	var errs []error
+	for _, c := range cmds {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, c[0], c[1:]...)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w: %s", c[0], err, strings.TrimSpace(stderr.String())))
+			continue
+		}
+		return string(out), nil
+	}
+	if len(errs) == 0 {
+		names := make([]string, len(cmds))
+		for i, c := range cmds {
+			names[i] = c[0]
+		}
+		return "", fmt.Errorf("read clipboard: no clipboard tool found (install %s)", strings.Join(names, ", "))
+	}
+	return "", fmt.Errorf("read clipboard: %w", errors.Join(errs...))
+}
@@ -1,17 +1,18 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -19,8 +20,21 @@ import (
 
 // Command names.
 const (
-	cmdSolve = "solve"
-	cmdHelp  = "help"
+	cmdSolve    = "solve"
+	cmdStatus   = "status"
+	cmdPuzzle   = "puzzle"
+	cmdSubmit   = "submit"
+	cmdFetch    = "fetch"
+	cmdStats    = "stats"
+	cmdHistory  = "history"
+	cmdDaemon   = "daemon"
+	cmdLogin    = "login"
+	cmdSelftest = "selftest"
+	cmdVerify   = "verify"
+	cmdRender   = "render"
+	cmdDoctor   = "doctor"
+	cmdTUI      = "tui"
+	cmdHelp     = "help"
 )
 
 // errAuthRequired indicates authentication is needed.
@@ -47,6 +61,32 @@ func run(ctx context.Context, log *logger, args []string) error {
 		return nil
 	case cmdSolve:
 		return runSolve(ctx, log, args[1:])
+	case cmdStatus:
+		return runStatusCmd(ctx, args[1:])
+	case cmdPuzzle:
+		return runPuzzleCmd(ctx, args[1:])
+	case cmdSubmit:
+		return runSubmit(ctx, log, args[1:])
+	case cmdFetch:
+		return runFetch(ctx, log, args[1:])
+	case cmdStats:
+		return runStats(ctx, log, args[1:])
+	case cmdHistory:
+		return runHistory(ctx, args[1:])
+	case cmdDaemon:
+		return runDaemon(ctx, log, args[1:])
+	case cmdLogin:
+		return runLogin(ctx, log, args[1:])
+	case cmdSelftest:
+		return runSelftest(ctx, log, args[1:])
+	case cmdVerify:
+		return runVerify(ctx, log, args[1:])
+	case cmdRender:
+		return runRender(ctx, log, args[1:])
+	case cmdDoctor:
+		return runDoctor(ctx, log, args[1:])
+	case cmdTUI:
+		return runTUI(ctx, log, args[1:])
 	default:
 		printUsage(os.Stderr)
 		return fmt.Errorf("unknown command: %s", args[0])
@@ -57,16 +97,55 @@ func printUsage(w io.Writer) {
 	_, _ = fmt.Fprintln(w, "ergo-solver: ARC puzzle solver CLI")
 	_, _ = fmt.Fprintln(w)
 	_, _ = fmt.Fprintln(w, "Usage:")
-	_, _ = fmt.Fprintln(w, "  ergo-solver solve --config PATH [--count N] [--dry-run] [--auto]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver solve --config PATH [--count N] [--dry-run] [--auto [--dashboard]] [--manual] [--log-file PATH] [--output json]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver solve --config PATH --resume")
+	_, _ = fmt.Fprintln(w, "  ergo-solver solve --config PATH --canary [--dry-run]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver tui --config PATH [--count N] [--dry-run] [--no-ai]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver solve (--file TASK.json | --dir DIR) [--config PATH] [--out DIR]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver status [--config PATH] [--json]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver puzzle show FILE | --id ID [--config PATH]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver submit --config PATH [--puzzle-id ID] --answer FILE [--force]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver verify --puzzle FILE --answer FILE [--config PATH] [--no-ai]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver render --puzzle FILE [--answer FILE] [--out DIR] [--format png|svg|both]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver fetch --config PATH [--out DIR]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver daemon --config PATH [--log-file PATH] [--read-only-config] [--dashboard]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver login --config PATH [--from-clipboard]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver doctor --config PATH [--fix] [--offline]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver selftest [--config PATH]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver stats [--config PATH] [--days N]")
+	_, _ = fmt.Fprintln(w, "  ergo-solver stats sync --config PATH")
+	_, _ = fmt.Fprintln(w, "  ergo-solver history [--config PATH] [--limit N] [--failed-only] [--json]")
 	_, _ = fmt.Fprintln(w)
 	_, _ = fmt.Fprintln(w, "Options:")
 	_, _ = fmt.Fprintln(w, "  --config  Path to config.json (required)")
 	_, _ = fmt.Fprintln(w, "  --count   Number of puzzles to solve (default: 1)")
 	_, _ = fmt.Fprintln(w, "  --dry-run Solve but do not submit")
 	_, _ = fmt.Fprintln(w, "  --auto    Auto-loop until daily limit exhausted (1-5 min interval)")
+	_, _ = fmt.Fprintln(w, "  --manual  Solve by hand in a grid editor; no AI needed")
+	_, _ = fmt.Fprintln(w, "  --skip-quota-check Never call /api/daily/remaining; use puzzle responses")
+	_, _ = fmt.Fprintln(w, "  --file/--dir Solve local ARC task files offline (no cookie/PoW); --out sets answer dir")
+	_, _ = fmt.Fprintln(w, "  --holdout Score a prediction of the last training pair instead (dry-run/offline)")
+	_, _ = fmt.Fprintln(w, "  --log-file Append logs to PATH instead of stderr (reopened on SIGHUP)")
+	_, _ = fmt.Fprintln(w, "  --output  text (default) or json: one result object per puzzle on stdout")
+	_, _ = fmt.Fprintln(w, "  --resume  Continue the run interrupted by Ctrl-C/SIGTERM from its checkpoint")
+	_, _ = fmt.Fprintln(w, "  --max-cost/--max-tokens Stop once the run's estimated AI cost (USD) or tokens reach this")
+	_, _ = fmt.Fprintln(w, "  --min-confidence Refuse to submit answers below this confidence (0-100)")
+	_, _ = fmt.Fprintln(w, "  --dashboard (solve --auto, daemon) Live full-screen view of the run in place of the scrolling log")
+	_, _ = fmt.Fprintln(w, "  --canary  Solve one puzzle with stricter checks and full artifacts; gates --auto (canary.max_age_hours)")
+	_, _ = fmt.Fprintln(w, "  --read-only-config Fail early unless the run can proceed without writing the config file")
+	_, _ = fmt.Fprintln(w, "  --no-ai   (verify) Run the programmatic checks only, without the AI verifier")
+	_, _ = fmt.Fprintln(w, "  --fix     (doctor) Offer to fix each problem found; config edits keep a .bak copy")
+	_, _ = fmt.Fprintln(w, "  --format  (render) Image format: png (default), svg or both")
+	_, _ = fmt.Fprintln(w, "  --days    (stats) Days of per-day points to show (default: 14)")
+	_, _ = fmt.Fprintln(w, "  --limit/--failed-only (history) Number of attempts to list (default: 20) / only failures")
 	_, _ = fmt.Fprintln(w)
 	_, _ = fmt.Fprintln(w, "Environment:")
-	_, _ = fmt.Fprintln(w, "  NO_COLOR  Disable colored output")
+	_, _ = fmt.Fprintln(w, "  NO_COLOR         Disable colored output")
+	_, _ = fmt.Fprintln(w, "  ERGO_PROXY_HOME  State directory for cookies, history and runtime files (default: config dir)")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Pausing:")
+	_, _ = fmt.Fprintln(w, "  touch $ERGO_PROXY_HOME/pause (or send SIGUSR1) to pause after the current puzzle;")
+	_, _ = fmt.Fprintln(w, "  remove the file (or send SIGUSR1 again) to resume.")
 }
 
 func runSolve(ctx context.Context, log *logger, args []string) error {
@@ -77,194 +156,920 @@ func runSolve(ctx context.Context, log *logger, args []string) error {
 		count      int
 		dryRun     bool
 		autoLoop   bool
+		logFile    string
+		manual     bool
+		skipQuota  bool
+		taskFile   string
+		taskDir    string
+		outDir     string
+		holdout    bool
+		output     string
+		resume     bool
+		maxCost    float64
+		maxTokens  int64
+		readOnly   bool
+		minConf    int
+		canary     bool
+		dashOn     bool
 	)
 	fs.StringVar(&configPath, "config", "", "config path (required)")
 	fs.IntVar(&count, "count", 1, "how many puzzles to solve per round")
 	fs.BoolVar(&dryRun, "dry-run", false, "solve but do not submit")
 	fs.BoolVar(&autoLoop, "auto", false, "auto loop until daily limit exhausted")
+	fs.BoolVar(&manual, "manual", false, "solve by hand in a grid editor instead of using AI")
+	fs.BoolVar(&skipQuota, "skip-quota-check", false, "never call /api/daily/remaining; rely on puzzle responses")
+	fs.StringVar(&taskFile, "file", "", "solve a local ARC task file offline")
+	fs.StringVar(&taskDir, "dir", "", "solve every *.json ARC task in a directory offline")
+	fs.StringVar(&outDir, "out", "", "output directory for offline answers (default: run directory)")
+	fs.BoolVar(&holdout, "holdout", false, "withhold the last training pair and score the prediction of it (with --dry-run or --file/--dir)")
+	fs.StringVar(&logFile, "log-file", "", "append logs to this file (reopened on SIGHUP)")
+	fs.StringVar(&output, "output", outputText, "result format: text, or json for one object per puzzle on stdout")
+	fs.BoolVar(&resume, "resume", false, "continue the run recorded in the last checkpoint")
+	fs.Float64Var(&maxCost, "max-cost", 0, "stop once the estimated AI cost in USD reaches this (default: ai.max_cost)")
+	fs.Int64Var(&maxTokens, "max-tokens", 0, "stop once AI prompt plus completion tokens reach this (default: ai.max_tokens)")
+	fs.IntVar(&minConf, "min-confidence", 0, "refuse to submit answers below this confidence, 0-100 (default: ai.min_confidence)")
+	fs.BoolVar(&readOnly, "read-only-config", false, "fail early unless the run can proceed without writing the config file")
+	fs.BoolVar(&canary, "canary", false, "solve one puzzle with stricter verification and full artifacts, recorded as a canary")
+	fs.BoolVar(&dashOn, "dashboard", false, "show auto mode on a live full-screen dashboard instead of the scrolling log")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
-	if configPath == "" {
+	offline := taskFile != "" || taskDir != ""
+	if configPath == "" && !offline {
 		return fmt.Errorf("--config is required")
 	}
 	if count <= 0 {
 		return fmt.Errorf("--count must be > 0")
 	}
+	if maxCost < 0 || maxTokens < 0 {
+		return fmt.Errorf("--max-cost and --max-tokens must be >= 0")
+	}
+	if minConf < 0 || minConf > 100 {
+		return fmt.Errorf("--min-confidence must be between 0 and 100")
+	}
+	if resume && offline {
+		return fmt.Errorf("--resume cannot be combined with --file/--dir")
+	}
+	if holdout && !dryRun && !offline && !resume {
+		return fmt.Errorf("--holdout requires --dry-run or --file/--dir")
+	}
+	if canary && (offline || autoLoop || manual || resume || holdout || count != 1) {
+		return fmt.Errorf("--canary solves exactly one puzzle online; it cannot be combined with --count, --auto, --manual, --resume, --holdout or --file/--dir")
+	}
+	if dashOn && (offline || manual || output != outputText) {
+		return fmt.Errorf("--dashboard cannot be combined with --manual, --output json or --file/--dir")
+	}
+	if readOnly && configPath != "" {
+		if err := checkReadOnlyConfig(configPath, logFile); err != nil {
+			return err
+		}
+	}
+	records, err := newRecordWriter(output, os.Stdout)
+	if err != nil {
+		return err
+	}
 
-	log.infof("starting: count=%d dryRun=%v autoLoop=%v", count, dryRun, autoLoop)
+	if logFile != "" {
+		closeLog, err := log.logToFile(logFile)
+		if err != nil {
+			return err
+		}
+		defer closeLog()
+	}
 
+	// SIGINT/SIGTERM cancel ctx, which aborts in-flight AI and API requests
+	// and lets the loop checkpoint and exit cleanly. A second signal kills
+	// the process as usual.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if offline {
+		files, err := localTaskFiles(taskFile, taskDir)
+		if err != nil {
+			return err
+		}
+		cfg, err := loadConfigFile(configPath)
+		if err != nil {
+			return err
+		}
+		if outDir == "" {
+			outDir = filepath.Join(runDirFor(homeDir(configPath), time.Now()), "answers")
+		}
+		return stoppedBySignal(ctx, log, runSolveOffline(ctx, log, cfg, files, outDir, holdout, records))
+	}
+
+	// The lock is held from before the login to the end of the last pass.
 	cfg, err := loadConfig(configPath)
 	if err != nil {
 		return err
 	}
-
-	cfg, err = ensureLoginInteractive(ctx, cfg, configPath, log)
+	lock, err := lockInstance(cfg)
 	if err != nil {
 		return err
 	}
+	defer lock.release()
+
+	o := onlineRun{
+		configPath: configPath,
+		count:      count,
+		dryRun:     dryRun,
+		autoLoop:   autoLoop,
+		manual:     manual,
+		skipQuota:  skipQuota,
+		holdout:    holdout,
+		records:    records,
+		minConf:    minConf,
+		canary:     canary,
+		lock:       lock,
+	}
+	if maxCost > 0 || maxTokens > 0 {
+		o.budget = &budget{maxCost: maxCost, maxTokens: maxTokens}
+	}
+	if resume {
+		cp, err := loadCheckpoint(checkpointPath(homeDir(configPath)))
+		if err != nil {
+			return err
+		}
+		o = resumeRun(o, cp, log)
+		if o.holdout && !o.dryRun {
+			return fmt.Errorf("--holdout requires --dry-run, and the checkpointed run submitted answers")
+		}
+	}
+	if dashOn {
+		if !o.autoLoop {
+			return fmt.Errorf("--dashboard requires --auto")
+		}
+		dash, err := startDashboard(log, "auto")
+		if err != nil {
+			return err
+		}
+		defer dash.close()
+		o.dash = dash
+	}
+	o.pause = newPauseController(homeDir(configPath))
+	defer o.pause.stop()
+	o.notify = newNotifier(cfg, log)
+	defer o.notify.close(ctx)
+	status, closeStatus := startStatus(homeDir(configPath), log)
+	defer closeStatus()
+	o.status = status
+	if o.autoLoop {
+		if err := requireCanary(ctx, cfg); err != nil {
+			return err
+		}
+		runs, err := parseSchedule(cfg.Schedule)
+		if err != nil {
+			return err
+		}
+		if len(runs) > 0 {
+			if o.budget == nil {
+				o.budget = &budget{maxCost: cfg.AI.MaxCost, maxTokens: cfg.AI.MaxTokens}
+			}
+			return stoppedBySignal(ctx, log, runAutoScheduled(ctx, log, o, runs, daemonRetry(cfg)))
+		}
+	}
+	_, err = runOnline(ctx, log, o)
+	return stoppedBySignal(ctx, log, err)
+}
+
+// stoppedBySignal turns the error of a run interrupted through ctx into a
+// clean exit.
+func stoppedBySignal(ctx context.Context, log *logger, err error) error {
+	if err != nil && ctx.Err() != nil {
+		log.info("stopped")
+		return nil
+	}
+	return err
+}
 
-	client, err := newAPIClient(cfg)
+// runAutoScheduled is --auto with a schedule: scheduled passes until the
+// daily limit is used up.
+func runAutoScheduled(ctx context.Context, log *logger, o onlineRun, runs []scheduledRun, retry time.Duration) error {
+	o.autoLoop = false
+	solved := 0
+	for {
+		out, err := runScheduledPass(ctx, log, o, runs, nil, retry)
+		// Only the first pass continues a resumed run.
+		o.resume, o.notBefore, o.scheduledAt = nil, time.Time{}, time.Time{}
+		solved += out.solved
+		if err != nil {
+			return err
+		}
+		if out.exhausted {
+			log.okf("auto mode complete: daily limit exhausted, solved %d puzzles", solved)
+			return nil
+		}
+		if out.overBudget {
+			log.okf("auto mode stopped: AI budget reached, solved %d puzzles", solved)
+			return nil
+		}
+	}
+}
+
+// onlineRun holds the options of one solve run against the site.
+type onlineRun struct {
+	configPath string
+	count      int
+	dryRun     bool
+	autoLoop   bool
+	manual     bool
+	skipQuota  bool
+	holdout    bool
+	records    *recordWriter
+	// stop, if set, is checked before each puzzle; true ends the run early.
+	stop func() bool
+	// paced spaces puzzles by the auto-mode delays and skips failed ones,
+	// like auto mode but ending after count solves (scheduled runs).
+	paced bool
+	// resume, if set, is a checkpointed puzzle answered before fetching
+	// new ones (solve --resume).
+	resume *puzzleNewResponse
+	// notBefore delays the first puzzle of a resumed run until the
+	// interrupted run's pacing would have fetched it.
+	notBefore time.Time
+	// scheduledAt is the firing of the scheduled pass this run belongs to.
+	scheduledAt time.Time
+	// budget caps the AI spend; nil takes ai.max_cost/ai.max_tokens for
+	// this run alone.
+	budget *budget
+	// minConf is the least confidence (percent) an answer needs to be
+	// submitted; 0 takes ai.min_confidence.
+	minConf int
+	// tui opens every answer in the full-screen editor before it is
+	// submitted (tui command); with manual the editor starts empty.
+	tui bool
+	// canary solves with stricter verification, writes the attempt's
+	// artifacts and records it as a canary (solve --canary).
+	canary bool
+	// dash, if set, shows the run on the live dashboard (--dashboard).
+	dash *dashboard
+	// status, if set, is the process's status served on the status socket,
+	// kept across the passes of --auto and the daemon; nil makes the run
+	// serve its own.
+	status *runStatus
+	// lock, if set, is the instance lock the process holds for the passes
+	// of --auto and the daemon; nil makes the run take its own.
+	lock *instanceLock
+	// notify, if set, is the process's notifier, shared by the passes of
+	// --auto and the daemon so its throttle spans them; nil lets the session
+	// make one for this run.
+	notify *notifier
+	// pause holds the loop while paused; shared by the passes of --auto and
+	// the daemon so a SIGUSR1 toggle lasts across them. Nil gives the run a
+	// controller of its own.
+	pause *pauseController
+}
+
+// onlineOutcome summarises a finished online run.
+type onlineOutcome struct {
+	solved int
+	// exhausted reports that the run ended because the daily limit was
+	// used up.
+	exhausted bool
+	// overBudget reports that the run ended because its AI budget was
+	// spent.
+	overBudget bool
+}
+
+// runOnline logs in and solves puzzles. If ctx is cancelled (SIGINT or
+// SIGTERM) the loop stops where it is and a checkpoint of its progress is
+// written to the runtime home; a run that completes removes any stale one.
+func runOnline(ctx context.Context, log *logger, o onlineRun) (out onlineOutcome, err error) {
+	var (
+		configPath = o.configPath
+		count      = o.count
+		dryRun     = o.dryRun
+		autoLoop   = o.autoLoop
+		manual     = o.manual
+		skipQuota  = o.skipQuota
+		holdout    = o.holdout
+		records    = o.records
+	)
+	solvedCount := 0
+
+	log.infof("starting: count=%d dryRun=%v autoLoop=%v", count, dryRun, autoLoop)
+
+	passStart := time.Now()
+	status := o.status
+	if status == nil {
+		var closeStatus func()
+		status, closeStatus = startStatus(homeDir(configPath), log)
+		defer closeStatus()
+	} else {
+		// Between the passes of the process the status shows it waiting.
+		defer func() {
+			status.setPuzzle("")
+			status.setPhase(phaseWaiting)
+		}()
+	}
+	status.setProgress(0, count)
+	status.setPhase(phaseLogin)
+	o.dash.attach(status)
+	defer o.dash.detach()
+
+	// open is the fetched puzzle not yet answered, saved with a checkpoint.
+	var open *puzzleNewResponse
+	// nextAt is when a paced run fetches its next puzzle, also saved.
+	nextAt := o.notBefore
+	cpPath := checkpointPath(homeDir(configPath))
+	// progress checkpoints auto mode and scheduled passes as they go, so a
+	// run killed without a chance to save can still be resumed.
+	progress := func(log *logger) {
+		if !autoLoop && !o.paced {
+			return
+		}
+		if err := saveCheckpoint(cpPath, newCheckpoint(status.snapshot(), o, open, nextAt)); err != nil {
+			log.warnf("%v", err)
+		}
+	}
+	// pace waits d before the next puzzle, checkpointing first.
+	pace := func(ctx context.Context, log *logger, d time.Duration) error {
+		nextAt = time.Now().Add(d)
+		o.dash.setNext(nextAt, "next puzzle")
+		progress(log)
+		if err := sleepCtx(ctx, d); err != nil {
+			return err
+		}
+		nextAt = time.Time{}
+		return nil
+	}
+	defer func() {
+		if ctx.Err() == nil {
+			if err == nil {
+				if cerr := clearCheckpoint(cpPath); cerr != nil {
+					log.warnf("%v", cerr)
+				}
+			}
+			return
+		}
+		cp := newCheckpoint(status.snapshot(), o, open, nextAt)
+		if cerr := saveCheckpoint(cpPath, cp); cerr != nil {
+			log.warnf("interrupted: %v", cerr)
+			return
+		}
+		log.warnf("interrupted during %s: solved %d/%d, checkpoint saved to %s", cp.Phase, cp.Solved, cp.Target, cpPath)
+	}()
+
+	if wait := time.Until(nextAt); wait > 0 {
+		status.setPhase(phaseSleeping)
+		o.dash.setNext(nextAt, "next puzzle")
+		log.infof("resume: waiting %s before the next puzzle, as the interrupted run was pacing", wait.Round(time.Second))
+		if err := sleepCtx(ctx, wait); err != nil {
+			return onlineOutcome{}, err
+		}
+		status.setPhase(phaseLogin)
+	}
+	nextAt = time.Time{}
+
+	if o.lock == nil {
+		cfg, err := loadConfig(configPath)
+		if err != nil {
+			return onlineOutcome{solved: solvedCount}, err
+		}
+		lock, err := lockInstance(cfg)
+		if err != nil {
+			return onlineOutcome{solved: solvedCount}, err
+		}
+		defer lock.release()
+	}
+	sess, err := openSession(ctx, configPath, log, o.notify)
 	if err != nil {
-		return err
+		return onlineOutcome{solved: solvedCount}, err
 	}
-	me, err := client.authMe(ctx)
+	latency := newLatencyTracker()
+	sess.latency = latency
+	sess.adopt(sess.client)
+	defer latency.logSummary(log)
+
+	log.infof("site: %s", sess.cfg.BaseURL)
+
+	// The daily quota is only queried up front when a PoW refresh is due, so
+	// no PoW work is wasted on an exhausted day; otherwise the first puzzle
+	// response carries the same counters and one request is saved.
+	status.setPhase(phasePow)
+	pst, err := sess.client.powStatus(ctx)
 	if err != nil {
-		if isAuthError(err) {
-			return errAuthRequired
+		return onlineOutcome{solved: solvedCount}, err
+	}
+	skipQuota = skipQuota || sess.cfg.SkipQuotaCheck
+	if powNeedsRefresh(pst) {
+		if skipQuota {
+			log.info("daily quota pre-check skipped")
+		} else if dr, err := sess.client.dailyRemaining(ctx); err == nil {
+			log.infof("daily quota: remaining=%d completed=%d limit=%d", dr.Remaining, dr.Completed, dr.Limit)
+			status.setQuota(dr.Remaining, dr.Limit)
+			if dr.Remaining <= 0 {
+				log.warn("stopping: daily limit exhausted")
+				return onlineOutcome{solved: solvedCount, exhausted: true}, nil
+			}
+		} else {
+			log.warnf("failed to query daily quota: %s (will try fetching puzzle)", err.Error())
 		}
-		return err
+		if err := solvePow(ctx, sess.client, log); err != nil {
+			return onlineOutcome{solved: solvedCount}, err
+		}
+	} else {
+		log.ok("PoW valid, no refresh needed")
 	}
-	_ = persistCookieIfChanged(configPath, &cfg, client, log)
-	log.okf("logged in: %s(%s)", me.User.Username, me.User.ID)
-	log.infof("site: %s", cfg.BaseURL)
+	sess.persist(log)
 
-	if dr, err := client.dailyRemaining(ctx); err == nil {
-		log.infof("daily quota: remaining=%d completed=%d limit=%d", dr.Remaining, dr.Completed, dr.Limit)
-		if dr.Remaining <= 0 {
-			log.warn("stopping: daily limit exhausted")
-			return nil
+	var solve func(context.Context, puzzle) (solveResult, error)
+	// resolve, when set, solves a puzzle again after incorrect answers.
+	var resolve func(context.Context, puzzle, [][][]int) (solveResult, error)
+	// refine solves a puzzle again in place of an answer graded incorrect in
+	// an earlier run; nil in manual mode.
+	var refine func(context.Context, puzzle, [][][]int) (solveResult, error)
+	var breaker *circuitBreaker
+	var meter *usageMeter
+	// aiSolver is nil in manual mode.
+	var aiSolver *Solver
+	if manual {
+		// The editor stays on the terminal; stdout is reserved for records.
+		editorOut := io.Writer(os.Stdout)
+		if records != nil {
+			editorOut = os.Stderr
 		}
+		solve = newManualSolver(os.Stdin, editorOut)
 	} else {
-		log.warnf("failed to query daily quota: %s (will try fetching puzzle)", err.Error())
+		if o.canary {
+			sess.cfg.AI = canaryAI(sess.cfg.AI)
+		}
+		solver, err := newAISolver(ctx, sess.cfg, log)
+		if err != nil {
+			return onlineOutcome{solved: solvedCount}, err
+		}
+		if solver == nil {
+			return onlineOutcome{solved: solvedCount}, errors.New("AI solver not configured")
+		}
+		meter = newUsageMeter(sess.cfg.AI.Prices)
+		if o.budget == nil {
+			o.budget = &budget{maxCost: sess.cfg.AI.MaxCost, maxTokens: sess.cfg.AI.MaxTokens}
+		}
+		solver.usage = meter
+		solver.latency = latency
+		if o.dash != nil {
+			solver.streamed = o.dash.streamed
+		}
+		defer meter.logSummary(log)
+		solve = solver.Solve
+		refine = solver.Resolve
+		if sess.cfg.AI.MaxResubmits > 0 {
+			resolve = solver.Resolve
+		}
+		minConf := o.minConf
+		if minConf == 0 {
+			minConf = sess.cfg.AI.MinConfidence
+		}
+		if minConf > 0 {
+			// Auto mode and scheduled runs solve a low-confidence puzzle
+			// once more before skipping it.
+			solve = confidenceGate(solve, minConf, autoLoop || o.paced)
+			refine = func(ctx context.Context, p puzzle, wrong [][][]int) (solveResult, error) {
+				res, err := solver.Resolve(ctx, p, wrong)
+				return checkConfidence(res, err, minConf)
+			}
+			if resolve != nil {
+				resolve = refine
+			}
+		}
+		breaker = solver.breaker
+		aiSolver = solver
+	}
+	if o.tui {
+		ai := solve
+		if manual {
+			ai = nil
+		}
+		solve = newTUISolver(ai)
+		// Re-solves would be submitted without passing the editor.
+		resolve, refine = nil, nil
 	}
 
-	if err := ensurePow(ctx, client, log); err != nil {
-		return err
+	pause := o.pause
+	if pause == nil {
+		pause = newPauseController(homeDir(configPath))
+		defer pause.stop()
 	}
-	_ = persistCookieIfChanged(configPath, &cfg, client, log)
 
-	solver, err := newAISolver(ctx, cfg, log)
+	hist, err := openHistory(sess.cfg)
 	if err != nil {
-		return err
+		log.warnf("run history disabled: %v", err)
+	}
+	defer hist.close()
+	if aiSolver != nil {
+		aiSolver.history = hist
+	}
+	o.dash.loadToday(ctx, hist)
+	notify := sess.notify
+	var summary runSummary
+	if autoLoop || o.paced {
+		defer func() {
+			summary.Solved = out.solved
+			summary.Elapsed = time.Since(passStart)
+			summary.Reason = finishReason(ctx, out, err)
+			notify.runFinished(context.WithoutCancel(ctx), log, summary)
+		}()
+	}
+	if notify != o.notify {
+		// The session's own notifier lives only as long as this run;
+		// deferred after the summary, so held events are sent before it.
+		defer notify.close(ctx)
 	}
-	if solver == nil {
-		return errors.New("AI solver not configured")
+	review, err := newReviewer(sess.cfg, log)
+	if err != nil {
+		return onlineOutcome{}, err
+	}
+	defer review.close()
+	recordAttempt := func(ctx context.Context, log *logger, p puzzle, a attempt) {
+		// An attempt that got this far is kept even if a signal arrives
+		// while it is being recorded.
+		ctx = context.WithoutCancel(ctx)
+		open = nil
+		if err := hist.record(ctx, a); err != nil {
+			log.warnf("record history: %v", err)
+		}
+		o.dash.graded(a)
+		switch {
+		case a.Err != nil:
+			summary.Failed++
+		case a.Submitted:
+			summary.Submitted++
+			if a.Correct != nil && *a.Correct {
+				summary.Correct++
+			}
+			summary.Points += a.Points
+		}
+		if err := records.write(a); err != nil {
+			log.warnf("write output: %v", err)
+		}
+		notify.puzzleOutcome(ctx, log, sess.runDir, p, a)
+		if a.Canary {
+			reportCanary(log, sess.runDir, p, a)
+		}
 	}
 
-	solvedCount := 0
 	startAll := time.Now()
+
+	// eta smooths fetch-to-submit times for the auto-mode quota estimate.
+	var eta etaEstimator
+
+	// retry is a puzzle answered incorrectly that is solved again with the
+	// attempts it has left (ai.max_resubmits) instead of fetching a new one.
+	var retry *retryPuzzle
+
+	// deferred holds puzzles degraded mode queued while the AI was
+	// unavailable, answered first once it is back.
+	var deferred []*puzzleNewResponse
+	degraded := aiSolver != nil && sess.cfg.AI.DegradedMode && (autoLoop || o.paced)
+	defer func() {
+		if len(deferred) > 0 {
+			ids := make([]string, 0, len(deferred))
+			for _, d := range deferred {
+				ids = append(ids, d.Puzzle.ID)
+			}
+			log.warnf("degraded mode: %d deferred puzzle(s) left unanswered: %s", len(deferred), strings.Join(ids, ", "))
+		}
+	}()
+
+	var holdoutScored, holdoutCorrect int
+	defer func() {
+		if holdoutScored > 0 {
+			log.okf("holdout accuracy: %d/%d (%.1f%%)", holdoutCorrect, holdoutScored, 100*float64(holdoutCorrect)/float64(holdoutScored))
+		}
+	}()
 	for solvedCount < count {
-		log.infof("fetching puzzle: index=%d/%d", solvedCount+1, count)
-		pNew, err := puzzleNewWithRetry(ctx, client, log)
-		if err != nil {
-			if isDailyExhaustedError(err) {
-				log.warn("stopping: daily limit exhausted")
-				return nil
+		trace := newTraceID()
+		pctx := withTraceID(ctx, trace)
+		plog := log.with("trace", trace)
+		status.setProgress(solvedCount, count)
+		status.setPuzzle("")
+		open = nil
+		if o.stop != nil && o.stop() {
+			log.infof("stopping early: solved %d puzzles", solvedCount)
+			return onlineOutcome{solved: solvedCount}, nil
+		}
+		if reason := o.budget.exceeded(); reason != "" {
+			log.warnf("stopping: %s, solved %d puzzles", reason, solvedCount)
+			return onlineOutcome{solved: solvedCount, overBudget: true}, nil
+		}
+		if pause.paused() {
+			status.setPhase(phasePaused)
+		}
+		if err := pause.wait(pctx, plog); err != nil {
+			return onlineOutcome{solved: solvedCount}, err
+		}
+		// Fetching while the AI circuit is open would only spend quota on
+		// a puzzle the solver cannot answer, unless degraded mode can
+		// queue it.
+		aiDown := time.Now().Before(breaker.openUntil())
+		if until := breaker.openUntil(); aiDown && !(degraded && len(deferred) < maxDeferred) {
+			status.setPhase(phaseAICircuit)
+			plog.warnf("AI circuit open: waiting until %s before fetching", until.Format(time.TimeOnly))
+			o.dash.setNext(until, "AI circuit closes")
+			if err := sleepCtx(pctx, time.Until(until)); err != nil {
+				return onlineOutcome{solved: solvedCount}, err
 			}
-			if isAuthError(err) {
-				log.warn("auth expired, re-authenticating...")
-				cfg, err = ensureLoginInteractive(ctx, cfg, configPath, log)
-				if err != nil {
-					return err
+		}
+		status.setPhase(phaseFetching)
+		plog.infof("fetching puzzle: index=%d/%d", solvedCount+1, count)
+		fetchStart := time.Now()
+		// A resumed run first answers the puzzle its checkpoint left open;
+		// that puzzle was already counted against the quota when fetched.
+		pNew, resumed := o.resume, o.resume != nil
+		o.resume = nil
+		retrying := retry
+		retry = nil
+		if retrying != nil {
+			pNew, resumed = retrying.puzzle, false
+			plog.infof("retrying puzzle: puzzleId=%s, remainingAttempts=%d, incorrect=%d", pNew.Puzzle.ID, pNew.RemainingAttempts, len(retrying.wrong))
+		} else if resumed {
+			plog.infof("puzzle resumed from checkpoint: puzzleId=%s, remainingAttempts=%d", pNew.Puzzle.ID, pNew.RemainingAttempts)
+		} else if len(deferred) > 0 && !aiDown {
+			// Like a resumed puzzle, a deferred one was already counted
+			// against the quota and may have expired meanwhile.
+			pNew, resumed = deferred[0], true
+			deferred = deferred[1:]
+			plog.infof("AI available: solving deferred puzzle: puzzleId=%s, %d more queued", pNew.Puzzle.ID, len(deferred))
+		} else {
+			pNew, err = sess.client.puzzleNew(pctx)
+			if err != nil {
+				if isDailyExhaustedError(err) {
+					plog.warn("stopping: daily limit exhausted")
+					return onlineOutcome{solved: solvedCount, exhausted: true}, nil
 				}
-				client, err = newAPIClient(cfg)
-				if err != nil {
-					return err
+				if isAuthError(err) {
+					if err := sess.reauth(pctx, plog); err != nil {
+						return onlineOutcome{solved: solvedCount}, err
+					}
+					continue
 				}
-				continue
+				return onlineOutcome{solved: solvedCount}, err
 			}
-			return err
+			sess.persist(plog)
+
+			if pNew.DailyRemaining <= 0 {
+				plog.warn("stopping: daily limit exhausted")
+				return onlineOutcome{solved: solvedCount, exhausted: true}, nil
+			}
+
+			plog.infof("puzzle fetched: puzzleId=%s, remainingAttempts=%d, dailyRemaining=%d/%d", pNew.Puzzle.ID, pNew.RemainingAttempts, pNew.DailyRemaining, pNew.DailyLimit)
+		}
+		open = pNew
+		plog = plog.with("puzzle", pNew.Puzzle.ID)
+		progress(plog)
+		if err := hist.savePuzzle(pctx, pNew.Puzzle, time.Now()); err != nil {
+			plog.warnf("record puzzle: %v", err)
 		}
-		_ = persistCookieIfChanged(configPath, &cfg, client, log)
+		status.setPuzzle(pNew.Puzzle.ID)
+		o.dash.newPuzzle()
+		status.setQuota(pNew.DailyRemaining, pNew.DailyLimit)
+		status.setPhase(phaseSolving)
 
-		if pNew.DailyRemaining <= 0 {
-			log.warn("stopping: daily limit exhausted")
-			return nil
+		target := pNew.Puzzle
+		var expected [][]int
+		if holdout {
+			if hp, exp, ok := holdoutPuzzle(pNew.Puzzle); ok {
+				target, expected = hp, exp
+				plog.infof("holdout: predicting training pair %d from the other %d", len(pNew.Puzzle.Train), len(hp.Train))
+			} else {
+				plog.warn("holdout: fewer than 2 training pairs, solving the test input instead")
+			}
 		}
 
-		log.infof("puzzle fetched: puzzleId=%s, remainingAttempts=%d, dailyRemaining=%d/%d", pNew.Puzzle.ID, pNew.RemainingAttempts, pNew.DailyRemaining, pNew.DailyLimit)
+		if below := sess.cfg.AI.Economy.Below; aiSolver != nil && below > 0 {
+			low := o.budget.low(below, count-solvedCount)
+			if aiSolver.economy.Swap(low) != low && low {
+				plog.warnf("AI budget running low (%.0f%% left): economy mode", 100*o.budget.left())
+			}
+		}
 
 		start := time.Now()
-		answer, err := solver.Solve(ctx, pNew.Puzzle)
+		spentBefore, repairsBefore := meter.spent(), aiSolver.repairCount()
+		var result solveResult
+		if retrying != nil {
+			result, err = resolve(pctx, target, retrying.wrong)
+		} else {
+			result, err = solve(pctx, target)
+		}
+		if err != nil && degraded && errors.Is(err, ErrAIUnavailable) {
+			result, err = degradedSolve(plog, target, err)
+		}
+		if err == nil {
+			err = sanityCheck(target, result.Answer)
+		}
+		if err == nil && expected == nil {
+			result, err = avoidKnownWrong(pctx, plog, hist, target, pNew.Puzzle.ID, result, refine)
+		}
+		if err == nil && o.canary {
+			err = checkCanary(result)
+		}
+		if ctx.Err() != nil {
+			// Interrupted mid-solve: nothing was submitted, so no attempt
+			// is recorded; the checkpoint names the puzzle.
+			return onlineOutcome{solved: solvedCount}, ctx.Err()
+		}
+		answer := result.Answer
+		att := attempt{PuzzleID: pNew.Puzzle.ID, StartedAt: start, Result: result, SolveTime: time.Since(start), DryRun: dryRun, Spend: meter.spent().minus(spentBefore), Repairs: int(aiSolver.repairCount() - repairsBefore), Canary: o.canary}
+		o.budget.charge(att.Spend)
+		if u := att.Spend.Usage; u.Requests > 0 {
+			plog.infof("puzzle AI usage: requests=%d prompt=%d completion=%d cost=$%.4f", u.Requests, u.PromptTokens, u.CompletionTokens, att.Spend.Cost)
+		}
 		if err != nil {
-			if errors.Is(err, ErrAIUnavailable) {
-				log.err("AI service unavailable")
-				return fmt.Errorf("AI unavailable: %w", err)
+			if errors.Is(err, errManualAborted) {
+				return onlineOutcome{solved: solvedCount}, err
 			}
-			if autoLoop {
-				log.warnf("AI solve failed: %v, skipping...", err)
-				waitDur := time.Duration(30+rand.Intn(30)) * time.Second
-				log.infof("sleeping %s before continue...", waitDur.Round(time.Second))
-				time.Sleep(waitDur)
-				count = solvedCount + 1
+			att.Err = err
+			recordAttempt(pctx, plog, target, att)
+			if errors.Is(err, ErrAIUnavailable) && !autoLoop && !o.paced {
+				plog.err("AI service unavailable")
+				return onlineOutcome{solved: solvedCount}, fmt.Errorf("AI unavailable: %w", err)
+			}
+			if errors.Is(err, errDeferred) {
+				deferred = append(deferred, pNew)
+				plog.warnf("degraded mode: puzzle %s %v, %d queued", pNew.Puzzle.ID, err, len(deferred))
+			} else if autoLoop || o.paced {
+				plog.warnf("AI solve failed: %v, skipping...", err)
+			}
+			if autoLoop || o.paced {
+				status.setPhase(phaseSleeping)
+				waitDur := randDelay(autoRetryDelayMin, autoRetryDelayMax)
+				plog.infof("sleeping %s before continue...", waitDur.Round(time.Second))
+				if err := pace(pctx, plog, waitDur); err != nil {
+					return onlineOutcome{solved: solvedCount}, err
+				}
+				if autoLoop {
+					count = solvedCount + 1
+				}
 				continue
 			}
-			return fmt.Errorf("ai solve failed: %w", err)
+			return onlineOutcome{solved: solvedCount}, fmt.Errorf("ai solve failed: %w", err)
+		}
+		if manual || o.tui {
+			plog.okf("answer entered (elapsed %s)", time.Since(start).Round(time.Second))
+		} else {
+			plog.okf("AI solved (elapsed %s)", time.Since(start).Round(10*time.Millisecond))
+			printAnswerPreview(target, answer)
 		}
-		log.okf("AI solved (elapsed %s)", time.Since(start).Round(10*time.Millisecond))
 
 		if dryRun {
-			log.okf("dry-run: puzzleId=%s answer generated but not submitted", pNew.Puzzle.ID)
+			if expected != nil {
+				holdoutScored++
+				if gridsEqual(answer, expected) {
+					holdoutCorrect++
+					plog.ok("holdout: prediction correct")
+				} else {
+					plog.warn("holdout: prediction incorrect")
+				}
+			}
+			plog.okf("dry-run: puzzleId=%s answer generated but not submitted", pNew.Puzzle.ID)
+			if expected != nil {
+				correct := gridsEqual(answer, expected)
+				att.Correct = &correct
+			}
+			recordAttempt(pctx, plog, target, att)
 			solvedCount++
+			status.setProgress(solvedCount, count)
 			continue
 		}
 
-		if err := ensurePow(ctx, client, log); err != nil {
-			return err
+		if !manual {
+			status.setPhase(phaseReviewing)
+			if err := review.review(pctx, plog, target, result); err != nil {
+				if ctx.Err() != nil {
+					return onlineOutcome{solved: solvedCount}, ctx.Err()
+				}
+				att.Err = err
+				recordAttempt(pctx, plog, target, att)
+				if autoLoop || o.paced {
+					plog.warnf("not submitting: %v, skipping...", err)
+					status.setPhase(phaseSleeping)
+					if err := pace(pctx, plog, randDelay(autoRetryDelayMin, autoRetryDelayMax)); err != nil {
+						return onlineOutcome{solved: solvedCount}, err
+					}
+					if autoLoop {
+						count = solvedCount + 1
+					}
+					continue
+				}
+				return onlineOutcome{solved: solvedCount}, err
+			}
 		}
-		_ = persistCookieIfChanged(configPath, &cfg, client, log)
 
-		log.infof("submitting: puzzleId=%s", pNew.Puzzle.ID)
-		sub, err := submitWithRetry(ctx, client, log, pNew.Puzzle.ID, answer)
+		if wait := thinkTime(sess.cfg.ThinkTime) - time.Since(fetchStart); wait > 0 {
+			status.setPhase(phaseSleeping)
+			plog.infof("think time: holding the answer %s before submitting", wait.Round(time.Second))
+			if err := sleepCtx(pctx, wait); err != nil {
+				return onlineOutcome{solved: solvedCount}, err
+			}
+		}
+
+		status.setPhase(phasePow)
+		if err := ensurePow(pctx, sess.client, plog); err != nil {
+			return onlineOutcome{solved: solvedCount}, err
+		}
+		sess.persist(plog)
+
+		status.setPhase(phaseSubmitting)
+		plog.infof("submitting: puzzleId=%s", pNew.Puzzle.ID)
+		sub, err := sess.client.puzzleSubmit(pctx, pNew.Puzzle.ID, answer)
 		if err != nil {
 			if isAuthError(err) {
-				log.warn("auth expired, re-authenticating...")
-				cfg, err = ensureLoginInteractive(ctx, cfg, configPath, log)
-				if err != nil {
-					return err
-				}
-				client, err = newAPIClient(cfg)
-				if err != nil {
-					return err
+				if err := sess.reauth(pctx, plog); err != nil {
+					return onlineOutcome{solved: solvedCount}, err
 				}
 				continue
 			}
-			return err
+			return onlineOutcome{solved: solvedCount}, err
 		}
-		_ = persistCookieIfChanged(configPath, &cfg, client, log)
+		sess.persist(plog)
 
 		if !sub.Success {
-			return fmt.Errorf("submit failed: %s", sub.Message)
+			att.Err = fmt.Errorf("submit failed: %s", sub.Message)
+			recordAttempt(pctx, plog, target, att)
+			if resumed {
+				// The checkpointed puzzle may have expired or been answered
+				// before the interruption; carry on with fresh puzzles.
+				plog.warnf("resumed puzzle not accepted: %s", sub.Message)
+				continue
+			}
+			return onlineOutcome{solved: solvedCount}, att.Err
 		}
+		att.Submitted = true
+		att.Correct = &sub.Correct
+		att.Points = sub.PointsAwarded
+		o.dash.setBalance(sub.PointsBalance)
+		recordAttempt(pctx, plog, target, att)
 
-		log.infof("submit response: %s", sub.Message)
+		plog.infof("submit response: %s", sub.Message)
+		eta.observe(time.Since(fetchStart))
 		if sub.Correct {
-			log.okf("correct: +%d points, balance=%d, dailyRemaining=%d/%d", sub.PointsAwarded, sub.PointsBalance, sub.DailyRemaining, sub.DailyLimit)
+			plog.okf("correct: +%d points, balance=%d, dailyRemaining=%d/%d", sub.PointsAwarded, sub.PointsBalance, sub.DailyRemaining, sub.DailyLimit)
 			solvedCount++
+			status.setProgress(solvedCount, count)
+			status.setQuota(sub.DailyRemaining, sub.DailyLimit)
 
 			if autoLoop && sub.DailyRemaining > 0 {
-				waitMin := 1*60 + rand.Intn(4*60+1) // 60-300s
-				waitDur := time.Duration(waitMin) * time.Second
-				log.infof("auto mode: sleeping %s (remaining %d)...", waitDur.Round(time.Second), sub.DailyRemaining)
-				time.Sleep(waitDur)
+				status.setPhase(phaseSleeping)
+				waitDur := randDelay(autoDelayMin, autoDelayMax)
+				plog.infof("auto mode: sleeping %s (remaining %d, ETA %s)...", waitDur.Round(time.Second), sub.DailyRemaining, eta.describe(sub.DailyRemaining))
+				if err := pace(pctx, plog, waitDur); err != nil {
+					return onlineOutcome{solved: solvedCount}, err
+				}
 				count = solvedCount + 1
+			} else if o.paced && solvedCount < count && sub.DailyRemaining > 0 {
+				status.setPhase(phaseSleeping)
+				waitDur := randDelay(autoDelayMin, autoDelayMax)
+				plog.infof("scheduled run: sleeping %s before the next puzzle (%d/%d solved)...", waitDur.Round(time.Second), solvedCount, count)
+				if err := pace(pctx, plog, waitDur); err != nil {
+					return onlineOutcome{solved: solvedCount}, err
+				}
+			}
+			continue
+		}
+		plog.warnf("incorrect: remainingAttempts=%d", sub.RemainingAttempts)
+		if next := retrying.next(pNew, sub, answer); resolve != nil && sub.RemainingAttempts > 0 && len(next.wrong) <= sess.cfg.AI.MaxResubmits {
+			plog.infof("re-solving for resubmission %d/%d", len(next.wrong), sess.cfg.AI.MaxResubmits)
+			retry = next
+			continue
+		}
+		if o.paced {
+			plog.warn("scheduled run: answer incorrect, skipping...")
+			status.setPhase(phaseSleeping)
+			if err := pace(pctx, plog, randDelay(autoRetryDelayMin, autoRetryDelayMax)); err != nil {
+				return onlineOutcome{solved: solvedCount}, err
 			}
 			continue
 		}
-		log.warnf("incorrect: remainingAttempts=%d", sub.RemainingAttempts)
 		if autoLoop {
-			log.warn("auto mode: answer incorrect, skipping...")
-			waitDur := time.Duration(30+rand.Intn(30)) * time.Second
-			log.infof("sleeping %s before continue...", waitDur.Round(time.Second))
-			time.Sleep(waitDur)
+			plog.warnf("auto mode: answer incorrect, skipping (remaining %d, ETA %s)...", sub.DailyRemaining, eta.describe(sub.DailyRemaining))
+			status.setPhase(phaseSleeping)
+			waitDur := randDelay(autoRetryDelayMin, autoRetryDelayMax)
+			plog.infof("sleeping %s before continue...", waitDur.Round(time.Second))
+			if err := pace(pctx, plog, waitDur); err != nil {
+				return onlineOutcome{solved: solvedCount}, err
+			}
 			count = solvedCount + 1
 			continue
 		}
-		return errors.New("submitted answer was incorrect")
+		return onlineOutcome{solved: solvedCount}, errors.New("submitted answer was incorrect")
 	}
 
 	if autoLoop {
 		log.okf("auto mode complete: daily limit exhausted, solved %d puzzles, elapsed %s", solvedCount, time.Since(startAll).Round(time.Second))
-		return nil
+		return onlineOutcome{solved: solvedCount, exhausted: true}, nil
 	}
 
 	log.okf("done: solved=%d/%d elapsed=%s", solvedCount, count, time.Since(startAll).Round(100*time.Millisecond))
-	return nil
+	return onlineOutcome{solved: solvedCount}, nil
 }
 
-// persistCookieIfChanged saves config if cookies have been updated.
-func persistCookieIfChanged(configPath string, cfg *appConfig, c *apiClient, log *logger) error {
+// persistCookieIfChanged saves the login state if cookies have been
+// updated; config.json itself is never rewritten.
+func persistCookieIfChanged(cfg *appConfig, c *apiClient, log *logger) error {
 	if cfg == nil || c == nil {
 		return nil
 	}
@@ -272,116 +1077,71 @@ func persistCookieIfChanged(configPath string, cfg *appConfig, c *apiClient, log
 	if newCookie == "" {
 		return nil
 	}
-	if strings.TrimSpace(cfg.Cookie) == newCookie {
+	if strings.TrimSpace(cfg.Cookie) == newCookie && c.userAgent == cfg.UserAgent {
 		return nil
 	}
 	cfg.Cookie = newCookie
-	if err := saveConfig(configPath, *cfg); err != nil {
+	cfg.UserAgent = c.userAgent
+	if err := saveLoginState(*cfg); err != nil {
 		return err
 	}
 	if log != nil {
-		log.ok("config.json updated (cookie refreshed)")
+		log.ok("login state saved (cookie refreshed)")
 	}
 	return nil
 }
 
-func puzzleNewWithRetry(ctx context.Context, client *apiClient, log *logger) (*puzzleNewResponse, error) {
-	backoff := 2 * time.Second
-	for {
-		pNew, err := client.puzzleNew(ctx)
-		if err == nil {
-			return pNew, nil
-		}
-		var ae *apiError
-		if errors.As(err, &ae) && ae.StatusCode == 429 {
-			log.warnf("rate limited (429), waiting %s...", backoff.Round(100*time.Millisecond))
-			time.Sleep(backoff)
-			if backoff < 30*time.Second {
-				backoff *= 2
-			}
-			continue
-		}
-		return nil, err
-	}
-}
-
-func submitWithRetry(ctx context.Context, client *apiClient, log *logger, puzzleID string, answer [][]int) (*puzzleSubmitResponse, error) {
-	backoff := 2 * time.Second
-	for {
-		sub, err := client.puzzleSubmit(ctx, puzzleID, answer)
-		if err == nil {
-			return sub, nil
-		}
-		var ae *apiError
-		if errors.As(err, &ae) && ae.StatusCode == 429 {
-			log.warnf("submit rate limited (429), waiting %s...", backoff.Round(100*time.Millisecond))
-			time.Sleep(backoff)
-			if backoff < 30*time.Second {
-				backoff *= 2
-			}
-			continue
-		}
-		return nil, err
-	}
-}
-
-func ensureLoginInteractive(ctx context.Context, cfg appConfig, configPath string, log *logger) (appConfig, error) {
+// ensureLoginInteractive verifies the stored cookie with authMe, prompting
+// for new auth material until it works; notify hears before the prompt. The
+// probing client and its authMe result are returned so callers need not
+// repeat the request.
+func ensureLoginInteractive(ctx context.Context, cfg appConfig, log *logger, notify *notifier) (appConfig, *apiClient, *authMeResponse, error) {
 	cfg.Cookie = strings.TrimSpace(cfg.Cookie)
-	if cfg.Cookie == "" {
-		in, err := promptAuthMaterial()
+	if cfg.Cookie != "" && cfg.BaseURL != "" {
+		client, err := newAPIClient(cfg)
 		if err != nil {
-			return appConfig{}, err
-		}
-		cfg.Cookie = in.Cookie
-		if in.UserAgent != "" {
-			cfg.UserAgent = in.UserAgent
+			return appConfig{}, nil, nil, err
 		}
-		if in.BaseURL != "" && cfg.BaseURL == "" {
-			cfg.BaseURL = in.BaseURL
+		me, err := client.authMe(ctx)
+		if err == nil {
+			return cfg, client, me, nil
 		}
-		if err := saveConfig(configPath, cfg); err != nil {
-			return appConfig{}, err
+		if !isAuthError(err) {
+			return appConfig{}, nil, nil, err
 		}
-		log.ok("config.json updated (cookie saved)")
 	}
-
-	client, err := newAPIClient(cfg)
-	if err != nil {
-		return appConfig{}, err
+	reason := "no cookie is set"
+	switch {
+	case cfg.BaseURL == "":
+		reason = "no base_url is set"
+	case cfg.Cookie != "":
+		reason = "the site rejected the saved cookie"
 	}
-	if _, err := client.authMe(ctx); err != nil {
-		if !isAuthError(err) {
-			return appConfig{}, err
-		}
+	notify.authRequired(ctx, log, reason)
 
-		in, perr := promptAuthMaterial()
-		if perr != nil {
-			return appConfig{}, perr
-		}
-		cfg.Cookie = in.Cookie
-		if in.UserAgent != "" {
-			cfg.UserAgent = in.UserAgent
-		}
-		if in.BaseURL != "" && cfg.BaseURL == "" {
-			cfg.BaseURL = in.BaseURL
-		}
-		if err := saveConfig(configPath, cfg); err != nil {
-			return appConfig{}, err
-		}
-		log.ok("config.json updated (cookie saved)")
-
-		client, err = newAPIClient(cfg)
+	// A pasted cookie is only saved once the site accepts it; a rejected
+	// one gets a second chance.
+	for attempt := 1; ; attempt++ {
+		in, err := promptAuthMaterial()
 		if err != nil {
-			return appConfig{}, err
+			return appConfig{}, nil, nil, err
 		}
-		if _, err := client.authMe(ctx); err != nil {
-			if isAuthError(err) {
-				return appConfig{}, errors.New("login still invalid: please check cookie/token")
+		next, client, me, err := previewAuth(ctx, cfg, in, os.Stdout)
+		if err != nil {
+			if !isAuthError(err) {
+				return appConfig{}, nil, nil, err
+			}
+			if attempt >= authPromptAttempts {
+				return appConfig{}, nil, nil, errors.New("login still invalid: please check cookie/token")
 			}
-			return appConfig{}, err
+			continue
+		}
+		if err := saveLoginState(next); err != nil {
+			return appConfig{}, nil, nil, err
 		}
+		log.ok("login state saved (cookie saved)")
+		return next, client, me, nil
 	}
-	return cfg, nil
 }
 
 // authMaterial holds parsed authentication data from user input.
@@ -391,34 +1151,6 @@ type authMaterial struct {
 	BaseURL   string
 }
 
-func promptAuthMaterial() (authMaterial, error) {
-	_, _ = fmt.Fprintln(os.Stdout, "Enter token/cookie (paste cookie / `Cookie: ...` / curl command, end with empty line):")
-	_, _ = fmt.Fprint(os.Stdout, "> ")
-
-	var lines []string
-	sc := bufio.NewScanner(os.Stdin)
-	for sc.Scan() {
-		line := sc.Text()
-		if strings.TrimSpace(line) == "" {
-			break
-		}
-		lines = append(lines, line)
-	}
-	if err := sc.Err(); err != nil && !errors.Is(err, io.EOF) {
-		return authMaterial{}, err
-	}
-	text := strings.TrimSpace(strings.Join(lines, "\n"))
-	if text == "" {
-		return authMaterial{}, errors.New("empty input")
-	}
-
-	out := parseAuthMaterial(text)
-	if out.Cookie == "" {
-		return authMaterial{}, errors.New("cookie not found: paste `-b '...'` content or `Cookie: ...`")
-	}
-	return out, nil
-}
-
 // Regex patterns for parsing curl commands and headers.
 var (
 	reCurlCookieBQuoted   = regexp.MustCompile(`(?s)(?:^|\s)-b\s+(?:'([^']*)'|"([^"]*)")`)
@@ -480,6 +1212,10 @@ func urlToBase(raw string) (string, error) {
 }
 
 func isAuthError(err error) bool {
+	var he *htmlPageError
+	if errors.As(err, &he) && he.Kind == htmlLogin {
+		return true
+	}
 	var ae *apiError
 	return errors.As(err, &ae) && (ae.StatusCode == 401 || ae.StatusCode == 403)
 }
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errManualAborted is returned when the human quits the grid editor.
+var errManualAborted = errors.New("manual solve aborted")
+
+const gridEditorHelp = `Commands (rows and columns are 1-based):
+  show                 print the current answer grid
+  copy                 start over from the test input
+  size H W             resize, padding with the background color
+  fill C               set every cell to color C
+  r ROW DIGITS         replace a row, e.g. "r 2 0 0 3 3" or "r 2 0033"
+  c ROW COL C          set a single cell
+  paste                enter every row in turn, one line each
+  done                 submit the current grid
+  quit                 abort without submitting`
+
+// newManualSolver returns a solve function that shows each puzzle and lets a
+// human build the answer in a line-based grid editor.
+func newManualSolver(in io.Reader, out io.Writer) func(context.Context, puzzle) (solveResult, error) {
+	r := bufio.NewReader(in)
+	return func(ctx context.Context, p puzzle) (solveResult, error) {
+		printPuzzle(out, p, colorEnabled(os.Stdout))
+		_, _ = fmt.Fprintln(out)
+		grid, err := editGrid(ctx, r, out, p, colorEnabled(os.Stdout))
+		return solveResult{Answer: grid, Model: "manual"}, err
+	}
+}
+
+// initialAnswerGrid returns the editor's starting grid: the hinted size
+// filled with the background color, or a copy of the test input.
+func initialAnswerGrid(p puzzle) [][]int {
+	h, w := p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width
+	if h > 0 && w > 0 {
+		return filledGrid(h, w, p.Hints.BackgroundColor)
+	}
+	return cloneGrid(p.TestInput)
+}
+
+func filledGrid(h, w, color int) [][]int {
+	g := make([][]int, h)
+	for i := range g {
+		g[i] = make([]int, w)
+		for j := range g[i] {
+			g[i][j] = color
+		}
+	}
+	return g
+}
+
+func cloneGrid(g [][]int) [][]int {
+	out := make([][]int, len(g))
+	for i, row := range g {
+		out[i] = append([]int(nil), row...)
+	}
+	return out
+}
+
+// parseGridRow accepts "0 1 2", "0,1,2" or "012".
+func parseGridRow(s string) ([]int, error) {
+	s = strings.TrimSpace(s)
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' })
+	if len(fields) == 1 && len(fields[0]) > 1 {
+		fields = strings.Split(fields[0], "")
+	}
+	row := make([]int, 0, len(fields))
+	for _, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil || v < 0 || v > 9 {
+			return nil, fmt.Errorf("invalid color %q", f)
+		}
+		row = append(row, v)
+	}
+	if len(row) == 0 {
+		return nil, errors.New("empty row")
+	}
+	return row, nil
+}
+
+// editGrid runs the line-based editor until the user types done or quit.
+func editGrid(ctx context.Context, r *bufio.Reader, out io.Writer, p puzzle, color bool) ([][]int, error) {
+	grid := initialAnswerGrid(p)
+	show := func() {
+		_, _ = fmt.Fprintf(out, "Answer %s\n", gridDims(grid))
+		for _, line := range renderGridLines(grid, color) {
+			_, _ = fmt.Fprintln(out, line)
+		}
+	}
+	// readLine returns early when ctx is cancelled; the pending read is
+	// abandoned since the run is ending.
+	readLine := func(prompt string) (string, error) {
+		_, _ = fmt.Fprint(out, prompt)
+		type read struct {
+			line string
+			err  error
+		}
+		ch := make(chan read, 1)
+		go func() {
+			line, err := r.ReadString('\n')
+			ch <- read{line, err}
+		}()
+		var res read
+		select {
+		case <-ctx.Done():
+			_, _ = fmt.Fprintln(out)
+			return "", ctx.Err()
+		case res = <-ch:
+		}
+		if res.err != nil && (res.line == "" || !errors.Is(res.err, io.EOF)) {
+			return "", res.err
+		}
+		return strings.TrimSpace(res.line), nil
+	}
+	atoi := func(s string, lo, hi int) (int, error) {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < lo || v > hi {
+			return 0, fmt.Errorf("%q is not in %d..%d", s, lo, hi)
+		}
+		return v, nil
+	}
+
+	_, _ = fmt.Fprintln(out, gridEditorHelp)
+	show()
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		line, err := readLine("edit> ")
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errManualAborted
+			}
+			return nil, err
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+
+		var cmdErr error
+		switch f[0] {
+		case "help", "?":
+			_, _ = fmt.Fprintln(out, gridEditorHelp)
+		case "show":
+			show()
+		case "copy":
+			grid = cloneGrid(p.TestInput)
+			show()
+		case "size":
+			if len(f) != 3 {
+				cmdErr = errors.New("usage: size H W")
+				break
+			}
+			h, err1 := atoi(f[1], 1, 30)
+			w, err2 := atoi(f[2], 1, 30)
+			if cmdErr = errors.Join(err1, err2); cmdErr != nil {
+				break
+			}
+			next := filledGrid(h, w, p.Hints.BackgroundColor)
+			for i := 0; i < h && i < len(grid); i++ {
+				copy(next[i], grid[i])
+			}
+			grid = next
+			show()
+		case "fill":
+			if len(f) != 2 {
+				cmdErr = errors.New("usage: fill C")
+				break
+			}
+			c, err := atoi(f[1], 0, 9)
+			if cmdErr = err; cmdErr != nil {
+				break
+			}
+			grid = filledGrid(len(grid), gridWidth(grid), c)
+			show()
+		case "r":
+			if len(f) < 3 {
+				cmdErr = errors.New("usage: r ROW DIGITS")
+				break
+			}
+			i, err := atoi(f[1], 1, len(grid))
+			if cmdErr = err; cmdErr != nil {
+				break
+			}
+			row, err := parseGridRow(strings.Join(f[2:], " "))
+			if cmdErr = err; cmdErr != nil {
+				break
+			}
+			if len(row) != gridWidth(grid) {
+				cmdErr = fmt.Errorf("row has %d cells, grid is %d wide (use size to change)", len(row), gridWidth(grid))
+				break
+			}
+			grid[i-1] = row
+			show()
+		case "c":
+			if len(f) != 4 {
+				cmdErr = errors.New("usage: c ROW COL C")
+				break
+			}
+			i, err1 := atoi(f[1], 1, len(grid))
+			j, err2 := atoi(f[2], 1, gridWidth(grid))
+			c, err3 := atoi(f[3], 0, 9)
+			if cmdErr = errors.Join(err1, err2, err3); cmdErr != nil {
+				break
+			}
+			grid[i-1][j-1] = c
+			show()
+		case "paste":
+			for i := range grid {
+				line, err := readLine(fmt.Sprintf("row %d/%d> ", i+1, len(grid)))
+				if err != nil {
+					return nil, err
+				}
+				row, err := parseGridRow(line)
+				if err == nil && len(row) != gridWidth(grid) {
+					err = fmt.Errorf("row has %d cells, want %d", len(row), gridWidth(grid))
+				}
+				if err != nil {
+					cmdErr = fmt.Errorf("row %d: %w (paste stopped)", i+1, err)
+					break
+				}
+				grid[i] = row
+			}
+			show()
+		case "done":
+			if err := checkGridShape(grid); err != nil {
+				cmdErr = err
+				break
+			}
+			return grid, nil
+		case "quit", "exit":
+			return nil, errManualAborted
+		default:
+			cmdErr = fmt.Errorf("unknown command %q (type help)", f[0])
+		}
+		if cmdErr != nil {
+			_, _ = fmt.Fprintf(out, "error: %v\n", cmdErr)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Notification event names.
+const (
+	eventPuzzleCorrect   = "puzzle_correct"
+	eventPuzzleIncorrect = "puzzle_incorrect"
+	eventPuzzleFailed    = "puzzle_failed"
+	eventPuzzleDryRun    = "puzzle_dry_run"
+	// eventAuthRequired reports a login that needs a fresh cookie; it is
+	// never held back by the throttle.
+	eventAuthRequired = "auth_required"
+	// eventRunFinished summarises an auto-mode run or scheduled pass.
+	eventRunFinished = "run_finished"
+	// eventDigest bundles the events the throttle held back.
+	eventDigest = "digest"
+)
+
+// notifyTimeout bounds one webhook delivery so a slow endpoint never stalls
+// the solve loop for long.
+const notifyTimeout = 10 * time.Second
+
+// notifyEvent is the JSON body posted for one puzzle outcome, a login
+// problem, or a digest of outcomes.
+type notifyEvent struct {
+	Event      string           `json:"event"`
+	Time       time.Time        `json:"time"`
+	PuzzleID   string           `json:"puzzleId"`
+	Model      string           `json:"model,omitempty"`
+	Confidence int              `json:"confidence"`
+	Correct    *bool            `json:"correct"`
+	Points     int              `json:"points"`
+	ElapsedMS  int64            `json:"elapsedMs"`
+	Error      string           `json:"error,omitempty"`
+	Text       string           `json:"text"`
+	Artifacts  *puzzleArtifacts `json:"artifacts,omitempty"`
+	// Events are the bundled events of a digest.
+	Events []notifyEvent `json:"events,omitempty"`
+}
+
+// notifier delivers notifyEvents to the configured webhook and Telegram
+// chat. A nil *notifier (nothing configured) drops every event. Its
+// throttles count across the runs it serves, so solve --auto and daemon
+// keep one for the whole process.
+type notifier struct {
+	cfg    notifyConfig
+	home   string
+	client *http.Client
+	// webhook applies notifications.throttle to the webhook, telegram
+	// notifications.telegram.throttle to the chat; nil when unset.
+	webhook  *dispatcher
+	telegram *dispatcher
+}
+
+func newNotifier(cfg appConfig, log *logger) *notifier {
+	hook := strings.TrimSpace(cfg.Notifications.WebhookURL) != ""
+	tg := cfg.Notifications.Telegram.configured()
+	if !hook && !tg {
+		return nil
+	}
+	client := &http.Client{Timeout: notifyTimeout}
+	// An invalid proxy already fails the API client; keep the default here.
+	if tr, err := proxiedTransport(cfg.Proxy); err == nil {
+		client.Transport = tr
+	}
+	n := &notifier{
+		cfg:    cfg.Notifications,
+		home:   cfg.home,
+		client: client,
+	}
+	if hook {
+		n.webhook = newDispatcher(cfg.Notifications.Throttle, log, n.post)
+	}
+	if tg {
+		n.telegram = newDispatcher(cfg.Notifications.Telegram.Throttle, log, n.postTelegram)
+	}
+	return n
+}
+
+// close sends the events the throttles still hold; call it once, when the
+// process is done with the notifier.
+func (n *notifier) close(ctx context.Context) {
+	if n == nil {
+		return
+	}
+	n.webhook.close(ctx)
+	n.telegram.close(ctx)
+}
+
+// authRequired reports that the login needs a fresh cookie, which an
+// unattended run cannot supply itself.
+func (n *notifier) authRequired(ctx context.Context, log *logger, reason string) {
+	if n == nil {
+		return
+	}
+	ev := notifyEvent{
+		Event: eventAuthRequired,
+		Time:  time.Now(),
+		Text:  fmt.Sprintf("🔑 login required: %s; run ergo-solver login or paste a fresh cookie at the prompt", reason),
+	}
+	n.webhook.dispatch(ctx, log, ev)
+	n.telegram.dispatch(ctx, log, ev)
+}
+
+// runSummary is what a finished auto-mode run or scheduled pass did.
+type runSummary struct {
+	Solved    int
+	Submitted int
+	Correct   int
+	Points    int
+	Failed    int
+	Elapsed   time.Duration
+	// Reason is why the run ended.
+	Reason string
+}
+
+// finishReason says why a run with the given result ended.
+func finishReason(ctx context.Context, out onlineOutcome, err error) string {
+	switch {
+	case ctx.Err() != nil:
+		return "stopped"
+	case err != nil:
+		return "failed: " + err.Error()
+	case out.exhausted:
+		return "daily limit used up"
+	case out.overBudget:
+		return "AI budget reached"
+	}
+	return "run complete"
+}
+
+// runFinished sends the summary of an auto-mode run or scheduled pass.
+func (n *notifier) runFinished(ctx context.Context, log *logger, s runSummary) {
+	if n == nil {
+		return
+	}
+	ev := notifyEvent{
+		Event:     eventRunFinished,
+		Time:      time.Now(),
+		Points:    s.Points,
+		ElapsedMS: s.Elapsed.Milliseconds(),
+		Text: fmt.Sprintf("🏁 auto run ended (%s) after %s: solved %d, %d/%d submitted correct, +%d points, %d failed",
+			s.Reason, s.Elapsed.Round(time.Second), s.Solved, s.Correct, s.Submitted, s.Points, s.Failed),
+	}
+	n.webhook.dispatch(ctx, log, ev)
+	n.telegram.dispatch(ctx, log, ev)
+}
+
+// puzzleOutcome notifies about one attempt at p, writing its artifacts
+// under runDir. Delivery is best effort: failures are logged and never
+// abort the run.
+func (n *notifier) puzzleOutcome(ctx context.Context, log *logger, runDir string, p puzzle, a attempt) {
+	if n == nil || n.webhook == nil {
+		return
+	}
+	ev := notifyEvent{
+		Time:       time.Now(),
+		PuzzleID:   a.PuzzleID,
+		Model:      a.Result.Model,
+		Confidence: a.Result.Confidence,
+		Correct:    a.Correct,
+		Points:     a.Points,
+		ElapsedMS:  a.SolveTime.Milliseconds(),
+	}
+	switch {
+	case a.Err != nil:
+		ev.Event = eventPuzzleFailed
+		ev.Error = a.Err.Error()
+		ev.Text = fmt.Sprintf("❌ %s failed: %s", a.PuzzleID, ev.Error)
+	case a.DryRun:
+		ev.Event = eventPuzzleDryRun
+		ev.Text = fmt.Sprintf("📝 %s solved (dry run, %s, confidence %d%%)", a.PuzzleID, a.Result.Model, a.Result.Confidence)
+	case a.Correct != nil && *a.Correct:
+		ev.Event = eventPuzzleCorrect
+		ev.Text = fmt.Sprintf("✅ %s correct: +%d points (%s, confidence %d%%)", a.PuzzleID, a.Points, a.Result.Model, a.Result.Confidence)
+	default:
+		ev.Event = eventPuzzleIncorrect
+		ev.Text = fmt.Sprintf("⚠️ %s incorrect (%s, confidence %d%%)", a.PuzzleID, a.Result.Model, a.Result.Confidence)
+	}
+
+	if n.cfg.Artifacts {
+		art, err := writePuzzleArtifacts(runDir, p, a.Result)
+		if err != nil {
+			log.warnf("write artifacts: %v", err)
+		} else {
+			art.Image, art.Answer, art.Reasoning = n.link(art.Image), n.link(art.Answer), n.link(art.Reasoning)
+			ev.Artifacts = &art
+		}
+	}
+
+	n.webhook.dispatch(ctx, log, ev)
+}
+
+// link turns an artifact path into a URL under ArtifactBaseURL, if set.
+func (n *notifier) link(path string) string {
+	base := strings.TrimSpace(n.cfg.ArtifactBaseURL)
+	if path == "" || base == "" {
+		return path
+	}
+	rel, err := filepath.Rel(n.home, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	u, err := url.JoinPath(base, strings.Split(filepath.ToSlash(rel), "/")...)
+	if err != nil {
+		return path
+	}
+	return u
+}
+
+func (n *notifier) post(ctx context.Context, ev notifyEvent) error {
+	var body any = ev
+	if n.cfg.Format == "discord" {
+		body = map[string]string{"content": discordContent(ev)}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.WebhookURL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// discordContent renders ev as a Discord message, with artifact links on
+// their own lines so Discord previews them.
+func discordContent(ev notifyEvent) string {
+	var b strings.Builder
+	b.WriteString(ev.Text)
+	if a := ev.Artifacts; a != nil {
+		for _, l := range []struct{ name, ref string }{{"image", a.Image}, {"answer", a.Answer}, {"reasoning", a.Reasoning}} {
+			if l.ref != "" {
+				fmt.Fprintf(&b, "\n%s: %s", l.name, l.ref)
+			}
+		}
+	}
+	return b.String()
+}
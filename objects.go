@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// objectSummaryLimit caps the objects listed per grid so noisy grids do not
+// flood the prompt; the count still covers all of them.
+const objectSummaryLimit = 12
+
+// gridObject is a 4-connected region of one non-background color, with its
+// bounding box given by inclusive row and column bounds.
+type gridObject struct {
+	color, size              int
+	top, left, bottom, right int
+}
+
+// findObjects lists the objects of g in reading order of their first cell.
+func findObjects(g [][]int, bg int) []gridObject {
+	seen := map[[2]int]bool{}
+	var objs []gridObject
+	for r, row := range g {
+		for c, v := range row {
+			if v == bg || seen[[2]int{r, c}] {
+				continue
+			}
+			o := gridObject{color: v, top: r, left: c, bottom: r, right: c}
+			for _, cell := range component(g, r, c) {
+				seen[cell] = true
+				o.size++
+				o.top, o.left = min(o.top, cell[0]), min(o.left, cell[1])
+				o.bottom, o.right = max(o.bottom, cell[0]), max(o.right, cell[1])
+			}
+			objs = append(objs, o)
+		}
+	}
+	return objs
+}
+
+// objectSummary describes the objects of every grid of p for the prompt:
+// how many there are and each one's color, size and bounding box.
+func objectSummary(p puzzle) string {
+	bg := p.Hints.BackgroundColor
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Objects (4-connected single-color regions, background %d; box = rows top-bottom, cols left-right, 0-based):\n", bg)
+	for i, ex := range p.Train {
+		writeObjects(&b, fmt.Sprintf("Training pair %d input", i+1), ex.Input, bg)
+		writeObjects(&b, fmt.Sprintf("Training pair %d output", i+1), ex.Output, bg)
+	}
+	writeObjects(&b, "Test input", p.TestInput, bg)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writeObjects(b *strings.Builder, label string, g [][]int, bg int) {
+	objs := findObjects(g, bg)
+	fmt.Fprintf(b, "%s: %d object(s)", label, len(objs))
+	for i, o := range objs {
+		if i == objectSummaryLimit {
+			fmt.Fprintf(b, "; … %d more", len(objs)-i)
+			break
+		}
+		sep := "; "
+		if i == 0 {
+			sep = ": "
+		}
+		fmt.Fprintf(b, "%scolor %d size %d box r%d-%d c%d-%d", sep, o.color, o.size, o.top, o.bottom, o.left, o.right)
+	}
+	b.WriteByte('\n')
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// arcPair is an input/output pair in the public ARC dataset format.
+type arcPair struct {
+	Input  [][]int `json:"input"`
+	Output [][]int `json:"output,omitempty"`
+}
+
+// arcTaskFile is a task file in the public ARC dataset format.
+type arcTaskFile struct {
+	Train []arcPair `json:"train"`
+	Test  []arcPair `json:"test"`
+}
+
+// localTask is one test case of a local task file. Expected is nil when the
+// file carries no test output.
+type localTask struct {
+	Puzzle   puzzle
+	Expected [][]int
+}
+
+// loadLocalTasks reads an ARC dataset task (one localTask per test input) or
+// a puzzle saved by this tool.
+func loadLocalTasks(path string) ([]localTask, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read task: %w", err)
+	}
+	var arc arcTaskFile
+	if err := json.Unmarshal(b, &arc); err == nil && len(arc.Test) > 0 {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		train := make([]puzzleExample, len(arc.Train))
+		for i, tp := range arc.Train {
+			train[i] = puzzleExample{Input: tp.Input, Output: tp.Output}
+		}
+		tasks := make([]localTask, 0, len(arc.Test))
+		for i, tp := range arc.Test {
+			p := puzzle{ID: name, Train: train, TestInput: tp.Input}
+			if len(arc.Test) > 1 {
+				p.ID = fmt.Sprintf("%s#%d", name, i+1)
+			}
+			if len(tp.Output) > 0 {
+				// Mirror the hints the live server provides.
+				p.Hints.AnswerSize.Height = len(tp.Output)
+				p.Hints.AnswerSize.Width = gridWidth(tp.Output)
+				p.Hints.BackgroundColor = dominantColor(tp.Output)
+			}
+			tasks = append(tasks, localTask{Puzzle: p, Expected: tp.Output})
+		}
+		return tasks, nil
+	}
+
+	p, err := loadPuzzleFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return []localTask{{Puzzle: p}}, nil
+}
+
+// dominantColor returns the most frequent value in grid (lowest on ties).
+func dominantColor(grid [][]int) int {
+	var counts [10]int
+	for _, row := range grid {
+		for _, v := range row {
+			if v >= 0 && v <= 9 {
+				counts[v]++
+			}
+		}
+	}
+	best := 0
+	for v := 1; v < 10; v++ {
+		if counts[v] > counts[best] {
+			best = v
+		}
+	}
+	return best
+}
+
+// holdoutPuzzle turns p into a scoring task: the last training pair is
+// withheld from the prompt and its input becomes the test input, with hints
+// derived from its output the way the live server derives them. ok is false
+// when fewer than two training pairs exist.
+func holdoutPuzzle(p puzzle) (held puzzle, expected [][]int, ok bool) {
+	n := len(p.Train)
+	if n < 2 {
+		return puzzle{}, nil, false
+	}
+	last := p.Train[n-1]
+	held = puzzle{
+		ID:        p.ID,
+		Train:     p.Train[:n-1],
+		TestInput: last.Input,
+	}
+	held.Hints.AnswerSize.Height = len(last.Output)
+	held.Hints.AnswerSize.Width = gridWidth(last.Output)
+	held.Hints.BackgroundColor = dominantColor(last.Output)
+	return held, last.Output, true
+}
+
+// gridsEqual reports whether a and b have identical shape and cells.
+func gridsEqual(a, b [][]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// localTaskFiles expands --file and --dir into a sorted list of JSON files.
+func localTaskFiles(file, dir string) ([]string, error) {
+	var files []string
+	if file != "" {
+		files = append(files, file)
+	}
+	if dir != "" {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no task files found")
+	}
+	return files, nil
+}
+
+// localAnswer is written for every locally solved task.
+type localAnswer struct {
+	ID      string  `json:"id"`
+	Answer  [][]int `json:"answer,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	Correct *bool   `json:"correct,omitempty"`
+	Elapsed string  `json:"elapsed"`
+}
+
+// runSolveOffline solves local task files with the AI solver and writes one
+// answer file per test case to outDir. No API client, cookie or PoW is used.
+// With holdout set, each task is replaced by its holdoutPuzzle and scored
+// against the withheld training output instead. Each answer is also passed to
+// records for --output json.
+func runSolveOffline(ctx context.Context, log *logger, cfg appConfig, files []string, outDir string, holdout bool, records *recordWriter) error {
+	solver, err := newAISolver(ctx, cfg, log)
+	if err != nil {
+		return err
+	}
+	if solver == nil {
+		return errors.New("AI solver not configured")
+	}
+	meter := newUsageMeter(cfg.AI.Prices)
+	solver.usage = meter
+	solver.latency = newLatencyTracker()
+	defer solver.latency.logSummary(log)
+	defer meter.logSummary(log)
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return fmt.Errorf("mkdir output dir: %w", err)
+	}
+
+	var solved, scored, correct, failed int
+	startAll := time.Now()
+	for _, f := range files {
+		tasks, err := loadLocalTasks(f)
+		if err != nil {
+			log.warnf("skipping %s: %v", f, err)
+			continue
+		}
+		if holdout && len(tasks) > 0 {
+			// Every test case of a file shares the training pairs, so one
+			// holdout task per file is enough.
+			hp, exp, ok := holdoutPuzzle(tasks[0].Puzzle)
+			if !ok {
+				log.warnf("skipping %s: holdout needs at least 2 training pairs", f)
+				continue
+			}
+			tasks = []localTask{{Puzzle: hp, Expected: exp}}
+		}
+		for _, t := range tasks {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			plog := log.with("puzzle", t.Puzzle.ID)
+			plog.infof("solving local task: %s", f)
+
+			start := time.Now()
+			spentBefore := meter.spent()
+			sr, err := solver.Solve(withTraceID(ctx, newTraceID()), t.Puzzle)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			grid := sr.Answer
+			res := localAnswer{ID: t.Puzzle.ID, Answer: grid, Elapsed: time.Since(start).Round(10 * time.Millisecond).String()}
+			if err != nil {
+				if errors.Is(err, ErrAIUnavailable) {
+					return fmt.Errorf("AI unavailable: %w", err)
+				}
+				plog.warnf("solve failed: %v", err)
+				res.Error = err.Error()
+				failed++
+			} else {
+				solved++
+			}
+			if t.Expected != nil {
+				ok := err == nil && gridsEqual(grid, t.Expected)
+				res.Correct = &ok
+				scored++
+				if ok {
+					correct++
+					plog.ok("correct")
+				} else if err == nil {
+					plog.warn("incorrect")
+				}
+			}
+
+			out := attempt{PuzzleID: t.Puzzle.ID, StartedAt: start, Result: sr, SolveTime: time.Since(start), Correct: res.Correct, Err: err, Spend: meter.spent().minus(spentBefore)}
+			if werr := records.write(out); werr != nil {
+				plog.warnf("write output: %v", werr)
+			}
+
+			name := strings.NewReplacer("#", "-", string(filepath.Separator), "_").Replace(t.Puzzle.ID)
+			b, _ := json.MarshalIndent(res, "", "  ")
+			if err := os.WriteFile(filepath.Join(outDir, name+".answer.json"), append(b, '\n'), 0o644); err != nil {
+				return fmt.Errorf("write answer: %w", err)
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("offline done: solved=%d failed=%d elapsed=%s output=%s", solved, failed, time.Since(startAll).Round(time.Second), outDir)
+	if scored > 0 {
+		summary += fmt.Sprintf(" accuracy=%d/%d (%.1f%%)", correct, scored, 100*float64(correct)/float64(scored))
+	}
+	log.ok(summary)
+	return nil
+}
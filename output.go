@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Solve output formats.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// uiOut receives decorative solver output: banners, spinners, reasoning and
+// vote tallies. JSON output discards it so stdout carries only results.
+var uiOut io.Writer = os.Stdout
+
+// solveRecord is the line printed per puzzle with --output json.
+type solveRecord struct {
+	PuzzleID   string  `json:"puzzleId"`
+	Answer     [][]int `json:"answer"`
+	Model      string  `json:"model,omitempty"`
+	Confidence int     `json:"confidence"`
+	Verified   *bool   `json:"verified"`
+	Submitted  bool    `json:"submitted"`
+	Correct    *bool   `json:"correct"`
+	Points     int     `json:"points"`
+	ElapsedMS  int64   `json:"elapsedMs"`
+	Error      string  `json:"error,omitempty"`
+}
+
+func newSolveRecord(a attempt) solveRecord {
+	r := solveRecord{
+		PuzzleID:   a.PuzzleID,
+		Answer:     a.Result.Answer,
+		Model:      a.Result.Model,
+		Confidence: a.Result.Confidence,
+		Verified:   a.Result.Verified,
+		Submitted:  a.Submitted,
+		Correct:    a.Correct,
+		Points:     a.Points,
+		ElapsedMS:  a.SolveTime.Milliseconds(),
+	}
+	if a.Err != nil {
+		r.Error = a.Err.Error()
+	}
+	return r
+}
+
+// recordWriter prints one JSON object per line. A nil *recordWriter (text
+// output) prints nothing.
+type recordWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// newRecordWriter validates format and returns the writer for it, switching
+// decorative output off for JSON.
+func newRecordWriter(format string, w io.Writer) (*recordWriter, error) {
+	switch format {
+	case outputText:
+		return nil, nil
+	case outputJSON:
+		uiOut = io.Discard
+		return &recordWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown --output %q (want %s or %s)", format, outputText, outputJSON)
+	}
+}
+
+func (w *recordWriter) write(a attempt) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(newSolveRecord(a))
+}
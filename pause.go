@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pauseFileName is the sentinel file that pauses the solve loop while present.
+const pauseFileName = "pause"
+
+// pausePollInterval is how often the paused loop checks whether to resume.
+const pausePollInterval = 2 * time.Second
+
+// pauseController decides whether the solve loop should hold before fetching
+// the next puzzle. The loop is paused while the sentinel file exists or after
+// an odd number of SIGUSR1 signals (where supported).
+type pauseController struct {
+	mu       sync.Mutex
+	toggled  bool
+	sentinel string
+	sigCh    chan os.Signal
+}
+
+// newPauseController creates a controller watching home/pause and SIGUSR1.
+func newPauseController(home string) *pauseController {
+	p := &pauseController{sentinel: filepath.Join(home, pauseFileName)}
+	p.sigCh = make(chan os.Signal, 1)
+	if notifyPauseToggle(p.sigCh) {
+		go func() {
+			for range p.sigCh {
+				p.mu.Lock()
+				p.toggled = !p.toggled
+				p.mu.Unlock()
+			}
+		}()
+	}
+	return p
+}
+
+// stop releases the signal subscription and ends the goroutine counting
+// the signals. The controller lives as long as the process: stopping it
+// between the passes of a daemon would let a later SIGUSR1 kill it.
+func (p *pauseController) stop() {
+	if p == nil {
+		return
+	}
+	stopPauseToggle(p.sigCh)
+	close(p.sigCh)
+}
+
+// paused reports whether the loop should currently hold.
+func (p *pauseController) paused() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	toggled := p.toggled
+	p.mu.Unlock()
+	if toggled {
+		return true
+	}
+	_, err := os.Stat(p.sentinel)
+	return err == nil
+}
+
+// wait blocks while paused, returning early if ctx is cancelled.
+func (p *pauseController) wait(ctx context.Context, log *logger) error {
+	if !p.paused() {
+		return nil
+	}
+	log.warnf("paused: remove %s or send SIGUSR1 to resume", p.sentinel)
+	start := time.Now()
+	t := time.NewTicker(pausePollInterval)
+	defer t.Stop()
+	for p.paused() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	log.infof("resumed after %s", time.Since(start).Round(time.Second))
+	return nil
+}
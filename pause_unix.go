@@ -0,0 +1,20 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyPauseToggle subscribes ch to SIGUSR1.
+func notifyPauseToggle(ch chan os.Signal) bool {
+	signal.Notify(ch, syscall.SIGUSR1)
+	return true
+}
+
+// stopPauseToggle unsubscribes ch from SIGUSR1.
+func stopPauseToggle(ch chan os.Signal) {
+	signal.Stop(ch)
+}
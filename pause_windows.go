@@ -0,0 +1,12 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// notifyPauseToggle is a no-op on Windows, which has no SIGUSR1; only the
+// sentinel file is honored there.
+func notifyPauseToggle(ch chan os.Signal) bool { return false }
+
+// stopPauseToggle is a no-op on Windows.
+func stopPauseToggle(ch chan os.Signal) {}
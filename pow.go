@@ -3,8 +3,14 @@ package main
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"math"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,32 +23,65 @@ func ensurePow(ctx context.Context, c *apiClient, log *logger) error {
 	if err != nil {
 		return err
 	}
-
-	now := time.Now()
-	exp := time.UnixMilli(st.PowExpiresAt)
-
-	need := !st.HasValidPow
-	if st.HasValidPow && st.PowExpiresAt > 0 && exp.Sub(now) < powRefreshWindow {
-		need = true
-	}
-
-	if !need {
+	if !powNeedsRefresh(st) {
 		log.ok("PoW valid, no refresh needed")
 		return nil
 	}
+	return solvePow(ctx, c, log)
+}
+
+// powNeedsRefresh reports whether the PoW token is missing or about to expire.
+func powNeedsRefresh(st *powStatusResponse) bool {
+	if !st.HasValidPow {
+		return true
+	}
+	return st.PowExpiresAt > 0 && time.Until(time.UnixMilli(st.PowExpiresAt)) < powRefreshWindow
+}
 
+// powAttempts bounds how many challenges solvePow works through when one
+// expires or its nonce is rejected.
+const powAttempts = 3
+
+// errPowExpired reports a challenge that was about to expire before a nonce
+// was found.
+var errPowExpired = errors.New("PoW challenge expiring before a nonce was found")
+
+// powExpiryMargin is how long before a challenge expires the nonce search
+// gives up, so a found nonce can still be verified in time.
+const powExpiryMargin = 5 * time.Second
+
+// solvePow requests a challenge, finds a nonce and verifies it. A challenge
+// that expires or whose nonce the server rejects is replaced by a new one,
+// up to powAttempts in total.
+func solvePow(ctx context.Context, c *apiClient, log *logger) error {
 	log.info("PoW needs refresh, solving...")
+	for attempt := 1; ; attempt++ {
+		err := solvePowChallenge(ctx, c, log)
+		if err == nil || attempt >= powAttempts || ctx.Err() != nil || !isPowRecoverable(err) {
+			return err
+		}
+		log.warnf("PoW failed: %v; requesting a new challenge (attempt %d/%d)", err, attempt+1, powAttempts)
+	}
+}
+
+func solvePowChallenge(ctx context.Context, c *apiClient, log *logger) error {
 	chal, err := c.powChallenge(ctx)
 	if err != nil {
 		return err
 	}
 
+	var expires time.Time
+	if chal.ExpiresAt > 0 {
+		expires = time.UnixMilli(chal.ExpiresAt)
+	}
+	checkPowEstimate(chal.Difficulty, c.pow, expires, log)
 	start := time.Now()
-	nonce, err := computePowNonce(ctx, chal.Challenge, chal.Difficulty, log)
+	nonce, err := computePowNonce(ctx, chal.Challenge, chal.Difficulty, c.pow.Workers, expires, log)
 	if err != nil {
 		return err
 	}
 	elapsed := time.Since(start)
+	c.latency.observe("pow nonce search", elapsed)
 
 	log.okf("PoW found nonce=%s (elapsed %s)", nonce, elapsed.Round(10*time.Millisecond))
 
@@ -53,45 +92,225 @@ func ensurePow(ctx context.Context, c *apiClient, log *logger) error {
 	return nil
 }
 
+// Defaults for the PoW time estimate.
+const (
+	powBenchDuration   = 250 * time.Millisecond
+	defaultPowWarnTime = 60 * time.Second
+)
+
+// powRates caches the benchmarked hash rate per worker count, so the
+// benchmark runs once per process rather than for every challenge.
+var powRates struct {
+	sync.Mutex
+	byWorkers map[int]float64
+}
+
+// cachedPowHashRate returns the hash rate of workers, benchmarking it on
+// first use.
+func cachedPowHashRate(workers int) float64 {
+	powRates.Lock()
+	defer powRates.Unlock()
+	if rate, ok := powRates.byWorkers[workers]; ok {
+		return rate
+	}
+	rate := powHashRate(workers, powBenchDuration)
+	if powRates.byWorkers == nil {
+		powRates.byWorkers = map[int]float64{}
+	}
+	powRates.byWorkers[workers] = rate
+	return rate
+}
+
+// checkPowEstimate logs how long a challenge of difficulty is expected to
+// take at the benchmarked hash rate, warning when that exceeds
+// pow.warn_seconds or the time left before the challenge expires.
+func checkPowEstimate(difficulty int, cfg powConfig, expires time.Time, log *logger) {
+	rate := cachedPowHashRate(cfg.Workers)
+	if rate <= 0 || difficulty < 0 || difficulty > 64 {
+		return
+	}
+	expected := expectedPowTime(difficulty, rate)
+	log.infof("PoW estimate: difficulty=%d rate=%.0f/s expected=%s", difficulty, rate, expected.Round(100*time.Millisecond))
+
+	limit := defaultPowWarnTime
+	if cfg.WarnSeconds > 0 {
+		limit = time.Duration(cfg.WarnSeconds) * time.Second
+	}
+	if !expires.IsZero() {
+		if left := time.Until(expires) - powExpiryMargin; left > 0 && expected > left {
+			log.warnf("expected PoW time %s exceeds the %s left on the challenge; raise pow.workers or use a faster machine", expected.Round(time.Second), left.Round(time.Second))
+			return
+		}
+	}
+	if expected > limit {
+		log.warnf("expected PoW time %s exceeds %s; raise pow.workers or use a faster machine", expected.Round(time.Second), limit)
+	}
+}
+
+// expectedPowTime is the mean time to find a nonce with difficulty leading
+// zero nibbles at rate hashes per second.
+func expectedPowTime(difficulty int, rate float64) time.Duration {
+	secs := math.Pow(16, float64(difficulty)) / rate
+	if secs >= math.MaxInt64/float64(time.Second) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// powHashRate measures how many challenge hashes per second workers
+// goroutines (GOMAXPROCS when workers <= 0) compute, hashing for d.
+func powHashRate(workers int, d time.Duration) float64 {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	const challenge = "ergo-pow-benchmark"
+	var (
+		stop  atomic.Bool
+		total atomic.Int64
+		wg    sync.WaitGroup
+	)
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(first int) {
+			defer wg.Done()
+			buf := make([]byte, len(challenge), len(challenge)+20)
+			copy(buf, challenge)
+			var sink byte
+			i := first
+			for !stop.Load() {
+				for range 1024 {
+					sum := sha256.Sum256(strconv.AppendInt(buf[:len(challenge)], int64(i), 10))
+					sink ^= sum[0]
+					i += workers
+				}
+				total.Add(1024)
+			}
+			_ = sink
+		}(w)
+	}
+	time.Sleep(d)
+	stop.Store(true)
+	wg.Wait()
+	return float64(total.Load()) / time.Since(start).Seconds()
+}
+
+// isPowRecoverable reports whether a fresh challenge may succeed where err
+// failed: the challenge expired, or the server rejected it or the nonce.
+// Note: Chinese strings match server-side error messages.
+func isPowRecoverable(err error) bool {
+	if errors.Is(err, errPowExpired) {
+		return true
+	}
+	var ae *apiError
+	if !errors.As(err, &ae) || ae.StatusCode < 400 || ae.StatusCode >= 500 || isAuthError(err) {
+		return false
+	}
+	msg := strings.ToLower(ae.Message)
+	for _, s := range []string{"expired", "invalid nonce", "invalid challenge", "过期", "无效"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
 // computePowNonce finds a nonce where sha256(challenge+nonce) has the required
-// number of leading zero nibbles (hex digits).
-func computePowNonce(ctx context.Context, challenge string, difficulty int, log *logger) (string, error) {
+// number of leading zero nibbles (hex digits). The nonce space is sharded
+// across workers goroutines (GOMAXPROCS when workers <= 0): worker k tries
+// k, k+workers, k+2*workers, ... until any worker finds a match.
+//
+// With a non-zero expires the search gives up with errPowExpired
+// powExpiryMargin before the challenge expires, leaving time to verify. An
+// expiry already that close means the clocks disagree, so it is ignored.
+func computePowNonce(ctx context.Context, challenge string, difficulty, workers int, expires time.Time, log *logger) (string, error) {
 	if difficulty < 0 || difficulty > 64 {
 		return "", fmt.Errorf("invalid difficulty: %d", difficulty)
 	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
 
 	fullZeroBytes := difficulty / 2
 	halfNibble := difficulty%2 == 1
 
-	start := time.Now()
-	nextLogAt := start.Add(2 * time.Second)
-	const checkEvery = 100_000
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if cutoff := expires.Add(-powExpiryMargin); !expires.IsZero() && cutoff.After(time.Now()) {
+		ctx, cancel = context.WithDeadline(ctx, cutoff)
+		defer cancel()
+	}
 
-	for i := 0; ; i++ {
-		if i%checkEvery == 0 {
-			select {
-			case <-ctx.Done():
-				return "", ctx.Err()
-			default:
+	// checkEvery is how many hashes a worker computes between looking at
+	// the shared found flag and publishing its attempt count.
+	const checkEvery = 4096
+
+	var (
+		found    atomic.Bool
+		attempts atomic.Int64
+		wg       sync.WaitGroup
+	)
+	result := make(chan string, 1)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(first int) {
+			defer wg.Done()
+			buf := make([]byte, len(challenge), len(challenge)+20)
+			copy(buf, challenge)
+			n := 0
+			for i := first; ; i += workers {
+				if n == checkEvery {
+					attempts.Add(checkEvery)
+					n = 0
+					if found.Load() || ctx.Err() != nil {
+						return
+					}
+				}
+				n++
+				b := strconv.AppendInt(buf[:len(challenge)], int64(i), 10)
+				if hasLeadingZeroNibbles(sha256.Sum256(b), fullZeroBytes, halfNibble) {
+					if found.CompareAndSwap(false, true) {
+						result <- string(b[len(challenge):])
+					}
+					return
+				}
 			}
-		}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
-		nonce := strconv.Itoa(i)
-		sum := sha256.Sum256([]byte(challenge + nonce))
-		if hasLeadingZeroNibbles(sum, fullZeroBytes, halfNibble) {
+	start := time.Now()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case nonce := <-result:
+			cancel()
+			<-done
 			return nonce, nil
-		}
-
-		if log != nil && i%checkEvery == 0 {
-			now := time.Now()
-			if now.Before(nextLogAt) {
+		case <-done:
+			select {
+			case nonce := <-result:
+				return nonce, nil
+			default:
+			}
+			if parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return "", errPowExpired
+			}
+			return "", ctx.Err()
+		case now := <-ticker.C:
+			if log == nil {
 				continue
 			}
-			attempts := i + 1
+			n := attempts.Load()
 			elapsed := now.Sub(start)
-			rate := float64(attempts) / elapsed.Seconds()
-			log.infof("PoW in progress: difficulty=%d attempts=%d rate=%.0f/s elapsed=%s", difficulty, attempts, rate, elapsed.Round(100*time.Millisecond))
-			nextLogAt = now.Add(2 * time.Second)
+			rate := float64(n) / elapsed.Seconds()
+			log.infof("PoW in progress: difficulty=%d workers=%d attempts=%d rate=%.0f/s elapsed=%s", difficulty, workers, n, rate, elapsed.Round(100*time.Millisecond))
 		}
 	}
 }
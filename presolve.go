@@ -0,0 +1,216 @@
+package main
+
+import "fmt"
+
+// gridTransform is a candidate rule of the pre-solver: a whole-grid
+// geometric change that a color mapping may follow.
+type gridTransform struct {
+	name  string
+	apply func([][]int) [][]int
+}
+
+// presolve looks for a deterministic rule that explains every training
+// pair: identity, a flip, rotation or transpose, scaling each cell to a
+// block, or tiling the input, each optionally followed by a uniform
+// recolor. If one does and the test input has no colors it cannot map, it
+// returns the rule applied to the test input. At least two training pairs
+// are required, as one pair fits too many rules.
+func presolve(p puzzle) (grid [][]int, rule string, ok bool) {
+	if len(p.Train) < 2 || !rectangular(p.TestInput) {
+		return nil, "", false
+	}
+	for _, ex := range p.Train {
+		if !rectangular(ex.Input) || !rectangular(ex.Output) {
+			return nil, "", false
+		}
+	}
+	// A recolor can make a simpler transform fit by coincidence, so every
+	// transform is tried on its own before any is tried with a recolor.
+	transforms := presolveTransforms(p.Train[0])
+	for _, withRecolor := range []bool{false, true} {
+		for _, t := range transforms {
+			colors, ok := fitTransform(p.Train, t)
+			if !ok || identityMap(colors) == withRecolor {
+				continue
+			}
+			out, ok := recolor(t.apply(p.TestInput), colors)
+			if !ok || validateAnswerSize(p, out) != nil {
+				continue
+			}
+			rule := t.name
+			switch {
+			case withRecolor && t.name == "identity":
+				rule = "recolor " + formatColorMap(colors)
+			case withRecolor:
+				rule += " with recolor " + formatColorMap(colors)
+			}
+			return out, rule, true
+		}
+	}
+	return nil, "", false
+}
+
+// presolveTransforms lists the candidate transforms, simplest first. Scale
+// and tile factors are taken from the first pair.
+func presolveTransforms(first puzzleExample) []gridTransform {
+	ts := []gridTransform{
+		{"identity", func(g [][]int) [][]int { return g }},
+		{"horizontal flip", flipH},
+		{"vertical flip", flipV},
+		{"rotate 90° clockwise", rotateCW},
+		{"rotate 180°", func(g [][]int) [][]int { return flipV(flipH(g)) }},
+		{"rotate 90° counter-clockwise", func(g [][]int) [][]int { return rotateCW(rotateCW(rotateCW(g))) }},
+		{"transpose", transpose},
+		{"anti-transpose", func(g [][]int) [][]int { return flipV(flipH(transpose(g))) }},
+	}
+	ih, iw := len(first.Input), len(first.Input[0])
+	oh, ow := len(first.Output), len(first.Output[0])
+	if oh%ih == 0 && ow%iw == 0 && (oh > ih || ow > iw) {
+		kh, kw := oh/ih, ow/iw
+		ts = append(ts,
+			gridTransform{fmt.Sprintf("scale %d×%d", kh, kw), func(g [][]int) [][]int { return scale(g, kh, kw) }},
+			gridTransform{fmt.Sprintf("tile %d×%d", kh, kw), func(g [][]int) [][]int { return tile(g, kh, kw) }})
+	}
+	return ts
+}
+
+// fitTransform reports whether t followed by one consistent color mapping
+// turns every training input into its output, and returns that mapping.
+func fitTransform(train []puzzleExample, t gridTransform) (map[int]int, bool) {
+	colors := map[int]int{}
+	for _, ex := range train {
+		got := t.apply(ex.Input)
+		if len(got) != len(ex.Output) || len(got[0]) != len(ex.Output[0]) {
+			return nil, false
+		}
+		for r, row := range got {
+			for c, v := range row {
+				want := ex.Output[r][c]
+				if m, seen := colors[v]; seen && m != want {
+					return nil, false
+				}
+				colors[v] = want
+			}
+		}
+	}
+	return colors, true
+}
+
+// recolor applies colors to g. A color the mapping does not cover keeps its
+// value only when the mapping changes no color; otherwise its image is
+// unknown and recolor fails.
+func recolor(g [][]int, colors map[int]int) ([][]int, bool) {
+	keep := identityMap(colors)
+	out := make([][]int, len(g))
+	for r, row := range g {
+		out[r] = make([]int, len(row))
+		for c, v := range row {
+			m, ok := colors[v]
+			if !ok && !keep {
+				return nil, false
+			}
+			if !ok {
+				m = v
+			}
+			out[r][c] = m
+		}
+	}
+	return out, true
+}
+
+func identityMap(colors map[int]int) bool {
+	for k, v := range colors {
+		if k != v {
+			return false
+		}
+	}
+	return true
+}
+
+// formatColorMap lists the colors a mapping changes, such as "1→2, 3→4".
+func formatColorMap(colors map[int]int) string {
+	var s string
+	for k := range 10 {
+		if v, ok := colors[k]; ok && v != k {
+			if s != "" {
+				s += ", "
+			}
+			s += fmt.Sprintf("%d→%d", k, v)
+		}
+	}
+	return s
+}
+
+// rectangular reports whether g is non-empty with rows of equal, non-zero
+// length.
+func rectangular(g [][]int) bool {
+	if len(g) == 0 || len(g[0]) == 0 {
+		return false
+	}
+	for _, row := range g {
+		if len(row) != len(g[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+func flipH(g [][]int) [][]int {
+	out := make([][]int, len(g))
+	for r, row := range g {
+		out[r] = make([]int, len(row))
+		for c, v := range row {
+			out[r][len(row)-1-c] = v
+		}
+	}
+	return out
+}
+
+func flipV(g [][]int) [][]int {
+	out := make([][]int, len(g))
+	for r, row := range g {
+		out[len(g)-1-r] = append([]int(nil), row...)
+	}
+	return out
+}
+
+func transpose(g [][]int) [][]int {
+	out := make([][]int, len(g[0]))
+	for c := range out {
+		out[c] = make([]int, len(g))
+		for r := range g {
+			out[c][r] = g[r][c]
+		}
+	}
+	return out
+}
+
+func rotateCW(g [][]int) [][]int {
+	return flipH(transpose(g))
+}
+
+// scale turns each cell of g into a kh×kw block.
+func scale(g [][]int, kh, kw int) [][]int {
+	out := make([][]int, len(g)*kh)
+	for r := range out {
+		src := g[r/kh]
+		out[r] = make([]int, len(src)*kw)
+		for c := range out[r] {
+			out[r][c] = src[c/kw]
+		}
+	}
+	return out
+}
+
+// tile repeats g kh times down and kw times across.
+func tile(g [][]int, kh, kw int) [][]int {
+	out := make([][]int, len(g)*kh)
+	for r := range out {
+		src := g[r%len(g)]
+		out[r] = make([]int, len(src)*kw)
+		for c := range out[r] {
+			out[r][c] = src[c%len(src)]
+		}
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPresolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		train    []puzzleExample
+		input    [][]int
+		want     [][]int
+		wantRule string
+	}{
+		{
+			// Swapping 1 and 2 explains both pairs as well as the flip does;
+			// the plain flip must win, or [[1,1,2]] becomes [[2,2,1]].
+			name: "recolor does not shadow a plain transform",
+			train: []puzzleExample{
+				{Input: [][]int{{1, 2}}, Output: [][]int{{2, 1}}},
+				{Input: [][]int{{2, 1}}, Output: [][]int{{1, 2}}},
+			},
+			input:    [][]int{{1, 1, 2}},
+			want:     [][]int{{2, 1, 1}},
+			wantRule: "horizontal flip",
+		},
+		{
+			name: "recolor",
+			train: []puzzleExample{
+				{Input: [][]int{{1, 0}, {0, 1}}, Output: [][]int{{2, 0}, {0, 2}}},
+				{Input: [][]int{{0, 1}, {1, 1}}, Output: [][]int{{0, 2}, {2, 2}}},
+			},
+			input:    [][]int{{1, 1}, {0, 0}},
+			want:     [][]int{{2, 2}, {0, 0}},
+			wantRule: "recolor 1→2",
+		},
+		{
+			name: "transform with recolor",
+			train: []puzzleExample{
+				{Input: [][]int{{1, 0}, {0, 0}}, Output: [][]int{{0, 3}, {0, 0}}},
+				{Input: [][]int{{0, 0}, {1, 0}}, Output: [][]int{{3, 0}, {0, 0}}},
+			},
+			input:    [][]int{{0, 1}, {0, 0}},
+			want:     [][]int{{0, 0}, {0, 3}},
+			wantRule: "rotate 90° clockwise with recolor 1→3",
+		},
+		{
+			name: "scale",
+			train: []puzzleExample{
+				{Input: [][]int{{1, 2}}, Output: [][]int{{1, 1, 2, 2}, {1, 1, 2, 2}}},
+				{Input: [][]int{{3, 0}}, Output: [][]int{{3, 3, 0, 0}, {3, 3, 0, 0}}},
+			},
+			input:    [][]int{{4, 5}},
+			want:     [][]int{{4, 4, 5, 5}, {4, 4, 5, 5}},
+			wantRule: "scale 2×2",
+		},
+		{
+			name: "tile",
+			train: []puzzleExample{
+				{Input: [][]int{{1, 2}}, Output: [][]int{{1, 2, 1, 2}}},
+				{Input: [][]int{{3, 3}}, Output: [][]int{{3, 3, 3, 3}}},
+			},
+			input:    [][]int{{5, 6}},
+			want:     [][]int{{5, 6, 5, 6}},
+			wantRule: "tile 1×2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rule, ok := presolve(puzzle{Train: tt.train, TestInput: tt.input})
+			if !ok {
+				t.Fatal("presolve found no rule")
+			}
+			if rule != tt.wantRule || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("presolve = %v (%s), want %v (%s)", got, rule, tt.want, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestPresolveNoRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		train []puzzleExample
+		input [][]int
+	}{
+		{
+			name: "one training pair",
+			train: []puzzleExample{
+				{Input: [][]int{{1, 2}}, Output: [][]int{{2, 1}}},
+			},
+			input: [][]int{{1, 2}},
+		},
+		{
+			name: "no transform fits",
+			train: []puzzleExample{
+				{Input: [][]int{{1, 2}}, Output: [][]int{{1, 1}}},
+				{Input: [][]int{{1, 2}}, Output: [][]int{{2, 2}}},
+			},
+			input: [][]int{{1, 2}},
+		},
+		{
+			name: "test color the recolor does not cover",
+			train: []puzzleExample{
+				{Input: [][]int{{1, 0}}, Output: [][]int{{2, 0}}},
+				{Input: [][]int{{0, 1}}, Output: [][]int{{0, 2}}},
+			},
+			input: [][]int{{5, 1}},
+		},
+		{
+			name: "ragged grid",
+			train: []puzzleExample{
+				{Input: [][]int{{1, 2}, {3}}, Output: [][]int{{1, 2}, {3}}},
+				{Input: [][]int{{1}}, Output: [][]int{{1}}},
+			},
+			input: [][]int{{1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, rule, ok := presolve(puzzle{Train: tt.train, TestInput: tt.input}); ok {
+				t.Errorf("presolve = %v (%s), want no rule", got, rule)
+			}
+		})
+	}
+}
+
+func TestPresolveAnswerSize(t *testing.T) {
+	// Identity fits, but the hinted size only admits the transpose.
+	p := puzzle{
+		Train: []puzzleExample{
+			{Input: [][]int{{1, 1}, {1, 1}}, Output: [][]int{{1, 1}, {1, 1}}},
+			{Input: [][]int{{2, 2}, {2, 2}}, Output: [][]int{{2, 2}, {2, 2}}},
+		},
+		TestInput: [][]int{{1, 2}},
+	}
+	p.Hints.AnswerSize.Width, p.Hints.AnswerSize.Height = 1, 2
+	got, rule, ok := presolve(p)
+	if want := [][]int{{1}, {2}}; !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("presolve = %v (%s), %v; want %v", got, rule, ok, want)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ai.solver values.
+const (
+	solverGrid    = "grid"
+	solverProgram = "program"
+)
+
+const programPrompt = `You are an expert ARC (Abstraction and Reasoning Corpus) puzzle solver. Instead of writing the output grid yourself, you write a program in a small grid DSL that turns each input grid into its output grid. The program is executed on every training input and must reproduce every training output exactly; it is then run on the test input.
+
+` + dslReference + `
+
+## Output Format (MUST be ONLY valid JSON, no other text):
+{
+  "reasoning": "The rule, and how the steps implement it",
+  "program": {"steps": [{"op": "flip", "axis": "h", "times": null, ...}]},
+  "confidence": 90
+}
+
+Use as few steps as possible. Every step must list all arguments; those its op does not use are null.`
+
+// programAnswer is the structured response in program mode.
+type programAnswer struct {
+	Reasoning  string     `json:"reasoning"`
+	Program    dslProgram `json:"program"`
+	Confidence int        `json:"confidence"`
+}
+
+// solveProgram asks the model for a DSL program instead of a grid, checks it
+// against every training pair, and runs it on the test input. A program
+// that fails a pair is sent back with the failures up to
+// ai.max_refinements times; one that still fails is an error, so a wrong
+// answer is caught before it is submitted.
+func (s *Solver) solveProgram(ctx context.Context, p puzzle) (solveResult, error) {
+	log := s.log.forContext(ctx)
+	query := fmt.Sprintf(`Write a DSL program that solves the ARC puzzle above.
+The program's result on the test input must be exactly %d rows × %d columns.`, p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width)
+
+	for round := 0; ; round++ {
+		spin := newSpinner()
+		spin.Start("🧮 AI writing a program...")
+		ans, err := s.askProgram(ctx, p, query)
+		spin.Stop()
+		if err != nil {
+			return solveResult{}, err
+		}
+		printAnswerDetails(Answer{Reasoning: ans.Reasoning, Confidence: ans.Confidence})
+		fmt.Fprintf(uiOut, "%s🧮 Program:\n%s%s\n", colorCyan, ans.Program, colorReset)
+
+		failures := checkProgram(ans.Program, p)
+		var out [][]int
+		if len(failures) == 0 {
+			out, err = ans.Program.run(p.TestInput)
+			if err == nil {
+				err = validateAnswerSize(p, out)
+			}
+			if err != nil {
+				failures = append(failures, "test input: "+err.Error())
+			}
+		}
+		if len(failures) == 0 {
+			fmt.Fprintf(uiOut, "%s✅ Program reproduces all %d training pairs%s\n", colorGreen, len(p.Train), colorReset)
+			verified := true
+			return solveResult{
+				Answer:     out,
+				Model:      "program(" + s.model + ")",
+				Confidence: ans.Confidence,
+				Reasoning:  ans.Reasoning + "\n\nProgram:\n" + ans.Program.String(),
+				Verified:   &verified,
+			}, nil
+		}
+		for _, f := range failures {
+			fmt.Fprintf(uiOut, "%s🧮 %s%s\n", colorYellow, f, colorReset)
+		}
+		if round >= s.cfg.MaxRefinements {
+			return solveResult{}, fmt.Errorf("program check failed: %s", strings.Join(failures, "; "))
+		}
+		log.warnf("program failed %d check(s), refining (%d/%d)", len(failures), round+1, s.cfg.MaxRefinements)
+		query = fmt.Sprintf(`A previous program for the ARC puzzle above was:
+%s
+
+Running it gave:
+- %s
+
+Fix the program or write a new one. Its result on the test input must be exactly %d rows × %d columns.`, ans.Program, strings.Join(failures, "\n- "), p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width)
+	}
+}
+
+// askProgram sends query about p in program mode and parses the program.
+func (s *Solver) askProgram(ctx context.Context, p puzzle, query string) (programAnswer, error) {
+	prompt, err := s.puzzlePrompt(programPrompt, p, query)
+	if err != nil {
+		return programAnswer{}, err
+	}
+	content, err := s.complete(ctx, s.model, prompt, "arc_program", "ARC puzzle solution as a grid DSL program", programSchema())
+	if err != nil {
+		return programAnswer{}, fmt.Errorf("%w: %w", ErrAIUnavailable, err)
+	}
+	if content == "" {
+		return programAnswer{}, errors.New("no content in response")
+	}
+	var ans programAnswer
+	if err := json.Unmarshal([]byte(content), &ans); err != nil {
+		if err := json.Unmarshal([]byte(repairJSON(content)), &ans); err != nil {
+			return programAnswer{}, fmt.Errorf("parse program: %w", err)
+		}
+	}
+	if len(ans.Program.Steps) == 0 {
+		return programAnswer{}, errors.New("empty program")
+	}
+	return ans, nil
+}
+
+// checkProgram runs prog on every training input and describes each pair
+// it does not reproduce.
+func checkProgram(prog dslProgram, p puzzle) []string {
+	var failures []string
+	for i, ex := range p.Train {
+		got, err := prog.run(ex.Input)
+		switch {
+		case err != nil:
+			failures = append(failures, fmt.Sprintf("training pair %d: %v", i+1, err))
+		case !gridsEqual(got, ex.Output):
+			failures = append(failures, fmt.Sprintf("training pair %d: %s", i+1, gridDiff(got, ex.Output)))
+		}
+	}
+	return failures
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// proxyDirect disables proxying, including the environment variables.
+const proxyDirect = "direct"
+
+// proxyFunc returns the http.Transport proxy for a proxy config value:
+// empty defers to HTTPS_PROXY/HTTP_PROXY/NO_PROXY, "direct" connects
+// directly, anything else is an http, https, socks5 or socks5h proxy URL
+// (credentials may be given as user:pass@).
+func proxyFunc(raw string) (func(*http.Request) (*url.URL, error), error) {
+	raw = strings.TrimSpace(raw)
+	switch raw {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case proxyDirect:
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: scheme must be http, https, socks5 or socks5h", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", raw)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// rootCAs, when set, replaces the system roots for every HTTPS client. Only
+// the selftest command sets it, to trust its mock server.
+var rootCAs *x509.CertPool
+
+// proxiedTransport returns a clone of the default transport that uses the
+// proxy configured by raw.
+func proxiedTransport(raw string) (*http.Transport, error) {
+	proxy, err := proxyFunc(raw)
+	if err != nil {
+		return nil, err
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = proxy
+	if rootCAs != nil {
+		tr.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	}
+	return tr, nil
+}
+
+// redactProxy hides the password of a proxy URL for logging.
+func redactProxy(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.User == nil {
+		return raw
+	}
+	return u.Redacted()
+}
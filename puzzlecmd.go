@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// puzzlesDirName is the directory under ERGO_PROXY_HOME holding saved puzzles.
+const puzzlesDirName = "puzzles"
+
+// loadPuzzleFile reads a puzzle saved either bare or wrapped in a
+// puzzleNewResponse ({"puzzle": {...}}), or the first test case of an ARC
+// dataset task.
+func loadPuzzleFile(path string) (puzzle, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return puzzle{}, fmt.Errorf("read puzzle: %w", err)
+	}
+	var arc arcTaskFile
+	if err := json.Unmarshal(b, &arc); err == nil && len(arc.Test) > 0 {
+		tasks, err := loadLocalTasks(path)
+		if err != nil {
+			return puzzle{}, err
+		}
+		return tasks[0].Puzzle, nil
+	}
+	var wrapped struct {
+		Puzzle *puzzle `json:"puzzle"`
+	}
+	if err := json.Unmarshal(b, &wrapped); err == nil && wrapped.Puzzle != nil {
+		return *wrapped.Puzzle, nil
+	}
+	var p puzzle
+	if err := json.Unmarshal(b, &p); err != nil {
+		return puzzle{}, fmt.Errorf("parse puzzle %s: %w", path, err)
+	}
+	if len(p.Train) == 0 && len(p.TestInput) == 0 {
+		return puzzle{}, fmt.Errorf("parse puzzle %s: no train pairs or test input", path)
+	}
+	return p, nil
+}
+
+// findSavedPuzzle returns the newest file in dir whose name contains id.
+func findSavedPuzzle(dir, id string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+id+"*.json"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no saved puzzle matching %q in %s", id, dir)
+	}
+	sort.Strings(matches)
+	return matches[len(matches)-1], nil
+}
+
+func runPuzzleCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: ergo-solver puzzle show FILE|--id ID")
+	}
+	switch args[0] {
+	case "show":
+		return runPuzzleShow(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown puzzle subcommand: %s", args[0])
+	}
+}
+
+func runPuzzleShow(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet("puzzle show", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath string
+		id         string
+	)
+	fs.StringVar(&configPath, "config", "", "config path (locates the runtime directory)")
+	fs.StringVar(&id, "id", "", "puzzle ID to look up among saved puzzles")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	path := fs.Arg(0)
+	switch {
+	case path == "" && id == "":
+		return errors.New("puzzle show requires FILE or --id")
+	case path == "":
+		p, err := findSavedPuzzle(filepath.Join(homeDir(configPath), puzzlesDirName), id)
+		if err != nil {
+			return err
+		}
+		path = p
+	}
+
+	p, err := loadPuzzleFile(path)
+	if err != nil {
+		return err
+	}
+	printPuzzle(os.Stdout, p, colorEnabled(os.Stdout))
+	return nil
+}
+
+// printPuzzle writes every train pair side by side followed by the test input.
+func printPuzzle(w io.Writer, p puzzle, color bool) {
+	title := p.ID
+	if title == "" {
+		title = "(no id)"
+	}
+	_, _ = fmt.Fprintf(w, "Puzzle %s: %d train pair(s)", title, len(p.Train))
+	if p.Hints.AnswerSize.Height > 0 {
+		_, _ = fmt.Fprintf(w, ", expected answer %d×%d, background %d", p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width, p.Hints.BackgroundColor)
+	}
+	_, _ = fmt.Fprintln(w)
+
+	for i, ex := range p.Train {
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintf(w, "Train %d: input %s → output %s\n", i+1, gridDims(ex.Input), gridDims(ex.Output))
+		left := renderGridLines(ex.Input, color)
+		right := renderGridLines(ex.Output, color)
+		for _, line := range sideBySide(left, right, gridWidth(ex.Input)*2, "  →  ") {
+			_, _ = fmt.Fprintln(w, line)
+		}
+		_, _ = fmt.Fprintf(w, "  in colors:  %s\n", colorCounts(ex.Input))
+		_, _ = fmt.Fprintf(w, "  out colors: %s\n", colorCounts(ex.Output))
+	}
+
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintf(w, "Test input %s\n", gridDims(p.TestInput))
+	for _, line := range renderGridLines(p.TestInput, color) {
+		_, _ = fmt.Fprintln(w, line)
+	}
+	_, _ = fmt.Fprintf(w, "  colors: %s\n", colorCounts(p.TestInput))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// verifyQuorum asks every model in ai.verifiers concurrently whether answer
+// follows the puzzle's pattern. The answer is valid when at least
+// ai.verify_quorum of them say so (default: a majority). Verifiers whose
+// request fails abstain; if the abstentions decide the outcome, an error is
+// returned, as when the single verifier fails. The reasoning of an invalid
+// result collects the objections of the verifiers that rejected the answer.
+func (s *Solver) verifyQuorum(ctx context.Context, p puzzle, answer [][]int) (VerifyResult, error) {
+	log := s.log.forContext(ctx)
+	models := s.cfg.Verifiers
+	need := s.verifyQuorumSize()
+
+	results := make([]VerifyResult, len(models))
+	errs := make([]error, len(models))
+	var wg sync.WaitGroup
+	for i, m := range models {
+		wg.Add(1)
+		go func(i int, m string) {
+			defer wg.Done()
+			results[i], errs[i] = s.verifyOnce(ctx, p, answer, m)
+		}(i, m)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return VerifyResult{}, err
+	}
+
+	var (
+		yes, failed int
+		objections  []string
+	)
+	for i, m := range models {
+		vr := results[i]
+		switch {
+		case errs[i] != nil:
+			failed++
+			log.warnf("verifier %s failed: %v", m, errs[i])
+		case vr.Valid:
+			yes++
+			fmt.Fprintf(uiOut, "%s🔍 %s: valid — %s%s\n", colorDim, m, vr.Reasoning, colorReset)
+		default:
+			objections = append(objections, m+": "+vr.Reasoning)
+			fmt.Fprintf(uiOut, "%s🔍 %s: invalid — %s%s\n", colorYellow, m, vr.Reasoning, colorReset)
+		}
+	}
+	fmt.Fprintf(uiOut, "%s🗳️  Verifier quorum: %d of %d valid, %d needed%s\n", colorCyan, yes, len(models), need, colorReset)
+
+	switch {
+	case yes >= need:
+		return VerifyResult{Valid: true, Reasoning: fmt.Sprintf("%d of %d verifiers accepted the answer", yes, len(models))}, nil
+	case yes+failed >= need:
+		return VerifyResult{}, fmt.Errorf("verifier quorum undecided: %d of %d valid, %d needed, %d failed: %w", yes, len(models), need, failed, errors.Join(errs...))
+	}
+	return VerifyResult{Reasoning: strings.Join(objections, "\n")}, nil
+}
+
+// verifyQuorumSize is how many verifiers must accept an answer.
+func (s *Solver) verifyQuorumSize() int {
+	if s.cfg.VerifyQuorum > 0 {
+		return s.cfg.VerifyQuorum
+	}
+	return len(s.cfg.Verifiers)/2 + 1
+}
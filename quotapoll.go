@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Default bounds of the quota polling interval.
+const (
+	defaultPollMin = time.Minute
+	defaultPollMax = time.Hour
+)
+
+// pollBounds returns the configured quota polling interval bounds.
+func pollBounds(cfg appConfig) (lo, hi time.Duration) {
+	lo, hi = defaultPollMin, defaultPollMax
+	if cfg.Daemon.PollMinMinutes > 0 {
+		lo = time.Duration(cfg.Daemon.PollMinMinutes) * time.Minute
+	}
+	if cfg.Daemon.PollMaxMinutes > 0 {
+		hi = time.Duration(cfg.Daemon.PollMaxMinutes) * time.Minute
+	}
+	return lo, max(lo, hi)
+}
+
+// quotaPollInterval returns how long to wait before the next quota poll at
+// now: a quarter of the time to the nearest quota reset, which is either the
+// coming one or, for servers that reset late, the one just passed, clamped
+// to [lo, hi]. Polls are sparse far from the reset and dense around it, and
+// never skip past it.
+func quotaPollInterval(now time.Time, reset int, lo, hi time.Duration) time.Duration {
+	next := nextClock(now, reset)
+	prev := nextClock(now.AddDate(0, 0, -1), reset)
+	d := min(next.Sub(now), now.Sub(prev)) / 4
+	return min(max(d, lo), hi)
+}
+
+// waitForQuota logs in once and polls /api/daily/remaining at adaptive
+// intervals until puzzles remain. Polls are conditional requests, so a
+// server that sends validators answers unchanged quota with an empty 304.
+func waitForQuota(ctx context.Context, log *logger, configPath string, reset int) error {
+	sess, err := openSession(ctx, configPath, log, nil)
+	if err != nil {
+		return err
+	}
+	lo, hi := pollBounds(sess.cfg)
+	for {
+		wait := quotaPollInterval(time.Now(), reset, lo, hi)
+		log.infof("daemon: quota used up, checking again at %s", time.Now().Add(wait).Format(time.DateTime))
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+		dr, err := sess.client.dailyRemaining(ctx)
+		if err != nil {
+			return err
+		}
+		if dr.Remaining > 0 {
+			log.infof("daemon: daily quota reset: remaining=%d limit=%d", dr.Remaining, dr.Limit)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ai.ragged_rows values.
+const (
+	raggedPad    = "pad"
+	raggedReject = "reject"
+)
+
+// ragged reports whether the rows of g differ in length.
+func ragged(g [][]int) bool {
+	return slices.ContainsFunc(g, func(row []int) bool { return len(row) != len(g[0]) })
+}
+
+// padRagged pads the short rows of g to the width of its longest row with
+// bg, returning the padded grid and how many rows were padded.
+func padRagged(g [][]int, bg int) ([][]int, int) {
+	width := 0
+	for _, row := range g {
+		width = max(width, len(row))
+	}
+	out := make([][]int, len(g))
+	n := 0
+	for i, row := range g {
+		out[i] = row
+		if len(row) < width {
+			out[i] = append(slices.Clone(row), slices.Repeat([]int{bg}, width-len(row))...)
+			n++
+		}
+	}
+	return out, n
+}
+
+func rowLengths(g [][]int) []int {
+	lens := make([]int, len(g))
+	for i, row := range g {
+		lens[i] = len(row)
+	}
+	return lens
+}
+
+// raggedObjection tells the model its answer had ragged rows.
+func raggedObjection(p puzzle, g [][]int) string {
+	msg := fmt.Sprintf("Your previous answer had rows of different lengths %v. Every row of the answer grid must have the same number of cells", rowLengths(g))
+	if h, w := p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width; h > 0 && w > 0 {
+		return fmt.Sprintf("%s: exactly %d rows × %d columns.", msg, h, w)
+	}
+	return msg + "."
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// maxReemits is how many times a reply that does not decode as an answer
+// object is sent back to be re-emitted as JSON before the grid is salvaged
+// from it.
+const maxReemits = 2
+
+// reemitEchoLimit caps how much of the broken reply is quoted back.
+const reemitEchoLimit = 8000
+
+// errNotAnswerObject is decodeAnswer's error for a reply that is no answer
+// object even after JSON repair and answer adapters.
+var errNotAnswerObject = errors.New("reply is not a JSON answer object")
+
+// answerFromReply decodes content, the reply to prompt. A reply that is not
+// a JSON answer object is quoted back with a request to re-emit only the
+// object, up to maxReemits times; if none decodes, the grid is salvaged
+// from the replies, latest first.
+func (s *Solver) answerFromReply(ctx context.Context, model string, prompt chatPrompt, schemaDesc string, schema map[string]any, content string) (Answer, error) {
+	answer, err := s.decodeAnswer(content, model)
+	if !errors.Is(err, errNotAnswerObject) {
+		return answer, err
+	}
+	log := s.log.forContext(ctx)
+	replies := []string{content}
+	for i := range maxReemits {
+		log.warnf("reply from %s is not valid JSON, asking it to re-emit the object (%d/%d)", model, i+1, maxReemits)
+		q := prompt
+		q.query = reemitQuery(content)
+		next, err := s.complete(ctx, model, q, "arc_answer", schemaDesc, schema)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Answer{}, ctx.Err()
+			}
+			log.warnf("re-emit request failed: %v", err)
+			break
+		}
+		answer, err := s.decodeAnswer(next, model)
+		if !errors.Is(err, errNotAnswerObject) {
+			return answer, err
+		}
+		content = next
+		replies = append(replies, next)
+	}
+	var salvageErr error
+	for _, r := range slices.Backward(replies) {
+		grid, err := parseAnswerGrid(r)
+		if err == nil {
+			return Answer{Answer: grid}, nil
+		}
+		salvageErr = cmp.Or(salvageErr, err)
+	}
+	return Answer{}, salvageErr
+}
+
+// reemitQuery asks for the answer object in reply again, as bare JSON.
+func reemitQuery(reply string) string {
+	if len(reply) > reemitEchoLimit {
+		reply = reply[:reemitEchoLimit] + "\n[… cut]"
+	}
+	return fmt.Sprintf(`Your previous reply was not valid JSON:
+
+%s
+
+Re-emit only the JSON object with the "reasoning", "answer" and "confidence" fields: no markdown fences, comments or text around it, and no trailing commas. Keep the answer you gave.`, reply)
+}
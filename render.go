@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Image formats of the render command (--format).
+const (
+	renderPNG  = "png"
+	renderSVG  = "svg"
+	renderBoth = "both"
+)
+
+// runRender draws a puzzle, and optionally a proposed answer beside its test
+// input, as images in the ARC palette for looking at failures: the
+// training pairs one per row, input left and output right, and the test
+// input with the answer last.
+func runRender(_ context.Context, log *logger, args []string) error {
+	fs := flag.NewFlagSet(cmdRender, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		puzzlePath string
+		answerPath string
+		outDir     string
+		format     string
+	)
+	fs.StringVar(&puzzlePath, "puzzle", "", "puzzle JSON file (required)")
+	fs.StringVar(&answerPath, "answer", "", "answer JSON file to draw beside the test input, or - for stdin")
+	fs.StringVar(&outDir, "out", ".", "directory to write the images to")
+	fs.StringVar(&format, "format", renderPNG, "png, svg or both")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if puzzlePath == "" {
+		return errors.New("--puzzle is required")
+	}
+	var formats []string
+	switch format {
+	case renderPNG, renderSVG:
+		formats = []string{format}
+	case renderBoth:
+		formats = []string{renderPNG, renderSVG}
+	default:
+		return fmt.Errorf("unknown --format %q (want %s, %s or %s)", format, renderPNG, renderSVG, renderBoth)
+	}
+
+	p, err := loadPuzzleFile(puzzlePath)
+	if err != nil {
+		return err
+	}
+	var answer [][]int
+	if answerPath != "" {
+		if answer, _, err = loadAnswerFile(answerPath); err != nil {
+			return err
+		}
+		if err := validateAnswerSize(p, answer); err != nil {
+			log.warnf("answer does not match the size hint: %v", err)
+		}
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return fmt.Errorf("mkdir output dir: %w", err)
+	}
+
+	name := p.ID
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(puzzlePath), filepath.Ext(puzzlePath))
+	}
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	for _, f := range formats {
+		path := filepath.Join(outDir, name+"."+f)
+		if err := writeRender(path, f, p, answer); err != nil {
+			return err
+		}
+		log.okf("rendered %s", path)
+	}
+	return nil
+}
+
+func writeRender(path, format string, p puzzle, answer [][]int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create image: %w", err)
+	}
+	if format == renderSVG {
+		_, err = io.WriteString(f, renderPuzzleSVG(p, answer))
+	} else {
+		err = png.Encode(f, renderPuzzleImage(p, answer))
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("write image: %w", err)
+	}
+	return nil
+}
+
+// renderPuzzleSVG draws the same picture as renderPuzzleImage as SVG, which
+// stays sharp when zoomed into large grids.
+func renderPuzzleSVG(p puzzle, answer [][]int) string {
+	grids, width, height := layoutPuzzle(p, answer)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", width, height, width, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`+"\n", width, height, svgColor(artifactBackground))
+	for _, pg := range grids {
+		if len(pg.g) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+			pg.x, pg.y, gridPixels(pg.g, false), gridPixels(pg.g, true), svgColor(artifactBorder))
+		for r, row := range pg.g {
+			for c, v := range row {
+				if v < 0 || v >= len(arcPalette) {
+					continue
+				}
+				pc := arcPalette[v]
+				fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+					pg.x+c*artifactCell+1, pg.y+r*artifactCell+1, artifactCell-1, artifactCell-1, svgColor(color.RGBA{pc[0], pc[1], pc[2], 0xFF}))
+			}
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
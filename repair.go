@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"slices"
+)
+
+// repairAnswer post-processes a grid the model answered with, running the
+// steps ai.repair enables in order and logging each one that changes it.
+func (s *Solver) repairAnswer(ctx context.Context, p puzzle, g [][]int) [][]int {
+	cfg := s.cfg.Repair
+	log := s.log.forContext(ctx)
+	h, w, bg := p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width, p.Hints.BackgroundColor
+	if cfg.TrimPadding {
+		if out, n := trimPadding(g, h, w); n > 0 {
+			log.infof("answer repair: trimmed %d uniform padding row(s)/column(s), %s → %s", n, gridDims(g), gridDims(out))
+			s.repairs.Add(1)
+			g = out
+		}
+	}
+	if cfg.Background {
+		if from, ok := dominantColorFor(p, g); ok {
+			log.infof("answer repair: background color %d → %d", from, bg)
+			s.repairs.Add(1)
+			g = replaceColor(g, from, bg)
+		}
+	}
+	if cfg.FitSize && h > 0 && w > 0 && validateAnswerSize(p, g) != nil && len(g) > 0 {
+		out := fitSize(g, h, w, bg)
+		log.infof("answer repair: truncated/padded %s → %s", gridDims(g), gridDims(out))
+		s.repairs.Add(1)
+		g = out
+	}
+	return g
+}
+
+// repairCount returns how many answer repairs s has made so far; a nil
+// solver (manual mode) makes none.
+func (s *Solver) repairCount() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.repairs.Load()
+}
+
+// trimPadding drops uniform outer rows while g has more than h rows and
+// uniform outer columns while it has more than w columns, returning the
+// trimmed grid and how many rows and columns went. Without a hint nothing
+// is trimmed, since a uniform border is often part of the answer.
+func trimPadding(g [][]int, h, w int) ([][]int, int) {
+	if h <= 0 || w <= 0 || !rectangular(g) {
+		return g, 0
+	}
+	uniform := func(cells []int) bool {
+		return !slices.ContainsFunc(cells, func(v int) bool { return v != cells[0] })
+	}
+	n := 0
+	for len(g) > h {
+		switch {
+		case uniform(g[0]):
+			g = g[1:]
+		case uniform(g[len(g)-1]):
+			g = g[:len(g)-1]
+		default:
+			return g, n
+		}
+		n++
+	}
+	for len(g[0]) > w {
+		t := transpose(g)
+		switch {
+		case uniform(t[0]):
+			g = transpose(t[1:])
+		case uniform(t[len(t)-1]):
+			g = transpose(t[:len(t)-1])
+		default:
+			return g, n
+		}
+		n++
+	}
+	return g, n
+}
+
+// dominantColorFor returns the color covering most of g when it should be
+// the hinted background instead: every training output is mostly
+// background, but g does not use the background color at all.
+func dominantColorFor(p puzzle, g [][]int) (int, bool) {
+	bg := p.Hints.BackgroundColor
+	if len(p.Train) == 0 || colorSet(g)[bg] {
+		return 0, false
+	}
+	for _, ex := range p.Train {
+		if c, ok := mostCommonColor(ex.Output); !ok || c != bg {
+			return 0, false
+		}
+	}
+	c, ok := mostCommonColor(g)
+	if !ok || 2*colorCount(g, c) <= len(g)*gridWidth(g) {
+		return 0, false
+	}
+	return c, true
+}
+
+// mostCommonColor returns the color covering the most cells of g, the
+// smallest on ties.
+func mostCommonColor(g [][]int) (int, bool) {
+	counts := map[int]int{}
+	for _, row := range g {
+		for _, v := range row {
+			counts[v]++
+		}
+	}
+	best, ok := 0, false
+	for c, n := range counts {
+		if !ok || n > counts[best] || n == counts[best] && c < best {
+			best, ok = c, true
+		}
+	}
+	return best, ok
+}
+
+func replaceColor(g [][]int, from, to int) [][]int {
+	out := make([][]int, len(g))
+	for r, row := range g {
+		out[r] = make([]int, len(row))
+		for c, v := range row {
+			if v == from {
+				v = to
+			}
+			out[r][c] = v
+		}
+	}
+	return out
+}
+
+// fitSize truncates or pads g, row by row, to h×w; missing cells are bg.
+func fitSize(g [][]int, h, w, bg int) [][]int {
+	out := make([][]int, h)
+	for r := range out {
+		out[r] = slices.Repeat([]int{bg}, w)
+		if r < len(g) {
+			copy(out[r], g[r])
+		}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Resolve solves p again after the site judged the answers in wrong
+// incorrect, for another submission while attempts remain. The model that
+// answers is the next one in rotation over the primary or ensemble models
+// and the fallbacks, so the first retry goes to a different model when
+// there is one; once the rotation wraps, or with a single model, it samples
+// at the sampling temperature instead. The rejected answers are shown to the
+// model, and an answer repeating one of them is refused.
+func (s *Solver) Resolve(ctx context.Context, p puzzle, wrong [][][]int) (solveResult, error) {
+	log := s.log.forContext(ctx)
+	model, temperature := s.retryModel(len(wrong))
+
+	prev := make([]string, 0, len(wrong))
+	for _, g := range wrong {
+		b, err := json.Marshal(g)
+		if err != nil {
+			return solveResult{}, fmt.Errorf("marshal answer: %w", err)
+		}
+		prev = append(prev, string(b))
+	}
+	query := fmt.Sprintf(`These answers to the ARC puzzle above were submitted and graded INCORRECT:
+- %s
+
+The rule that produced them is wrong or was misapplied. Re-derive the transformation rule from the training pairs, considering rules you did not use before, and answer with a different grid.
+The answer MUST be exactly %d rows × %d columns.`, strings.Join(prev, "\n- "), p.Hints.AnswerSize.Height, p.Hints.AnswerSize.Width)
+
+	fmt.Fprintf(uiOut, "%s🔁 Retrying with %s after %d incorrect answer(s)%s\n", colorCyan, model, len(wrong), colorReset)
+	spin := newSpinner()
+	spin.Start("🤔 AI re-solving...")
+	answer, err := s.askAnswerAt(ctx, p, model, query, temperature)
+	spin.Stop()
+	if err != nil {
+		return solveResult{}, err
+	}
+	if err := validateAnswerSize(p, answer.Answer); err != nil {
+		return solveResult{}, err
+	}
+	for _, g := range wrong {
+		if gridsEqual(answer.Answer, g) {
+			return solveResult{}, errors.New("model repeated an answer already graded incorrect")
+		}
+	}
+	printAnswerDetails(answer)
+	res := solveResult{Answer: answer.Answer, Model: model, Confidence: answer.Confidence, Reasoning: answer.Reasoning}
+
+	spin = newSpinner()
+	spin.Start("🔄 AI self-verifying...")
+	vr, err := s.verifyAnswer(ctx, p, answer.Answer, s.model)
+	spin.Stop()
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	if err != nil {
+		log.warnf("verification error: %v", err)
+	} else {
+		res.Verified = &vr.Valid
+		if !vr.Valid {
+			return res, errors.New("AI self-verification failed: answer does not match pattern")
+		}
+		fmt.Fprintf(uiOut, "%s✅ AI self-verification passed!%s\n", colorGreen, colorReset)
+	}
+	if s.cfg.SelfTest {
+		if err := s.selfTest(ctx, p, model, res.Reasoning); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// retryModel picks the model and temperature for the n-th retry (n >= 1).
+func (s *Solver) retryModel(n int) (string, float64) {
+	var models []string
+	seen := map[string]bool{}
+	for _, m := range append(s.models(), s.cfg.FallbackModels...) {
+		if m = strings.TrimSpace(m); m != "" && !seen[m] {
+			seen[m] = true
+			models = append(models, m)
+		}
+	}
+	model := models[n%len(models)]
+	if n < len(models) {
+		return model, 0
+	}
+	temperature := s.cfg.SampleTemperature
+	if temperature <= 0 {
+		temperature = defaultSampleTemperature
+	}
+	return model, temperature
+}
+
+// retryPuzzle is a puzzle answered incorrectly that still has attempts.
+type retryPuzzle struct {
+	puzzle *puzzleNewResponse
+	// wrong holds the answers graded incorrect so far, oldest first.
+	wrong [][][]int
+}
+
+// next records answer as graded incorrect by sub and returns the retry for
+// the following attempt; r is nil on the puzzle's first attempt.
+func (r *retryPuzzle) next(p *puzzleNewResponse, sub *puzzleSubmitResponse, answer [][]int) *retryPuzzle {
+	np := *p
+	np.RemainingAttempts = sub.RemainingAttempts
+	np.DailyRemaining, np.DailyLimit = sub.DailyRemaining, sub.DailyLimit
+	var wrong [][][]int
+	if r != nil {
+		wrong = append(wrong, r.wrong...)
+	}
+	return &retryPuzzle{puzzle: &np, wrong: append(wrong, answer)}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retry defaults, used for unset retryConfig fields.
+const (
+	defaultRetryAttempts = 6
+	defaultRetryBase     = 2 * time.Second
+	defaultRetryMax      = 30 * time.Second
+	defaultRetryJitter   = 0.2
+)
+
+// defaultRetryStatuses are the HTTP statuses retried by default.
+var defaultRetryStatuses = []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}
+
+// maxRetryAfter caps how long a server's Retry-After header can hold a
+// request back.
+const maxRetryAfter = 10 * time.Minute
+
+// retryPolicy decides whether and when a failed API call is repeated.
+type retryPolicy struct {
+	attempts      int
+	baseDelay     time.Duration
+	maxDelay      time.Duration
+	jitter        float64
+	statuses      []int
+	networkErrors bool
+	post          bool
+}
+
+func newRetryPolicy(cfg retryConfig) retryPolicy {
+	p := retryPolicy{
+		attempts:      defaultRetryAttempts,
+		baseDelay:     defaultRetryBase,
+		maxDelay:      defaultRetryMax,
+		jitter:        defaultRetryJitter,
+		statuses:      defaultRetryStatuses,
+		networkErrors: cfg.NetworkErrors == nil || *cfg.NetworkErrors,
+		post:          cfg.Post,
+	}
+	if cfg.MaxAttempts > 0 {
+		p.attempts = cfg.MaxAttempts
+	}
+	if cfg.BaseDelayMS > 0 {
+		p.baseDelay = time.Duration(cfg.BaseDelayMS) * time.Millisecond
+	}
+	if cfg.MaxDelayMS > 0 {
+		p.maxDelay = time.Duration(cfg.MaxDelayMS) * time.Millisecond
+	}
+	if cfg.Jitter != nil {
+		p.jitter = min(max(*cfg.Jitter, 0), 1)
+	}
+	if cfg.StatusCodes != nil {
+		p.statuses = cfg.StatusCodes
+	}
+	return p
+}
+
+// retryable reports whether err from a method request may be repeated. A
+// request that may have changed server state (anything but GET/HEAD) is
+// only repeated on 429, which the server rejected unprocessed, unless
+// retry.post is set.
+func (p retryPolicy) retryable(method string, err error) bool {
+	var ae *apiError
+	if errors.As(err, &ae) {
+		if !slices.Contains(p.statuses, ae.StatusCode) {
+			return false
+		}
+		return ae.StatusCode == http.StatusTooManyRequests || p.post || method == http.MethodGet || method == http.MethodHead
+	}
+	var ue *url.Error
+	if errors.As(err, &ue) && p.networkErrors {
+		return p.post || method == http.MethodGet || method == http.MethodHead
+	}
+	return false
+}
+
+// delay returns the wait before retry number n (1-based). A server
+// Retry-After is waited out exactly, plus up to jitter of it on top so
+// clients told the same time do not return in lockstep. Without one the
+// delay is exponential backoff from baseDelay capped at maxDelay, spread by
+// ±jitter.
+func (p retryPolicy) delay(n int, err error) time.Duration {
+	var ae *apiError
+	if errors.As(err, &ae) && ae.RetryAfter > 0 {
+		d := min(ae.RetryAfter, maxRetryAfter)
+		return d + time.Duration(float64(d)*p.jitter*rand.Float64())
+	}
+	d := p.baseDelay
+	for i := 1; i < n && d < p.maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.maxDelay)
+	return time.Duration(float64(d) * (1 + p.jitter*(2*rand.Float64()-1)))
+}
+
+// do runs call until it succeeds, fails with a non-retryable error, or the
+// attempts are used up.
+func (p retryPolicy) do(ctx context.Context, log *logger, method, path string, call func() error) error {
+	for n := 1; ; n++ {
+		err := call()
+		if err == nil || n >= p.attempts || ctx.Err() != nil || !p.retryable(method, err) {
+			return err
+		}
+		d := p.delay(n, err)
+		if log != nil {
+			log.forContext(ctx).warnf("%s %s failed: %v; retrying in %s (attempt %d/%d)", method, path, err, d.Round(100*time.Millisecond), n+1, p.attempts)
+		}
+		if err := sleepCtx(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date; zero means absent or unparseable.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	h = strings.TrimSpace(h)
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Review decisions.
+const (
+	reviewApprove = "approve"
+	reviewReject  = "reject"
+)
+
+// Review defaults, used for unset reviewConfig fields.
+const (
+	defaultReviewTimeout = 10 * time.Minute
+	defaultReviewPoll    = 5 * time.Second
+)
+
+// errReviewRejected marks an answer a reviewer (or the timeout) rejected.
+var errReviewRejected = errors.New("answer rejected in review")
+
+// reviewRequest is the JSON body posted to the review webhook.
+type reviewRequest struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	PuzzleID   string    `json:"puzzleId"`
+	Answer     [][]int   `json:"answer"`
+	Model      string    `json:"model,omitempty"`
+	Confidence int       `json:"confidence"`
+	Verified   *bool     `json:"verified"`
+	Reasoning  string    `json:"reasoning,omitempty"`
+	Deadline   time.Time `json:"deadline"`
+	OnTimeout  string    `json:"onTimeout"`
+	ApproveURL string    `json:"approveUrl,omitempty"`
+	RejectURL  string    `json:"rejectUrl,omitempty"`
+	Text       string    `json:"text"`
+}
+
+// reviewDecision is read from webhook and poll responses; an empty Decision
+// means still pending.
+type reviewDecision struct {
+	Decision string `json:"decision"`
+}
+
+// reviewer holds answers back until a human approves them. A nil *reviewer
+// (review not configured) approves everything at once.
+type reviewer struct {
+	cfg    reviewConfig
+	client *http.Client
+	srv    *http.Server
+	// addr is the address the callback listener is bound to.
+	addr string
+
+	mu      sync.Mutex
+	pending map[string]chan string
+}
+
+func newReviewer(cfg appConfig, log *logger) (*reviewer, error) {
+	rc := cfg.Review
+	if strings.TrimSpace(rc.WebhookURL) == "" {
+		return nil, nil
+	}
+	switch rc.OnTimeout {
+	case "", reviewApprove, reviewReject:
+	default:
+		return nil, fmt.Errorf("unknown review.on_timeout %q (want %q or %q)", rc.OnTimeout, reviewApprove, reviewReject)
+	}
+	client := &http.Client{Timeout: notifyTimeout}
+	if tr, err := proxiedTransport(cfg.Proxy); err == nil {
+		client.Transport = tr
+	}
+	r := &reviewer{cfg: rc, client: client, pending: map[string]chan string{}}
+	if rc.Listen != "" {
+		ln, err := net.Listen("tcp", rc.Listen)
+		if err != nil {
+			return nil, fmt.Errorf("review listener: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/review/{id}/{decision}", r.handleCallback)
+		r.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		r.addr = ln.Addr().String()
+		go func() { _ = r.srv.Serve(ln) }()
+		log.infof("review callbacks listening on %s", ln.Addr())
+	}
+	return r, nil
+}
+
+func (r *reviewer) close() {
+	if r != nil && r.srv != nil {
+		_ = r.srv.Close()
+	}
+}
+
+// handleCallback records an approve/reject link being followed. The review
+// ID is random and unguessable, so it doubles as the credential.
+func (r *reviewer) handleCallback(w http.ResponseWriter, req *http.Request) {
+	decision := req.PathValue("decision")
+	if decision != reviewApprove && decision != reviewReject {
+		http.NotFound(w, req)
+		return
+	}
+	r.mu.Lock()
+	ch, ok := r.pending[req.PathValue("id")]
+	r.mu.Unlock()
+	if !ok {
+		http.Error(w, "review not pending (decided or timed out)", http.StatusGone)
+		return
+	}
+	select {
+	case ch <- decision:
+	default:
+	}
+	_, _ = fmt.Fprintf(w, "%sd\n", decision)
+}
+
+// review posts res for p to the webhook and waits for a decision from the
+// webhook response, a callback or the poll URL, falling back to
+// review.on_timeout. It returns errReviewRejected for a rejected answer.
+func (r *reviewer) review(ctx context.Context, log *logger, p puzzle, res solveResult) error {
+	if r == nil {
+		return nil
+	}
+	timeout := defaultReviewTimeout
+	if r.cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(r.cfg.TimeoutSeconds) * time.Second
+	}
+	onTimeout := r.cfg.OnTimeout
+	if onTimeout == "" {
+		onTimeout = reviewReject
+	}
+	id, err := newReviewID()
+	if err != nil {
+		return err
+	}
+
+	decided := make(chan string, 1)
+	if r.srv != nil {
+		r.mu.Lock()
+		r.pending[id] = decided
+		r.mu.Unlock()
+		defer func() {
+			r.mu.Lock()
+			delete(r.pending, id)
+			r.mu.Unlock()
+		}()
+	}
+
+	rq := reviewRequest{
+		ID:         id,
+		Time:       time.Now(),
+		PuzzleID:   p.ID,
+		Answer:     res.Answer,
+		Model:      res.Model,
+		Confidence: res.Confidence,
+		Verified:   res.Verified,
+		Reasoning:  res.Reasoning,
+		Deadline:   time.Now().Add(timeout),
+		OnTimeout:  onTimeout,
+	}
+	if r.srv != nil {
+		rq.ApproveURL, rq.RejectURL = r.callbackURL(id, reviewApprove), r.callbackURL(id, reviewReject)
+	}
+	rq.Text = reviewText(rq)
+
+	var first reviewDecision
+	if err := r.send(ctx, http.MethodPost, r.cfg.WebhookURL, rq, &first); err != nil {
+		return fmt.Errorf("review webhook: %w", err)
+	}
+	log.infof("review requested: id=%s, waiting up to %s", id, timeout.Round(time.Second))
+	if d, ok := decisionOf(first); ok {
+		return r.finish(log, d, "webhook")
+	}
+
+	var poll <-chan time.Time
+	if r.cfg.PollURL != "" {
+		interval := defaultReviewPoll
+		if r.cfg.PollIntervalSeconds > 0 {
+			interval = time.Duration(r.cfg.PollIntervalSeconds) * time.Second
+		}
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		poll = t.C
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case d := <-decided:
+			return r.finish(log, d, "callback")
+		case <-poll:
+			var got reviewDecision
+			if err := r.send(ctx, http.MethodGet, strings.ReplaceAll(r.cfg.PollURL, "{id}", id), nil, &got); err != nil {
+				log.warnf("review poll: %v", err)
+				continue
+			}
+			if d, ok := decisionOf(got); ok {
+				return r.finish(log, d, "poll")
+			}
+		case <-deadline.C:
+			return r.finish(log, onTimeout, "timeout")
+		}
+	}
+}
+
+func (r *reviewer) finish(log *logger, decision, source string) error {
+	if decision == reviewApprove {
+		log.okf("review: approved (%s)", source)
+		return nil
+	}
+	log.warnf("review: rejected (%s)", source)
+	return errReviewRejected
+}
+
+// callbackURL is the link that records decision for review id.
+func (r *reviewer) callbackURL(id, decision string) string {
+	base := strings.TrimSpace(r.cfg.CallbackBaseURL)
+	if base == "" {
+		base = "http://" + r.addr
+	}
+	u, err := url.JoinPath(base, "review", id, decision)
+	if err != nil {
+		return ""
+	}
+	return u
+}
+
+// send makes one request to the review endpoint and decodes a decision
+// from a JSON response, if there is one.
+func (r *reviewer) send(ctx context.Context, method, target string, body any, out *reviewDecision) error {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal review: %w", err)
+		}
+		rd = bytes.NewReader(b)
+	}
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, target, rd)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s", target, resp.Status)
+	}
+	// Anything but a JSON decision leaves the review pending.
+	_ = json.Unmarshal(b, out)
+	return nil
+}
+
+func decisionOf(d reviewDecision) (string, bool) {
+	switch v := strings.ToLower(strings.TrimSpace(d.Decision)); v {
+	case reviewApprove, reviewReject:
+		return v, true
+	}
+	return "", false
+}
+
+// reviewText is a chat-friendly summary of rq with the answer grid and the
+// decision links.
+func reviewText(rq reviewRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔎 Review %s (%s, confidence %d%%)\n", rq.PuzzleID, rq.Model, rq.Confidence)
+	for _, row := range rq.Answer {
+		for _, v := range row {
+			fmt.Fprintf(&b, "%d", v)
+		}
+		b.WriteByte('\n')
+	}
+	if rq.ApproveURL != "" {
+		fmt.Fprintf(&b, "approve: %s\nreject: %s\n", rq.ApproveURL, rq.RejectURL)
+	}
+	fmt.Fprintf(&b, "%s at %s", rq.OnTimeout, rq.Deadline.Format(time.TimeOnly))
+	return b.String()
+}
+
+func newReviewID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("review id: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// defaultSampleTemperature is used for best-of-N samples unless
+// ai.sample_temperature is set; some spread is what makes sampling pay off.
+const defaultSampleTemperature = 1.0
+
+// sampleCandidate is one distinct grid among the samples.
+type sampleCandidate struct {
+	answer Answer
+	// votes is the number of samples that produced this grid and conf their
+	// summed confidence.
+	votes int
+	conf  int
+	// verified is nil when verification errored; objection is the
+	// verifier's reasoning.
+	verified  *bool
+	objection string
+	first     int
+}
+
+// approvals counts the verifier's approval once for every sample backing the
+// candidate; unverified and rejected candidates have none.
+func (c sampleCandidate) approvals() int {
+	if c.verified != nil && *c.verified {
+		return c.votes
+	}
+	return 0
+}
+
+// solveSampled draws n answers from the primary model at the sampling
+// temperature, verifies each distinct grid, and returns the candidate with
+// the most verifier approvals, then the highest confidence. Samples that
+// fail or contradict the size hint are dropped.
+func (s *Solver) solveSampled(ctx context.Context, p puzzle, n int) (solveResult, error) {
+	log := s.log.forContext(ctx)
+	temperature := s.cfg.SampleTemperature
+	if temperature <= 0 {
+		temperature = defaultSampleTemperature
+	}
+
+	spin := newSpinner()
+	spin.Start(fmt.Sprintf("🎲 Sampling %d answers...", n))
+	answers := make([]Answer, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			answers[i], errs[i] = s.askAnswerAt(ctx, p, s.model, solveQuery(p), temperature)
+		}(i)
+	}
+	wg.Wait()
+	spin.Stop()
+	if err := ctx.Err(); err != nil {
+		return solveResult{}, err
+	}
+
+	byGrid := map[string]*sampleCandidate{}
+	var cands []*sampleCandidate
+	var firstErr error
+	for i, a := range answers {
+		if errs[i] == nil {
+			errs[i] = validateAnswerSize(p, a.Answer)
+		}
+		if errs[i] != nil {
+			log.warnf("sample %d/%d dropped: %v", i+1, n, errs[i])
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		k := gridKey(a.Answer)
+		c, ok := byGrid[k]
+		if !ok {
+			c = &sampleCandidate{answer: a, first: i}
+			byGrid[k] = c
+			cands = append(cands, c)
+		}
+		c.votes++
+		c.conf += a.Confidence
+		if a.Confidence > c.answer.Confidence {
+			c.answer = a
+		}
+	}
+	if len(cands) == 0 {
+		return solveResult{}, firstErr
+	}
+
+	spin = newSpinner()
+	spin.Start(fmt.Sprintf("🔄 Verifying %d distinct answers...", len(cands)))
+	for _, c := range cands {
+		wg.Add(1)
+		go func(c *sampleCandidate) {
+			defer wg.Done()
+			vr, err := s.verifyAnswer(ctx, p, c.answer.Answer, s.model)
+			if err != nil {
+				log.warnf("verification error: %v", err)
+				return
+			}
+			c.verified = &vr.Valid
+			c.objection = vr.Reasoning
+		}(c)
+	}
+	wg.Wait()
+	spin.Stop()
+	if err := ctx.Err(); err != nil {
+		return solveResult{}, err
+	}
+
+	rankCandidates(cands)
+	for _, c := range cands {
+		verdict := "unverified"
+		if c.verified != nil {
+			verdict = map[bool]string{true: "approved", false: "rejected"}[*c.verified]
+		}
+		fmt.Fprintf(uiOut, "%s🎲 %s: %d/%d samples, %s, confidence %d%%%s\n", colorDim, gridDims(c.answer.Answer), c.votes, n, verdict, c.answer.Confidence, colorReset)
+	}
+	best := cands[0]
+	printAnswerDetails(best.answer)
+	res := solveResult{
+		Answer:     best.answer.Answer,
+		Model:      fmt.Sprintf("best-of-%d(%s)", n, s.model),
+		Confidence: best.answer.Confidence,
+		Reasoning:  best.answer.Reasoning,
+		Verified:   best.verified,
+	}
+	if best.verified != nil && !*best.verified {
+		answer, vr, err := s.correct(ctx, p, s.model, best.answer, VerifyResult{Reasoning: best.objection})
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		res.Answer, res.Confidence, res.Reasoning = answer.Answer, answer.Confidence, answer.Reasoning
+		if err != nil {
+			log.warnf("verification error: %v", err)
+			res.Verified = nil
+			return res, nil
+		}
+		res.Verified = &vr.Valid
+		if !vr.Valid {
+			return res, errors.New("AI self-verification failed: no sampled answer matches the pattern")
+		}
+		fmt.Fprintf(uiOut, "%s✨ Corrected answer passed verification%s\n", colorGreen, colorReset)
+		return res, nil
+	}
+	fmt.Fprintf(uiOut, "%s✨ Answer chosen: %d/%d samples agree%s\n", colorGreen, best.votes, n, colorReset)
+	return res, nil
+}
+
+// rankCandidates orders candidates by verifier approvals, then by how many
+// were not rejected, then by summed confidence, then by first appearance.
+func rankCandidates(cands []*sampleCandidate) {
+	notRejected := func(c *sampleCandidate) bool { return c.verified == nil || *c.verified }
+	sort.SliceStable(cands, func(i, j int) bool {
+		a, b := cands[i], cands[j]
+		if a.approvals() != b.approvals() {
+			return a.approvals() > b.approvals()
+		}
+		if notRejected(a) != notRejected(b) {
+			return notRejected(a)
+		}
+		if a.conf != b.conf {
+			return a.conf > b.conf
+		}
+		return a.first < b.first
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// sanityCheck rejects answers no ARC rule can produce, before an attempt is
+// spent on them: a grid that is empty or ragged, cells outside 0-9, colors
+// used nowhere in the puzzle, or an unchanged copy of the test input when
+// no training pair leaves its input unchanged. Unlike crossCheck, which
+// only flags unlikely answers for refinement, a failure here is final.
+func sanityCheck(p puzzle, answer [][]int) error {
+	if !rectangular(answer) {
+		return fmt.Errorf("sanity check: answer grid %s is empty or ragged", gridDims(answer))
+	}
+	puzzleColors := colorSet(p.TestInput)
+	for _, ex := range p.Train {
+		for c := range colorSet(ex.Input) {
+			puzzleColors[c] = true
+		}
+		for c := range colorSet(ex.Output) {
+			puzzleColors[c] = true
+		}
+	}
+	var unknown []int
+	for c := range colorSet(answer) {
+		if c < 0 || c > 9 {
+			return fmt.Errorf("sanity check: answer has cell value %d outside 0-9", c)
+		}
+		if !puzzleColors[c] {
+			unknown = append(unknown, c)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Ints(unknown)
+		return fmt.Errorf("sanity check: answer uses colors %v that appear nowhere in the puzzle", unknown)
+	}
+	changes := func(in, out [][]int) bool { return !gridsEqual(in, out) }
+	if len(p.Train) > 0 && gridsEqual(answer, p.TestInput) && allPairs(p, changes) {
+		return errors.New("sanity check: answer is the unchanged test input, but no training pair leaves its input unchanged")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const selfTestPrompt = `You apply a stated transformation rule to an ARC grid.
+
+Apply the rule exactly as written, step by step, to the input grid and output the grid it produces. Do not invent a different rule; if the rule is ambiguous, follow its most literal reading.
+
+Output ONLY the JSON object: "reasoning" describes how you applied the rule, "answer" is the output grid and "confidence" how sure you are that you applied the rule correctly (0-100).`
+
+// selfTest checks the rule behind an answer against the training pairs:
+// model gets only the rule (the answer's reasoning) and one training input
+// at a time, with the training outputs withheld, and must reproduce each
+// training output. It returns an error if the rule fails on any pair and
+// nil if it holds or the test could not be run, which is logged.
+func (s *Solver) selfTest(ctx context.Context, p puzzle, model, rule string) error {
+	log := s.log.forContext(ctx)
+	if strings.TrimSpace(rule) == "" {
+		log.warn("training self-test skipped: the answer states no rule")
+		return nil
+	}
+	if len(p.Train) == 0 {
+		return nil
+	}
+
+	spin := newSpinner()
+	spin.Start(fmt.Sprintf("🧪 Testing the rule on %d training pairs...", len(p.Train)))
+	got := make([][][]int, len(p.Train))
+	errs := make([]error, len(p.Train))
+	var wg sync.WaitGroup
+	for i, ex := range p.Train {
+		wg.Add(1)
+		go func(i int, input [][]int) {
+			defer wg.Done()
+			got[i], errs[i] = s.applyRule(ctx, p.ID, model, rule, input)
+		}(i, ex.Input)
+	}
+	wg.Wait()
+	spin.Stop()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := errors.Join(errs...); err != nil {
+		log.warnf("training self-test error: %v", err)
+		return nil
+	}
+
+	var failed []string
+	for i, ex := range p.Train {
+		if gridsEqual(got[i], ex.Output) {
+			fmt.Fprintf(uiOut, "%s🧪 Training pair %d: reproduced%s\n", colorDim, i+1, colorReset)
+			continue
+		}
+		fmt.Fprintf(uiOut, "%s🧪 Training pair %d: %s%s\n", colorYellow, i+1, gridDiff(got[i], ex.Output), colorReset)
+		failed = append(failed, fmt.Sprint(i+1))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("training self-test failed: the rule does not reproduce training pair(s) %s", strings.Join(failed, ", "))
+	}
+	fmt.Fprintf(uiOut, "%s✅ Training self-test passed: the rule reproduces all %d pairs%s\n", colorGreen, len(p.Train), colorReset)
+	return nil
+}
+
+// applyRule asks model to apply rule to input, showing it nothing else of
+// the puzzle.
+func (s *Solver) applyRule(ctx context.Context, puzzleID, model, rule string, input [][]int) ([][]int, error) {
+	in, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("marshal input: %w", err)
+	}
+	prompt := chatPrompt{
+		system:   selfTestPrompt,
+		puzzle:   "Rule:\n" + rule,
+		query:    "Input grid:\n" + string(in) + "\n\nApply the rule to this input grid.",
+		cacheKey: puzzleID,
+	}
+	content, err := s.complete(ctx, model, prompt, "arc_answer", "Grid produced by applying the rule", arcAnswerSchema)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAIUnavailable, err)
+	}
+	answer, err := s.answerFromReply(ctx, model, prompt, "Grid produced by applying the rule", arcAnswerSchema, content)
+	if err != nil {
+		return nil, err
+	}
+	return answer.Answer, nil
+}
+
+// gridDiff describes how got differs from want.
+func gridDiff(got, want [][]int) string {
+	if gridDims(got) != gridDims(want) {
+		return fmt.Sprintf("got %s, want %s", gridDims(got), gridDims(want))
+	}
+	n, total := 0, 0
+	for r := range want {
+		for c := range want[r] {
+			total++
+			if c >= len(got[r]) || got[r][c] != want[r][c] {
+				n++
+			}
+		}
+	}
+	return fmt.Sprintf("%d of %d cells differ", n, total)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// session bundles an authenticated API client with the config it was built
+// from, so commands share login, cookie persistence and re-authentication.
+type session struct {
+	cfg    appConfig
+	runDir string
+	log    *logger
+	client *apiClient
+	me     *authMeResponse
+	// latency, when set, is handed to every client the session adopts.
+	latency *latencyTracker
+	// notify reports logins that need a fresh cookie, and the puzzle
+	// outcomes of the run.
+	notify *notifier
+}
+
+// openSession loads the config, makes sure the stored cookie is valid
+// (prompting for a new one if not) and returns a logged-in session. notify
+// is the process's notifier; when nil the session makes one from the config.
+func openSession(ctx context.Context, configPath string, log *logger, notify *notifier) (*session, error) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range lintConfig(configPath, cfg) {
+		log.warnf("config: %s", w)
+	}
+	runDir := runDirFor(homeDir(configPath), time.Now())
+	if notify == nil {
+		notify = newNotifier(cfg, log)
+	}
+	cfg, client, me, err := ensureLoginInteractive(ctx, cfg, log, notify)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &session{
+		cfg:    cfg,
+		runDir: runDir,
+		log:    log,
+		me:     me,
+		notify: notify,
+	}
+	s.adopt(client)
+	s.persist(log)
+	checkCertPin(client, log)
+	log.okf("logged in: %s(%s)", me.User.Username, me.User.ID)
+	return s, nil
+}
+
+// adopt makes c the session's client, archiving failed responses into this
+// run's directory.
+func (s *session) adopt(c *apiClient) {
+	c.archiveDir = s.runDir
+	c.log = s.log
+	c.latency = s.latency
+	s.client = c
+}
+
+// reauth prompts for fresh auth material and reconnects.
+func (s *session) reauth(ctx context.Context, log *logger) error {
+	log.warn("auth expired, re-authenticating...")
+	cfg, client, me, err := ensureLoginInteractive(ctx, s.cfg, log, s.notify)
+	if err != nil {
+		return err
+	}
+	s.cfg = cfg
+	s.me = me
+	s.adopt(client)
+	return nil
+}
+
+// persist saves refreshed cookies to the login state file. Failures are
+// non-fatal: the in-memory session keeps working.
+func (s *session) persist(log *logger) {
+	_ = persistCookieIfChanged(&s.cfg, s.client, log)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stateFileName holds the refreshed login state inside the runtime home, so
+// config.json is only ever read.
+const stateFileName = "state.json"
+
+// loginState is the mutable part of a session: the cookie jar (including
+// the PoW token cookie), the user agent it was issued to and the site it
+// belongs to.
+type loginState struct {
+	SavedAt   time.Time `json:"savedAt"`
+	Cookie    string    `json:"cookie"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	// BaseURL is the site taken from a pasted curl command; it stands in
+	// for base_url when the config has none.
+	BaseURL string `json:"baseUrl,omitempty"`
+	// Seed is the config cookie this state grew from. When config.json
+	// carries a different cookie, the user replaced it and the state is
+	// stale.
+	Seed string `json:"seed"`
+}
+
+func statePath(home string) string {
+	return filepath.Join(home, stateFileName)
+}
+
+// loadLoginState reads the state at path; a missing file yields the zero
+// state.
+func loadLoginState(path string) (loginState, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return loginState{}, nil
+	}
+	if err != nil {
+		return loginState{}, fmt.Errorf("read state: %w", err)
+	}
+	var st loginState
+	if err := json.Unmarshal(b, &st); err != nil {
+		return loginState{}, fmt.Errorf("parse state %s: %w", path, err)
+	}
+	return st, nil
+}
+
+// applyLoginState overlays the saved cookie and user agent on cfg unless
+// config.json has since been given a different cookie. The saved base URL
+// is used whenever config.json has none.
+func applyLoginState(cfg *appConfig, st loginState) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = st.BaseURL
+	}
+	if st.Cookie == "" || st.Seed != cfg.Cookie {
+		return
+	}
+	cfg.Cookie = st.Cookie
+	if st.UserAgent != "" {
+		cfg.UserAgent = st.UserAgent
+	}
+}
+
+// saveLoginState records cfg's cookie, user agent and base URL in the state
+// file of cfg.home.
+func saveLoginState(cfg appConfig) error {
+	st := loginState{
+		SavedAt:   time.Now(),
+		Cookie:    strings.TrimSpace(cfg.Cookie),
+		UserAgent: cfg.UserAgent,
+		BaseURL:   cfg.BaseURL,
+		Seed:      cfg.seedCookie,
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+	path := statePath(cfg.home)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("mkdir state dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write temp state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace state: %w", err)
+	}
+	return nil
+}
+
+// checkReadOnlyConfig backs --read-only-config: it fails before any work
+// is done if a file the run writes (the state files, or logFile) is the
+// config itself, or if the state directory is not writable, which would
+// otherwise surface only at the first cookie refresh.
+func checkReadOnlyConfig(configPath, logFile string) error {
+	cfgReal, err := realPath(configPath)
+	if err != nil {
+		return fmt.Errorf("resolve config path: %w", err)
+	}
+	home := homeDir(configPath)
+	written := []string{logFile}
+	for _, name := range []string{stateFileName, checkpointFileName, historyFileName, historyJSONLFileName, knownHostsFile} {
+		written = append(written, filepath.Join(home, name))
+	}
+	for _, path := range written {
+		if path == "" {
+			continue
+		}
+		if p, err := realPath(path); err == nil && p == cfgReal {
+			return fmt.Errorf("read-only config: %s would be written to; set ERGO_PROXY_HOME or --log-file elsewhere", configPath)
+		}
+	}
+	if err := os.MkdirAll(home, 0o755); err != nil {
+		return fmt.Errorf("read-only config: state directory %s: %w (set ERGO_PROXY_HOME to a writable directory)", home, err)
+	}
+	f, err := os.CreateTemp(home, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("read-only config: state directory %s is not writable (set ERGO_PROXY_HOME to a writable directory): %w", home, err)
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	return nil
+}
+
+// realPath resolves path to an absolute path with symlinks followed, so two
+// names of one file compare equal. A file that does not exist yet resolves
+// to its absolute path.
+func realPath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return filepath.Abs(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(resolved)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// statusSocketName is the Unix socket a running solve loop listens on.
+const statusSocketName = "ergo-solver.sock"
+
+// Solver phases reported over the status socket.
+const (
+	phaseStarting   = "starting"
+	phaseLogin      = "login"
+	phasePow        = "pow"
+	phaseFetching   = "fetching"
+	phaseSolving    = "solving"
+	phaseReviewing  = "reviewing"
+	phaseSubmitting = "submitting"
+	phaseSleeping   = "sleeping"
+	phasePaused     = "paused"
+	phaseAICircuit  = "ai_circuit_open"
+	// phaseWaiting is a daemon or --auto process between passes.
+	phaseWaiting = "waiting"
+)
+
+// statusSnapshot is the JSON document served to `ergo-solver status`.
+type statusSnapshot struct {
+	PID            int       `json:"pid"`
+	Phase          string    `json:"phase"`
+	PuzzleID       string    `json:"puzzleId,omitempty"`
+	Solved         int       `json:"solved"`
+	Target         int       `json:"target"`
+	DailyRemaining int       `json:"dailyRemaining"`
+	DailyLimit     int       `json:"dailyLimit"`
+	StartedAt      time.Time `json:"startedAt"`
+	Uptime         string    `json:"uptime"`
+}
+
+// runStatus tracks the live state of a solve loop for the status socket.
+type runStatus struct {
+	mu   sync.Mutex
+	snap statusSnapshot
+}
+
+func newRunStatus() *runStatus {
+	return &runStatus{snap: statusSnapshot{
+		PID:            os.Getpid(),
+		Phase:          phaseStarting,
+		DailyRemaining: -1,
+		DailyLimit:     -1,
+		StartedAt:      time.Now(),
+	}}
+}
+
+func (s *runStatus) setPhase(phase string) {
+	s.mu.Lock()
+	s.snap.Phase = phase
+	s.mu.Unlock()
+}
+
+func (s *runStatus) setPuzzle(id string) {
+	s.mu.Lock()
+	s.snap.PuzzleID = id
+	s.mu.Unlock()
+}
+
+func (s *runStatus) setProgress(solved, target int) {
+	s.mu.Lock()
+	s.snap.Solved = solved
+	s.snap.Target = target
+	s.mu.Unlock()
+}
+
+func (s *runStatus) setQuota(remaining, limit int) {
+	s.mu.Lock()
+	s.snap.DailyRemaining = remaining
+	s.snap.DailyLimit = limit
+	s.mu.Unlock()
+}
+
+func (s *runStatus) snapshot() statusSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.snap
+	out.Uptime = time.Since(out.StartedAt).Round(time.Second).String()
+	return out
+}
+
+// startStatus serves a new runStatus on the status socket of home until the
+// returned func is called. A socket that cannot be opened only disables
+// status queries.
+func startStatus(home string, log *logger) (*runStatus, func()) {
+	st := newRunStatus()
+	closeStatus, err := serveStatus(home, st, log)
+	if err != nil {
+		log.warnf("status socket disabled: %v", err)
+		return st, func() {}
+	}
+	return st, closeStatus
+}
+
+// serveStatus listens on home/ergo-solver.sock and writes one JSON snapshot
+// per connection. The returned func closes the listener and removes the
+// socket file.
+func serveStatus(home string, st *runStatus, log *logger) (func(), error) {
+	path := filepath.Join(home, statusSocketName)
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("another instance is serving status on %s", path)
+		}
+		_ = os.Remove(path)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen status socket: %w", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.warnf("status socket: %v", err)
+				}
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+			_ = json.NewEncoder(conn).Encode(st.snapshot())
+			_ = conn.Close()
+		}
+	}()
+
+	return func() {
+		_ = ln.Close()
+		_ = os.Remove(path)
+	}, nil
+}
+
+func runStatusCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet(cmdStatus, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath string
+		asJSON     bool
+	)
+	fs.StringVar(&configPath, "config", "", "config path (locates the runtime directory)")
+	fs.BoolVar(&asJSON, "json", false, "print raw JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	path := filepath.Join(homeDir(configPath), statusSocketName)
+	var d net.Dialer
+	dctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	conn, err := d.DialContext(dctx, "unix", path)
+	if err != nil {
+		return fmt.Errorf("no running instance found at %s: %w", path, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	b, err := io.ReadAll(io.LimitReader(conn, 64*1024))
+	if err != nil {
+		return fmt.Errorf("read status: %w", err)
+	}
+	if asJSON {
+		_, _ = os.Stdout.Write(b)
+		return nil
+	}
+
+	var snap statusSnapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return fmt.Errorf("parse status: %w", err)
+	}
+	printStatus(os.Stdout, snap)
+	return nil
+}
+
+func printStatus(w io.Writer, snap statusSnapshot) {
+	puzzleID := snap.PuzzleID
+	if puzzleID == "" {
+		puzzleID = "-"
+	}
+	quota := "unknown"
+	if snap.DailyLimit >= 0 {
+		quota = fmt.Sprintf("%d/%d remaining", snap.DailyRemaining, snap.DailyLimit)
+	}
+	_, _ = fmt.Fprintf(w, "pid:      %d\n", snap.PID)
+	_, _ = fmt.Fprintf(w, "phase:    %s\n", snap.Phase)
+	_, _ = fmt.Fprintf(w, "puzzle:   %s\n", puzzleID)
+	_, _ = fmt.Fprintf(w, "solved:   %d/%d\n", snap.Solved, snap.Target)
+	_, _ = fmt.Fprintf(w, "quota:    %s\n", quota)
+	_, _ = fmt.Fprintf(w, "uptime:   %s (since %s)\n", snap.Uptime, snap.StartedAt.Format(time.RFC3339))
+}
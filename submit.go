@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadAnswerFile reads an answer grid from path ("-" for stdin). Both a bare
+// [[...]] grid and an object ({"answer": [[...]], "id": "..."}) are
+// accepted; the object's optional id is returned as the puzzle ID.
+func loadAnswerFile(path string) ([][]int, string, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if path == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("read answer: %w", err)
+	}
+
+	var (
+		grid [][]int
+		id   string
+	)
+	if err := json.Unmarshal(b, &grid); err != nil {
+		var obj struct {
+			ID     string  `json:"id"`
+			Answer [][]int `json:"answer"`
+		}
+		if err2 := json.Unmarshal(b, &obj); err2 != nil || len(obj.Answer) == 0 {
+			return nil, "", fmt.Errorf("parse answer %s: expected [[...]] or {\"answer\": [[...]]}", path)
+		}
+		grid, id = obj.Answer, obj.ID
+	}
+	if err := checkGridShape(grid); err != nil {
+		return nil, "", fmt.Errorf("answer %s: %w", path, err)
+	}
+	return grid, id, nil
+}
+
+// checkGridShape verifies grid is non-empty, rectangular and uses colors 0-9.
+func checkGridShape(grid [][]int) error {
+	if len(grid) == 0 || len(grid[0]) == 0 {
+		return errors.New("empty grid")
+	}
+	w := len(grid[0])
+	for i, row := range grid {
+		if len(row) != w {
+			return fmt.Errorf("row %d has %d cells, want %d", i, len(row), w)
+		}
+		for j, v := range row {
+			if v < 0 || v > 9 {
+				return fmt.Errorf("cell (%d,%d) has color %d outside 0-9", i, j, v)
+			}
+		}
+	}
+	return nil
+}
+
+func runSubmit(ctx context.Context, log *logger, args []string) error {
+	fs := flag.NewFlagSet(cmdSubmit, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath string
+		puzzleID   string
+		answerPath string
+		force      bool
+	)
+	fs.StringVar(&configPath, "config", "", "config path (required)")
+	fs.StringVar(&puzzleID, "puzzle-id", "", "puzzle ID to submit for (default: the answer file's id)")
+	fs.StringVar(&answerPath, "answer", "", "answer JSON file, or - for stdin (required)")
+	fs.BoolVar(&force, "force", false, "submit even if the answer contradicts the saved puzzle's size hint")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case configPath == "":
+		return errors.New("--config is required")
+	case answerPath == "":
+		return errors.New("--answer is required")
+	}
+
+	answer, fileID, err := loadAnswerFile(answerPath)
+	if err != nil {
+		return err
+	}
+	puzzleID = strings.TrimSpace(puzzleID)
+	if puzzleID == "" {
+		puzzleID = strings.TrimSpace(fileID)
+	}
+	if puzzleID == "" {
+		return errors.New("--puzzle-id is required (the answer file has no id)")
+	}
+	log.infof("answer loaded: puzzleId=%s %s", puzzleID, gridDims(answer))
+
+	// A puzzle saved by fetch carries the size hint; catching a mismatch
+	// here saves a submission attempt.
+	if path, err := findSavedPuzzle(filepath.Join(homeDir(configPath), puzzlesDirName), puzzleID); err == nil {
+		if p, err := loadPuzzleFile(path); err == nil {
+			if err := validateAnswerSize(p, answer); err != nil {
+				if !force {
+					return fmt.Errorf("answer does not match saved puzzle %s: %v (use --force to submit anyway)", path, err)
+				}
+				log.warnf("answer size mismatch (forced): %v", err)
+			}
+		}
+	}
+
+	sess, err := openSession(ctx, configPath, log, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := ensurePow(ctx, sess.client, log); err != nil {
+		return err
+	}
+	sess.persist(log)
+
+	log.infof("submitting: puzzleId=%s", puzzleID)
+	sub, err := sess.client.puzzleSubmit(ctx, puzzleID, answer)
+	if err != nil {
+		if isAuthError(err) {
+			if err := sess.reauth(ctx, log); err != nil {
+				return err
+			}
+			sub, err = sess.client.puzzleSubmit(ctx, puzzleID, answer)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	sess.persist(log)
+
+	if !sub.Success {
+		return fmt.Errorf("submit failed: %s", sub.Message)
+	}
+	log.infof("submit response: %s", sub.Message)
+	if !sub.Correct {
+		log.warnf("incorrect: remainingAttempts=%d", sub.RemainingAttempts)
+		return errors.New("submitted answer was incorrect")
+	}
+	log.okf("correct: +%d points, balance=%d, dailyRemaining=%d/%d", sub.PointsAwarded, sub.PointsBalance, sub.DailyRemaining, sub.DailyLimit)
+	return nil
+}
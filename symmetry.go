@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// gridSymmetries are the symmetries symmetrySummary checks, each as the
+// transform that leaves a symmetric grid unchanged. The diagonal ones and
+// the 90° rotation apply to square grids only.
+var gridSymmetries = []struct {
+	name   string
+	square bool
+	apply  func([][]int) [][]int
+}{
+	{"left-right mirror", false, flipH},
+	{"top-bottom mirror", false, flipV},
+	{"180° rotation", false, func(g [][]int) [][]int { return flipV(flipH(g)) }},
+	{"main-diagonal mirror", true, transpose},
+	{"anti-diagonal mirror", true, func(g [][]int) [][]int { return flipV(flipH(transpose(g))) }},
+	{"90° rotation", true, rotateCW},
+}
+
+// symmetrySummary states the symmetries and repetition periods of every
+// grid of p for the prompt. A grid that is symmetric except for cells of
+// one color, as in puzzles that ask for a masked area to be completed, is
+// reported with that color and the number of cells that break symmetry.
+func symmetrySummary(p puzzle) string {
+	var b strings.Builder
+	b.WriteString("## Symmetries (checked locally; periods are the smallest shift after which the rows or columns repeat):\n")
+	for i, ex := range p.Train {
+		fmt.Fprintf(&b, "Training pair %d input: %s\n", i+1, describeSymmetry(ex.Input))
+		fmt.Fprintf(&b, "Training pair %d output: %s\n", i+1, describeSymmetry(ex.Output))
+	}
+	fmt.Fprintf(&b, "Test input: %s", describeSymmetry(p.TestInput))
+	return b.String()
+}
+
+func describeSymmetry(g [][]int) string {
+	if !rectangular(g) {
+		return "none"
+	}
+	var facts []string
+	for _, sym := range gridSymmetries {
+		// A single row or column is its own mirror image.
+		if len(g) < 2 || len(g[0]) < 2 || sym.square && len(g) != len(g[0]) {
+			continue
+		}
+		switch n, color, ok := symmetryBreaks(g, sym.apply(g)); {
+		case ok && n == 0:
+			facts = append(facts, sym.name)
+		case ok:
+			facts = append(facts, fmt.Sprintf("%s apart from %d cells of color %d", sym.name, n, color))
+		}
+	}
+	if py := period(len(g), func(a, b int) bool { return gridsEqual(g[a:a+1], g[b:b+1]) }); py > 0 {
+		facts = append(facts, fmt.Sprintf("rows repeat every %d", py))
+	}
+	if px := period(len(g[0]), func(a, b int) bool {
+		for _, row := range g {
+			if row[a] != row[b] {
+				return false
+			}
+		}
+		return true
+	}); px > 0 {
+		facts = append(facts, fmt.Sprintf("columns repeat every %d", px))
+	}
+	if len(facts) == 0 {
+		return "none"
+	}
+	return strings.Join(facts, "; ")
+}
+
+// symmetryBreaks compares g with its image t under a symmetry. It reports
+// ok with n == 0 when they are equal. When every difference involves one
+// color, on either side, and at most a quarter of the cells differ, it
+// reports ok with that color and the number of its cells that break the
+// symmetry. Otherwise g is not symmetric.
+func symmetryBreaks(g, t [][]int) (n, color int, ok bool) {
+	// candidates holds the colors every difference so far involves.
+	var candidates []int
+	total := 0
+	for r, row := range g {
+		for c, v := range row {
+			total++
+			w := t[r][c]
+			if v == w {
+				continue
+			}
+			if n == 0 {
+				candidates = []int{v, w}
+			}
+			n++
+			candidates = slices.DeleteFunc(candidates, func(k int) bool { return k != v && k != w })
+			if len(candidates) == 0 {
+				return 0, 0, false
+			}
+		}
+	}
+	if n == 0 {
+		return 0, 0, true
+	}
+	if n > total/4 {
+		return 0, 0, false
+	}
+	// Two candidates remain when every difference swaps the same two
+	// colors; the rarer one is more likely the mask.
+	color = candidates[0]
+	if len(candidates) == 2 && colorCount(g, candidates[1]) < colorCount(g, color) {
+		color = candidates[1]
+	}
+	n = 0
+	for r, row := range g {
+		for c, v := range row {
+			if v == color && v != t[r][c] {
+				n++
+			}
+		}
+	}
+	return n, color, true
+}
+
+func colorCount(g [][]int, color int) int {
+	n := 0
+	for _, row := range g {
+		for _, v := range row {
+			if v == color {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// period returns the smallest shift p, at most half of n, such that
+// same(i, i+p) holds for every i, or 0 if there is none.
+func period(n int, same func(a, b int) bool) int {
+	for p := 1; 2*p <= n; p++ {
+		ok := true
+		for i := 0; i+p < n && ok; i++ {
+			ok = same(i, i+p)
+		}
+		if ok {
+			return p
+		}
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// telegramAPI is the Bot API endpoint messages are sent through.
+var telegramAPI = "https://api.telegram.org"
+
+// telegramMaxText is the longest message Telegram accepts, in characters.
+const telegramMaxText = 4096
+
+// postTelegram sends ev's text to the configured chat with the Bot API's
+// sendMessage.
+func (n *notifier) postTelegram(ctx context.Context, ev notifyEvent) error {
+	tg := n.cfg.Telegram
+	text := ev.Text
+	if r := []rune(text); len(r) > telegramMaxText {
+		text = string(r[:telegramMaxText-1]) + "…"
+	}
+	b, err := json.Marshal(map[string]any{
+		"chat_id":                  strings.TrimSpace(tg.ChatID),
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal telegram message: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	endpoint := telegramAPI + "/bot" + strings.TrimSpace(tg.Token) + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		// The error would quote the URL, and with it the token.
+		return errors.New("telegram: invalid bot token")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("telegram: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var out struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&out)
+	if resp.StatusCode/100 != 2 || !out.OK {
+		if out.Description != "" {
+			return fmt.Errorf("telegram returned %s: %s", resp.Status, out.Description)
+		}
+		return fmt.Errorf("telegram returned %s", resp.Status)
+	}
+	return nil
+}
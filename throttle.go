@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// digestLines is how many events a digest lists before summing up the rest.
+const digestLines = 15
+
+// dispatcher delivers the events of one notification channel under its
+// throttle settings: auth_required and run summaries at once, puzzle events
+// at once or collected into a digest, and no more messages in any hour than
+// the cap allows.
+type dispatcher struct {
+	send    func(context.Context, notifyEvent) error
+	log     *logger
+	every   time.Duration
+	perHour int
+
+	mu      sync.Mutex
+	pending []notifyEvent
+	// sent holds the delivery times of the last hour, oldest first.
+	sent []time.Time
+	// stop and done run the flush loop, started with the first held event.
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newDispatcher(t throttleConfig, log *logger, send func(context.Context, notifyEvent) error) *dispatcher {
+	d := &dispatcher{send: send, log: log, perHour: t.MaxPerHour}
+	if t.DigestMinutes > 0 {
+		d.every = time.Duration(t.DigestMinutes) * time.Minute
+	}
+	return d
+}
+
+// dispatch sends ev now or holds it for the next digest. Failed deliveries
+// are logged.
+func (d *dispatcher) dispatch(ctx context.Context, log *logger, ev notifyEvent) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	urgent := ev.Event == eventAuthRequired || ev.Event == eventRunFinished
+	if !urgent && (d.every > 0 || !d.allow(time.Now())) {
+		d.pending = append(d.pending, ev)
+		if d.stop == nil {
+			d.stop, d.done = make(chan struct{}), make(chan struct{})
+			go d.loop()
+		}
+		d.mu.Unlock()
+		return
+	}
+	d.sent = append(d.sent, time.Now())
+	d.mu.Unlock()
+	if err := d.send(ctx, ev); err != nil {
+		log.warnf("notification failed: %v", err)
+	}
+}
+
+// allow reports whether the hourly cap has room for another message at now,
+// forgetting deliveries older than an hour. d.mu must be held.
+func (d *dispatcher) allow(now time.Time) bool {
+	old := 0
+	for old < len(d.sent) && now.Sub(d.sent[old]) >= time.Hour {
+		old++
+	}
+	d.sent = d.sent[old:]
+	return d.perHour <= 0 || len(d.sent) < d.perHour
+}
+
+// loop flushes held events every digest interval or, with only a cap, each
+// minute until the cap has room again.
+func (d *dispatcher) loop() {
+	defer close(d.done)
+	every := d.every
+	if every <= 0 {
+		every = time.Minute
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-t.C:
+			d.flush(context.Background(), false)
+		}
+	}
+}
+
+// flush sends the held events as one digest; unless force, only when the
+// cap has room.
+func (d *dispatcher) flush(ctx context.Context, force bool) {
+	d.mu.Lock()
+	if len(d.pending) == 0 || (!d.allow(time.Now()) && !force) {
+		d.mu.Unlock()
+		return
+	}
+	evs := d.pending
+	d.pending = nil
+	d.sent = append(d.sent, time.Now())
+	d.mu.Unlock()
+	if err := d.send(ctx, digestEvent(evs)); err != nil {
+		d.log.warnf("notification digest failed: %v", err)
+	}
+}
+
+// close stops the flush loop and sends whatever is still held, over the cap
+// if need be, so a run never ends with events unsent.
+func (d *dispatcher) close(ctx context.Context) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	stop, done := d.stop, d.done
+	d.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	d.flush(context.WithoutCancel(ctx), true)
+}
+
+// digestEvent bundles evs into one event whose text counts the outcomes and
+// lists the first digestLines of them. A lone event is sent as itself.
+func digestEvent(evs []notifyEvent) notifyEvent {
+	if len(evs) == 1 {
+		return evs[0]
+	}
+	ev := notifyEvent{Event: eventDigest, Time: time.Now(), Events: evs}
+	counts := map[string]int{}
+	for _, e := range evs {
+		counts[e.Event]++
+		ev.Points += e.Points
+	}
+	var parts []string
+	if n := counts[eventPuzzleCorrect]; n > 0 {
+		parts = append(parts, fmt.Sprintf("%d correct (+%d points)", n, ev.Points))
+	}
+	for _, c := range []struct {
+		event, label string
+	}{{eventPuzzleIncorrect, "incorrect"}, {eventPuzzleFailed, "failed"}, {eventPuzzleDryRun, "dry run"}} {
+		if n := counts[c.event]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, c.label))
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 %d puzzles since %s: %s", len(evs), evs[0].Time.Format(time.TimeOnly), strings.Join(parts, ", "))
+	for i, e := range evs {
+		if i == digestLines {
+			fmt.Fprintf(&b, "\n… and %d more", len(evs)-digestLines)
+			break
+		}
+		b.WriteString("\n" + e.Text)
+	}
+	ev.Text = b.String()
+	return ev
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// knownHostsFile stores trust-on-first-use certificate pins under home.
+const knownHostsFile = "known_hosts.json"
+
+// TLS pin modes (tls_pin).
+const (
+	pinWarn   = "warn"
+	pinRefuse = "refuse"
+	pinOff    = "off"
+)
+
+// pinEntry records the certificate first seen for a host.
+type pinEntry struct {
+	SHA256    string    `json:"sha256"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// pinStore is the on-disk set of pinned hosts.
+type pinStore struct {
+	path  string
+	Hosts map[string]pinEntry `json:"hosts"`
+}
+
+func loadPinStore(home string) (*pinStore, error) {
+	s := &pinStore{path: filepath.Join(home, knownHostsFile), Hosts: map[string]pinEntry{}}
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", knownHostsFile, err)
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", knownHostsFile, err)
+	}
+	if s.Hosts == nil {
+		s.Hosts = map[string]pinEntry{}
+	}
+	return s, nil
+}
+
+func (s *pinStore) save() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append(b, '\n'), 0o600)
+}
+
+// certFingerprint returns the hex SHA-256 of the DER certificate.
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// pinMode normalizes the configured tls_pin value.
+func pinMode(v string) string {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case pinRefuse:
+		return pinRefuse
+	case pinOff:
+		return pinOff
+	default:
+		return pinWarn
+	}
+}
+
+// verifyPinned is installed as tls.Config.VerifyConnection. It records the
+// peer's leaf fingerprint and, in refuse mode, aborts the handshake on a
+// mismatch so no cookie is ever sent to the changed endpoint.
+func (c *apiClient) verifyPinned(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	fp := certFingerprint(cs.PeerCertificates[0])
+	c.pinMu.Lock()
+	c.peerFP = fp
+	c.pinMu.Unlock()
+	if c.pinMode == pinRefuse && c.pinned != "" && fp != c.pinned {
+		return fmt.Errorf("TLS certificate for %s changed (pinned %s, got %s); refusing to send cookies. If the change is expected, remove the host from %s",
+			c.baseURLParsed.Host, shortFP(c.pinned), shortFP(fp), filepath.Join(c.home, knownHostsFile))
+	}
+	return nil
+}
+
+// peerFingerprint returns the leaf fingerprint seen on the last handshake.
+func (c *apiClient) peerFingerprint() string {
+	c.pinMu.Lock()
+	defer c.pinMu.Unlock()
+	return c.peerFP
+}
+
+func shortFP(fp string) string {
+	if len(fp) > 16 {
+		return fp[:16] + "…"
+	}
+	return fp
+}
+
+// checkCertPin pins the site's certificate after the first successful login
+// and warns loudly when it later changes. In refuse mode a change never gets
+// this far: the handshake itself fails.
+func checkCertPin(c *apiClient, log *logger) {
+	if c.pinMode == pinOff || c.home == "" {
+		return
+	}
+	fp := c.peerFingerprint()
+	if fp == "" {
+		return
+	}
+	store, err := loadPinStore(c.home)
+	if err != nil {
+		log.warnf("certificate pinning disabled: %v", err)
+		return
+	}
+	host := c.baseURLParsed.Host
+	old, ok := store.Hosts[host]
+	switch {
+	case !ok:
+		store.Hosts[host] = pinEntry{SHA256: fp, FirstSeen: time.Now()}
+		log.infof("pinned TLS certificate for %s (sha256 %s)", host, shortFP(fp))
+	case old.SHA256 != fp:
+		log.warn("!!! ========================================================== !!!")
+		log.warnf("!!! TLS certificate for %s CHANGED since %s", host, old.FirstSeen.Format(time.RFC3339))
+		log.warnf("!!! pinned %s, now %s", shortFP(old.SHA256), shortFP(fp))
+		log.warn("!!! expected after a certificate renewal; otherwise the endpoint may be hijacked")
+		log.warn("!!! set \"tls_pin\": \"refuse\" to block requests on changes instead")
+		log.warn("!!! ========================================================== !!!")
+		store.Hosts[host] = pinEntry{SHA256: fp, FirstSeen: time.Now()}
+	default:
+		return
+	}
+	if err := store.save(); err != nil {
+		log.warnf("save %s: %v", knownHostsFile, err)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type traceKey struct{}
+
+// newTraceID returns a short random hex identifier.
+func newTraceID() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// withTraceID returns a context carrying the per-puzzle trace ID.
+func withTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceKey{}, id)
+}
+
+// traceIDFrom returns the trace ID stored in ctx, or "".
+func traceIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(traceKey{}).(string)
+	return id
+}
+
+// newRequestID returns an ID for a single API call. Calls made on behalf of
+// a puzzle are prefixed with its trace ID so server-side logs can be joined
+// with ours.
+func newRequestID(ctx context.Context) string {
+	id := newTraceID()
+	if trace := traceIDFrom(ctx); trace != "" {
+		return trace + "-" + id
+	}
+	return id
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// runTUI solves puzzles with a human in the loop: each puzzle is solved by
+// the AI as usual, then shown in a full-screen editor with the proposed
+// answer loaded, where cells can be changed before the answer is submitted.
+func runTUI(ctx context.Context, log *logger, args []string) error {
+	fs := flag.NewFlagSet(cmdTUI, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath string
+		count      int
+		dryRun     bool
+		noAI       bool
+	)
+	fs.StringVar(&configPath, "config", "", "config path (required)")
+	fs.IntVar(&count, "count", 1, "how many puzzles to solve")
+	fs.BoolVar(&dryRun, "dry-run", false, "edit answers but do not submit")
+	fs.BoolVar(&noAI, "no-ai", false, "start from an empty grid instead of the AI's answer")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		return errors.New("--config is required")
+	}
+	if count <= 0 {
+		return errors.New("--count must be > 0")
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("tui needs a terminal on stdin and stdout (use solve --manual for piped input)")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	_, err := runOnline(ctx, log, onlineRun{
+		configPath: configPath,
+		count:      count,
+		dryRun:     dryRun,
+		manual:     noAI,
+		tui:        true,
+	})
+	return stoppedBySignal(ctx, log, err)
+}
+
+// newTUISolver returns a solve function that opens the full-screen editor on
+// each puzzle, loaded with the answer of ai. Without ai, or when it fails,
+// the editor starts from the hinted size as the manual editor does. An
+// answer the human leaves unchanged keeps the AI's result; an edited one is
+// recorded with model "hybrid".
+func newTUISolver(ai func(context.Context, puzzle) (solveResult, error)) func(context.Context, puzzle) (solveResult, error) {
+	return func(ctx context.Context, p puzzle) (solveResult, error) {
+		res := solveResult{Answer: initialAnswerGrid(p), Model: "manual"}
+		msg := ""
+		if ai != nil {
+			proposed, err := ai(ctx, p)
+			switch {
+			case ctx.Err() != nil:
+				return proposed, ctx.Err()
+			case err != nil:
+				msg = fmt.Sprintf("AI failed: %v", err)
+			default:
+				res = proposed
+				msg = fmt.Sprintf("AI answer from %s loaded", proposed.Model)
+			}
+		}
+		ed := newTUIEditor(p, res.Answer, colorEnabled(os.Stdout))
+		ed.msg = msg
+		grid, err := ed.run(ctx, os.Stdin, os.Stdout)
+		if err != nil {
+			return res, err
+		}
+		switch {
+		case res.Model == "manual":
+			res.Answer = grid
+		case !gridsEqual(grid, res.Answer):
+			res = solveResult{Answer: grid, Model: "hybrid"}
+		}
+		return res, nil
+	}
+}
+
+// tuiKeyMap holds the editor's key bindings. Move stands for Up to Right in
+// the help line and is not matched itself.
+type tuiKeyMap struct {
+	Up, Down, Left, Right key.Binding
+	Move                  key.Binding
+	Cell, Paint, Pick     key.Binding
+	Fill                  key.Binding
+	Wider, Narrower       key.Binding
+	Taller, Shorter       key.Binding
+	Copy, Reset, Pair     key.Binding
+	Submit, Quit          key.Binding
+}
+
+var tuiKeys = tuiKeyMap{
+	Up:       key.NewBinding(key.WithKeys("up", "k")),
+	Down:     key.NewBinding(key.WithKeys("down", "j")),
+	Left:     key.NewBinding(key.WithKeys("left", "h")),
+	Right:    key.NewBinding(key.WithKeys("right", "l")),
+	Move:     key.NewBinding(key.WithKeys("up", "down", "left", "right", "h", "j", "k", "l"), key.WithHelp("arrows/hjkl", "move")),
+	Cell:     key.NewBinding(key.WithKeys("0", "1", "2", "3", "4", "5", "6", "7", "8", "9"), key.WithHelp("0-9", "set cell")),
+	Paint:    key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "paint")),
+	Pick:     key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "pick")),
+	Fill:     key.NewBinding(key.WithKeys("f"), key.WithHelp("f", "fill")),
+	Wider:    key.NewBinding(key.WithKeys("]"), key.WithHelp("[ ] { }", "resize")),
+	Narrower: key.NewBinding(key.WithKeys("[")),
+	Taller:   key.NewBinding(key.WithKeys("}")),
+	Shorter:  key.NewBinding(key.WithKeys("{")),
+	Copy:     key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "copy input")),
+	Reset:    key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "reset")),
+	Pair:     key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "next pair")),
+	Submit:   key.NewBinding(key.WithKeys("enter", "s"), key.WithHelp("enter", "submit")),
+	Quit:     key.NewBinding(key.WithKeys("q", "esc", "ctrl+c"), key.WithHelp("q", "quit")),
+}
+
+// ShortHelp is the help line under the editor.
+func (k tuiKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Move, k.Cell, k.Paint, k.Pick, k.Fill, k.Wider, k.Copy, k.Reset, k.Pair, k.Submit, k.Quit}
+}
+
+func (k tuiKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
+
+// tuiEditor is the state of the full-screen grid editor, run as a
+// bubbletea program.
+type tuiEditor struct {
+	p     puzzle
+	start [][]int
+	grid  [][]int
+	// row and col are the cursor; brush is the color space paints.
+	row, col int
+	brush    int
+	// pair is the training pair shown above the answer.
+	pair  int
+	msg   string
+	color bool
+	help  help.Model
+	// submitted is set when the editor ends with the answer submitted
+	// rather than quit.
+	submitted bool
+}
+
+// newTUIEditor loads answer into the editor; an empty or ragged one is
+// replaced by the manual editor's starting grid.
+func newTUIEditor(p puzzle, answer [][]int, color bool) *tuiEditor {
+	if len(answer) == 0 || !rectangular(answer) {
+		answer = initialAnswerGrid(p)
+	}
+	if len(answer) == 0 || gridWidth(answer) == 0 {
+		answer = filledGrid(1, 1, p.Hints.BackgroundColor)
+	}
+	return &tuiEditor{p: p, start: cloneGrid(answer), grid: cloneGrid(answer), color: color, help: help.New()}
+}
+
+// run shows the editor on out, in the terminal's alternate screen, and
+// handles keys from in until the answer is submitted or the editor is quit.
+// Signals are left to ctx, which ends the editor when cancelled.
+func (e *tuiEditor) run(ctx context.Context, in, out *os.File) ([][]int, error) {
+	prog := tea.NewProgram(e,
+		tea.WithContext(ctx),
+		tea.WithInput(in),
+		tea.WithOutput(out),
+		tea.WithAltScreen(),
+		tea.WithoutSignalHandler(),
+	)
+	if _, err := prog.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("tui: %w", err)
+	}
+	if !e.submitted {
+		return nil, errManualAborted
+	}
+	return e.grid, nil
+}
+
+func (e *tuiEditor) Init() tea.Cmd {
+	return nil
+}
+
+func (e *tuiEditor) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		e.help.Width = msg.Width
+	case tea.KeyMsg:
+		return e, e.handle(msg)
+	}
+	return e, nil
+}
+
+// handle applies one key to the editor, returning tea.Quit once the answer
+// is submitted or the editor is quit.
+func (e *tuiEditor) handle(msg tea.KeyMsg) tea.Cmd {
+	e.msg = ""
+	h, w := len(e.grid), gridWidth(e.grid)
+	k := tuiKeys
+	switch {
+	case key.Matches(msg, k.Up):
+		e.row = max(e.row-1, 0)
+	case key.Matches(msg, k.Down):
+		e.row = min(e.row+1, h-1)
+	case key.Matches(msg, k.Left):
+		e.col = max(e.col-1, 0)
+	case key.Matches(msg, k.Right):
+		e.col = min(e.col+1, w-1)
+	case key.Matches(msg, k.Cell):
+		e.brush = int(msg.String()[0] - '0')
+		e.grid[e.row][e.col] = e.brush
+	case key.Matches(msg, k.Paint):
+		e.grid[e.row][e.col] = e.brush
+		if e.col++; e.col == w {
+			e.col = 0
+			e.row = (e.row + 1) % h
+		}
+	case key.Matches(msg, k.Pick):
+		e.brush = e.grid[e.row][e.col]
+	case key.Matches(msg, k.Fill):
+		e.grid = filledGrid(h, w, e.brush)
+	case key.Matches(msg, k.Wider):
+		e.resize(h, w+1)
+	case key.Matches(msg, k.Narrower):
+		e.resize(h, w-1)
+	case key.Matches(msg, k.Taller):
+		e.resize(h+1, w)
+	case key.Matches(msg, k.Shorter):
+		e.resize(h-1, w)
+	case key.Matches(msg, k.Copy):
+		e.grid = cloneGrid(e.p.TestInput)
+		e.clampCursor()
+		e.msg = "copied the test input"
+	case key.Matches(msg, k.Reset):
+		e.grid = cloneGrid(e.start)
+		e.clampCursor()
+		e.msg = "reset to the proposed answer"
+	case key.Matches(msg, k.Pair):
+		if len(e.p.Train) > 0 {
+			e.pair = (e.pair + 1) % len(e.p.Train)
+		}
+	case key.Matches(msg, k.Submit):
+		if err := checkGridShape(e.grid); err != nil {
+			e.msg = err.Error()
+			return nil
+		}
+		e.submitted = true
+		return tea.Quit
+	case key.Matches(msg, k.Quit):
+		return tea.Quit
+	default:
+		e.msg = fmt.Sprintf("unknown key %q", msg.String())
+	}
+	return nil
+}
+
+// resize changes the grid to h×w, 1 to 30 each, keeping the overlapping
+// cells and padding with the background color.
+func (e *tuiEditor) resize(h, w int) {
+	if h < 1 || w < 1 || h > 30 || w > 30 {
+		e.msg = "grids are 1×1 to 30×30"
+		return
+	}
+	next := filledGrid(h, w, e.p.Hints.BackgroundColor)
+	for i := 0; i < h && i < len(e.grid); i++ {
+		copy(next[i], e.grid[i])
+	}
+	e.grid = next
+	e.clampCursor()
+}
+
+func (e *tuiEditor) clampCursor() {
+	e.row = min(e.row, len(e.grid)-1)
+	e.col = min(e.col, gridWidth(e.grid)-1)
+}
+
+// View renders the whole screen: the current training pair, the test input
+// beside the answer being edited, a status line and the key help.
+func (e *tuiEditor) View() string {
+	var lines []string
+	title := e.p.ID
+	if title == "" {
+		title = "(no id)"
+	}
+	if len(e.p.Train) > 0 {
+		ex := e.p.Train[e.pair]
+		lines = append(lines, fmt.Sprintf("Puzzle %s · train %d/%d: input %s → output %s", title, e.pair+1, len(e.p.Train), gridDims(ex.Input), gridDims(ex.Output)))
+		lines = append(lines, sideBySide(renderGridLines(ex.Input, e.color), renderGridLines(ex.Output, e.color), gridWidth(ex.Input)*2, "  →  ")...)
+	} else {
+		lines = append(lines, "Puzzle "+title)
+	}
+	lines = append(lines, "", fmt.Sprintf("Test input %s → answer %s", gridDims(e.p.TestInput), gridDims(e.grid)))
+	if hs := e.p.Hints.AnswerSize; hs.Height > 0 {
+		lines[len(lines)-1] += fmt.Sprintf(" (expected %d×%d)", hs.Height, hs.Width)
+	}
+	lines = append(lines, sideBySide(renderGridLines(e.p.TestInput, e.color), renderGridCursor(e.grid, e.color, e.row, e.col), gridWidth(e.p.TestInput)*2, "  →  ")...)
+	lines = append(lines, "", fmt.Sprintf("row %d col %d · brush %d", e.row+1, e.col+1, e.brush))
+	if e.msg != "" {
+		lines = append(lines, e.msg)
+	}
+	lines = append(lines, e.help.View(tuiKeys))
+	return strings.Join(lines, "\n")
+}
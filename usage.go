@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// tokenUsage is what AI requests consumed, in provider-neutral terms.
+// ReasoningTokens are part of CompletionTokens and CachedTokens part of
+// PromptTokens, as providers bill them.
+type tokenUsage struct {
+	Requests         int64 `json:"requests"`
+	PromptTokens     int64 `json:"promptTokens"`
+	CompletionTokens int64 `json:"completionTokens"`
+	ReasoningTokens  int64 `json:"reasoningTokens"`
+	CachedTokens     int64 `json:"cachedTokens"`
+}
+
+func (u *tokenUsage) add(o tokenUsage) {
+	u.Requests += o.Requests
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.ReasoningTokens += o.ReasoningTokens
+	u.CachedTokens += o.CachedTokens
+}
+
+func (u tokenUsage) minus(o tokenUsage) tokenUsage {
+	return tokenUsage{
+		Requests:         u.Requests - o.Requests,
+		PromptTokens:     u.PromptTokens - o.PromptTokens,
+		CompletionTokens: u.CompletionTokens - o.CompletionTokens,
+		ReasoningTokens:  u.ReasoningTokens - o.ReasoningTokens,
+		CachedTokens:     u.CachedTokens - o.CachedTokens,
+	}
+}
+
+// cost is the estimated price of u in USD. Cached prompt tokens are billed
+// at the cached input rate when one is set.
+func (p modelPrice) cost(u tokenUsage) float64 {
+	cachedRate := p.Input
+	if p.CachedInput != nil {
+		cachedRate = *p.CachedInput
+	}
+	uncached := u.PromptTokens - u.CachedTokens
+	return (float64(uncached)*p.Input + float64(u.CachedTokens)*cachedRate + float64(u.CompletionTokens)*p.Output) / 1e6
+}
+
+// spend is token usage together with its estimated cost. Only models with a
+// price in ai.prices contribute to Cost.
+type spend struct {
+	Usage tokenUsage
+	Cost  float64
+}
+
+func (s spend) minus(o spend) spend {
+	return spend{Usage: s.Usage.minus(o.Usage), Cost: s.Cost - o.Cost}
+}
+
+// cacheHitRate is the share of prompt tokens served from the provider's
+// prompt cache.
+func (u tokenUsage) cacheHitRate() string {
+	if u.PromptTokens == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(u.CachedTokens)/float64(u.PromptTokens))
+}
+
+// usageReporter receives the usage of every AI request. Each provider
+// backend reports once per request it sends, with Requests set to 1 and
+// whatever token counts the provider returned (none for backends that do
+// not report usage), so accounting and budgets never depend on the backend.
+type usageReporter interface {
+	reportUsage(ctx context.Context, model string, u tokenUsage)
+}
+
+// usageMeter is a usageReporter that totals usage and cost per model. It is
+// safe for concurrent use by ensemble requests. A nil *usageMeter (manual
+// mode) has nothing to report.
+type usageMeter struct {
+	prices map[string]modelPrice
+
+	mu      sync.Mutex
+	byModel map[string]spend
+	total   spend
+}
+
+func newUsageMeter(prices map[string]modelPrice) *usageMeter {
+	return &usageMeter{prices: prices, byModel: map[string]spend{}}
+}
+
+func (m *usageMeter) reportUsage(_ context.Context, model string, u tokenUsage) {
+	var cost float64
+	if p, ok := m.prices[model]; ok {
+		cost = p.cost(u)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.byModel[model]
+	t.Usage.add(u)
+	t.Cost += cost
+	m.byModel[model] = t
+	m.total.Usage.add(u)
+	m.total.Cost += cost
+}
+
+// spent returns the usage and cost so far; the difference of two calls is
+// what happened in between.
+func (m *usageMeter) spent() spend {
+	if m == nil {
+		return spend{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total
+}
+
+// logSummary logs one usage line per model and the run total, if any
+// request was made.
+func (m *usageMeter) logSummary(log *logger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	models := make([]string, 0, len(m.byModel))
+	for model := range m.byModel {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		t := m.byModel[model]
+		u := t.Usage
+		log.infof("AI usage: model=%s requests=%d prompt=%d (cached %d, hit rate %s) completion=%d (reasoning %d) cost=%s",
+			model, u.Requests, u.PromptTokens, u.CachedTokens, u.cacheHitRate(), u.CompletionTokens, u.ReasoningTokens, m.costText(model, t.Cost))
+	}
+	if len(models) > 1 {
+		u := m.total.Usage
+		log.infof("AI usage total: requests=%d prompt=%d completion=%d cost=$%.4f", u.Requests, u.PromptTokens, u.CompletionTokens, m.total.Cost)
+	}
+}
+
+// costText formats cost for model, or "n/a" when the model has no price.
+func (m *usageMeter) costText(model string, cost float64) string {
+	if _, ok := m.prices[model]; !ok {
+		return "n/a"
+	}
+	return fmt.Sprintf("$%.4f", cost)
+}
+
+// budget caps the AI spend of a run; a zero limit is unlimited. One budget
+// may be shared by the passes of a scheduled or daemon run so the cap
+// covers all of them.
+type budget struct {
+	maxCost   float64
+	maxTokens int64
+	spent     spend
+	// puzzles counts the charges, one per solved puzzle.
+	puzzles int
+}
+
+func (b *budget) charge(s spend) {
+	if b == nil {
+		return
+	}
+	b.spent.Usage.add(s.Usage)
+	b.spent.Cost += s.Cost
+	b.puzzles++
+}
+
+// left returns the share (0-1) of the tighter limit not yet spent, or 1
+// without limits.
+func (b *budget) left() float64 {
+	left := 1.0
+	if b == nil {
+		return left
+	}
+	if b.maxCost > 0 {
+		left = min(left, 1-b.spent.Cost/b.maxCost)
+	}
+	if b.maxTokens > 0 {
+		left = min(left, 1-float64(b.spent.Usage.PromptTokens+b.spent.Usage.CompletionTokens)/float64(b.maxTokens))
+	}
+	return max(left, 0)
+}
+
+// low reports whether economy mode should apply: at most below of the
+// budget is left, or the average spend per puzzle so far would exhaust it
+// before remaining more puzzles are done.
+func (b *budget) low(below float64, remaining int) bool {
+	left := b.left()
+	if left <= below {
+		return true
+	}
+	if b == nil || b.puzzles == 0 || remaining <= 0 {
+		return false
+	}
+	perPuzzle := (1 - left) / float64(b.puzzles)
+	return perPuzzle*float64(remaining) > left
+}
+
+// exceeded describes the limit the spend has reached, or returns "" while
+// within budget.
+func (b *budget) exceeded() string {
+	if b == nil {
+		return ""
+	}
+	if b.maxCost > 0 && b.spent.Cost >= b.maxCost {
+		return fmt.Sprintf("AI cost $%.4f reached the $%.2f budget", b.spent.Cost, b.maxCost)
+	}
+	if tokens := b.spent.Usage.PromptTokens + b.spent.Usage.CompletionTokens; b.maxTokens > 0 && tokens >= b.maxTokens {
+		return fmt.Sprintf("AI tokens %d reached the %d budget", tokens, b.maxTokens)
+	}
+	return ""
+}
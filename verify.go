@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runVerify screens an answer produced elsewhere before it is submitted by
+// hand: it runs the verification stage of a solve, the programmatic checks
+// and the AI verifier, without solving or submitting anything, and prints a
+// verdict. It fails when the answer is rejected.
+func runVerify(ctx context.Context, log *logger, args []string) error {
+	fs := flag.NewFlagSet(cmdVerify, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		configPath string
+		puzzlePath string
+		answerPath string
+		noAI       bool
+	)
+	fs.StringVar(&configPath, "config", "", "config path (AI settings and run history)")
+	fs.StringVar(&puzzlePath, "puzzle", "", "puzzle JSON file (required)")
+	fs.StringVar(&answerPath, "answer", "", "answer JSON file, or - for stdin (required)")
+	fs.BoolVar(&noAI, "no-ai", false, "run the programmatic checks only")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case puzzlePath == "":
+		return errors.New("--puzzle is required")
+	case answerPath == "":
+		return errors.New("--answer is required")
+	}
+
+	p, err := loadPuzzleFile(puzzlePath)
+	if err != nil {
+		return err
+	}
+	answer, _, err := loadAnswerFile(answerPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	id := p.ID
+	if id == "" {
+		id = "(no id)"
+	}
+	log.infof("verifying answer %s for puzzle %s", gridDims(answer), id)
+
+	// rejected collects the reasons the answer fails; warnings are the
+	// cross-check flags, which a correct answer can trip.
+	var rejected, warnings []string
+	if err := sanityCheck(p, answer); err != nil {
+		rejected = append(rejected, err.Error())
+	}
+	if err := validateAnswerSize(p, answer); err != nil {
+		rejected = append(rejected, "answer size: "+err.Error())
+	}
+	warnings = append(warnings, crossCheck(p, answer)...)
+	if p.ID != "" && configPath != "" {
+		hist, err := openHistoryFile(configPath)
+		if err == nil {
+			wrong, err := hist.wrongAnswers(ctx, p.ID)
+			hist.close()
+			if err != nil {
+				log.warnf("read earlier answers: %v", err)
+			} else if err := checkKnownWrong(uiOut, answer, wrong); err != nil {
+				rejected = append(rejected, err.Error())
+			}
+		}
+	}
+
+	switch {
+	case noAI:
+		fmt.Fprintf(uiOut, "%s⏭️  AI verification skipped (--no-ai)%s\n", colorDim, colorReset)
+	case len(rejected) > 0:
+		fmt.Fprintf(uiOut, "%s⏭️  AI verification skipped: the programmatic checks already reject the answer%s\n", colorDim, colorReset)
+	default:
+		solver, err := newAISolver(ctx, cfg, log)
+		if err != nil {
+			return err
+		}
+		if solver == nil {
+			return errors.New("AI solver not configured (use --no-ai for the programmatic checks only)")
+		}
+		meter := newUsageMeter(cfg.AI.Prices)
+		solver.usage = meter
+		defer meter.logSummary(log)
+		spin := newSpinner()
+		spin.Start("🔄 AI verifying...")
+		vr, err := solver.verifyAnswer(ctx, p, answer, solver.model)
+		spin.Stop()
+		if err != nil {
+			return fmt.Errorf("AI verification: %w", err)
+		}
+		if !vr.Valid {
+			rejected = append(rejected, "AI verifier: answer does not match pattern")
+		}
+	}
+
+	for _, w := range warnings {
+		fmt.Fprintf(uiOut, "%s⚠ cross-check: %s%s\n", colorYellow, w, colorReset)
+	}
+	if len(rejected) > 0 {
+		for _, r := range rejected {
+			fmt.Fprintf(uiOut, "%s❌ %s%s\n", colorYellow, r, colorReset)
+		}
+		return fmt.Errorf("verify: answer rejected (%s)", strings.Join(rejected, "; "))
+	}
+	verdict := "✅ Answer passed verification"
+	if len(warnings) > 0 {
+		verdict += fmt.Sprintf(" with %d cross-check warning(s)", len(warnings))
+	}
+	fmt.Fprintf(uiOut, "%s%s%s\n", colorGreen, verdict, colorReset)
+	return nil
+}
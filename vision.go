@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+)
+
+// AI prompt modes (ai.mode).
+const (
+	aiModeText   = "text"
+	aiModeVision = "vision"
+)
+
+// promptImage is a rendered grid sent as an image content part after its
+// caption.
+type promptImage struct {
+	caption string
+	dataURL string
+}
+
+// puzzleImages renders each training pair (input left, output right) and
+// the test input as PNG data URLs for vision mode.
+func puzzleImages(p puzzle) ([]promptImage, error) {
+	out := make([]promptImage, 0, len(p.Train)+1)
+	for i, ex := range p.Train {
+		url, err := pngDataURL(puzzle{TestInput: ex.Input}, ex.Output)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, promptImage{caption: fmt.Sprintf("Training example %d (input left, output right):", i+1), dataURL: url})
+	}
+	url, err := pngDataURL(puzzle{TestInput: p.TestInput}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, promptImage{caption: "Test input:", dataURL: url}), nil
+}
+
+// pngDataURL renders the test input of p beside answer, as
+// renderPuzzleImage draws its last row, and encodes it as a data URL.
+func pngDataURL(p puzzle, answer [][]int) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, renderPuzzleImage(p, answer)); err != nil {
+		return "", fmt.Errorf("render grid image: %w", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// voteWeighted is the ai.vote strategy weighing each model's vote by its
+// historical accuracy.
+const voteWeighted = "weighted"
+
+// minClassAttempts is how many judged attempts on puzzles of the same size
+// class a model needs before its accuracy there is used instead of its
+// accuracy overall.
+const minClassAttempts = 5
+
+// puzzleClass buckets p by the size of its answer (the hinted size, or the
+// test input's when there is no hint), a cheap stand-in for complexity.
+func puzzleClass(p puzzle) string {
+	cells := p.Hints.AnswerSize.Height * p.Hints.AnswerSize.Width
+	if cells <= 0 {
+		cells = len(p.TestInput) * gridWidth(p.TestInput)
+	}
+	switch {
+	case cells <= 100:
+		return "small"
+	case cells <= 400:
+		return "medium"
+	default:
+		return "large"
+	}
+}
+
+// accuracyRecord is a model's judged attempts and how many were correct.
+type accuracyRecord struct {
+	Judged, Correct int
+}
+
+// rate is the accuracy with add-one smoothing, so a model with no record
+// weighs 0.5 and a short lucky streak does not count as certainty.
+func (r accuracyRecord) rate() float64 {
+	return float64(r.Correct+1) / float64(r.Judged+2)
+}
+
+// accuracyTally accumulates judged attempts per model, overall and on
+// puzzles of one size class.
+type accuracyTally struct {
+	class            string
+	inClass, overall map[string]accuracyRecord
+}
+
+func newAccuracyTally(class string) *accuracyTally {
+	return &accuracyTally{class: class, inClass: map[string]accuracyRecord{}, overall: map[string]accuracyRecord{}}
+}
+
+// add counts one judged answer of model on a puzzle of class pclass, ""
+// when the puzzle could not be read.
+func (t *accuracyTally) add(model string, correct bool, pclass string) {
+	t.overall[model] = t.overall[model].plus(correct)
+	if pclass == t.class {
+		t.inClass[model] = t.inClass[model].plus(correct)
+	}
+}
+
+func (r accuracyRecord) plus(correct bool) accuracyRecord {
+	r.Judged++
+	if correct {
+		r.Correct++
+	}
+	return r
+}
+
+// voteWeights returns each ensemble model's vote weight for p: its accuracy
+// on puzzles of the same size class, or overall while it has fewer than
+// minClassAttempts judged there. Without history every model weighs 0.5.
+func (s *Solver) voteWeights(ctx context.Context, p puzzle, models []string) map[string]float64 {
+	class := puzzleClass(p)
+	inClass, overall, err := s.history.modelAccuracy(ctx, class)
+	if err != nil {
+		s.log.forContext(ctx).warnf("weighted vote: reading history: %v; weighing models equally", err)
+	}
+	weights := map[string]float64{}
+	for _, m := range models {
+		r, scope := inClass[m], class
+		if r.Judged < minClassAttempts {
+			r, scope = overall[m], "all"
+		}
+		weights[m] = r.rate()
+		fmt.Fprintf(uiOut, "%s⚖  %s: weight %.2f (%d/%d correct, %s puzzles)%s\n", colorDim, m, weights[m], r.Correct, r.Judged, scope, colorReset)
+	}
+	return weights
+}
+
+// voteWeightedMatch picks the grid with the most summed weight of the
+// models returning it. Ties are broken by summed confidence, then by model
+// order, and the answer carries the reasoning of its most confident
+// supporter. It also reports how many models back the winner.
+func voteWeightedMatch(answers []modelAnswer, weights map[string]float64) (Answer, int) {
+	type bucket struct {
+		first  int
+		count  int
+		weight float64
+		conf   int
+		best   Answer
+	}
+	buckets := map[string]*bucket{}
+	var list []*bucket
+	for i, a := range answers {
+		k := gridKey(a.Answer.Answer)
+		b, ok := buckets[k]
+		if !ok {
+			b = &bucket{first: i, best: a.Answer}
+			buckets[k] = b
+			list = append(list, b)
+		}
+		b.count++
+		b.weight += weights[a.Model]
+		b.conf += a.Answer.Confidence
+		if a.Answer.Confidence > b.best.Confidence {
+			b.best = a.Answer
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].weight != list[j].weight {
+			return list[i].weight > list[j].weight
+		}
+		return list[i].conf > list[j].conf
+	})
+	return list[0].best, list[0].count
+}